@@ -18,11 +18,13 @@
 package main
 
 import (
+	"fmt"
 	_ "net/http/pprof"
 	"os"
 	"runtime"
 	"time"
 
+	"mosn.io/mosn/pkg/admin/ctl"
 	admin "mosn.io/mosn/pkg/admin/server"
 
 	"github.com/urfave/cli"
@@ -36,6 +38,7 @@ import (
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/mosn"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/protocol/xprotocol"
 	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
@@ -43,6 +46,7 @@ import (
 	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
 	"mosn.io/mosn/pkg/protocol/xprotocol/dubbothrift"
 	"mosn.io/mosn/pkg/protocol/xprotocol/tars"
+	"mosn.io/mosn/pkg/protocol/xprotocol/thrift"
 	"mosn.io/mosn/pkg/server"
 	"mosn.io/mosn/pkg/stagemanager"
 	xstream "mosn.io/mosn/pkg/stream/xprotocol"
@@ -207,6 +211,9 @@ var (
 			stm.AppendStartStage(mosn.DefaultStartStage)
 			// after-stop
 			stm.AppendAfterStopStage(holmes.Stop)
+			stm.AppendAfterStopStage(func(_ stagemanager.Application) {
+				overload.Stop()
+			})
 			// execute all stages
 			stm.RunAll()
 			return nil
@@ -233,6 +240,36 @@ var (
 		},
 	}
 
+	cmdValidate = cli.Command{
+		Name:  "validate",
+		Usage: "validate the configuration without starting mosn",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:   "config, c",
+				Usage:  "load configuration from `FILE`",
+				EnvVar: "MOSN_CONFIG",
+				Value:  "configs/mosn_config.json",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			// only the validation result is printed
+			log.GetErrorLoggerManagerInstance().Disable()
+			ExtensionsRegister(c)
+			path := c.String("config")
+			errs := mosn.ValidateConfig(path)
+			for _, err := range errs {
+				fmt.Println(err.Error())
+			}
+			if len(errs) > 0 {
+				return cli.NewExitError(fmt.Sprintf("config %s is invalid, %d errors found", path, len(errs)), 1)
+			}
+			fmt.Printf("config %s is valid\n", path)
+			return nil
+		},
+	}
+
+	cmdCtl = ctl.Command
+
 	cmdReload = cli.Command{
 		Name:  "reload",
 		Usage: "reconfiguration",
@@ -276,6 +313,7 @@ func ExtensionsRegister(c *cli.Context) {
 	_ = xprotocol.RegisterXProtocolCodec(&dubbo.XCodec{})
 	_ = xprotocol.RegisterXProtocolCodec(&dubbothrift.XCodec{})
 	_ = xprotocol.RegisterXProtocolCodec(&tars.XCodec{})
+	_ = xprotocol.RegisterXProtocolCodec(&thrift.XCodec{})
 	// trace register
 	xtrace.RegisterDelegate(bolt.ProtocolName, tracebolt.Boltv1Delegate)
 	xtrace.RegisterDelegate(boltv2.ProtocolName, tracebolt.Boltv2Delegate)
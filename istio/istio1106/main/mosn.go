@@ -33,9 +33,13 @@ import (
 	_ "mosn.io/mosn/istio/istio1106/sds"
 	_ "mosn.io/mosn/istio/istio1106/xds"
 	_ "mosn.io/mosn/pkg/admin/debug"
+	_ "mosn.io/mosn/pkg/configmanager/filesource"
 	_ "mosn.io/mosn/pkg/filter/listener/originaldst"
+	_ "mosn.io/mosn/pkg/filter/network/connectionlimit"
 	_ "mosn.io/mosn/pkg/filter/network/connectionmanager"
 	_ "mosn.io/mosn/pkg/filter/network/grpc"
+	_ "mosn.io/mosn/pkg/filter/network/kafka"
+	_ "mosn.io/mosn/pkg/filter/network/mqtt"
 	_ "mosn.io/mosn/pkg/filter/network/proxy"
 	_ "mosn.io/mosn/pkg/filter/network/streamproxy"
 	_ "mosn.io/mosn/pkg/filter/stream/dsl"
@@ -44,6 +48,7 @@ import (
 	_ "mosn.io/mosn/pkg/filter/stream/faulttolerance"
 	_ "mosn.io/mosn/pkg/filter/stream/flowcontrol"
 	_ "mosn.io/mosn/pkg/filter/stream/grpcmetric"
+	_ "mosn.io/mosn/pkg/filter/stream/grpcweb"
 	_ "mosn.io/mosn/pkg/filter/stream/gzip"
 	_ "mosn.io/mosn/pkg/filter/stream/headertometadata"
 	_ "mosn.io/mosn/pkg/filter/stream/ipaccess"
@@ -52,9 +57,13 @@ import (
 	_ "mosn.io/mosn/pkg/filter/stream/proxywasm"
 	_ "mosn.io/mosn/pkg/filter/stream/seata"
 	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2bolt"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/http2dubbo"
 	_ "mosn.io/mosn/pkg/filter/stream/transcoder/httpconv"
+	_ "mosn.io/mosn/pkg/filter/stream/transcoder/jsongrpc"
 	_ "mosn.io/mosn/pkg/metrics/sink"
+	_ "mosn.io/mosn/pkg/metrics/sink/otlp"
 	_ "mosn.io/mosn/pkg/metrics/sink/prometheus"
+	_ "mosn.io/mosn/pkg/metrics/sink/statsd"
 	_ "mosn.io/mosn/pkg/network"
 	_ "mosn.io/mosn/pkg/protocol"
 	_ "mosn.io/mosn/pkg/protocol/xprotocol"
@@ -62,6 +71,7 @@ import (
 	_ "mosn.io/mosn/pkg/server/keeper"
 	_ "mosn.io/mosn/pkg/stream/http"
 	_ "mosn.io/mosn/pkg/stream/http2"
+	_ "mosn.io/mosn/pkg/stream/triple"
 	_ "mosn.io/mosn/pkg/stream/xprotocol"
 	_ "mosn.io/mosn/pkg/trace/jaeger"
 	_ "mosn.io/mosn/pkg/trace/skywalking"
@@ -101,6 +111,8 @@ func newMosnApp(startCmd *cli.Command) *cli.App {
 	app.Commands = []cli.Command{
 		cmdStart,
 		cmdStop,
+		cmdValidate,
+		cmdCtl,
 		cmdReload,
 	}
 
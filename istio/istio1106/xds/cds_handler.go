@@ -21,6 +21,7 @@ import (
 	envoy_config_cluster_v3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/golang/protobuf/ptypes"
+	"mosn.io/mosn/pkg/configmanager"
 	"mosn.io/mosn/pkg/log"
 )
 
@@ -31,6 +32,11 @@ func (ads *AdsStreamClient) handleCds(resp *envoy_service_discovery_v3.Discovery
 	}
 	// TODO: handle error, support error detail for ack
 	ads.config.converter.ConvertUpdateClusters(clusters)
+	names := make([]string, 0, len(clusters))
+	for _, cluster := range clusters {
+		names = append(names, cluster.Name)
+	}
+	recordVersion(resp, configmanager.CfgTypeCluster, names)
 	// save response info
 	ads.config.previousInfo.Store(resp.TypeUrl, &responseInfo{
 		ResponseNonce: resp.Nonce,
@@ -11,6 +11,7 @@ import (
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"google.golang.org/grpc"
+	"mosn.io/mosn/pkg/configmanager"
 	"mosn.io/mosn/pkg/featuregate"
 	"mosn.io/mosn/pkg/istio"
 	"mosn.io/mosn/pkg/log"
@@ -164,6 +165,23 @@ func (ads *AdsStreamClient) HandleResponse(resp interface{}) {
 	}
 }
 
+// responseVersion returns the version of the resources updated by the response
+func responseVersion(resp *envoy_service_discovery_v3.DiscoveryResponse) configmanager.ResourceVersion {
+	return configmanager.ResourceVersion{
+		Source:      configmanager.SourceXds,
+		VersionInfo: resp.VersionInfo,
+		Nonce:       resp.Nonce,
+	}
+}
+
+// recordVersion records the response version of the resources updated by the response
+func recordVersion(resp *envoy_service_discovery_v3.DiscoveryResponse, typ string, names []string) {
+	version := responseVersion(resp)
+	for _, name := range names {
+		configmanager.SetResourceVersion(typ, name, version)
+	}
+}
+
 func (ads *AdsStreamClient) AckResponse(resp *envoy_service_discovery_v3.DiscoveryResponse) {
 
 	info := ads.config.previousInfo.Find(resp.TypeUrl)
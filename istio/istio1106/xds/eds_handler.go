@@ -21,6 +21,7 @@ import (
 	envoy_config_endpoint_v3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/golang/protobuf/ptypes"
+	"mosn.io/mosn/pkg/configmanager"
 	"mosn.io/mosn/pkg/log"
 )
 
@@ -30,6 +31,12 @@ func (ads *AdsStreamClient) handleEds(resp *envoy_service_discovery_v3.Discovery
 		log.DefaultLogger.Infof("get %d endpoints from EDS", len(endpoints))
 	}
 	ads.config.converter.ConvertUpdateEndpoints(endpoints)
+	// the endpoints are recorded as the hosts of the clusters
+	names := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		names = append(names, endpoint.ClusterName)
+	}
+	recordVersion(resp, configmanager.CfgTypeCluster, names)
 	info := &responseInfo{
 		VersionInfo:   resp.VersionInfo,
 		ResponseNonce: resp.Nonce,
@@ -21,6 +21,7 @@ import (
 	envoy_config_listener_v3 "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/golang/protobuf/ptypes"
+	"mosn.io/mosn/pkg/configmanager"
 	"mosn.io/mosn/pkg/log"
 )
 
@@ -30,6 +31,11 @@ func (ads *AdsStreamClient) handleLds(resp *envoy_service_discovery_v3.Discovery
 		log.DefaultLogger.Infof("get %d listeners from LDS", len(listeners))
 	}
 	ads.config.converter.ConvertAddOrUpdateListeners(listeners)
+	names := make([]string, 0, len(listeners))
+	for _, listener := range listeners {
+		names = append(names, listener.GetName())
+	}
+	recordVersion(resp, configmanager.CfgTypeListener, names)
 	info := &responseInfo{
 		ResponseNonce: resp.Nonce,
 		VersionInfo:   resp.VersionInfo,
@@ -21,6 +21,7 @@ import (
 	envoy_config_route_v3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	envoy_service_discovery_v3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/golang/protobuf/ptypes"
+	"mosn.io/mosn/pkg/configmanager"
 	"mosn.io/mosn/pkg/log"
 )
 
@@ -34,6 +35,7 @@ func (ads *AdsStreamClient) handleRds(resp *envoy_service_discovery_v3.Discovery
 	for _, rt := range routes {
 		resourceNames = append(resourceNames, rt.Name)
 	}
+	recordVersion(resp, configmanager.CfgTypeRouter, resourceNames)
 	info := &responseInfo{
 		ResponseNonce: resp.Nonce,
 		VersionInfo:   resp.VersionInfo,
@@ -0,0 +1,440 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	admin "mosn.io/mosn/pkg/admin/server"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/runtime"
+	"mosn.io/mosn/pkg/server"
+)
+
+// Client calls the admin api of a running mosn
+type Client struct {
+	// Address is the admin api address, such as 127.0.0.1:34901
+	Address string
+	// Token is the bearer token of the admin write apis
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewClient creates a client for the admin api
+func NewClient(address, token string, timeout time.Duration) *Client {
+	return &Client{
+		Address: address,
+		Token:   token,
+		HTTPClient: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (c *Client) url(path string, query url.Values) string {
+	address := c.Address
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+	u := strings.TrimRight(address, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// request sends the request and returns the response body,
+// the body is marshaled as json if it is not nil
+func (c *Client) request(method, path string, query url.Values, body interface{}) ([]byte, error) {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, c.url(path, query), reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s failed: %s: %s", method, path, resp.Status, errorMessage(data))
+	}
+	return data, nil
+}
+
+// do sends the request like request, and the response is unmarshaled into v if v is not nil
+func (c *Client) do(method, path string, query url.Values, body interface{}, v interface{}) error {
+	data, err := c.request(method, path, query, body)
+	if err != nil || v == nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s %s returns invalid response: %v", method, path, err)
+	}
+	return nil
+}
+
+// errorMessage returns the message in the admin api error response
+func errorMessage(data []byte) string {
+	msg := struct {
+		Error string `json:"error"`
+	}{}
+	if json.Unmarshal(data, &msg) == nil && msg.Error != "" {
+		return msg.Error
+	}
+	if s := strings.TrimSpace(string(data)); s != "" {
+		return s
+	}
+	return "empty response"
+}
+
+type resourceDump struct {
+	Config  json.RawMessage               `json:"config"`
+	Version configmanager.ResourceVersion `json:"version"`
+}
+
+type resourcesDump struct {
+	Listeners map[string]resourceDump `json:"listeners"`
+	Routers   map[string]resourceDump `json:"routers"`
+}
+
+func (c *Client) dumpResources(typ string, names []string) (*resourcesDump, error) {
+	query := url.Values{"type": {typ}}
+	if len(names) > 0 {
+		query["name"] = names
+	}
+	dump := &resourcesDump{}
+	if err := c.do(http.MethodGet, "/api/v1/config_dump", query, nil, dump); err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+// Listener is the summary of a listener
+type Listener struct {
+	Name    string                        `json:"name"`
+	Address string                        `json:"address"`
+	Network string                        `json:"network,omitempty"`
+	Version configmanager.ResourceVersion `json:"version"`
+}
+
+// Listeners returns the listeners sorted by name
+func (c *Client) Listeners(names ...string) ([]Listener, error) {
+	dump, err := c.dumpResources("listener", names)
+	if err != nil {
+		return nil, err
+	}
+	listeners := make([]Listener, 0, len(dump.Listeners))
+	for name, res := range dump.Listeners {
+		cfg := struct {
+			Address string `json:"address"`
+			Network string `json:"network"`
+		}{}
+		if err := json.Unmarshal(res.Config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid listener %s: %v", name, err)
+		}
+		listeners = append(listeners, Listener{
+			Name:    name,
+			Address: cfg.Address,
+			Network: cfg.Network,
+			Version: res.Version,
+		})
+	}
+	sort.Slice(listeners, func(i, j int) bool {
+		return listeners[i].Name < listeners[j].Name
+	})
+	return listeners, nil
+}
+
+// Route is the summary of a route in a virtual host
+type Route struct {
+	Router      string                        `json:"router"`
+	VirtualHost string                        `json:"virtual_host"`
+	Domains     []string                      `json:"domains"`
+	Name        string                        `json:"name,omitempty"`
+	Match       string                        `json:"match"`
+	Action      string                        `json:"action"`
+	Version     configmanager.ResourceVersion `json:"version"`
+}
+
+type routeConfig struct {
+	Name  string `json:"name"`
+	Match struct {
+		Prefix  string            `json:"prefix"`
+		Path    string            `json:"path"`
+		Regex   string            `json:"regex"`
+		Headers []json.RawMessage `json:"headers"`
+	} `json:"match"`
+	Route struct {
+		ClusterName      string `json:"cluster_name"`
+		ClusterHeader    string `json:"cluster_header"`
+		ClusterVariable  string `json:"cluster_variable"`
+		WeightedClusters []struct {
+			Cluster struct {
+				Name   string `json:"name"`
+				Weight uint32 `json:"weight"`
+			} `json:"cluster"`
+		} `json:"weighted_clusters"`
+	} `json:"route"`
+	Redirect       json.RawMessage `json:"redirect"`
+	DirectResponse json.RawMessage `json:"direct_response"`
+}
+
+func (r *routeConfig) match() string {
+	var match string
+	switch {
+	case r.Match.Path != "":
+		match = "path:" + r.Match.Path
+	case r.Match.Prefix != "":
+		match = "prefix:" + r.Match.Prefix
+	case r.Match.Regex != "":
+		match = "regex:" + r.Match.Regex
+	default:
+		match = "-"
+	}
+	if n := len(r.Match.Headers); n > 0 {
+		match += fmt.Sprintf(",headers:%d", n)
+	}
+	return match
+}
+
+func (r *routeConfig) action() string {
+	switch {
+	case len(r.Redirect) > 0 && string(r.Redirect) != "null":
+		return "redirect"
+	case len(r.DirectResponse) > 0 && string(r.DirectResponse) != "null":
+		return "direct_response"
+	case len(r.Route.WeightedClusters) > 0:
+		clusters := make([]string, 0, len(r.Route.WeightedClusters))
+		for _, wc := range r.Route.WeightedClusters {
+			clusters = append(clusters, fmt.Sprintf("%s:%d", wc.Cluster.Name, wc.Cluster.Weight))
+		}
+		return "cluster:" + strings.Join(clusters, ",")
+	case r.Route.ClusterHeader != "":
+		return "cluster_header:" + r.Route.ClusterHeader
+	case r.Route.ClusterVariable != "":
+		return "cluster_variable:" + r.Route.ClusterVariable
+	default:
+		return "cluster:" + r.Route.ClusterName
+	}
+}
+
+// Routes returns the routes of the routers sorted by the router name,
+// the routes in a router keep their orders.
+func (c *Client) Routes(routers ...string) ([]Route, error) {
+	dump, err := c.dumpResources("router", routers)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(dump.Routers))
+	for name := range dump.Routers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	routes := []Route{}
+	for _, name := range names {
+		res := dump.Routers[name]
+		cfg := struct {
+			VirtualHosts []struct {
+				Name    string        `json:"name"`
+				Domains []string      `json:"domains"`
+				Routers []routeConfig `json:"routers"`
+			} `json:"virtual_hosts"`
+		}{}
+		if err := json.Unmarshal(res.Config, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid router %s: %v", name, err)
+		}
+		for _, vh := range cfg.VirtualHosts {
+			for i := range vh.Routers {
+				r := &vh.Routers[i]
+				routes = append(routes, Route{
+					Router:      name,
+					VirtualHost: vh.Name,
+					Domains:     vh.Domains,
+					Name:        r.Name,
+					Match:       r.match(),
+					Action:      r.action(),
+					Version:     res.Version,
+				})
+			}
+		}
+	}
+	return routes, nil
+}
+
+// Clusters returns the clusters with their hosts status
+func (c *Client) Clusters(names ...string) ([]admin.ClusterStatus, error) {
+	var query url.Values
+	if len(names) > 0 {
+		query = url.Values{"name": names}
+	}
+	var clusters []admin.ClusterStatus
+	if err := c.do(http.MethodGet, "/api/v1/cluster_status", query, nil, &clusters); err != nil {
+		return nil, err
+	}
+	return clusters, nil
+}
+
+// Features returns the known features and whether they are enabled
+func (c *Client) Features() (map[string]bool, error) {
+	features := map[string]bool{}
+	if err := c.do(http.MethodGet, "/api/v1/features", nil, nil, &features); err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+// LogLevels returns the error loggers and their levels
+func (c *Client) LogLevels() (map[string]string, error) {
+	levels := map[string]string{}
+	if err := c.do(http.MethodGet, "/api/v1/get_loglevel", nil, nil, &levels); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// SetLogLevel updates the level of the error logger
+func (c *Client) SetLogLevel(path, level string) error {
+	return c.do(http.MethodPost, "/api/v1/update_loglevel", nil, &admin.LogLevelData{
+		LogPath:  path,
+		LogLevel: strings.ToUpper(level),
+	}, nil)
+}
+
+// SetHostState ejects, drains or restores a host in the cluster
+func (c *Client) SetHostState(cluster, address, action string) error {
+	if c.Token == "" {
+		return errors.New("the admin write token is required")
+	}
+	return c.do(http.MethodPost, "/api/v1/cluster/host_state", nil, &admin.HostStateConfig{
+		ClusterName: cluster,
+		Address:     address,
+		Action:      action,
+	}, nil)
+}
+
+// Drain starts to drain the downstream connections
+func (c *Client) Drain(cfg *admin.DrainConfig) error {
+	if c.Token == "" {
+		return errors.New("the admin write token is required")
+	}
+	return c.do(http.MethodPost, "/api/v1/drain", nil, cfg, nil)
+}
+
+// DrainStatus returns the progress of the last drain
+func (c *Client) DrainStatus() (*server.DrainStatus, error) {
+	status := &server.DrainStatus{}
+	if err := c.do(http.MethodGet, "/api/v1/drain_status", nil, nil, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Runtime returns the effective runtime values, the keys can be filtered by the prefix
+func (c *Client) Runtime(prefix string) ([]runtime.Entry, error) {
+	var query url.Values
+	if prefix != "" {
+		query = url.Values{"prefix": []string{prefix}}
+	}
+	var entries []runtime.Entry
+	if err := c.do(http.MethodGet, "/api/v1/runtime", query, nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SetRuntime sets the values in the admin layer of the runtime
+func (c *Client) SetRuntime(values map[string]string) error {
+	if c.Token == "" {
+		return errors.New("the admin write token is required")
+	}
+	return c.do(http.MethodPost, "/api/v1/runtime/update", nil, values, nil)
+}
+
+// UnsetRuntime removes the keys from the admin layer of the runtime
+func (c *Client) UnsetRuntime(keys ...string) error {
+	if c.Token == "" {
+		return errors.New("the admin write token is required")
+	}
+	return c.do(http.MethodDelete, "/api/v1/runtime/update", url.Values{"key": keys}, nil, nil)
+}
+
+// RouteMatch evaluates a synthetic request against the routers without sending traffic
+func (c *Client) RouteMatch(req *admin.RouteMatchRequest) (*admin.RouteMatchResult, error) {
+	result := &admin.RouteMatchResult{}
+	if err := c.do(http.MethodPost, "/api/v1/route_match", nil, req, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Stat is a metrics value, its name is joined by the metrics type, labels and key
+type Stat struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+	// Delta is the changes since the last sample, it is nil in the first sample
+	Delta *int64 `json:"delta,omitempty"`
+}
+
+// Stats returns the integer metrics sorted by name, the key selects a metrics by its
+// full name, such as downstream.proxy.global
+func (c *Client) Stats(key string) ([]Stat, error) {
+	var query url.Values
+	if key != "" {
+		query = url.Values{"key": {key}}
+	}
+	data, err := c.request(http.MethodGet, "/api/v1/stats", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the stats api returns a message rather than json if the key is not found
+	if key != "" && bytes.HasPrefix(data, []byte("no metrics key")) {
+		return []Stat{}, nil
+	}
+	// type -> namespace -> key -> value
+	all := map[string]map[string]map[string]string{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("GET /api/v1/stats returns invalid response: %v", err)
+	}
+	return flattenStats(all), nil
+}
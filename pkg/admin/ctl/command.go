@@ -0,0 +1,678 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ctl implements the mosn ctl command, which operates a running mosn by the admin api.
+// The table output is aligned by spaces and the empty cells are written as "-", so it can be
+// parsed by fields, and the json output is recommended for the scripts.
+package ctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+	"mosn.io/api"
+	admin "mosn.io/mosn/pkg/admin/server"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/server"
+)
+
+// Command is the mosn ctl command
+var Command = cli.Command{
+	Name:  "ctl",
+	Usage: "operate a running mosn by the admin api",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:   "admin, a",
+			Usage:  "admin api `ADDRESS`",
+			EnvVar: "MOSN_ADMIN_ADDRESS",
+			Value:  "127.0.0.1:34901",
+		}, cli.StringFlag{
+			Name:   "token, t",
+			Usage:  "bearer token of the admin write apis",
+			EnvVar: "MOSN_ADMIN_TOKEN",
+		}, cli.StringFlag{
+			Name:  "output, o",
+			Usage: "output format, table|json",
+			Value: FormatTable,
+		}, cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "timeout of the admin api requests",
+			Value: 5 * time.Second,
+		},
+	},
+	Subcommands: []cli.Command{
+		{
+			Name:      "listeners",
+			Usage:     "list the listeners",
+			ArgsUsage: "[NAME...]",
+			Action:    listListeners,
+		},
+		{
+			Name:      "clusters",
+			Usage:     "list the clusters with their hosts health and stats",
+			ArgsUsage: "[NAME...]",
+			Action:    listClusters,
+		},
+		{
+			Name:      "routes",
+			Usage:     "list the routes of the routers",
+			ArgsUsage: "[ROUTER...]",
+			Action:    listRoutes,
+		},
+		{
+			Name:   "features",
+			Usage:  "list the feature gates",
+			Action: listFeatures,
+		},
+		{
+			Name:  "log",
+			Usage: "list or change the log levels",
+			Subcommands: []cli.Command{
+				{
+					Name:   "levels",
+					Usage:  "list the loggers and their levels",
+					Action: listLogLevels,
+				},
+				{
+					Name:      "set",
+					Usage:     "change the level of a logger, the level is trace|debug|info|warn|error|fatal",
+					ArgsUsage: "LOG_PATH LEVEL",
+					Action:    setLogLevel,
+				},
+			},
+		},
+		{
+			Name:  "host",
+			Usage: "eject, drain or restore an upstream host, requires the token",
+			Subcommands: []cli.Command{
+				{
+					Name:      admin.HostActionEject,
+					Usage:     "stop choosing the host and close its connections",
+					ArgsUsage: "CLUSTER ADDRESS",
+					Action:    hostAction(admin.HostActionEject),
+				},
+				{
+					Name:      admin.HostActionDrain,
+					Usage:     "stop choosing the host but keep its connections",
+					ArgsUsage: "CLUSTER ADDRESS",
+					Action:    hostAction(admin.HostActionDrain),
+				},
+				{
+					Name:      admin.HostActionRestore,
+					Usage:     "restore the ejected or draining host",
+					ArgsUsage: "CLUSTER ADDRESS",
+					Action:    hostAction(admin.HostActionRestore),
+				},
+			},
+		},
+		{
+			Name:  "drain",
+			Usage: "drain the downstream connections before the mosn is stopped",
+			Subcommands: []cli.Command{
+				{
+					Name:      "start",
+					Usage:     "stop accepting new connections and drain the existing connections, requires the token",
+					ArgsUsage: "[LISTENER...]",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "mode",
+							Usage: "stop_listening keeps the existing connections, goaway asks them to go away",
+							Value: string(server.DrainGoAway),
+						}, cli.StringFlag{
+							Name:  "strategy",
+							Usage: "immediate notifies all the connections at once, gradual spreads them over the window",
+							Value: string(server.DrainImmediate),
+						}, cli.DurationFlag{
+							Name:  "window",
+							Usage: "duration of the gradual drain",
+						}, cli.DurationFlag{
+							Name:  "wait",
+							Usage: "max duration to wait for the active requests, the mosn drain time is used if it is zero",
+						},
+					},
+					Action: startDrain,
+				},
+				{
+					Name:   "status",
+					Usage:  "show the progress of the last drain",
+					Action: drainStatus,
+				},
+			},
+		},
+		{
+			Name:  "runtime",
+			Usage: "list or change the runtime overrides",
+			Subcommands: []cli.Command{
+				{
+					Name:      "list",
+					Usage:     "list the effective values and their layers",
+					ArgsUsage: "[PREFIX]",
+					Action:    listRuntime,
+				},
+				{
+					Name:      "set",
+					Usage:     "set the values in the admin layer, requires the token",
+					ArgsUsage: "KEY=VALUE...",
+					Action:    setRuntime,
+				},
+				{
+					Name:      "unset",
+					Usage:     "remove the keys from the admin layer, the lower layers take effect again, requires the token",
+					ArgsUsage: "KEY...",
+					Action:    unsetRuntime,
+				},
+			},
+		},
+		{
+			Name:      "route-match",
+			Usage:     "explain which route a synthetic request matches and the host it would be sent to",
+			ArgsUsage: "PATH",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "listener, l",
+					Usage: "find the router by the proxy filter of the listener",
+				}, cli.StringFlag{
+					Name:  "router, r",
+					Usage: "router config name, it takes precedence over the listener",
+				}, cli.StringFlag{
+					Name:  "protocol",
+					Usage: "downstream protocol of the request",
+				}, cli.StringFlag{
+					Name:  "host",
+					Usage: "host of the request",
+				}, cli.StringFlag{
+					Name:  "method, X",
+					Usage: "method of the request",
+				}, cli.StringFlag{
+					Name:  "query",
+					Usage: "query string of the request",
+				}, cli.StringSliceFlag{
+					Name:  "header, H",
+					Usage: "header of the request, in KEY=VALUE",
+				}, cli.StringSliceFlag{
+					Name:  "var",
+					Usage: "variable of the request, in KEY=VALUE",
+				},
+			},
+			Action: routeMatch,
+		},
+		{
+			Name:  "stats",
+			Usage: "show the stats, and the deltas since the last sample when tailing",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "key, k",
+					Usage: "select a metrics by its full name, such as downstream.proxy.global",
+				}, cli.StringSliceFlag{
+					Name:  "filter, f",
+					Usage: "only show the stats whose names contain the filter",
+				}, cli.DurationFlag{
+					Name:  "interval, i",
+					Usage: "interval between the samples",
+					Value: time.Second,
+				}, cli.IntFlag{
+					Name:  "count, n",
+					Usage: "number of the samples, 0 means tailing until interrupted",
+					Value: 1,
+				}, cli.BoolFlag{
+					Name:  "changed",
+					Usage: "only show the stats changed since the last sample",
+				},
+			},
+			Action: tailStats,
+		},
+	},
+}
+
+func newClient(c *cli.Context) *Client {
+	return NewClient(c.GlobalString("admin"), c.GlobalString("token"), c.GlobalDuration("timeout"))
+}
+
+func outputFormat(c *cli.Context) (string, error) {
+	format := c.GlobalString("output")
+	switch format {
+	case FormatTable, FormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid output format: %s", format)
+	}
+}
+
+// exitError makes the command exits with non-zero code
+func exitError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return cli.NewExitError(err.Error(), 1)
+}
+
+func versionCells(v configmanager.ResourceVersion) []string {
+	updated := ""
+	if !v.LastUpdated.IsZero() {
+		updated = v.LastUpdated.Format(time.RFC3339)
+	}
+	return []string{v.Source, v.VersionInfo, updated}
+}
+
+func listListeners(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return exitError(err)
+	}
+	listeners, err := newClient(c).Listeners(c.Args()...)
+	if err != nil {
+		return exitError(err)
+	}
+	if format == FormatJSON {
+		return exitError(writeJSON(c.App.Writer, listeners))
+	}
+	t := newTable(c.App.Writer, "NAME", "ADDRESS", "NETWORK", "SOURCE", "VERSION", "UPDATED")
+	for _, l := range listeners {
+		t.row(append([]string{l.Name, l.Address, l.Network}, versionCells(l.Version)...)...)
+	}
+	return exitError(t.flush())
+}
+
+func listClusters(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return exitError(err)
+	}
+	clusters, err := newClient(c).Clusters(c.Args()...)
+	if err != nil {
+		return exitError(err)
+	}
+	if format == FormatJSON {
+		return exitError(writeJSON(c.App.Writer, clusters))
+	}
+	t := newTable(c.App.Writer, "CLUSTER", "TYPE", "LB", "HOST", "HEALTHY", "FLAGS", "WEIGHT",
+		"CX_ACTIVE", "CX_FAIL", "RQ_TOTAL", "RQ_ACTIVE", "RQ_TIMEOUT", "RESP_FAILED")
+	for _, cluster := range clusters {
+		if len(cluster.Hosts) == 0 {
+			t.row(cluster.Name, cluster.Type, cluster.LbType, "", "", "", "", "", "", "", "", "", "")
+			continue
+		}
+		for _, host := range cluster.Hosts {
+			t.row(cluster.Name, cluster.Type, cluster.LbType, host.Address,
+				strconv.FormatBool(host.Healthy),
+				strings.Join(host.HealthFlags, ","),
+				strconv.FormatUint(uint64(host.Weight), 10),
+				hostStat(host, metrics.UpstreamConnectionActive),
+				hostStat(host, metrics.UpstreamConnectionConFail),
+				hostStat(host, metrics.UpstreamRequestTotal),
+				hostStat(host, metrics.UpstreamRequestActive),
+				hostStat(host, metrics.UpstreamRequestTimeout),
+				hostStat(host, metrics.UpstreamResponseFailed),
+			)
+		}
+	}
+	return exitError(t.flush())
+}
+
+func hostStat(host admin.HostStatus, key string) string {
+	v, ok := host.Stats[key]
+	if !ok {
+		return ""
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+func listRoutes(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return exitError(err)
+	}
+	routes, err := newClient(c).Routes(c.Args()...)
+	if err != nil {
+		return exitError(err)
+	}
+	if format == FormatJSON {
+		return exitError(writeJSON(c.App.Writer, routes))
+	}
+	t := newTable(c.App.Writer, "ROUTER", "VIRTUAL_HOST", "DOMAINS", "ROUTE", "MATCH", "ACTION", "SOURCE", "VERSION", "UPDATED")
+	for _, r := range routes {
+		t.row(append([]string{r.Router, r.VirtualHost, strings.Join(r.Domains, ","), r.Name, r.Match, r.Action}, versionCells(r.Version)...)...)
+	}
+	return exitError(t.flush())
+}
+
+func listFeatures(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return exitError(err)
+	}
+	features, err := newClient(c).Features()
+	if err != nil {
+		return exitError(err)
+	}
+	if format == FormatJSON {
+		return exitError(writeJSON(c.App.Writer, features))
+	}
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	t := newTable(c.App.Writer, "FEATURE", "ENABLED")
+	for _, name := range names {
+		t.row(name, strconv.FormatBool(features[name]))
+	}
+	return exitError(t.flush())
+}
+
+func listLogLevels(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return exitError(err)
+	}
+	levels, err := newClient(c).LogLevels()
+	if err != nil {
+		return exitError(err)
+	}
+	if format == FormatJSON {
+		return exitError(writeJSON(c.App.Writer, levels))
+	}
+	paths := make([]string, 0, len(levels))
+	for path := range levels {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	t := newTable(c.App.Writer, "LOG_PATH", "LEVEL")
+	for _, path := range paths {
+		t.row(path, levels[path])
+	}
+	return exitError(t.flush())
+}
+
+func setLogLevel(c *cli.Context) error {
+	if len(c.Args()) != 2 {
+		return exitError(errors.New("log path and level are required"))
+	}
+	path, level := c.Args().Get(0), c.Args().Get(1)
+	if err := newClient(c).SetLogLevel(path, level); err != nil {
+		return exitError(err)
+	}
+	fmt.Fprintf(c.App.Writer, "log %s level is set to %s\n", path, strings.ToUpper(level))
+	return nil
+}
+
+func hostAction(action string) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if len(c.Args()) != 2 {
+			return exitError(errors.New("cluster and address are required"))
+		}
+		cluster, address := c.Args().Get(0), c.Args().Get(1)
+		if err := newClient(c).SetHostState(cluster, address, action); err != nil {
+			return exitError(err)
+		}
+		fmt.Fprintf(c.App.Writer, "%s host %s in cluster %s success\n", action, address, cluster)
+		return nil
+	}
+}
+
+func startDrain(c *cli.Context) error {
+	cfg := &admin.DrainConfig{
+		Mode:      c.String("mode"),
+		Strategy:  c.String("strategy"),
+		Listeners: c.Args(),
+	}
+	if window := c.Duration("window"); window > 0 {
+		cfg.Window = &api.DurationConfig{Duration: window}
+	}
+	if wait := c.Duration("wait"); wait > 0 {
+		cfg.Timeout = &api.DurationConfig{Duration: wait}
+	}
+	if err := newClient(c).Drain(cfg); err != nil {
+		return exitError(err)
+	}
+	fmt.Fprintf(c.App.Writer, "drain started, mode: %s, strategy: %s\n", cfg.Mode, cfg.Strategy)
+	return nil
+}
+
+func drainStatus(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return exitError(err)
+	}
+	status, err := newClient(c).DrainStatus()
+	if err != nil {
+		return exitError(err)
+	}
+	if format == FormatJSON {
+		return exitError(writeJSON(c.App.Writer, status))
+	}
+	fmt.Fprintf(c.App.Writer, "state: %s\n", status.State)
+	names := make([]string, 0, len(status.Listeners))
+	for name := range status.Listeners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	t := newTable(c.App.Writer, "LISTENER", "CONNECTIONS", "NOTIFIED", "REMAINING", "REQUESTS")
+	for _, name := range names {
+		l := status.Listeners[name]
+		t.row(name, strconv.FormatInt(l.ConnectionsTotal, 10), strconv.FormatInt(l.ConnectionsNotified, 10),
+			strconv.FormatInt(l.ConnectionsRemaining, 10), strconv.FormatInt(l.RequestsActive, 10))
+	}
+	return exitError(t.flush())
+}
+
+// statsSample is a line of the json output of the stats command
+type statsSample struct {
+	Time  time.Time `json:"time"`
+	Stats []Stat    `json:"stats"`
+}
+
+func parseKeyValues(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	kvs := make(map[string]string, len(values))
+	for _, v := range values {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid KEY=VALUE: %s", v)
+		}
+		kvs[kv[0]] = kv[1]
+	}
+	return kvs, nil
+}
+
+func routeMatch(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return exitError(err)
+	}
+	req := &admin.RouteMatchRequest{
+		Listener:         c.String("listener"),
+		RouterConfigName: c.String("router"),
+		Protocol:         c.String("protocol"),
+		Host:             c.String("host"),
+		Method:           c.String("method"),
+		Path:             c.Args().First(),
+		Query:            c.String("query"),
+	}
+	if req.Headers, err = parseKeyValues(c.StringSlice("header")); err != nil {
+		return exitError(err)
+	}
+	if req.Variables, err = parseKeyValues(c.StringSlice("var")); err != nil {
+		return exitError(err)
+	}
+	result, err := newClient(c).RouteMatch(req)
+	if err != nil {
+		return exitError(err)
+	}
+	if format == FormatJSON {
+		return exitError(writeJSON(c.App.Writer, result))
+	}
+	w := c.App.Writer
+	fmt.Fprintf(w, "router: %s\n", result.RouterConfigName)
+	fmt.Fprintf(w, "virtual host: %s (%s)\n", result.Match.VirtualHost, result.Match.Reason)
+	t := newTable(w, "INDEX", "NAME", "MATCH", "MATCHED", "REASON")
+	for _, r := range result.Match.Routes {
+		t.row(strconv.Itoa(r.Index), r.Name, r.Match, strconv.FormatBool(r.Matched), r.Reason)
+	}
+	if err := t.flush(); err != nil {
+		return exitError(err)
+	}
+	switch {
+	case !result.Matched:
+		fmt.Fprintln(w, "no route matched")
+	case result.DirectResponse != nil:
+		fmt.Fprintf(w, "direct response: %d\n", result.DirectResponse.StatusCode)
+	case result.Redirect != nil:
+		fmt.Fprintf(w, "redirect: %d\n", result.Redirect.Code)
+	default:
+		fmt.Fprintf(w, "cluster: %s\n", result.Cluster)
+		if len(result.Subset) > 0 {
+			fmt.Fprintf(w, "subset: %v\n", result.Subset)
+		}
+		if result.Host != "" {
+			fmt.Fprintf(w, "host: %s\n", result.Host)
+		} else {
+			fmt.Fprintf(w, "host: - (%s)\n", result.HostReason)
+		}
+	}
+	return nil
+}
+
+func listRuntime(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return exitError(err)
+	}
+	entries, err := newClient(c).Runtime(c.Args().First())
+	if err != nil {
+		return exitError(err)
+	}
+	if format == FormatJSON {
+		return exitError(writeJSON(c.App.Writer, entries))
+	}
+	t := newTable(c.App.Writer, "KEY", "VALUE", "LAYER", "OVERRIDDEN")
+	for _, entry := range entries {
+		overridden := make([]string, 0, len(entry.Overridden))
+		for layer, value := range entry.Overridden {
+			overridden = append(overridden, layer+"="+value)
+		}
+		sort.Strings(overridden)
+		t.row(entry.Key, entry.Value, entry.Layer, strings.Join(overridden, ","))
+	}
+	return exitError(t.flush())
+}
+
+func setRuntime(c *cli.Context) error {
+	values, err := parseKeyValues(c.Args())
+	if err != nil {
+		return exitError(err)
+	}
+	if len(values) == 0 {
+		return exitError(errors.New("KEY=VALUE is required"))
+	}
+	if err := newClient(c).SetRuntime(values); err != nil {
+		return exitError(err)
+	}
+	fmt.Fprintf(c.App.Writer, "runtime values are set: %d\n", len(values))
+	return nil
+}
+
+func unsetRuntime(c *cli.Context) error {
+	if len(c.Args()) == 0 {
+		return exitError(errors.New("KEY is required"))
+	}
+	if err := newClient(c).UnsetRuntime(c.Args()...); err != nil {
+		return exitError(err)
+	}
+	fmt.Fprintf(c.App.Writer, "runtime keys are unset: %s\n", strings.Join(c.Args(), ","))
+	return nil
+}
+
+func matchStat(stat Stat, filters []string, changed bool) bool {
+	if changed && (stat.Delta == nil || *stat.Delta == 0) {
+		return false
+	}
+	if len(filters) == 0 {
+		return true
+	}
+	for _, f := range filters {
+		if strings.Contains(stat.Name, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// tailStats samples the stats by the interval, the table output writes a row for each stats,
+// and the json output writes a line for each sample.
+func tailStats(c *cli.Context) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return exitError(err)
+	}
+	client := newClient(c)
+	key := c.String("key")
+	filters := c.StringSlice("filter")
+	changed := c.Bool("changed")
+	count := c.Int("count")
+	interval := c.Duration("interval")
+	var t *table
+	if format == FormatTable {
+		t = newTable(c.App.Writer, "TIME", "NAME", "VALUE", "DELTA")
+	}
+	var last map[string]int64
+	for i := 0; count <= 0 || i < count; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		stats, err := client.Stats(key)
+		if err != nil {
+			return exitError(err)
+		}
+		now := time.Now()
+		last = statsDelta(stats, last)
+		selected := make([]Stat, 0, len(stats))
+		for _, stat := range stats {
+			if matchStat(stat, filters, changed && i > 0) {
+				selected = append(selected, stat)
+			}
+		}
+		if format == FormatJSON {
+			data, err := json.Marshal(statsSample{Time: now, Stats: selected})
+			if err != nil {
+				return exitError(err)
+			}
+			fmt.Fprintln(c.App.Writer, string(data))
+			continue
+		}
+		for _, stat := range selected {
+			delta := ""
+			if stat.Delta != nil {
+				delta = strconv.FormatInt(*stat.Delta, 10)
+			}
+			t.row(now.Format(time.RFC3339), stat.Name, strconv.FormatInt(stat.Value, 10), delta)
+		}
+		if err := t.flush(); err != nil {
+			return exitError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli"
+	admin "mosn.io/mosn/pkg/admin/server"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+const testToken = "ctl-token"
+
+func setupAdmin(t *testing.T) *httptest.Server {
+	configmanager.Reset()
+	cluster.NewClusterManagerSingleton(nil, nil, nil)
+	if err := cluster.GetClusterMngAdapterInstance().AddOrUpdateClusterAndHost(v2.Cluster{
+		Name:        "ctl_cluster",
+		ClusterType: v2.SIMPLE_CLUSTER,
+		LbType:      v2.LB_RANDOM,
+	}, []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:9180"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	configmanager.SetListenerConfig(v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			Name:       "ctl_listener",
+			AddrConfig: "127.0.0.1:9190",
+		},
+	})
+	configmanager.SetResourceVersion(configmanager.CfgTypeListener, "ctl_listener", configmanager.ResourceVersion{Source: configmanager.SourceXds, VersionInfo: "v1"})
+	configmanager.SetRouter(v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: "ctl_router",
+		},
+		VirtualHosts: []v2.VirtualHost{
+			{
+				Name:    "vh",
+				Domains: []string{"*"},
+				Routers: []v2.Router{
+					{
+						RouterConfig: v2.RouterConfig{
+							Match: v2.RouterMatch{Prefix: "/"},
+							Route: v2.RouteAction{
+								RouterActionConfig: v2.RouterActionConfig{
+									ClusterName: "ctl_cluster",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	admin.SetWriteToken(testToken)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/config_dump", admin.ConfigDump)
+	mux.HandleFunc("/api/v1/cluster_status", admin.ClusterStatusDump)
+	mux.HandleFunc("/api/v1/features", admin.KnownFeatures)
+	mux.HandleFunc("/api/v1/stats", admin.StatsDump)
+	mux.Handle("/api/v1/cluster/host_state", admin.NewAPIHandler(admin.HostState, admin.WriteAuth))
+	return httptest.NewServer(mux)
+}
+
+func runCtl(t *testing.T, args ...string) (string, error) {
+	buf := &bytes.Buffer{}
+	app := cli.NewApp()
+	app.Writer = buf
+	app.ErrWriter = buf
+	app.Commands = []cli.Command{Command}
+	err := app.Run(append([]string{"mosn", "ctl"}, args...))
+	return buf.String(), err
+}
+
+func TestCtlCommands(t *testing.T) {
+	exiter := cli.OsExiter
+	cli.OsExiter = func(int) {}
+	defer func() {
+		cli.OsExiter = exiter
+	}()
+	server := setupAdmin(t)
+	defer server.Close()
+	defer admin.SetWriteToken("")
+	addr := strings.TrimPrefix(server.URL, "http://")
+	// table output
+	for _, tc := range []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"listeners"}, []string{"ctl_listener", "127.0.0.1:9190", "xds", "v1"}},
+		{[]string{"clusters"}, []string{"ctl_cluster", "127.0.0.1:9180", "true"}},
+		{[]string{"routes", "ctl_router"}, []string{"ctl_router", "vh", "prefix:/", "cluster:ctl_cluster"}},
+	} {
+		out, err := runCtl(t, append([]string{"-a", addr}, tc.args...)...)
+		if err != nil {
+			t.Fatalf("%v failed: %v", tc.args, err)
+		}
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("%v output is not expected: %s", tc.args, out)
+		}
+		fields := strings.Fields(lines[1])
+		for _, expected := range tc.expected {
+			found := false
+			for _, f := range fields {
+				if f == expected {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("%v output does not contain %s: %s", tc.args, expected, out)
+			}
+		}
+	}
+	// the write apis require the token
+	if _, err := runCtl(t, "-a", addr, "host", "eject", "ctl_cluster", "127.0.0.1:9180"); err == nil {
+		t.Fatal("eject host without token should be failed")
+	}
+	if out, err := runCtl(t, "-a", addr, "-t", testToken, "host", "eject", "ctl_cluster", "127.0.0.1:9180"); err != nil {
+		t.Fatalf("eject host failed: %v, %s", err, out)
+	}
+	out, err := runCtl(t, "-a", addr, "-o", "json", "clusters", "ctl_cluster")
+	if err != nil {
+		t.Fatalf("list clusters failed: %v", err)
+	}
+	var clusters []admin.ClusterStatus
+	if err := json.Unmarshal([]byte(out), &clusters); err != nil {
+		t.Fatalf("invalid json output: %v, %s", err, out)
+	}
+	if len(clusters) != 1 || len(clusters[0].Hosts) != 1 || clusters[0].Hosts[0].Healthy {
+		t.Fatalf("host should be ejected: %s", out)
+	}
+	if out, err := runCtl(t, "-a", addr, "-t", testToken, "host", "restore", "ctl_cluster", "127.0.0.1:9180"); err != nil {
+		t.Fatalf("restore host failed: %v, %s", err, out)
+	}
+	// errors
+	if _, err := runCtl(t, "-a", addr, "clusters", "unknown"); err == nil {
+		t.Fatal("list unknown cluster should be failed")
+	}
+	if _, err := runCtl(t, "-a", addr, "-o", "yaml", "features"); err == nil {
+		t.Fatal("invalid output format should be failed")
+	}
+	// stats samples are json lines
+	out, err = runCtl(t, "-a", addr, "-o", "json", "stats", "-n", "2", "-i", "1ms")
+	if err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 {
+		t.Fatalf("stats output is not expected: %s", out)
+	}
+}
+
+func TestCtlDrain(t *testing.T) {
+	exiter := cli.OsExiter
+	cli.OsExiter = func(int) {}
+	defer func() {
+		cli.OsExiter = exiter
+	}()
+	var received admin.DrainConfig
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/drain", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte("drain success\n"))
+	})
+	mux.HandleFunc("/api/v1/drain_status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"state":"draining","listeners":{"l1":{"connections_total":4,"connections_notified":2,"connections_remaining":3,"requests_active":1}}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	if _, err := runCtl(t, "-a", addr, "drain", "start"); err == nil {
+		t.Fatal("drain without token should be failed")
+	}
+	if out, err := runCtl(t, "-a", addr, "-t", testToken, "drain", "start", "--strategy", "gradual", "--window", "30s", "l1"); err != nil {
+		t.Fatalf("drain failed: %v, %s", err, out)
+	}
+	if received.Mode != "goaway" || received.Strategy != "gradual" || received.Window == nil ||
+		received.Window.Duration != 30*time.Second || received.Timeout != nil ||
+		len(received.Listeners) != 1 || received.Listeners[0] != "l1" {
+		t.Fatalf("unexpected drain config: %+v", received)
+	}
+	out, err := runCtl(t, "-a", addr, "drain", "status")
+	if err != nil {
+		t.Fatalf("drain status failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || lines[0] != "state: draining" || strings.Join(strings.Fields(lines[2]), " ") != "l1 4 2 3 1" {
+		t.Fatalf("drain status output is not expected: %s", out)
+	}
+}
+
+func TestCtlRouteMatch(t *testing.T) {
+	exiter := cli.OsExiter
+	cli.OsExiter = func(int) {}
+	defer func() {
+		cli.OsExiter = exiter
+	}()
+	var received admin.RouteMatchRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/route_match", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{
+			"router_config_name":"r1",
+			"match":{"virtual_host":"vh","reason":"the default virtual host is used","routes":[
+				{"index":0,"name":"a","match":"prefix:/a","matched":false,"reason":"path \"/b\" does not have the prefix \"/a\""},
+				{"index":1,"name":"b","match":"prefix:/","matched":true}
+			]},
+			"matched":true,
+			"cluster":"c1",
+			"host":"127.0.0.1:8080"
+		}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	if _, err := runCtl(t, "-a", addr, "route-match", "-l", "l1", "-H", "invalid", "/b"); err == nil {
+		t.Fatal("invalid header should be failed")
+	}
+	out, err := runCtl(t, "-a", addr, "route-match", "-l", "l1", "-X", "GET", "-H", "x-key=v1", "--var", "k=v=2", "/b")
+	if err != nil {
+		t.Fatalf("route match failed: %v, %s", err, out)
+	}
+	if received.Listener != "l1" || received.Method != "GET" || received.Path != "/b" ||
+		received.Headers["x-key"] != "v1" || received.Variables["k"] != "v=2" {
+		t.Fatalf("unexpected route match request: %+v", received)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 7 || lines[1] != "virtual host: vh (the default virtual host is used)" ||
+		strings.Join(strings.Fields(lines[4]), " ") != "1 b prefix:/ true -" ||
+		lines[5] != "cluster: c1" || lines[6] != "host: 127.0.0.1:8080" {
+		t.Fatalf("route match output is not expected: %s", out)
+	}
+}
+
+func TestCtlRuntime(t *testing.T) {
+	exiter := cli.OsExiter
+	cli.OsExiter = func(int) {}
+	defer func() {
+		cli.OsExiter = exiter
+	}()
+	var received map[string]string
+	var unset []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/runtime", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("prefix") != "fault" {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`[{"key":"fault.percent","value":"50","layer":"admin","overridden":{"static":"10","directory":"20"}}]`))
+	})
+	mux.HandleFunc("/api/v1/runtime/update", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			unset = r.URL.Query()["key"]
+		} else {
+			json.NewDecoder(r.Body).Decode(&received)
+		}
+		w.Write([]byte("runtime update success\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+
+	out, err := runCtl(t, "-a", addr, "runtime", "list", "fault")
+	if err != nil {
+		t.Fatalf("runtime list failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || strings.Join(strings.Fields(lines[1]), " ") != "fault.percent 50 admin directory=20,static=10" {
+		t.Fatalf("runtime list output is not expected: %s", out)
+	}
+	if _, err := runCtl(t, "-a", addr, "runtime", "set", "fault.percent=1"); err == nil {
+		t.Fatal("runtime set without token should be failed")
+	}
+	if _, err := runCtl(t, "-a", addr, "-t", testToken, "runtime", "set", "fault.percent"); err == nil {
+		t.Fatal("runtime set without value should be failed")
+	}
+	if out, err := runCtl(t, "-a", addr, "-t", testToken, "runtime", "set", "fault.percent=1", "timeout=1s"); err != nil {
+		t.Fatalf("runtime set failed: %v, %s", err, out)
+	}
+	if len(received) != 2 || received["fault.percent"] != "1" || received["timeout"] != "1s" {
+		t.Fatalf("unexpected runtime values: %+v", received)
+	}
+	if out, err := runCtl(t, "-a", addr, "-t", testToken, "runtime", "unset", "fault.percent"); err != nil {
+		t.Fatalf("runtime unset failed: %v, %s", err, out)
+	}
+	if len(unset) != 1 || unset[0] != "fault.percent" {
+		t.Fatalf("unexpected unset keys: %v", unset)
+	}
+}
+
+func TestStatsDelta(t *testing.T) {
+	stats := flattenStats(map[string]map[string]map[string]string{
+		"cluster": {
+			"cluster.c1": {
+				"request_total": "10",
+				"request_rate":  "1.5",
+			},
+		},
+		"downstream": {
+			"": {
+				"request_total": "3",
+			},
+		},
+	})
+	if len(stats) != 2 || stats[0].Name != "cluster.cluster.c1.request_total" || stats[1].Name != "downstream.request_total" {
+		t.Fatalf("flatten stats is not expected: %+v", stats)
+	}
+	last := statsDelta(stats, nil)
+	if stats[0].Delta != nil {
+		t.Fatal("the first sample has no delta")
+	}
+	next := []Stat{
+		{Name: "cluster.cluster.c1.request_total", Value: 15},
+		{Name: "downstream.request_total", Value: 3},
+		{Name: "upstream.request_total", Value: 2},
+	}
+	statsDelta(next, last)
+	for i, expected := range []int64{5, 0, 2} {
+		if next[i].Delta == nil || *next[i].Delta != expected {
+			t.Fatalf("stats %s delta is not expected: %v", next[i].Name, next[i].Delta)
+		}
+	}
+	if matchStat(next[1], nil, true) || !matchStat(next[0], []string{"c1"}, true) || matchStat(next[2], []string{"c1"}, false) {
+		t.Fatal("match stats is not expected")
+	}
+}
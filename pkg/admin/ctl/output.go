@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// The output formats
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+// table writes the rows separated by tabs, the cells are aligned
+type table struct {
+	w *tabwriter.Writer
+}
+
+func newTable(w io.Writer, header ...string) *table {
+	t := &table{
+		w: tabwriter.NewWriter(w, 0, 8, 2, ' ', 0),
+	}
+	t.row(header...)
+	return t
+}
+
+func (t *table) row(cells ...string) {
+	for i, cell := range cells {
+		// empty cells break the columns when the output is parsed by fields
+		if cell == "" {
+			cells[i] = "-"
+		}
+	}
+	fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+}
+
+func (t *table) flush() error {
+	return t.w.Flush()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// flattenStats flattens the stats api response, the metrics that are not integers are ignored
+func flattenStats(all map[string]map[string]map[string]string) []Stat {
+	stats := []Stat{}
+	for typ, namespaces := range all {
+		for namespace, values := range namespaces {
+			for key, value := range values {
+				v, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					continue
+				}
+				name := typ
+				if namespace != "" {
+					name += "." + namespace
+				}
+				stats = append(stats, Stat{
+					Name:  name + "." + key,
+					Value: v,
+				})
+			}
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// statsDelta sets the deltas of the stats with the last sample,
+// and returns the sample for the next call
+func statsDelta(stats []Stat, last map[string]int64) map[string]int64 {
+	sample := make(map[string]int64, len(stats))
+	for i := range stats {
+		sample[stats[i].Name] = stats[i].Value
+		if last == nil {
+			continue
+		}
+		// the new metrics increase from zero
+		delta := stats[i].Value - last[stats[i].Name]
+		stats[i].Delta = &delta
+	}
+	return sample
+}
@@ -24,11 +24,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"mosn.io/mosn/pkg/configmanager"
 	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/tap/conntap"
 )
 
 func TestKnownFeatures(t *testing.T) {
@@ -245,3 +247,58 @@ func TestVersion(t *testing.T) {
 		t.Fatalf("expectation failure: %v", err)
 	}
 }
+
+func TestTapRequests(t *testing.T) {
+	for _, tc := range []struct {
+		method   string
+		body     string
+		expected int
+	}{
+		{"GET", "", http.StatusMethodNotAllowed},
+		{"POST", "invalid", http.StatusBadRequest},
+		{"POST", `{"expression":"invalid expression ("}`, http.StatusBadRequest},
+		{"POST", `{"count":100000000}`, http.StatusBadRequest},
+		{"POST", `{"max_body_bytes":100000000}`, http.StatusBadRequest},
+		{"POST", `{"count":1,"timeout":"100ms"}`, http.StatusOK},
+	} {
+		r := httptest.NewRequest(tc.method, "http://127.0.0.1/api/v1/tap", bytes.NewBufferString(tc.body))
+		w := httptest.NewRecorder()
+		TapRequests(w, r)
+		if w.Result().StatusCode != tc.expected {
+			t.Fatalf("tap %s response status code is %d, wanna: %d", tc.body, w.Result().StatusCode, tc.expected)
+		}
+	}
+}
+
+func TestConnTap(t *testing.T) {
+	conntap.SetOutputDir(os.TempDir())
+	defer conntap.SetOutputDir("")
+	path := "mosn_admin_conn_tap.pcapng"
+	defer os.Remove(filepath.Join(os.TempDir(), path))
+	for _, tc := range []struct {
+		handler  http.HandlerFunc
+		method   string
+		body     string
+		expected int
+	}{
+		{ConnTapStatus, "GET", "", http.StatusNotFound},
+		{ConnTapStop, "POST", "", http.StatusNotFound},
+		{ConnTapStart, "GET", "", http.StatusMethodNotAllowed},
+		{ConnTapStart, "POST", "invalid", http.StatusBadRequest},
+		{ConnTapStart, "POST", `{"format":"pcapng"}`, http.StatusBadRequest},
+		{ConnTapStart, "POST", `{"listeners":["test"],"path":"/etc/mosn.pcapng"}`, http.StatusBadRequest},
+		{ConnTapStart, "POST", `{"listeners":["test"],"path":"../mosn.pcapng"}`, http.StatusBadRequest},
+		{ConnTapStart, "POST", `{"listeners":["test"],"path":"` + path + `"}`, http.StatusOK},
+		{ConnTapStart, "POST", `{"listeners":["test"],"path":"` + path + `"}`, http.StatusConflict},
+		{ConnTapStatus, "GET", "", http.StatusOK},
+		{ConnTapStop, "POST", "", http.StatusOK},
+		{ConnTapStatus, "GET", "", http.StatusOK},
+	} {
+		r := httptest.NewRequest(tc.method, "http://127.0.0.1/api/v1/conn_tap", bytes.NewBufferString(tc.body))
+		w := httptest.NewRecorder()
+		tc.handler(w, r)
+		if w.Result().StatusCode != tc.expected {
+			t.Fatalf("connection tap %s %s response status code is %d, wanna: %d", tc.method, tc.body, w.Result().StatusCode, tc.expected)
+		}
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	gometrics "github.com/rcrowley/go-metrics"
 	v2 "mosn.io/mosn/pkg/config/v2"
@@ -35,6 +37,8 @@ import (
 	"mosn.io/mosn/pkg/metrics/sink/console"
 	"mosn.io/mosn/pkg/plugin"
 	"mosn.io/mosn/pkg/stagemanager"
+	"mosn.io/mosn/pkg/tap"
+	"mosn.io/mosn/pkg/tap/conntap"
 	"mosn.io/mosn/pkg/types"
 )
 
@@ -74,6 +78,42 @@ func OutputVersion(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("mosn version: " + version))
 }
 
+// config dump query parameters
+const (
+	configDumpType           = "type"
+	configDumpName           = "name"
+	configDumpIncludeSecrets = "include_secrets"
+)
+
+var configDumpTypes = map[string]string{
+	"mosn":      configmanager.CfgTypeMOSN,
+	"listener":  configmanager.CfgTypeListener,
+	"listeners": configmanager.CfgTypeListener,
+	"cluster":   configmanager.CfgTypeCluster,
+	"clusters":  configmanager.CfgTypeCluster,
+	"router":    configmanager.CfgTypeRouter,
+	"routers":   configmanager.CfgTypeRouter,
+	"extend":    configmanager.CfgTypeExtend,
+	"extends":   configmanager.CfgTypeExtend,
+}
+
+// splitQuery returns the values of the query, the values can be repeated or separated by comma
+func splitQuery(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+// ConfigDump dumps the effective config, the secrets in the tls configs are redacted
+// unless include_secrets is set with the write token.
+// The type and name parameters select the resources, which are dumped with their versions,
+// such as: /api/v1/config_dump?type=listeners,clusters&name=test
 func ConfigDump(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "config dump", r.Method)
@@ -81,8 +121,36 @@ func ConfigDump(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	r.ParseForm()
+	includeSecrets := false
+	if _, ok := r.Form[configDumpIncludeSecrets]; ok {
+		includeSecrets, _ = strconv.ParseBool(r.Form.Get(configDumpIncludeSecrets))
+		if includeSecrets && !checkWriteToken(r) {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: dump secrets without valid token", "config dump")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, errMsgFmt, "include_secrets requires the write token")
+			return
+		}
+		r.Form.Del(configDumpIncludeSecrets)
+	}
+	redact := func(v interface{}) interface{} {
+		if includeSecrets {
+			return configmanager.RedactWriteToken(v)
+		}
+		return configmanager.RedactSecrets(v)
+	}
+	_, hasType := r.Form[configDumpType]
+	_, hasName := r.Form[configDumpName]
+	if hasType || hasName {
+		configDumpResources(w, r, includeSecrets)
+		return
+	}
 	if len(r.Form) == 0 {
-		buf, _ := configmanager.DumpJSON()
+		var buf []byte
+		if includeSecrets {
+			buf, _ = configmanager.DumpSecretsJSON()
+		} else {
+			buf, _ = configmanager.DumpRedactedJSON()
+		}
 		log.DefaultLogger.Infof("[admin api] [config dump] config dump")
 		w.WriteHeader(200)
 		w.Write(buf)
@@ -100,7 +168,7 @@ func ConfigDump(w http.ResponseWriter, r *http.Request) {
 			msg := fmt.Sprintf(errMsgFmt, "internal error")
 			fmt.Fprint(w, msg)
 		} else {
-			buf, _ := json.MarshalIndent(info, "", " ")
+			buf, _ := json.MarshalIndent(redact(info), "", " ")
 			log.DefaultLogger.Infof("[admin api] [config dump] config dump, parameters:%v", r.Form)
 			w.WriteHeader(200)
 			w.Write(buf)
@@ -147,6 +215,41 @@ func ConfigDump(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func configDumpResources(w http.ResponseWriter, r *http.Request, includeSecrets bool) {
+	for key := range r.Form {
+		if key != configDumpType && key != configDumpName {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid parameter: %s", "config dump", key)
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, errMsgFmt, "type and name can not be used with "+key)
+			return
+		}
+	}
+	opts := configmanager.DumpOptions{
+		Names:          splitQuery(r.Form[configDumpName]),
+		IncludeSecrets: includeSecrets,
+	}
+	for _, typ := range splitQuery(r.Form[configDumpType]) {
+		cfgType, ok := configDumpTypes[strings.ToLower(typ)]
+		if !ok {
+			log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid type: %s", "config dump", typ)
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, errMsgFmt, "invalid type "+typ)
+			return
+		}
+		opts.Types = append(opts.Types, cfgType)
+	}
+	dump := configmanager.DumpResources(opts)
+	if len(opts.Names) > 0 && dump.Empty() {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, errMsgFmt, "no resources found")
+		return
+	}
+	buf, _ := json.MarshalIndent(dump, "", " ")
+	log.DefaultLogger.Infof("[admin api] [config dump] config dump, parameters:%v", r.Form)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+}
+
 func StatsDump(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "stats dump", r.Method)
@@ -383,3 +486,169 @@ func GetEnv(w http.ResponseWriter, r *http.Request) {
 	data, _ := json.MarshalIndent(results, "", " ")
 	w.Write(data)
 }
+
+// TapRequests streams the requests matched the tap config as json lines, until the
+// count or timeout is reached, or the client closes the connection.
+// post data: tap config in json, such as
+// {"listener":"serverListener","headers":{"service":"test"},"count":10,"timeout":"30s"}
+func TapRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "tap requests", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", "tap requests", err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "read body error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	cfg := &tap.Config{}
+	if err := json.Unmarshal(body, cfg); err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid tap config: %s, %v", "tap requests", string(body), err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "invalid tap config")
+		fmt.Fprint(w, msg)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		msg := fmt.Sprintf(errMsgFmt, "streaming unsupported")
+		fmt.Fprint(w, msg)
+		return
+	}
+	session, err := tap.NewSession(cfg)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: create tap session failed: %v", "tap requests", err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "create tap session failed: "+err.Error())
+		fmt.Fprint(w, msg)
+		return
+	}
+	defer session.Close()
+	log.DefaultLogger.Infof("[admin api] [tap requests] start tap session: %s", string(body))
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	write := func(record *tap.Record) bool {
+		if err := encoder.Encode(record); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+	timer := time.NewTimer(session.Timeout())
+	defer timer.Stop()
+	for {
+		select {
+		case record := <-session.Records():
+			if !write(record) {
+				return
+			}
+		case <-session.Done():
+			// all records are captured, write the rest
+			for {
+				select {
+				case record := <-session.Records():
+					if !write(record) {
+						return
+					}
+				default:
+					log.DefaultLogger.Infof("[admin api] [tap requests] tap session finished")
+					return
+				}
+			}
+		case <-timer.C:
+			log.DefaultLogger.Infof("[admin api] [tap requests] tap session timeout")
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// ConnTapStart starts a connection tap, the raw bytes of the connections on the listeners
+// or clusters are written to a pcapng or json file until stopped.
+// the path is relative to the connection tap output directory, which is the mosn log directory by default.
+// post data: connection tap config in json, such as
+// {"listeners":["serverListener"],"format":"pcapng","path":"mosn.pcapng","max_bytes":1048576}
+func ConnTapStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "connection tap start", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: read body failed, %v", "connection tap start", err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "read body error")
+		fmt.Fprint(w, msg)
+		return
+	}
+	cfg := &conntap.Config{}
+	if err := json.Unmarshal(body, cfg); err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid connection tap config: %s, %v", "connection tap start", string(body), err)
+		w.WriteHeader(http.StatusBadRequest)
+		msg := fmt.Sprintf(errMsgFmt, "invalid connection tap config")
+		fmt.Fprint(w, msg)
+		return
+	}
+	if err := conntap.Start(cfg); err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: start connection tap failed: %v", "connection tap start", err)
+		if err == conntap.ErrCaptureRunning {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		msg := fmt.Sprintf(errMsgFmt, err.Error())
+		fmt.Fprint(w, msg)
+		return
+	}
+	log.DefaultLogger.Infof("[admin api] [connection tap] start connection tap: %s", string(body))
+	data, _ := json.MarshalIndent(conntap.GetStatus(), "", " ")
+	w.Write(data)
+}
+
+// ConnTapStop stops the running connection tap, and returns its final status
+func ConnTapStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "connection tap stop", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	status, err := conntap.Stop()
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		msg := fmt.Sprintf(errMsgFmt, err.Error())
+		fmt.Fprint(w, msg)
+		return
+	}
+	log.DefaultLogger.Infof("[admin api] [connection tap] stop connection tap")
+	data, _ := json.MarshalIndent(status, "", " ")
+	w.Write(data)
+}
+
+// ConnTapStatus returns the status of the running or the last connection tap
+func ConnTapStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", "connection tap status", r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	status := conntap.GetStatus()
+	if status == nil {
+		w.WriteHeader(http.StatusNotFound)
+		msg := fmt.Sprintf(errMsgFmt, "no connection tap")
+		fmt.Fprint(w, msg)
+		return
+	}
+	data, _ := json.MarshalIndent(status, "", " ")
+	w.Write(data)
+}
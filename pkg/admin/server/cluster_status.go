@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+// HostStatus is the runtime status of a host
+type HostStatus struct {
+	Address     string           `json:"address"`
+	Hostname    string           `json:"hostname,omitempty"`
+	Weight      uint32           `json:"weight"`
+	Healthy     bool             `json:"healthy"`
+	HealthFlags []string         `json:"health_flags,omitempty"`
+	Stats       map[string]int64 `json:"stats"`
+}
+
+// ClusterStatus is the runtime status of a cluster and its hosts
+type ClusterStatus struct {
+	Name   string       `json:"name"`
+	Type   string       `json:"type"`
+	LbType string       `json:"lb_type"`
+	Hosts  []HostStatus `json:"hosts"`
+}
+
+var healthFlagNames = []struct {
+	flag api.HealthFlag
+	name string
+}{
+	{api.FAILED_ACTIVE_HC, "failed_active_hc"},
+	{api.FAILED_OUTLIER_CHECK, "failed_outlier_check"},
+	{cluster.ADMIN_EJECTED, "admin_ejected"},
+	{cluster.ADMIN_DRAINING, "admin_draining"},
+}
+
+func hostStatus(host types.Host) HostStatus {
+	status := HostStatus{
+		Address:  host.AddressString(),
+		Hostname: host.Hostname(),
+		Weight:   host.Weight(),
+		Healthy:  host.Health(),
+	}
+	for _, f := range healthFlagNames {
+		if host.ContainHealthFlag(f.flag) {
+			status.HealthFlags = append(status.HealthFlags, f.name)
+		}
+	}
+	stats := host.HostStats()
+	status.Stats = map[string]int64{
+		metrics.UpstreamConnectionTotal:   stats.UpstreamConnectionTotal.Count(),
+		metrics.UpstreamConnectionActive:  stats.UpstreamConnectionActive.Count(),
+		metrics.UpstreamConnectionConFail: stats.UpstreamConnectionConFail.Count(),
+		metrics.UpstreamRequestTotal:      stats.UpstreamRequestTotal.Count(),
+		metrics.UpstreamRequestActive:     stats.UpstreamRequestActive.Count(),
+		metrics.UpstreamRequestTimeout:    stats.UpstreamRequestTimeout.Count(),
+		metrics.UpstreamResponseSuccess:   stats.UpstreamResponseSuccess.Count(),
+		metrics.UpstreamResponseFailed:    stats.UpstreamResponseFailed.Count(),
+	}
+	return status
+}
+
+// ClusterStatusDump dumps the clusters with their hosts health and stats, the clusters and hosts are sorted.
+// GET /api/v1/cluster_status?name=cluster1&name=cluster2 to dump the selected clusters.
+func ClusterStatusDump(w http.ResponseWriter, r *http.Request) {
+	const api = "cluster status"
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	cm := cluster.GetClusterMngAdapterInstance()
+	if cm == nil || cm.ClusterManager == nil {
+		writeAPIError(w, api, http.StatusServiceUnavailable, errClusterManagerNotReady)
+		return
+	}
+	names := splitQuery(r.URL.Query()["name"])
+	selected := len(names) > 0
+	if !selected {
+		configmanager.HandleMOSNConfig(configmanager.CfgTypeCluster, func(v interface{}) {
+			for name := range v.(map[string]v2.Cluster) {
+				names = append(names, name)
+			}
+		})
+	}
+	sort.Strings(names)
+	result := make([]ClusterStatus, 0, len(names))
+	for _, name := range names {
+		snap := cm.GetClusterSnapshot(context.Background(), name)
+		if snap == nil {
+			continue
+		}
+		info := snap.ClusterInfo()
+		status := ClusterStatus{
+			Name:   info.Name(),
+			Type:   string(info.ClusterType()),
+			LbType: string(info.LbType()),
+			Hosts:  []HostStatus{},
+		}
+		snap.HostSet().Range(func(host types.Host) bool {
+			status.Hosts = append(status.Hosts, hostStatus(host))
+			return true
+		})
+		sort.Slice(status.Hosts, func(i, j int) bool {
+			return status.Hosts[i].Address < status.Hosts[j].Address
+		})
+		result = append(result, status)
+	}
+	if selected && len(result) == 0 {
+		writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("clusters %v are not exists", names))
+		return
+	}
+	data, _ := json.MarshalIndent(result, "", " ")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
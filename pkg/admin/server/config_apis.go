@@ -0,0 +1,579 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/server"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+// The admin write apis change the clusters, hosts, routers and listeners at runtime.
+// All of them require a bearer token that configured in the admin config, and they
+// are disabled if no token is configured.
+
+var writeToken atomic.Value
+
+// SetWriteToken sets the token required by the admin write apis
+func SetWriteToken(token string) {
+	writeToken.Store(token)
+}
+
+func checkWriteToken(r *http.Request) bool {
+	token, _ := writeToken.Load().(string)
+	if token == "" {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(token)) == 1
+}
+
+// WriteAuth is the auth of the admin write apis
+var WriteAuth = NewAuth(checkWriteToken, func(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusUnauthorized)
+	fmt.Fprintf(w, errMsgFmt, "write api is disabled or the token is invalid")
+})
+
+// configWriteMux makes the read-modify-write of the router config serial
+var configWriteMux sync.Mutex
+
+var (
+	errClusterManagerNotReady  = errors.New("cluster manager is not initialized")
+	errListenerAdapterNotReady = errors.New("listener adapter is not initialized")
+)
+
+type adminVersionKey struct{}
+
+// withAdminVersion records the resources updated by the write api with the admin source,
+// the version info can be set by the version query parameter. The version is passed to
+// the handler in the request context, and recorded by the handler with recordVersion.
+func withAdminVersion(handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version := configmanager.ResourceVersion{
+			Source:      configmanager.SourceAdmin,
+			VersionInfo: r.URL.Query().Get("version"),
+		}
+		handler(w, r.WithContext(context.WithValue(r.Context(), adminVersionKey{}, version)))
+	}
+}
+
+// recordVersion records the version of the request for the updated resource
+func recordVersion(r *http.Request, typ, name string) {
+	if version, ok := r.Context().Value(adminVersionKey{}).(configmanager.ResourceVersion); ok {
+		configmanager.SetResourceVersion(typ, name, version)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, api string, status int, err error) {
+	log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: %v", api, err)
+	w.WriteHeader(status)
+	fmt.Fprintf(w, errMsgFmt, err.Error())
+}
+
+// readConfig reads the request body and unmarshals it by the v2 unmarshalers
+func readConfig(r *http.Request, v interface{}) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("read body error: %v", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	return nil
+}
+
+func writeSuccess(w http.ResponseWriter, api string, msg string) {
+	log.DefaultLogger.Infof("[admin api] [%s] %s", api, msg)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "%s success\n", api)
+}
+
+// ClusterConfig adds, updates or deletes clusters.
+// POST a cluster config to add or update the cluster, the exists hosts are kept if the config contains no hosts.
+// DELETE /api/v1/clusters?name=cluster1&name=cluster2 to delete the clusters.
+func ClusterConfig(w http.ResponseWriter, r *http.Request) {
+	const api = "cluster config"
+	cm := cluster.GetClusterMngAdapterInstance()
+	if cm == nil || cm.ClusterManager == nil {
+		writeAPIError(w, api, http.StatusServiceUnavailable, errClusterManagerNotReady)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		cfg := v2.Cluster{}
+		if err := readConfig(r, &cfg); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		if cfg.Name == "" {
+			writeAPIError(w, api, http.StatusBadRequest, errors.New("cluster name is required"))
+			return
+		}
+		var err error
+		if len(cfg.Hosts) > 0 {
+			err = cm.AddOrUpdateClusterAndHost(cfg, cfg.Hosts)
+		} else {
+			err = cm.AddOrUpdatePrimaryCluster(cfg)
+		}
+		if err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		recordVersion(r, configmanager.CfgTypeCluster, cfg.Name)
+		writeSuccess(w, api, "add or update cluster: "+cfg.Name)
+	case http.MethodDelete:
+		names := r.URL.Query()["name"]
+		if len(names) == 0 {
+			writeAPIError(w, api, http.StatusBadRequest, errors.New("cluster name is required"))
+			return
+		}
+		if err := cm.RemovePrimaryCluster(names...); err != nil {
+			writeAPIError(w, api, http.StatusNotFound, err)
+			return
+		}
+		writeSuccess(w, api, fmt.Sprintf("delete clusters: %v", names))
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// HostsConfig is the request body of the hosts api
+type HostsConfig struct {
+	ClusterName string    `json:"cluster_name"`
+	Hosts       []v2.Host `json:"hosts"`
+}
+
+// ClusterHostsConfig changes the hosts of a cluster.
+// POST a hosts config to append the hosts, PUT a hosts config to replace all the hosts.
+// DELETE /api/v1/cluster/hosts?cluster=cluster1&address=127.0.0.1:8080 to delete the hosts.
+func ClusterHostsConfig(w http.ResponseWriter, r *http.Request) {
+	const api = "cluster hosts config"
+	cm := cluster.GetClusterMngAdapterInstance()
+	if cm == nil || cm.ClusterManager == nil {
+		writeAPIError(w, api, http.StatusServiceUnavailable, errClusterManagerNotReady)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut:
+		cfg := HostsConfig{}
+		if err := readConfig(r, &cfg); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		if !cm.ClusterExist(cfg.ClusterName) {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("cluster %s is not exists", cfg.ClusterName))
+			return
+		}
+		for _, h := range cfg.Hosts {
+			if h.Address == "" {
+				writeAPIError(w, api, http.StatusBadRequest, errors.New("host address is required"))
+				return
+			}
+		}
+		var err error
+		if r.Method == http.MethodPost {
+			err = cm.AppendClusterHosts(cfg.ClusterName, cfg.Hosts)
+		} else {
+			err = cm.UpdateClusterHosts(cfg.ClusterName, cfg.Hosts)
+		}
+		if err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		recordVersion(r, configmanager.CfgTypeCluster, cfg.ClusterName)
+		writeSuccess(w, api, fmt.Sprintf("%s cluster %s hosts: %d", r.Method, cfg.ClusterName, len(cfg.Hosts)))
+	case http.MethodDelete:
+		query := r.URL.Query()
+		clusterName := query.Get("cluster")
+		addrs := query["address"]
+		if clusterName == "" || len(addrs) == 0 {
+			writeAPIError(w, api, http.StatusBadRequest, errors.New("cluster and address are required"))
+			return
+		}
+		if !cm.ClusterExist(clusterName) {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("cluster %s is not exists", clusterName))
+			return
+		}
+		if err := cm.RemoveClusterHosts(clusterName, addrs); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		recordVersion(r, configmanager.CfgTypeCluster, clusterName)
+		writeSuccess(w, api, fmt.Sprintf("delete cluster %s hosts: %v", clusterName, addrs))
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// The actions of the host state api
+const (
+	HostActionEject   = "eject"
+	HostActionDrain   = "drain"
+	HostActionRestore = "restore"
+)
+
+// HostStateConfig is the request body of the host state api
+type HostStateConfig struct {
+	ClusterName string `json:"cluster_name"`
+	Address     string `json:"address"`
+	Action      string `json:"action"`
+}
+
+// HostState ejects, drains or restores a host in a cluster, POST a host state config.
+// The ejected host's connections are closed, while the draining host keeps its connections,
+// neither of them is chosen by the load balancers until they are restored.
+func HostState(w http.ResponseWriter, r *http.Request) {
+	const api = "host state"
+	cm := cluster.GetClusterMngAdapterInstance()
+	if cm == nil || cm.ClusterManager == nil {
+		writeAPIError(w, api, http.StatusServiceUnavailable, errClusterManagerNotReady)
+		return
+	}
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := HostStateConfig{}
+	if err := readConfig(r, &cfg); err != nil {
+		writeAPIError(w, api, http.StatusBadRequest, err)
+		return
+	}
+	if cfg.ClusterName == "" || cfg.Address == "" {
+		writeAPIError(w, api, http.StatusBadRequest, errors.New("cluster_name and address are required"))
+		return
+	}
+	var err error
+	switch cfg.Action {
+	case HostActionEject:
+		err = cm.EjectHost(cfg.ClusterName, cfg.Address)
+	case HostActionDrain:
+		err = cm.DrainHost(cfg.ClusterName, cfg.Address)
+	case HostActionRestore:
+		err = cm.RestoreHost(cfg.ClusterName, cfg.Address)
+	default:
+		writeAPIError(w, api, http.StatusBadRequest, fmt.Errorf("invalid action: %s", cfg.Action))
+		return
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, cluster.ErrClusterNotFound) || errors.Is(err, cluster.ErrHostNotFound) {
+			status = http.StatusNotFound
+		}
+		writeAPIError(w, api, status, err)
+		return
+	}
+	writeSuccess(w, api, fmt.Sprintf("%s cluster %s host %s", cfg.Action, cfg.ClusterName, cfg.Address))
+}
+
+// RouterConfig adds or updates a whole router configuration, POST a router configuration.
+func RouterConfig(w http.ResponseWriter, r *http.Request) {
+	const api = "router config"
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := &v2.RouterConfiguration{}
+	if err := readConfig(r, cfg); err != nil {
+		writeAPIError(w, api, http.StatusBadRequest, err)
+		return
+	}
+	if cfg.RouterConfigName == "" {
+		writeAPIError(w, api, http.StatusBadRequest, errors.New("router config name is required"))
+		return
+	}
+	configWriteMux.Lock()
+	defer configWriteMux.Unlock()
+	if err := updateRouters(cfg); err != nil {
+		writeAPIError(w, api, http.StatusBadRequest, err)
+		return
+	}
+	recordVersion(r, configmanager.CfgTypeRouter, cfg.RouterConfigName)
+	writeSuccess(w, api, "add or update router: "+cfg.RouterConfigName)
+}
+
+// updateRouters checks the router config before it is stored, the router manager accepts
+// invalid routers silently
+func updateRouters(cfg *v2.RouterConfiguration) error {
+	if len(cfg.VirtualHosts) > 0 {
+		if _, err := router.NewRouters(cfg); err != nil {
+			return err
+		}
+	}
+	return router.GetRoutersMangerInstance().AddOrUpdateRouters(cfg)
+}
+
+// copyRouters returns a copy of the stored router configuration, which can be modified safely
+func copyRouters(name string) (*v2.RouterConfiguration, bool) {
+	rw := router.GetRoutersMangerInstance().GetRouterWrapperByName(name)
+	if rw == nil {
+		return nil, false
+	}
+	cfg := rw.GetRoutersConfig()
+	vhs := make([]v2.VirtualHost, len(cfg.VirtualHosts))
+	copy(vhs, cfg.VirtualHosts)
+	cfg.VirtualHosts = vhs
+	return &cfg, true
+}
+
+// VirtualHostConfigData is the request body of the virtual host api
+type VirtualHostConfigData struct {
+	RouterConfigName string         `json:"router_config_name"`
+	VirtualHost      v2.VirtualHost `json:"virtual_host"`
+}
+
+// VirtualHostConfig adds, updates or deletes a virtual host in a router configuration.
+// POST a virtual host config data to add or update the virtual host with the same name.
+// DELETE /api/v1/virtual_hosts?router=router1&name=vh1 to delete the virtual host.
+func VirtualHostConfig(w http.ResponseWriter, r *http.Request) {
+	const api = "virtual host config"
+	switch r.Method {
+	case http.MethodPost:
+		data := VirtualHostConfigData{}
+		if err := readConfig(r, &data); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		vh := data.VirtualHost
+		if vh.Name == "" {
+			writeAPIError(w, api, http.StatusBadRequest, errors.New("virtual host name is required"))
+			return
+		}
+		configWriteMux.Lock()
+		defer configWriteMux.Unlock()
+		cfg, ok := copyRouters(data.RouterConfigName)
+		if !ok {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("router %s is not exists", data.RouterConfigName))
+			return
+		}
+		found := false
+		for i := range cfg.VirtualHosts {
+			if cfg.VirtualHosts[i].Name == vh.Name {
+				cfg.VirtualHosts[i] = vh
+				found = true
+				break
+			}
+		}
+		if !found {
+			cfg.VirtualHosts = append(cfg.VirtualHosts, vh)
+		}
+		if err := updateRouters(cfg); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		recordVersion(r, configmanager.CfgTypeRouter, data.RouterConfigName)
+		writeSuccess(w, api, fmt.Sprintf("add or update router %s virtual host: %s", data.RouterConfigName, vh.Name))
+	case http.MethodDelete:
+		query := r.URL.Query()
+		routerName, name := query.Get("router"), query.Get("name")
+		configWriteMux.Lock()
+		defer configWriteMux.Unlock()
+		cfg, ok := copyRouters(routerName)
+		if !ok {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("router %s is not exists", routerName))
+			return
+		}
+		vhs := cfg.VirtualHosts[:0]
+		for _, vh := range cfg.VirtualHosts {
+			if vh.Name != name {
+				vhs = append(vhs, vh)
+			}
+		}
+		if len(vhs) == len(cfg.VirtualHosts) {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("virtual host %s is not exists", name))
+			return
+		}
+		cfg.VirtualHosts = vhs
+		if err := updateRouters(cfg); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		recordVersion(r, configmanager.CfgTypeRouter, routerName)
+		writeSuccess(w, api, fmt.Sprintf("delete router %s virtual host: %s", routerName, name))
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// RouteConfigData is the request body of the route api
+type RouteConfigData struct {
+	RouterConfigName string    `json:"router_config_name"`
+	Domain           string    `json:"domain"`
+	Route            v2.Router `json:"route"`
+}
+
+// RouteConfig adds or deletes routes in the virtual host matched the domain.
+// POST a route config data to append the route.
+// DELETE /api/v1/routes?router=router1&domain=www.example.com to delete all the routes of the virtual host,
+// or with a name=route1 to delete the routes with the name only.
+func RouteConfig(w http.ResponseWriter, r *http.Request) {
+	const api = "route config"
+	rm := router.GetRoutersMangerInstance()
+	switch r.Method {
+	case http.MethodPost:
+		data := RouteConfigData{}
+		if err := readConfig(r, &data); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		configWriteMux.Lock()
+		defer configWriteMux.Unlock()
+		if rm.GetRouterWrapperByName(data.RouterConfigName) == nil {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("router %s is not exists", data.RouterConfigName))
+			return
+		}
+		if _, err := router.NewRouteRuleImplBase(nil, &data.Route); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		if err := rm.AddRoute(data.RouterConfigName, data.Domain, &data.Route); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		recordVersion(r, configmanager.CfgTypeRouter, data.RouterConfigName)
+		writeSuccess(w, api, fmt.Sprintf("add route into router %s domain: %s", data.RouterConfigName, data.Domain))
+	case http.MethodDelete:
+		query := r.URL.Query()
+		routerName, domain, name := query.Get("router"), query.Get("domain"), query.Get("name")
+		configWriteMux.Lock()
+		defer configWriteMux.Unlock()
+		if name == "" {
+			if rm.GetRouterWrapperByName(routerName) == nil {
+				writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("router %s is not exists", routerName))
+				return
+			}
+			if err := rm.RemoveAllRoutes(routerName, domain); err != nil {
+				writeAPIError(w, api, http.StatusNotFound, err)
+				return
+			}
+			recordVersion(r, configmanager.CfgTypeRouter, routerName)
+			writeSuccess(w, api, fmt.Sprintf("delete all routes in router %s domain: %s", routerName, domain))
+			return
+		}
+		cfg, ok := copyRouters(routerName)
+		if !ok {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("router %s is not exists", routerName))
+			return
+		}
+		removed := 0
+		for i := range cfg.VirtualHosts {
+			vh := &cfg.VirtualHosts[i]
+			if !containsDomain(vh.Domains, domain) {
+				continue
+			}
+			routes := make([]v2.Router, 0, len(vh.Routers))
+			for _, rt := range vh.Routers {
+				if rt.Name != name {
+					routes = append(routes, rt)
+				}
+			}
+			removed += len(vh.Routers) - len(routes)
+			vh.Routers = routes
+		}
+		if removed == 0 {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("route %s is not exists in domain %s", name, domain))
+			return
+		}
+		if err := updateRouters(cfg); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		recordVersion(r, configmanager.CfgTypeRouter, routerName)
+		writeSuccess(w, api, fmt.Sprintf("delete route %s in router %s domain: %s", name, routerName, domain))
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func containsDomain(domains []string, domain string) bool {
+	for _, d := range domains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// ListenerConfig adds, updates or deletes listeners.
+// POST a listener config to add or update the listener, the new listener is started.
+// DELETE /api/v1/listeners?name=listener1 to close and delete the listener.
+// The server can be specified by server=name, the default server is used if it is empty.
+func ListenerConfig(w http.ResponseWriter, r *http.Request) {
+	const api = "listener config"
+	adapter := server.GetListenerAdapterInstance()
+	if adapter == nil {
+		writeAPIError(w, api, http.StatusServiceUnavailable, errListenerAdapterNotReady)
+		return
+	}
+	serverName := r.URL.Query().Get("server")
+	switch r.Method {
+	case http.MethodPost:
+		cfg := &v2.Listener{}
+		if err := readConfig(r, cfg); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		if cfg.Addr == nil {
+			writeAPIError(w, api, http.StatusBadRequest, errors.New("listener address is required"))
+			return
+		}
+		if err := adapter.AddOrUpdateListener(serverName, cfg); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		recordVersion(r, configmanager.CfgTypeListener, cfg.Name)
+		writeSuccess(w, api, "add or update listener: "+cfg.Name)
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if adapter.FindListenerByName(serverName, name) == nil {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("listener %s is not exists", name))
+			return
+		}
+		if err := adapter.DeleteListener(serverName, name); err != nil {
+			writeAPIError(w, api, http.StatusInternalServerError, err)
+			return
+		}
+		writeSuccess(w, api, "delete listener: "+name)
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
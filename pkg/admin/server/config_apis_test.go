@@ -0,0 +1,402 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/runtime"
+	"mosn.io/mosn/pkg/server"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+const testWriteToken = "test-token"
+
+func doWriteRequest(handler http.HandlerFunc, method, url, token, body string) int {
+	r := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	NewAPIHandler(handler, WriteAuth).ServeHTTP(w, r)
+	return w.Result().StatusCode
+}
+
+func TestWriteAuth(t *testing.T) {
+	defer SetWriteToken("")
+	// disabled without token
+	SetWriteToken("")
+	if code := doWriteRequest(RouterConfig, "POST", "http://127.0.0.1/api/v1/routers", testWriteToken, "{}"); code != http.StatusUnauthorized {
+		t.Fatalf("write api should be disabled, but got %d", code)
+	}
+	SetWriteToken(testWriteToken)
+	if code := doWriteRequest(RouterConfig, "POST", "http://127.0.0.1/api/v1/routers", "", "{}"); code != http.StatusUnauthorized {
+		t.Fatalf("request without token should be rejected, but got %d", code)
+	}
+	if code := doWriteRequest(RouterConfig, "POST", "http://127.0.0.1/api/v1/routers", "invalid", "{}"); code != http.StatusUnauthorized {
+		t.Fatalf("request with invalid token should be rejected, but got %d", code)
+	}
+	if code := doWriteRequest(RouterConfig, "GET", "http://127.0.0.1/api/v1/routers", testWriteToken, ""); code != http.StatusMethodNotAllowed {
+		t.Fatalf("request with valid token should be passed, but got %d", code)
+	}
+}
+
+func TestClusterConfigAPI(t *testing.T) {
+	SetWriteToken(testWriteToken)
+	defer SetWriteToken("")
+	configmanager.Reset()
+	cluster.NewClusterManagerSingleton(nil, nil, nil)
+	cm := cluster.GetClusterMngAdapterInstance()
+	for _, tc := range []struct {
+		handler  http.HandlerFunc
+		method   string
+		url      string
+		body     string
+		expected int
+	}{
+		{ClusterConfig, "POST", "/api/v1/clusters", "invalid", http.StatusBadRequest},
+		{ClusterConfig, "POST", "/api/v1/clusters", `{"type":"SIMPLE"}`, http.StatusBadRequest},
+		{ClusterConfig, "POST", "/api/v1/clusters", `{"name":"admin_cluster","type":"SIMPLE","lb_type":"LB_RANDOM","hosts":[{"address":"127.0.0.1:8080"}]}`, http.StatusOK},
+		{ClusterHostsConfig, "POST", "/api/v1/cluster/hosts", `{"cluster_name":"unknown","hosts":[{"address":"127.0.0.1:8081"}]}`, http.StatusNotFound},
+		{ClusterHostsConfig, "POST", "/api/v1/cluster/hosts", `{"cluster_name":"admin_cluster","hosts":[{"address":"127.0.0.1:8081"}]}`, http.StatusOK},
+		{ClusterHostsConfig, "DELETE", "/api/v1/cluster/hosts?cluster=admin_cluster&address=127.0.0.1:8080", "", http.StatusOK},
+		{ClusterHostsConfig, "GET", "/api/v1/cluster/hosts", "", http.StatusMethodNotAllowed},
+	} {
+		if code := doWriteRequest(tc.handler, tc.method, "http://127.0.0.1"+tc.url, testWriteToken, tc.body); code != tc.expected {
+			t.Fatalf("%s %s %s response status code is %d, wanna: %d", tc.method, tc.url, tc.body, code, tc.expected)
+		}
+	}
+	if !cm.ClusterExist("admin_cluster") {
+		t.Fatal("cluster should be added")
+	}
+	configmanager.HandleMOSNConfig(configmanager.CfgTypeCluster, func(v interface{}) {
+		c := v.(map[string]v2.Cluster)["admin_cluster"]
+		if len(c.Hosts) != 1 || c.Hosts[0].Address != "127.0.0.1:8081" {
+			t.Fatalf("effective config hosts is not expected: %+v", c.Hosts)
+		}
+	})
+	// the updates are recorded with the admin version
+	if code := doWriteRequest(withAdminVersion(ClusterHostsConfig), "POST", "http://127.0.0.1/api/v1/cluster/hosts?version=v1", testWriteToken,
+		`{"cluster_name":"admin_cluster","hosts":[{"address":"127.0.0.1:8082"}]}`); code != http.StatusOK {
+		t.Fatalf("update hosts with version response status code is %d", code)
+	}
+	if v, _ := configmanager.GetResourceVersion(configmanager.CfgTypeCluster, "admin_cluster"); v.Source != configmanager.SourceAdmin || v.VersionInfo != "v1" {
+		t.Fatalf("cluster version is not expected: %+v", v)
+	}
+	if code := doWriteRequest(ClusterConfig, "DELETE", "http://127.0.0.1/api/v1/clusters?name=admin_cluster", testWriteToken, ""); code != http.StatusOK {
+		t.Fatalf("delete cluster response status code is %d", code)
+	}
+	if cm.ClusterExist("admin_cluster") {
+		t.Fatal("cluster should be deleted")
+	}
+	if code := doWriteRequest(ClusterConfig, "DELETE", "http://127.0.0.1/api/v1/clusters?name=admin_cluster", testWriteToken, ""); code != http.StatusNotFound {
+		t.Fatalf("delete cluster twice response status code is %d", code)
+	}
+}
+
+func TestRouteConfigAPI(t *testing.T) {
+	SetWriteToken(testWriteToken)
+	defer SetWriteToken("")
+	configmanager.Reset()
+	routerConfig := `{
+		"router_config_name":"admin_router",
+		"virtual_hosts":[{
+			"name":"vh1",
+			"domains":["www.example.com"],
+			"routers":[{"name":"r1","match":{"prefix":"/"},"route":{"cluster_name":"c1"}}]
+		}]
+	}`
+	for _, tc := range []struct {
+		handler  http.HandlerFunc
+		method   string
+		url      string
+		body     string
+		expected int
+	}{
+		{RouterConfig, "POST", "/api/v1/routers", `{"virtual_hosts":[]}`, http.StatusBadRequest},
+		{RouterConfig, "POST", "/api/v1/routers", routerConfig, http.StatusOK},
+		{VirtualHostConfig, "POST", "/api/v1/virtual_hosts", `{"router_config_name":"unknown","virtual_host":{"name":"vh2"}}`, http.StatusNotFound},
+		{VirtualHostConfig, "POST", "/api/v1/virtual_hosts", `{"router_config_name":"admin_router","virtual_host":{"domains":["*"]}}`, http.StatusBadRequest},
+		{VirtualHostConfig, "POST", "/api/v1/virtual_hosts", `{"router_config_name":"admin_router","virtual_host":{"name":"vh2","domains":["*"]}}`, http.StatusOK},
+		{RouteConfig, "POST", "/api/v1/routes", `{"router_config_name":"admin_router","domain":"www.example.com","route":{"name":"r2","match":{"prefix":"/test"},"route":{"cluster_name":"c2"}}}`, http.StatusOK},
+		{RouteConfig, "DELETE", "/api/v1/routes?router=admin_router&domain=www.example.com&name=r1", "", http.StatusOK},
+		{RouteConfig, "DELETE", "/api/v1/routes?router=admin_router&domain=www.example.com&name=r1", "", http.StatusNotFound},
+		{VirtualHostConfig, "DELETE", "/api/v1/virtual_hosts?router=admin_router&name=vh2", "", http.StatusOK},
+		{VirtualHostConfig, "DELETE", "/api/v1/virtual_hosts?router=admin_router&name=vh2", "", http.StatusNotFound},
+	} {
+		if code := doWriteRequest(tc.handler, tc.method, "http://127.0.0.1"+tc.url, testWriteToken, tc.body); code != tc.expected {
+			t.Fatalf("%s %s %s response status code is %d, wanna: %d", tc.method, tc.url, tc.body, code, tc.expected)
+		}
+	}
+	rw := router.GetRoutersMangerInstance().GetRouterWrapperByName("admin_router")
+	cfg := rw.GetRoutersConfig()
+	if len(cfg.VirtualHosts) != 1 || len(cfg.VirtualHosts[0].Routers) != 1 || cfg.VirtualHosts[0].Routers[0].Name != "r2" {
+		t.Fatalf("router config is not expected: %+v", cfg.VirtualHosts)
+	}
+	configmanager.HandleMOSNConfig(configmanager.CfgTypeRouter, func(v interface{}) {
+		stored := v.(map[string]v2.RouterConfiguration)["admin_router"]
+		if len(stored.VirtualHosts) != 1 || len(stored.VirtualHosts[0].Routers) != 1 {
+			t.Fatalf("effective router config is not expected: %+v", stored.VirtualHosts)
+		}
+	})
+}
+
+func TestListenerConfigAPI(t *testing.T) {
+	SetWriteToken(testWriteToken)
+	defer SetWriteToken("")
+	// no listener adapter in test
+	if code := doWriteRequest(ListenerConfig, "POST", "http://127.0.0.1/api/v1/listeners", testWriteToken, "{}"); code != http.StatusServiceUnavailable {
+		t.Fatalf("listener api response status code is %d", code)
+	}
+}
+
+func TestDrainAPI(t *testing.T) {
+	SetWriteToken(testWriteToken)
+	defer SetWriteToken("")
+	// no listener adapter in test
+	if code := doWriteRequest(Drain, "POST", "http://127.0.0.1/api/v1/drain", testWriteToken, `{"mode":"goaway"}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("drain api response status code is %d", code)
+	}
+	cfg := DrainConfig{}
+	if err := json.Unmarshal([]byte(`{"mode":"goaway","strategy":"gradual","window":"30s","timeout":"1m","listeners":["l1"]}`), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	opts := cfg.options()
+	if opts.Mode != server.DrainGoAway || opts.Strategy != server.DrainGradual ||
+		opts.Window != 30*time.Second || opts.Timeout != time.Minute || len(opts.Listeners) != 1 {
+		t.Fatalf("unexpected drain options: %+v", opts)
+	}
+}
+
+func TestHostStateAPI(t *testing.T) {
+	SetWriteToken(testWriteToken)
+	defer SetWriteToken("")
+	configmanager.Reset()
+	cluster.NewClusterManagerSingleton(nil, nil, nil)
+	cm := cluster.GetClusterMngAdapterInstance()
+	if err := cm.AddOrUpdateClusterAndHost(v2.Cluster{
+		Name:   "state_cluster",
+		LbType: v2.LB_RANDOM,
+	}, []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:9081"}},
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:9080"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		body     string
+		expected int
+	}{
+		{`{"cluster_name":"state_cluster","address":"127.0.0.1:9080"}`, http.StatusBadRequest},
+		{`{"cluster_name":"state_cluster","action":"eject"}`, http.StatusBadRequest},
+		{`{"cluster_name":"unknown","address":"127.0.0.1:9080","action":"eject"}`, http.StatusNotFound},
+		{`{"cluster_name":"state_cluster","address":"127.0.0.1:9082","action":"eject"}`, http.StatusNotFound},
+		{`{"cluster_name":"state_cluster","address":"127.0.0.1:9080","action":"eject"}`, http.StatusOK},
+		{`{"cluster_name":"state_cluster","address":"127.0.0.1:9081","action":"drain"}`, http.StatusOK},
+	} {
+		if code := doWriteRequest(HostState, "POST", "http://127.0.0.1/api/v1/cluster/host_state", testWriteToken, tc.body); code != tc.expected {
+			t.Fatalf("%s response status code is %d, wanna: %d", tc.body, code, tc.expected)
+		}
+	}
+	dumpStatus := func(url string) (int, []ClusterStatus) {
+		r := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		ClusterStatusDump(w, r)
+		var result []ClusterStatus
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, result
+	}
+	code, result := dumpStatus("http://127.0.0.1/api/v1/cluster_status?name=state_cluster")
+	if code != http.StatusOK || len(result) != 1 || len(result[0].Hosts) != 2 {
+		t.Fatalf("cluster status is not expected, code: %d, result: %+v", code, result)
+	}
+	hosts := result[0].Hosts
+	// the hosts are sorted by address
+	if hosts[0].Address != "127.0.0.1:9080" || hosts[0].Healthy ||
+		len(hosts[0].HealthFlags) != 1 || hosts[0].HealthFlags[0] != "admin_ejected" {
+		t.Fatalf("ejected host status is not expected: %+v", hosts[0])
+	}
+	if hosts[1].Healthy || len(hosts[1].HealthFlags) != 1 || hosts[1].HealthFlags[0] != "admin_draining" {
+		t.Fatalf("draining host status is not expected: %+v", hosts[1])
+	}
+	for _, addr := range []string{"127.0.0.1:9080", "127.0.0.1:9081"} {
+		body := `{"cluster_name":"state_cluster","address":"` + addr + `","action":"restore"}`
+		if code := doWriteRequest(HostState, "POST", "http://127.0.0.1/api/v1/cluster/host_state", testWriteToken, body); code != http.StatusOK {
+			t.Fatalf("restore host response status code is %d", code)
+		}
+	}
+	if _, result := dumpStatus("http://127.0.0.1/api/v1/cluster_status"); len(result) != 1 || !result[0].Hosts[0].Healthy || !result[0].Hosts[1].Healthy {
+		t.Fatalf("hosts should be restored: %+v", result)
+	}
+	if code, _ := dumpStatus("http://127.0.0.1/api/v1/cluster_status?name=unknown"); code != http.StatusNotFound {
+		t.Fatalf("unknown cluster status code is %d", code)
+	}
+}
+
+func TestRouteMatchAPI(t *testing.T) {
+	configmanager.Reset()
+	cluster.NewClusterManagerSingleton(nil, nil, nil)
+	if err := cluster.GetClusterMngAdapterInstance().AddOrUpdateClusterAndHost(v2.Cluster{
+		Name:   "match_cluster",
+		LbType: v2.LB_RANDOM,
+	}, []v2.Host{
+		{HostConfig: v2.HostConfig{Address: "127.0.0.1:9090"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	newRouter := func(name, cluster string, match v2.RouterMatch) v2.Router {
+		return v2.Router{
+			RouterConfig: v2.RouterConfig{
+				Name:  name,
+				Match: match,
+				Route: v2.RouteAction{
+					RouterActionConfig: v2.RouterActionConfig{
+						ClusterName: cluster,
+					},
+				},
+			},
+		}
+	}
+	if err := router.GetRoutersMangerInstance().AddOrUpdateRouters(&v2.RouterConfiguration{
+		RouterConfigurationConfig: v2.RouterConfigurationConfig{
+			RouterConfigName: "match_router",
+		},
+		VirtualHosts: []v2.VirtualHost{
+			{
+				Name:    "vh",
+				Domains: []string{"*"},
+				Routers: []v2.Router{
+					newRouter("r1", "unknown_cluster", v2.RouterMatch{Prefix: "/unknown"}),
+					newRouter("r2", "match_cluster", v2.RouterMatch{Prefix: "/"}),
+				},
+			},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	configmanager.SetListenerConfig(v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			Name: "match_listener",
+			FilterChains: []v2.FilterChain{
+				{
+					FilterChainConfig: v2.FilterChainConfig{
+						Filters: []v2.Filter{
+							{
+								Type:   v2.DEFAULT_NETWORK_FILTER,
+								Config: map[string]interface{}{"router_config_name": "match_router"},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	doMatch := func(body string) (int, *RouteMatchResult) {
+		r := httptest.NewRequest("POST", "http://127.0.0.1/api/v1/route_match", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		RouteMatch(w, r)
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		result := &RouteMatchResult{}
+		if err := json.Unmarshal(w.Body.Bytes(), result); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, result
+	}
+	for _, tc := range []struct {
+		body     string
+		expected int
+	}{
+		{"invalid", http.StatusBadRequest},
+		{`{"path":"/"}`, http.StatusBadRequest},
+		{`{"listener":"unknown","path":"/"}`, http.StatusNotFound},
+		{`{"router_config_name":"unknown","path":"/"}`, http.StatusNotFound},
+	} {
+		if code, _ := doMatch(tc.body); code != tc.expected {
+			t.Fatalf("%s response status code is %d, wanna: %d", tc.body, code, tc.expected)
+		}
+	}
+	code, result := doMatch(`{"listener":"match_listener","host":"www.example.com","method":"GET","path":"/test"}`)
+	if code != http.StatusOK || !result.Matched || result.RouterConfigName != "match_router" {
+		t.Fatalf("route match result is not expected, code: %d, result: %+v", code, result)
+	}
+	if result.Match.VirtualHost != "vh" || len(result.Match.Routes) != 2 ||
+		result.Match.Routes[0].Matched || result.Match.Routes[0].Reason == "" || !result.Match.Routes[1].Matched {
+		t.Fatalf("route match explanation is not expected: %+v", result.Match)
+	}
+	if result.Cluster != "match_cluster" || result.Host != "127.0.0.1:9090" {
+		t.Fatalf("route match upstream is not expected: %+v", result)
+	}
+	// the matched cluster is not exists
+	code, result = doMatch(`{"router_config_name":"match_router","path":"/unknown"}`)
+	if code != http.StatusOK || result.Cluster != "unknown_cluster" || result.Host != "" || result.HostReason == "" {
+		t.Fatalf("route match result is not expected, code: %d, result: %+v", code, result)
+	}
+}
+
+func TestRuntimeAPI(t *testing.T) {
+	SetWriteToken(testWriteToken)
+	defer SetWriteToken("")
+	runtime.Reset()
+	defer runtime.Reset()
+	if err := runtime.SetLayer(runtime.LayerStatic, map[string]string{"fault_inject.abort.percent": "10"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		method   string
+		url      string
+		body     string
+		expected int
+	}{
+		{"POST", "/api/v1/runtime/update", "invalid", http.StatusBadRequest},
+		{"POST", "/api/v1/runtime/update", `{}`, http.StatusBadRequest},
+		{"POST", "/api/v1/runtime/update", `{"a":{"b":1}}`, http.StatusBadRequest},
+		{"POST", "/api/v1/runtime/update", `{"invalid key":"1"}`, http.StatusBadRequest},
+		{"POST", "/api/v1/runtime/update", `{"fault_inject.abort.percent":50,"fault_inject.abort.status":"503"}`, http.StatusOK},
+		{"DELETE", "/api/v1/runtime/update", "", http.StatusBadRequest},
+		{"DELETE", "/api/v1/runtime/update?key=fault_inject.abort.status", "", http.StatusOK},
+		{"GET", "/api/v1/runtime/update", "", http.StatusMethodNotAllowed},
+	} {
+		if code := doWriteRequest(RuntimeUpdate, tc.method, "http://127.0.0.1"+tc.url, testWriteToken, tc.body); code != tc.expected {
+			t.Fatalf("%s %s %s response status code is %d, wanna: %d", tc.method, tc.url, tc.body, code, tc.expected)
+		}
+	}
+	r := httptest.NewRequest("GET", "http://127.0.0.1/api/v1/runtime?prefix=fault_inject.", nil)
+	w := httptest.NewRecorder()
+	RuntimeDump(w, r)
+	var entries []runtime.Entry
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Value != "50" || entries[0].Layer != runtime.LayerAdmin ||
+		entries[0].Overridden[runtime.LayerStatic] != "10" {
+		t.Fatalf("runtime dump is not expected: %+v", entries)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/server"
+	"mosn.io/mosn/pkg/types"
+)
+
+// DrainConfig is the request body of the drain api
+type DrainConfig struct {
+	Mode      string              `json:"mode"`
+	Strategy  string              `json:"strategy,omitempty"`
+	Window    *api.DurationConfig `json:"window,omitempty"`
+	Timeout   *api.DurationConfig `json:"timeout,omitempty"`
+	Listeners []string            `json:"listeners,omitempty"`
+}
+
+func (cfg *DrainConfig) options() server.DrainOptions {
+	opts := server.DrainOptions{
+		Mode:      server.DrainMode(cfg.Mode),
+		Strategy:  server.DrainStrategy(cfg.Strategy),
+		Listeners: cfg.Listeners,
+	}
+	if cfg.Window != nil {
+		opts.Window = cfg.Window.Duration
+	}
+	if cfg.Timeout != nil {
+		opts.Timeout = cfg.Timeout.Duration
+	}
+	return opts
+}
+
+// Drain starts to drain the downstream connections, POST a drain config.
+// The listeners stop accepting new connections, and the existing connections are asked
+// to go away in goaway mode, immediately or gradually over the window.
+// The server can be specified by server=name, the default server is used if it is empty.
+func Drain(w http.ResponseWriter, r *http.Request) {
+	const api = "drain"
+	adapter := server.GetListenerAdapterInstance()
+	if adapter == nil {
+		writeAPIError(w, api, http.StatusServiceUnavailable, errListenerAdapterNotReady)
+		return
+	}
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := DrainConfig{}
+	if err := readConfig(r, &cfg); err != nil {
+		writeAPIError(w, api, http.StatusBadRequest, err)
+		return
+	}
+	if err := adapter.Drain(r.URL.Query().Get("server"), cfg.options()); err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, server.ErrDrainInProgress):
+			status = http.StatusConflict
+		case errors.Is(err, server.ErrListenerNotFound):
+			status = http.StatusNotFound
+		}
+		writeAPIError(w, api, status, err)
+		return
+	}
+	writeSuccess(w, api, fmt.Sprintf("start drain, mode: %s, strategy: %s, listeners: %v", cfg.Mode, cfg.Strategy, cfg.Listeners))
+}
+
+// DrainStatusDump dumps the progress of the last drain
+func DrainStatusDump(w http.ResponseWriter, r *http.Request) {
+	const api = "drain status"
+	adapter := server.GetListenerAdapterInstance()
+	if adapter == nil {
+		writeAPIError(w, api, http.StatusServiceUnavailable, errListenerAdapterNotReady)
+		return
+	}
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	status, err := adapter.DrainStatus(r.URL.Query().Get("server"))
+	if err != nil {
+		writeAPIError(w, api, http.StatusNotFound, err)
+		return
+	}
+	data, _ := json.MarshalIndent(status, "", " ")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
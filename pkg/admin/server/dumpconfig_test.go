@@ -20,7 +20,9 @@ package server
 import (
 	"errors"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"mosn.io/mosn/pkg/config/v2"
@@ -172,3 +174,80 @@ func TestDumpConfigWithParameters(t *testing.T) {
 		}
 	}
 }
+
+func TestDumpConfigResources(t *testing.T) {
+	initStoreConfig()
+	defer SetWriteToken("")
+	SetWriteToken(testWriteToken)
+	configmanager.SetClusterConfig(v2.Cluster{
+		Name: "tls_cluster",
+		TLS: v2.TLSConfig{
+			Status:     true,
+			PrivateKey: "test_private_key",
+		},
+	})
+	configmanager.SetResourceVersion(configmanager.CfgTypeCluster, "tls_cluster", configmanager.ResourceVersion{Source: configmanager.SourceXds, VersionInfo: "v1", Nonce: "n1"})
+	dump := func(url, token string) (int, []byte) {
+		r := httptest.NewRequest("GET", url, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		ConfigDump(w, r)
+		resp := w.Result()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("read response error: %v", err)
+		}
+		return resp.StatusCode, b
+	}
+	// the secrets are redacted by default
+	for _, url := range []string{
+		"http://127.0.0.1/api/v1/config_dump",
+		"http://127.0.0.1/api/v1/config_dump?allclusters",
+		"http://127.0.0.1/api/v1/config_dump?cluster=tls_cluster",
+		"http://127.0.0.1/api/v1/config_dump?type=clusters",
+	} {
+		code, b := dump(url, "")
+		if code != http.StatusOK || strings.Contains(string(b), "test_private_key") ||
+			!strings.Contains(string(b), configmanager.RedactedValue) {
+			t.Fatalf("%s secrets are not redacted, code: %d, body: %s", url, code, string(b))
+		}
+	}
+	// include_secrets requires the write token
+	url := "http://127.0.0.1/api/v1/config_dump?type=cluster&include_secrets=true"
+	if code, _ := dump(url, ""); code != http.StatusUnauthorized {
+		t.Fatalf("include secrets without token should be rejected, but got %d", code)
+	}
+	if code, b := dump(url, testWriteToken); code != http.StatusOK || !strings.Contains(string(b), "test_private_key") {
+		t.Fatalf("secrets should be included, code: %d, body: %s", code, string(b))
+	}
+	// select resources by type and name
+	code, b := dump("http://127.0.0.1/api/v1/config_dump?type=cluster,listener&name=tls_cluster&name=test_listener", "")
+	if code != http.StatusOK {
+		t.Fatalf("response is not 200, got:%d", code)
+	}
+	result := configmanager.ResourcesDump{}
+	if err := json.Unmarshal(b, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.MosnConfig != nil || len(result.Routers) != 0 || len(result.Clusters) != 1 || len(result.Listeners) != 1 {
+		t.Fatalf("result is not expected: %s", string(b))
+	}
+	if v := result.Clusters["tls_cluster"].Version; v.Source != configmanager.SourceXds || v.VersionInfo != "v1" || v.Nonce != "n1" {
+		t.Fatalf("cluster version is not expected: %+v", v)
+	}
+	// invalid queries
+	for _, tc := range []struct {
+		url      string
+		expected int
+	}{
+		{"http://127.0.0.1/api/v1/config_dump?type=unknown", http.StatusBadRequest},
+		{"http://127.0.0.1/api/v1/config_dump?type=cluster&allclusters", http.StatusBadRequest},
+		{"http://127.0.0.1/api/v1/config_dump?name=unknown", http.StatusNotFound},
+	} {
+		if code, _ := dump(tc.url, ""); code != tc.expected {
+			t.Fatalf("%s response status code is %d, wanna: %d", tc.url, code, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/mosn/pkg/variable"
+)
+
+// RouteMatchRequest is a synthetic request to be evaluated by the route match api.
+// The router config is found by the listener's proxy filter if RouterConfigName is empty.
+type RouteMatchRequest struct {
+	Listener         string            `json:"listener,omitempty"`
+	RouterConfigName string            `json:"router_config_name,omitempty"`
+	Protocol         string            `json:"protocol,omitempty"`
+	Host             string            `json:"host,omitempty"`
+	Method           string            `json:"method,omitempty"`
+	Path             string            `json:"path,omitempty"`
+	Query            string            `json:"query,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	Variables        map[string]string `json:"variables,omitempty"`
+}
+
+// RouteMatchDirectResponse is the direct response of the matched route
+type RouteMatchDirectResponse struct {
+	StatusCode int    `json:"status_code"`
+	Body       string `json:"body,omitempty"`
+}
+
+// RouteMatchRedirect is the redirect of the matched route
+type RouteMatchRedirect struct {
+	Code   int    `json:"code"`
+	Scheme string `json:"scheme,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// RouteMatchResult is the response of the route match api
+type RouteMatchResult struct {
+	RouterConfigName string                    `json:"router_config_name"`
+	Match            *router.MatchExplanation  `json:"match"`
+	Matched          bool                      `json:"matched"`
+	DirectResponse   *RouteMatchDirectResponse `json:"direct_response,omitempty"`
+	Redirect         *RouteMatchRedirect       `json:"redirect,omitempty"`
+	// Cluster is picked randomly if the matched route has weighted clusters,
+	// a real request may be routed to another cluster.
+	Cluster string            `json:"cluster,omitempty"`
+	Subset  map[string]string `json:"subset,omitempty"`
+	Host    string            `json:"host,omitempty"`
+	// HostReason explains why no host is picked
+	HostReason string `json:"host_reason,omitempty"`
+}
+
+var errRouterNotFound = errors.New("router config not found")
+
+// routerConfigNameOfListener returns the router config name of the listener's proxy filter
+func routerConfigNameOfListener(listenerName string) string {
+	name := ""
+	configmanager.HandleMOSNConfig(configmanager.CfgTypeListener, func(v interface{}) {
+		ln, ok := v.(map[string]v2.Listener)[listenerName]
+		if !ok {
+			return
+		}
+		for _, fc := range ln.FilterChains {
+			for _, f := range fc.Filters {
+				if f.Type != v2.DEFAULT_NETWORK_FILTER {
+					continue
+				}
+				if s, ok := f.Config["router_config_name"].(string); ok {
+					name = s
+					return
+				}
+			}
+		}
+	})
+	return name
+}
+
+func (req *RouteMatchRequest) context() (context.Context, error) {
+	ctx := variable.NewVariableContext(context.Background())
+	for key, value := range map[string]string{
+		types.VarHost:         req.Host,
+		types.VarPath:         req.Path,
+		types.VarPathOriginal: req.Path,
+		types.VarQueryString:  req.Query,
+		types.VarMethod:       req.Method,
+	} {
+		if value == "" {
+			continue
+		}
+		if err := variable.SetString(ctx, key, value); err != nil {
+			return nil, fmt.Errorf("set variable %s failed: %v", key, err)
+		}
+	}
+	for key, value := range req.Variables {
+		if err := variable.SetString(ctx, key, value); err != nil {
+			return nil, fmt.Errorf("set variable %s failed: %v", key, err)
+		}
+	}
+	if req.Protocol != "" {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyDownStreamProtocol, api.ProtocolName(req.Protocol))
+	}
+	if req.Listener != "" {
+		ctx = mosnctx.WithValue(ctx, types.ContextKeyListenerName, req.Listener)
+	}
+	return ctx, nil
+}
+
+// routeMatchLbContext is the load balancer context of the synthetic request
+type routeMatchLbContext struct {
+	ctx      context.Context
+	headers  api.HeaderMap
+	route    api.Route
+	cluster  types.ClusterInfo
+	criteria api.MetadataMatchCriteria
+}
+
+func (lbctx *routeMatchLbContext) MetadataMatchCriteria() api.MetadataMatchCriteria {
+	return lbctx.criteria
+}
+
+// DownstreamConnection returns nil, there is no connection for the synthetic request
+func (lbctx *routeMatchLbContext) DownstreamConnection() net.Conn {
+	return nil
+}
+
+func (lbctx *routeMatchLbContext) DownstreamHeaders() api.HeaderMap {
+	return lbctx.headers
+}
+
+func (lbctx *routeMatchLbContext) DownstreamContext() context.Context {
+	return lbctx.ctx
+}
+
+func (lbctx *routeMatchLbContext) DownstreamCluster() types.ClusterInfo {
+	return lbctx.cluster
+}
+
+func (lbctx *routeMatchLbContext) DownstreamRoute() api.Route {
+	return lbctx.route
+}
+
+func (result *RouteMatchResult) setAction(ctx context.Context, headers api.HeaderMap, route api.Route) {
+	rule := route.RouteRule()
+	if resp := rule.DirectResponseRule(); !(resp == nil || reflect.ValueOf(resp).IsNil()) {
+		result.DirectResponse = &RouteMatchDirectResponse{
+			StatusCode: resp.StatusCode(),
+			Body:       resp.Body(),
+		}
+		return
+	}
+	if redirect := rule.RedirectRule(); redirect != nil {
+		result.Redirect = &RouteMatchRedirect{
+			Code:   redirect.RedirectCode(),
+			Scheme: redirect.RedirectScheme(),
+			Host:   redirect.RedirectHost(),
+			Path:   redirect.RedirectPath(),
+		}
+		return
+	}
+	result.Cluster = rule.ClusterName(ctx)
+	criteria := rule.MetadataMatchCriteria(result.Cluster)
+	if criteria != nil {
+		result.Subset = map[string]string{}
+		for _, kv := range criteria.MetadataMatchCriteria() {
+			result.Subset[kv.MetadataKeyName()] = kv.MetadataValue()
+		}
+	}
+	cm := cluster.GetClusterMngAdapterInstance()
+	if cm == nil || cm.ClusterManager == nil {
+		result.HostReason = errClusterManagerNotReady.Error()
+		return
+	}
+	snap := cm.GetClusterSnapshot(ctx, result.Cluster)
+	if snap == nil {
+		result.HostReason = fmt.Sprintf("cluster %s is not exists", result.Cluster)
+		return
+	}
+	host := snap.LoadBalancer().ChooseHost(&routeMatchLbContext{
+		ctx:      ctx,
+		headers:  headers,
+		route:    route,
+		cluster:  snap.ClusterInfo(),
+		criteria: criteria,
+	})
+	if host == nil {
+		result.HostReason = "no host is available"
+		return
+	}
+	result.Host = host.AddressString()
+}
+
+// RouteMatch evaluates a synthetic request against the routers without sending any traffic, POST a RouteMatchRequest.
+// It returns the selected virtual host and route, the reasons why the rules before it are skipped,
+// and the cluster, subset and host the load balancer picks.
+func RouteMatch(w http.ResponseWriter, r *http.Request) {
+	const api = "route match"
+	if r.Method != http.MethodPost {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	req := RouteMatchRequest{}
+	if err := readConfig(r, &req); err != nil {
+		writeAPIError(w, api, http.StatusBadRequest, err)
+		return
+	}
+	name := req.RouterConfigName
+	if name == "" {
+		if req.Listener == "" {
+			writeAPIError(w, api, http.StatusBadRequest, errors.New("listener or router_config_name is required"))
+			return
+		}
+		name = routerConfigNameOfListener(req.Listener)
+		if name == "" {
+			writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("%w for listener %s", errRouterNotFound, req.Listener))
+			return
+		}
+	}
+	wrapper := router.GetRoutersMangerInstance().GetRouterWrapperByName(name)
+	if wrapper == nil || wrapper.GetRouters() == nil {
+		writeAPIError(w, api, http.StatusNotFound, fmt.Errorf("%w: %s", errRouterNotFound, name))
+		return
+	}
+	ctx, err := req.context()
+	if err != nil {
+		writeAPIError(w, api, http.StatusBadRequest, err)
+		return
+	}
+	headers := protocol.CommonHeader{}
+	for key, value := range req.Headers {
+		headers.Set(key, value)
+	}
+	result := &RouteMatchResult{
+		RouterConfigName: name,
+		Match:            router.ExplainMatch(ctx, wrapper.GetRouters(), headers),
+	}
+	if result.Match.Route != nil {
+		result.Matched = true
+		result.setAction(ctx, headers, result.Match.Route)
+	}
+	data, _ := json.MarshalIndent(result, "", " ")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	rawjson "encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/runtime"
+	"mosn.io/mosn/pkg/types"
+)
+
+// RuntimeDump dumps the effective runtime values with their layers and the overridden values.
+// GET /api/v1/runtime?prefix=fault_inject. to dump the keys with the prefix.
+func RuntimeDump(w http.ResponseWriter, r *http.Request) {
+	const api = "runtime"
+	if r.Method != http.MethodGet {
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+	entries := []runtime.Entry{}
+	for _, entry := range runtime.Dump() {
+		if strings.HasPrefix(entry.Key, prefix) {
+			entries = append(entries, entry)
+		}
+	}
+	data, _ := json.MarshalIndent(entries, "", " ")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// RuntimeUpdate changes the admin layer of the runtime.
+// POST a json object of the keys and values to set them, and
+// DELETE /api/v1/runtime/update?key=a&key=b to unset the keys, the values of the lower layers take effect again.
+func RuntimeUpdate(w http.ResponseWriter, r *http.Request) {
+	const api = "runtime update"
+	switch r.Method {
+	case http.MethodPost:
+		raws := map[string]rawjson.RawMessage{}
+		if err := readConfig(r, &raws); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		values, err := runtime.ParseValues(raws)
+		if err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		if len(values) == 0 {
+			writeAPIError(w, api, http.StatusBadRequest, errors.New("no runtime values"))
+			return
+		}
+		if err := runtime.Set(values); err != nil {
+			writeAPIError(w, api, http.StatusBadRequest, err)
+			return
+		}
+		writeSuccess(w, api, fmt.Sprintf("set runtime values: %v", values))
+	case http.MethodDelete:
+		keys := splitQuery(r.URL.Query()["key"])
+		if len(keys) == 0 {
+			writeAPIError(w, api, http.StatusBadRequest, errors.New("key is required"))
+			return
+		}
+		runtime.Unset(keys...)
+		writeSuccess(w, api, fmt.Sprintf("unset runtime keys: %v", keys))
+	default:
+		log.DefaultLogger.Alertf(types.ErrorKeyAdmin, "api: %s, error: invalid method: %s", api, r.Method)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
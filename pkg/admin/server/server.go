@@ -53,19 +53,36 @@ func DeleteRegisteredAdminHandler(pattern string) {
 func init() {
 	// default admin api
 	apiHandlerStore = map[string]*APIHandler{
-		"/api/v1/version":         NewAPIHandler(OutputVersion),
-		"/api/v1/config_dump":     NewAPIHandler(ConfigDump),
-		"/api/v1/stats":           NewAPIHandler(StatsDump),
-		"/api/v1/stats_glob":      NewAPIHandler(StatsDumpProxyTotal),
-		"/api/v1/update_loglevel": NewAPIHandler(UpdateLogLevel),
-		"/api/v1/get_loglevel":    NewAPIHandler(GetLoggerInfo),
-		"/api/v1/enable_log":      NewAPIHandler(EnableLogger),
-		"/api/v1/disable_log":     NewAPIHandler(DisableLogger),
-		"/api/v1/states":          NewAPIHandler(GetState),
-		"/api/v1/plugin":          NewAPIHandler(PluginApi),
-		"/api/v1/features":        NewAPIHandler(KnownFeatures),
-		"/api/v1/env":             NewAPIHandler(GetEnv),
-		"/":                       NewAPIHandler(Help),
+		"/api/v1/version":            NewAPIHandler(OutputVersion),
+		"/api/v1/config_dump":        NewAPIHandler(ConfigDump),
+		"/api/v1/stats":              NewAPIHandler(StatsDump),
+		"/api/v1/stats_glob":         NewAPIHandler(StatsDumpProxyTotal),
+		"/api/v1/update_loglevel":    NewAPIHandler(UpdateLogLevel),
+		"/api/v1/get_loglevel":       NewAPIHandler(GetLoggerInfo),
+		"/api/v1/enable_log":         NewAPIHandler(EnableLogger),
+		"/api/v1/disable_log":        NewAPIHandler(DisableLogger),
+		"/api/v1/states":             NewAPIHandler(GetState),
+		"/api/v1/plugin":             NewAPIHandler(PluginApi),
+		"/api/v1/features":           NewAPIHandler(KnownFeatures),
+		"/api/v1/env":                NewAPIHandler(GetEnv),
+		"/api/v1/tap":                NewAPIHandler(TapRequests, WriteAuth),
+		"/api/v1/conn_tap":           NewAPIHandler(ConnTapStatus),
+		"/api/v1/conn_tap/start":     NewAPIHandler(ConnTapStart, WriteAuth),
+		"/api/v1/conn_tap/stop":      NewAPIHandler(ConnTapStop, WriteAuth),
+		"/api/v1/clusters":           NewAPIHandler(withAdminVersion(ClusterConfig), WriteAuth),
+		"/api/v1/cluster/hosts":      NewAPIHandler(withAdminVersion(ClusterHostsConfig), WriteAuth),
+		"/api/v1/cluster/host_state": NewAPIHandler(HostState, WriteAuth),
+		"/api/v1/cluster_status":     NewAPIHandler(ClusterStatusDump),
+		"/api/v1/routers":            NewAPIHandler(withAdminVersion(RouterConfig), WriteAuth),
+		"/api/v1/virtual_hosts":      NewAPIHandler(withAdminVersion(VirtualHostConfig), WriteAuth),
+		"/api/v1/routes":             NewAPIHandler(withAdminVersion(RouteConfig), WriteAuth),
+		"/api/v1/listeners":          NewAPIHandler(withAdminVersion(ListenerConfig), WriteAuth),
+		"/api/v1/drain":              NewAPIHandler(Drain, WriteAuth),
+		"/api/v1/drain_status":       NewAPIHandler(DrainStatusDump),
+		"/api/v1/route_match":        NewAPIHandler(RouteMatch),
+		"/api/v1/runtime":            NewAPIHandler(RuntimeDump),
+		"/api/v1/runtime/update":     NewAPIHandler(RuntimeUpdate, WriteAuth),
+		"/":                          NewAPIHandler(Help),
 	}
 }
 
@@ -84,6 +101,7 @@ func (s *Server) Start(config Config) {
 			return
 		}
 		addr = fmt.Sprintf("%s:%d", adminConfig.GetAddress(), adminConfig.GetPortValue())
+		SetWriteToken(adminConfig.WriteToken)
 	}
 
 	mux := http.NewServeMux()
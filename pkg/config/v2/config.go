@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 
 	"github.com/c2h5oh/datasize"
+	"mosn.io/api"
 )
 
 // MOSNConfig make up mosn to start the mosn project
@@ -69,6 +70,15 @@ type MetricsConfig struct {
 	ShmSize      datasize.ByteSize `json:"shm_size"`
 	FlushMosn    bool              `json:"flush_mosn"`
 	LazyFlush    bool              `json:"lazy_flush"`
+	// HistogramBuckets is the explicit bucket upper bounds of latency histograms
+	HistogramBuckets []api.DurationConfig `json:"histogram_buckets,omitempty"`
+	RouteStats       RouteStatsConfig     `json:"route_stats,omitempty"`
+}
+
+// RouteStatsConfig for the stats scoped by virtual host and route
+type RouteStatsConfig struct {
+	Enabled   bool `json:"enabled,omitempty"`
+	MaxScopes int  `json:"max_scopes,omitempty"` // limits the count of virtual host and route scopes
 }
 
 // PluginConfig for plugin config
@@ -143,6 +153,9 @@ func (c *MOSNConfig) Mode() Mode {
 
 type Admin struct {
 	Address *AddressInfo `json:"address,omitempty"`
+	// WriteToken is the bearer token required by the admin write apis,
+	// the write apis are disabled if it is empty
+	WriteToken string `json:"write_token,omitempty"`
 }
 
 func (admin *Admin) GetAddress() string {
@@ -86,6 +86,9 @@ const (
 	Transcoder                  = "transcoder"
 	GRPC_NETWORK_FILTER         = "grpc"
 	TUNNEL                      = "tunnel"
+	CONNECTION_LIMIT            = "connection_limit"
+	MQTT_PROXY                  = "mqtt_proxy"
+	KAFKA                       = "kafka"
 )
 
 // Stream Filter's Type
@@ -99,6 +102,7 @@ const (
 	DubboStream                = "dubbo_stream"
 	GoPluginStreamFilterSuffix = "so_plugin"
 	GrpcMetricFilter           = "grpc_metric"
+	GrpcWeb                    = "grpc_web"
 	IPAccess                   = "ip_access"
 )
 
@@ -157,6 +161,8 @@ type StreamFaultInject struct {
 	Abort           *AbortInject    `json:"abort,omitempty"`
 	UpstreamCluster string          `json:"upstream_cluster,omitempty"`
 	Headers         []HeaderMatcher `json:"headers,omitempty"`
+	// RuntimeKeyPrefix is the prefix of the runtime keys that override the config, default is fault_inject
+	RuntimeKeyPrefix string `json:"runtime_key_prefix,omitempty"`
 }
 
 type DelayInject struct {
@@ -42,6 +42,7 @@ type RouterConfigurationConfig struct {
 }
 
 type RouterConfig struct {
+	Name                  string                 `json:"name,omitempty"`
 	Match                 RouterMatch            `json:"match,omitempty"`
 	Route                 RouteAction            `json:"route,omitempty"`
 	Redirect              *RedirectAction        `json:"redirect,omitempty"`
@@ -70,6 +71,16 @@ type RouterActionConfig struct {
 	RequestHeadersToRemove  []string             `json:"request_headers_to_remove,omitempty"`
 	ResponseHeadersToAdd    []*HeaderValueOption `json:"response_headers_to_add,omitempty"`
 	ResponseHeadersToRemove []string             `json:"response_headers_to_remove,omitempty"`
+	UpgradeConfigs          []UpgradeConfig      `json:"upgrade_configs,omitempty"`
+}
+
+// UpgradeConfig enables an upgrade type for the route, such as websocket, or CONNECT
+// for the http CONNECT requests. The enabled requests are switched into the
+// raw bidirectional byte relay after the upstream accepts them.
+type UpgradeConfig struct {
+	UpgradeType string `json:"upgrade_type,omitempty"`
+	// IdleTimeout closes the tunnel if no data is relayed, zero means the default idle timeout
+	IdleTimeout api.DurationConfig `json:"idle_timeout,omitempty"`
 }
 
 type ClusterWeightConfig struct {
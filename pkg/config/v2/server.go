@@ -37,6 +37,8 @@ type ServerConfig struct {
 	UseNetpollMode bool `json:"use_netpoll_mode,omitempty"`
 	//graceful shutdown config
 	GracefulTimeout api.DurationConfig `json:"graceful_timeout,omitempty"`
+	// MaxConnections is the max downstream connections of the process, no limit if it is zero
+	MaxConnections uint64 `json:"max_connections,omitempty"`
 	// int go processor number
 	// string set auto means use real cpu core or limit cpu core
 	Processor interface{} `json:"processor,omitempty"`
@@ -65,6 +67,8 @@ type ListenerConfig struct {
 	StreamFilters         []Filter            `json:"stream_filters,omitempty"`
 	Inspector             bool                `json:"inspector,omitempty"`
 	ConnectionIdleTimeout *api.DurationConfig `json:"connection_idle_timeout,omitempty"`
+	// MaxConnections is the max downstream connections of the listener, no limit if it is zero
+	MaxConnections uint64 `json:"max_connections,omitempty"`
 }
 
 // Listener contains the listener's information
@@ -35,6 +35,8 @@ type effectiveConfig struct {
 	ExtendConfigs     []v2.ExtendConfig                 `json:"extends,omitempty"`
 	clusterConfigPath string                            `json:"-"`
 	routerConfigPath  map[string]string                 `json:"-"`
+	// versions of the listeners, clusters and routers
+	versions map[string]map[string]ResourceVersion `json:"-"`
 }
 
 func init() {
@@ -44,6 +46,7 @@ func init() {
 		Routers:          make(map[string]v2.RouterConfiguration),
 		ExtendConfigs:    []v2.ExtendConfig{},
 		routerConfigPath: make(map[string]string),
+		versions:         newVersions(),
 	}
 }
 
@@ -56,6 +59,7 @@ func Reset() {
 	conf.Routers = make(map[string]v2.RouterConfiguration)
 	conf.ExtendConfigs = []v2.ExtendConfig{}
 	conf.routerConfigPath = make(map[string]string)
+	conf.versions = newVersions()
 }
 
 func SetMosnConfig(cfg *v2.MOSNConfig) {
@@ -88,6 +92,16 @@ func SetListenerConfig(listenerConfig v2.Listener) {
 	defer configLock.Unlock()
 	listenerName := listenerConfig.Name
 	conf.Listener[listenerName] = listenerConfig
+	setVersion(CfgTypeListener, listenerName)
+	tryDump()
+}
+
+// SetRemoveListenerConfig update the listener config when DeleteListener
+func SetRemoveListenerConfig(listenerName string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	delete(conf.Listener, listenerName)
+	removeVersion(CfgTypeListener, listenerName)
 	tryDump()
 }
 
@@ -96,6 +110,7 @@ func SetClusterConfig(cluster v2.Cluster) {
 	configLock.Lock()
 	defer configLock.Unlock()
 	conf.Cluster[cluster.Name] = cluster
+	setVersion(CfgTypeCluster, cluster.Name)
 	tryDump()
 }
 
@@ -104,6 +119,7 @@ func SetRemoveClusterConfig(clusterName string) {
 	configLock.Lock()
 	defer configLock.Unlock()
 	delete(conf.Cluster, clusterName)
+	removeVersion(CfgTypeCluster, clusterName)
 	tryDump()
 }
 
@@ -113,6 +129,7 @@ func SetHosts(clusterName string, hostConfigs []v2.Host) {
 	if cluster, ok := conf.Cluster[clusterName]; ok {
 		cluster.Hosts = hostConfigs
 		conf.Cluster[clusterName] = cluster
+		setVersion(CfgTypeCluster, clusterName)
 		tryDump()
 	}
 }
@@ -126,6 +143,7 @@ func SetRouter(router v2.RouterConfiguration) {
 	// clear the router's dynamic mode, so the dump api will show all routes in the router
 	router.RouterConfigPath = ""
 	conf.Routers[routerName] = router
+	setVersion(CfgTypeRouter, routerName)
 	tryDump()
 }
 
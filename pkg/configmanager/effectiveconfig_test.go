@@ -25,6 +25,7 @@ import (
 	"math/rand"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -570,6 +571,58 @@ func TestRemoveClusterConfig(t *testing.T) {
 	})
 }
 
+func TestRemoveListenerConfig(t *testing.T) {
+	Reset()
+	SetListenerConfig(v2.Listener{ListenerConfig: v2.ListenerConfig{Name: "listener1"}})
+	SetListenerConfig(v2.Listener{ListenerConfig: v2.ListenerConfig{Name: "listener2"}})
+	SetRemoveListenerConfig("listener1")
+	HandleMOSNConfig(CfgTypeListener, func(v interface{}) {
+		ls := v.(map[string]v2.Listener)
+		if len(ls) != 1 {
+			t.Fatal("test remove listener failed")
+		}
+		if _, ok := ls["listener2"]; !ok {
+			t.Fatal("test remove listener failed")
+		}
+	})
+}
+
+func TestAdminWriteTokenDump(t *testing.T) {
+	Reset()
+	defer Reset()
+	cfg := &v2.MOSNConfig{
+		RawAdmin: &v2.Admin{
+			WriteToken: "write-token",
+		},
+	}
+	SetMosnConfig(cfg)
+	// the config file keeps the token, or the write apis are disabled after restart
+	content, err := transferConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "write-token") {
+		t.Fatalf("write token should be dumped into the config file: %s", string(content))
+	}
+	// the admin dumps never contain the token
+	redacted, _ := DumpRedactedJSON()
+	secrets, _ := DumpSecretsJSON()
+	for _, data := range [][]byte{redacted, secrets} {
+		if strings.Contains(string(data), "write-token") {
+			t.Fatalf("write token should not be dumped by the admin api: %s", string(data))
+		}
+	}
+	for _, includeSecrets := range []bool{true, false} {
+		dump := DumpResources(DumpOptions{Types: []string{CfgTypeMOSN}, IncludeSecrets: includeSecrets})
+		if dump.MosnConfig == nil || dump.MosnConfig.RawAdmin == nil || dump.MosnConfig.RawAdmin.WriteToken != "" {
+			t.Fatalf("write token should not be dumped by the admin api: %+v", dump.MosnConfig)
+		}
+	}
+	if cfg.RawAdmin.WriteToken != "write-token" {
+		t.Fatal("the parameter config has been changed")
+	}
+}
+
 func TestSetExtend(t *testing.T) {
 	type extConfig struct {
 		ExtInt   int
@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import (
+	"errors"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/mosn"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/server"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+var (
+	errNoClusterManager  = errors.New("cluster manager is not initialized")
+	errNoListenerAdapter = errors.New("listener adapter is not initialized")
+)
+
+// defaultApplier applies the resources by the managers, same as the xds
+type defaultApplier struct {
+	serverName string
+}
+
+// ValidateCluster creates the cluster without adding it, see mosn.ValidateCluster
+func (a defaultApplier) ValidateCluster(c v2.Cluster) error {
+	cm := cluster.GetClusterMngAdapterInstance()
+	if cm == nil || cm.ClusterManager == nil {
+		return errNoClusterManager
+	}
+	return mosn.ValidateCluster(c)
+}
+
+// UpdateCluster keeps the exists hosts if the cluster config contains no hosts,
+// the hosts may be managed by other sources
+func (a defaultApplier) UpdateCluster(c v2.Cluster) error {
+	cm := cluster.GetClusterMngAdapterInstance()
+	if cm == nil || cm.ClusterManager == nil {
+		return errNoClusterManager
+	}
+	if len(c.Hosts) > 0 {
+		return cm.TriggerClusterAndHostsAddOrUpdate(c, c.Hosts)
+	}
+	return cm.TriggerClusterAddOrUpdate(c)
+}
+
+func (a defaultApplier) DeleteCluster(name string) error {
+	cm := cluster.GetClusterMngAdapterInstance()
+	if cm == nil || cm.ClusterManager == nil {
+		return errNoClusterManager
+	}
+	return cm.TriggerClusterDel(name)
+}
+
+// ValidateRouter builds the routers, the router manager accepts an invalid new router silently.
+// a router without virtual hosts is valid, the virtual hosts may be added by the admin api.
+func (a defaultApplier) ValidateRouter(r *v2.RouterConfiguration) error {
+	if len(r.VirtualHosts) == 0 {
+		return nil
+	}
+	return mosn.ValidateRouter(r)
+}
+
+func (a defaultApplier) UpdateRouter(r *v2.RouterConfiguration) error {
+	return router.GetRoutersMangerInstance().AddOrUpdateRouters(r)
+}
+
+// ValidateListener checks the listener can be updated by the connection handler,
+// and creates its filter factories and tls contexts, see mosn.ValidateListener
+func (a defaultApplier) ValidateListener(l *v2.Listener) error {
+	adapter := server.GetListenerAdapterInstance()
+	if adapter == nil {
+		return errNoListenerAdapter
+	}
+	if len(l.FilterChains) != 1 {
+		return errors.New("listener should have exactly one filter chain")
+	}
+	if old := adapter.FindListenerByName(a.serverName, l.Name); old != nil {
+		if old.Addr().String() != l.Addr.String() || old.Addr().Network() != l.Addr.Network() {
+			return errors.New("listener address can not be changed")
+		}
+	}
+	return mosn.ValidateListener(l)
+}
+
+func (a defaultApplier) UpdateListener(l *v2.Listener) error {
+	adapter := server.GetListenerAdapterInstance()
+	if adapter == nil {
+		return errNoListenerAdapter
+	}
+	return adapter.AddOrUpdateListener(a.serverName, l)
+}
+
+func (a defaultApplier) DeleteListener(name string) error {
+	adapter := server.GetListenerAdapterInstance()
+	if adapter == nil {
+		return errNoListenerAdapter
+	}
+	return adapter.DeleteListener(a.serverName, name)
+}
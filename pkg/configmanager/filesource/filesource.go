@@ -0,0 +1,509 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package filesource is a file based dynamic configuration source.
+// It watches the cluster, router and listener files, and applies the changed
+// resources incrementally, just like the xds does.
+//
+// The files contain a json or yaml array of v2.Cluster, v2.RouterConfiguration
+// or v2.Listener, and it is configured as an extend config:
+//
+//	"extends": [{
+//		"type": "file_config_source",
+//		"config": {
+//			"clusters": "/home/admin/mosn/conf/clusters.yaml",
+//			"routers": "/home/admin/mosn/conf/routers.yaml",
+//			"listeners": "/home/admin/mosn/conf/listeners.json"
+//		}
+//	}]
+package filesource
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+// ExtendConfigType is the extend config type of the file source
+const ExtendConfigType = "file_config_source"
+
+const defaultDebounce = 200 * time.Millisecond
+
+func init() {
+	v2.RegisterParseExtendConfig(ExtendConfigType, OnFileSourceParsed)
+}
+
+// Config is the config of the file source
+type Config struct {
+	Clusters  string `json:"clusters,omitempty"`
+	Routers   string `json:"routers,omitempty"`
+	Listeners string `json:"listeners,omitempty"`
+	// ServerName is the server that the listeners added to, the default server is used if it is empty
+	ServerName string `json:"server_name,omitempty"`
+	// Debounce merges the file events in the duration, some tools write a file more than once
+	Debounce api.DurationConfig `json:"debounce,omitempty"`
+}
+
+// OnFileSourceParsed starts the file source with the extend config
+func OnFileSourceParsed(data json.RawMessage) error {
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return err
+	}
+	src, err := NewSource(cfg, defaultApplier{serverName: cfg.ServerName})
+	if err != nil {
+		return err
+	}
+	return src.Start()
+}
+
+// resource kinds, the files are applied in the order
+const (
+	kindCluster  = "cluster"
+	kindRouter   = "router"
+	kindListener = "listener"
+)
+
+// Applier applies the changed resources, the default applier calls the managers.
+// All the changed resources of a file are validated before any of them is applied,
+// so a file is applied entirely or not at all.
+type Applier interface {
+	ValidateCluster(c v2.Cluster) error
+	ValidateRouter(r *v2.RouterConfiguration) error
+	ValidateListener(l *v2.Listener) error
+	UpdateCluster(c v2.Cluster) error
+	DeleteCluster(name string) error
+	UpdateRouter(r *v2.RouterConfiguration) error
+	UpdateListener(l *v2.Listener) error
+	DeleteListener(name string) error
+}
+
+// Source watches the files and applies the changes
+type Source struct {
+	mux      sync.Mutex
+	files    map[string]string // file path to resource kind
+	applier  Applier
+	debounce time.Duration
+	watcher  *watcher
+	// names of the resources created by the source, only these resources
+	// are deleted when they are removed from the files
+	owned map[string]map[string]struct{}
+	// the last error of each file
+	errs map[string]error
+}
+
+// NewSource creates a file source, the files are not watched until started
+func NewSource(cfg *Config, applier Applier) (*Source, error) {
+	s := &Source{
+		files:    make(map[string]string, 3),
+		applier:  applier,
+		debounce: cfg.Debounce.Duration,
+		owned: map[string]map[string]struct{}{
+			kindCluster:  {},
+			kindRouter:   {},
+			kindListener: {},
+		},
+		errs: make(map[string]error, 3),
+	}
+	if s.debounce <= 0 {
+		s.debounce = defaultDebounce
+	}
+	for kind, path := range map[string]string{
+		kindCluster:  cfg.Clusters,
+		kindRouter:   cfg.Routers,
+		kindListener: cfg.Listeners,
+	} {
+		if path == "" {
+			continue
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		s.files[abs] = kind
+	}
+	if len(s.files) == 0 {
+		return nil, errors.New("no files to watch")
+	}
+	return s, nil
+}
+
+// Start applies the files and watches them
+func (s *Source) Start() error {
+	paths := make([]string, 0, len(s.files))
+	for path := range s.files {
+		paths = append(paths, path)
+	}
+	w, err := newWatcher(paths)
+	if err != nil {
+		return err
+	}
+	s.watcher = w
+	// apply the files first, the files may be changed before the mosn started
+	s.Sync()
+	utils.GoWithRecover(func() {
+		s.run()
+	}, nil)
+	log.DefaultLogger.Infof("[config] [file source] watch files: %v", paths)
+	return nil
+}
+
+// Stop stops watching the files
+func (s *Source) Stop() {
+	if s.watcher != nil {
+		s.watcher.close()
+	}
+}
+
+func (s *Source) run() {
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	changed := map[string]struct{}{}
+	for {
+		select {
+		case path, ok := <-s.watcher.events:
+			if !ok {
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+			changed[path] = struct{}{}
+			if timer == nil {
+				timer = time.NewTimer(s.debounce)
+				timerC = timer.C
+			}
+		case <-timerC:
+			timer, timerC = nil, nil
+			paths := make([]string, 0, len(changed))
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			changed = map[string]struct{}{}
+			s.apply(paths)
+		}
+	}
+}
+
+// Sync applies all the files
+func (s *Source) Sync() {
+	paths := make([]string, 0, len(s.files))
+	for path := range s.files {
+		paths = append(paths, path)
+	}
+	s.apply(paths)
+}
+
+// Errors returns the last errors of the files, a file with parse error is not applied
+func (s *Source) Errors() map[string]error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	errs := make(map[string]error, len(s.errs))
+	for path, err := range s.errs {
+		errs[path] = err
+	}
+	return errs
+}
+
+var kindOrder = map[string]int{
+	kindCluster:  0,
+	kindRouter:   1,
+	kindListener: 2,
+}
+
+// apply applies the files in order: clusters, routers and listeners,
+// so that a new listener can refer to the new routers and clusters.
+func (s *Source) apply(paths []string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ordered := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if _, ok := s.files[path]; ok {
+			ordered = append(ordered, path)
+		}
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return kindOrder[s.files[ordered[i]]] < kindOrder[s.files[ordered[j]]]
+	})
+	for _, path := range ordered {
+		kind := s.files[path]
+		// a missing file is an error rather than an empty file,
+		// the resources are deleted only if the file is emptied
+		data, err := ioutil.ReadFile(path)
+		if err == nil {
+			version := configmanager.ResourceVersion{
+				Source:      configmanager.SourceFile,
+				VersionInfo: fileVersion(data),
+			}
+			switch kind {
+			case kindCluster:
+				err = s.applyClusters(path, data, version)
+			case kindRouter:
+				err = s.applyRouters(path, data, version)
+			case kindListener:
+				err = s.applyListeners(path, data, version)
+			}
+		}
+		if err != nil {
+			s.errs[path] = err
+			log.DefaultLogger.Alertf(types.ErrorKeyConfigParse, "[config] [file source] apply %s file %s failed: %v", kind, path, err)
+			continue
+		}
+		delete(s.errs, path)
+	}
+}
+
+// fileVersion returns the short sha256 of the file content as the version of the resources
+func fileVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// decodeFile decodes the json or yaml file content into v, an empty file contains no resources
+func decodeFile(path string, data []byte, v interface{}) error {
+	var err error
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return err
+		}
+	}
+	if len(strings.TrimSpace(string(data))) == 0 || string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// equalConfig compares the configs by their json, the configs contain
+// fields that are not comparable, such as the parsed addresses
+func equalConfig(a, b interface{}) bool {
+	da, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	db, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	var va, vb interface{}
+	if json.Unmarshal(da, &va) != nil || json.Unmarshal(db, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func checkNames(kind string, names []string) error {
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if name == "" {
+			return fmt.Errorf("%s name is required", kind)
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("duplicate %s: %s", kind, name)
+		}
+		seen[name] = struct{}{}
+	}
+	return nil
+}
+
+func (s *Source) applyClusters(path string, data []byte, version configmanager.ResourceVersion) error {
+	var clusters []v2.Cluster
+	if err := decodeFile(path, data, &clusters); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(clusters))
+	for _, c := range clusters {
+		names = append(names, c.Name)
+	}
+	if err := checkNames(kindCluster, names); err != nil {
+		return err
+	}
+	var effective map[string]v2.Cluster
+	configmanager.HandleMOSNConfig(configmanager.CfgTypeCluster, func(v interface{}) {
+		effective = copyClusters(v.(map[string]v2.Cluster))
+	})
+	owned := s.owned[kindCluster]
+	current := make(map[string]struct{}, len(clusters))
+	changed := make([]v2.Cluster, 0, len(clusters))
+	for _, c := range clusters {
+		current[c.Name] = struct{}{}
+		if old, ok := effective[c.Name]; ok && equalConfig(old, c) {
+			owned[c.Name] = struct{}{}
+			continue
+		}
+		if err := s.applier.ValidateCluster(c); err != nil {
+			return fmt.Errorf("invalid cluster %s: %v", c.Name, err)
+		}
+		changed = append(changed, c)
+	}
+	for _, c := range changed {
+		if err := s.applier.UpdateCluster(c); err != nil {
+			return fmt.Errorf("update cluster %s failed: %v", c.Name, err)
+		}
+		owned[c.Name] = struct{}{}
+		configmanager.SetResourceVersion(configmanager.CfgTypeCluster, c.Name, version)
+		log.DefaultLogger.Infof("[config] [file source] cluster %s updated", c.Name)
+	}
+	for name := range owned {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		if err := s.applier.DeleteCluster(name); err != nil {
+			return fmt.Errorf("delete cluster %s failed: %v", name, err)
+		}
+		delete(owned, name)
+		log.DefaultLogger.Infof("[config] [file source] cluster %s deleted", name)
+	}
+	return nil
+}
+
+func copyClusters(m map[string]v2.Cluster) map[string]v2.Cluster {
+	c := make(map[string]v2.Cluster, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func (s *Source) applyRouters(path string, data []byte, version configmanager.ResourceVersion) error {
+	var routers []*v2.RouterConfiguration
+	if err := decodeFile(path, data, &routers); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(routers))
+	for _, r := range routers {
+		if r == nil {
+			return errors.New("router config is null")
+		}
+		names = append(names, r.RouterConfigName)
+	}
+	if err := checkNames(kindRouter, names); err != nil {
+		return err
+	}
+	effective := map[string]v2.RouterConfiguration{}
+	configmanager.HandleMOSNConfig(configmanager.CfgTypeRouter, func(v interface{}) {
+		for k, r := range v.(map[string]v2.RouterConfiguration) {
+			effective[k] = r
+		}
+	})
+	owned := s.owned[kindRouter]
+	current := make(map[string]struct{}, len(routers))
+	changed := make([]*v2.RouterConfiguration, 0, len(routers))
+	for _, r := range routers {
+		current[r.RouterConfigName] = struct{}{}
+		if old, ok := effective[r.RouterConfigName]; ok && equalConfig(old.VirtualHosts, r.VirtualHosts) &&
+			equalConfig(old.RouterConfigurationConfig, r.RouterConfigurationConfig) {
+			owned[r.RouterConfigName] = struct{}{}
+			continue
+		}
+		if err := s.applier.ValidateRouter(r); err != nil {
+			return fmt.Errorf("invalid router %s: %v", r.RouterConfigName, err)
+		}
+		changed = append(changed, r)
+	}
+	for _, r := range changed {
+		if err := s.applier.UpdateRouter(r); err != nil {
+			return fmt.Errorf("update router %s failed: %v", r.RouterConfigName, err)
+		}
+		owned[r.RouterConfigName] = struct{}{}
+		configmanager.SetResourceVersion(configmanager.CfgTypeRouter, r.RouterConfigName, version)
+		log.DefaultLogger.Infof("[config] [file source] router %s updated", r.RouterConfigName)
+	}
+	for name := range owned {
+		if _, ok := current[name]; !ok {
+			// the router manager can not delete a router, the listeners may still refer to it
+			log.DefaultLogger.Warnf("[config] [file source] router %s is removed from the file, but it can not be deleted", name)
+			delete(owned, name)
+		}
+	}
+	return nil
+}
+
+func (s *Source) applyListeners(path string, data []byte, version configmanager.ResourceVersion) error {
+	var listeners []*v2.Listener
+	if err := decodeFile(path, data, &listeners); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		if l == nil {
+			return errors.New("listener config is null")
+		}
+		if l.Addr == nil {
+			return fmt.Errorf("listener %s address is invalid", l.Name)
+		}
+		names = append(names, l.Name)
+	}
+	if err := checkNames(kindListener, names); err != nil {
+		return err
+	}
+	effective := map[string]v2.Listener{}
+	configmanager.HandleMOSNConfig(configmanager.CfgTypeListener, func(v interface{}) {
+		for k, l := range v.(map[string]v2.Listener) {
+			effective[k] = l
+		}
+	})
+	owned := s.owned[kindListener]
+	current := make(map[string]struct{}, len(listeners))
+	changed := make([]*v2.Listener, 0, len(listeners))
+	for _, l := range listeners {
+		current[l.Name] = struct{}{}
+		if old, ok := effective[l.Name]; ok && equalConfig(old, l) {
+			owned[l.Name] = struct{}{}
+			continue
+		}
+		if err := s.applier.ValidateListener(l); err != nil {
+			return fmt.Errorf("invalid listener %s: %v", l.Name, err)
+		}
+		changed = append(changed, l)
+	}
+	for _, l := range changed {
+		if err := s.applier.UpdateListener(l); err != nil {
+			return fmt.Errorf("update listener %s failed: %v", l.Name, err)
+		}
+		owned[l.Name] = struct{}{}
+		configmanager.SetResourceVersion(configmanager.CfgTypeListener, l.Name, version)
+		log.DefaultLogger.Infof("[config] [file source] listener %s updated", l.Name)
+	}
+	for name := range owned {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		if err := s.applier.DeleteListener(name); err != nil {
+			return fmt.Errorf("delete listener %s failed: %v", name, err)
+		}
+		delete(owned, name)
+		log.DefaultLogger.Infof("[config] [file source] listener %s deleted", name)
+	}
+	return nil
+}
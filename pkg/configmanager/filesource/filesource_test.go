@@ -0,0 +1,320 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+)
+
+// recordApplier stores the applied configs into configmanager, same as the managers
+type recordApplier struct {
+	mux     sync.Mutex
+	updates []string
+	deletes []string
+	changed chan struct{}
+	// the resource with the invalid name is rejected by the validation
+	invalid string
+}
+
+func newRecordApplier() *recordApplier {
+	return &recordApplier{
+		changed: make(chan struct{}, 16),
+	}
+}
+
+func (a *recordApplier) record(update bool, name string) {
+	a.mux.Lock()
+	if update {
+		a.updates = append(a.updates, name)
+	} else {
+		a.deletes = append(a.deletes, name)
+	}
+	a.mux.Unlock()
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (a *recordApplier) reset() (updates, deletes []string) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	updates, deletes = a.updates, a.deletes
+	a.updates, a.deletes = nil, nil
+	return
+}
+
+func (a *recordApplier) validate(name string) error {
+	if name == a.invalid {
+		return errors.New("invalid resource")
+	}
+	return nil
+}
+
+func (a *recordApplier) ValidateCluster(c v2.Cluster) error {
+	return a.validate(c.Name)
+}
+
+func (a *recordApplier) ValidateRouter(r *v2.RouterConfiguration) error {
+	return a.validate(r.RouterConfigName)
+}
+
+func (a *recordApplier) ValidateListener(l *v2.Listener) error {
+	return a.validate(l.Name)
+}
+
+func (a *recordApplier) UpdateCluster(c v2.Cluster) error {
+	configmanager.SetClusterConfig(c)
+	a.record(true, c.Name)
+	return nil
+}
+
+func (a *recordApplier) DeleteCluster(name string) error {
+	configmanager.SetRemoveClusterConfig(name)
+	a.record(false, name)
+	return nil
+}
+
+func (a *recordApplier) UpdateRouter(r *v2.RouterConfiguration) error {
+	configmanager.SetRouter(*r)
+	a.record(true, r.RouterConfigName)
+	return nil
+}
+
+func (a *recordApplier) UpdateListener(l *v2.Listener) error {
+	configmanager.SetListenerConfig(*l)
+	a.record(true, l.Name)
+	return nil
+}
+
+func (a *recordApplier) DeleteListener(name string) error {
+	configmanager.SetRemoveListenerConfig(name)
+	a.record(false, name)
+	return nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	// write and rename, as most of the tools do
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkApplied(t *testing.T, a *recordApplier, updates, deletes int) {
+	t.Helper()
+	u, d := a.reset()
+	if len(u) != updates || len(d) != deletes {
+		t.Fatalf("expected %d updates and %d deletes, but got updates: %v, deletes: %v", updates, deletes, u, d)
+	}
+}
+
+func TestApplyClusters(t *testing.T) {
+	configmanager.Reset()
+	dir, err := ioutil.TempDir("", "filesource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clusters.json")
+	writeFile(t, path, `[
+		{"name":"c1","type":"SIMPLE","lb_type":"LB_RANDOM","hosts":[{"address":"127.0.0.1:8080"}]},
+		{"name":"c2","type":"SIMPLE","lb_type":"LB_RANDOM"}
+	]`)
+	a := newRecordApplier()
+	src, err := NewSource(&Config{Clusters: path}, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Sync()
+	checkApplied(t, a, 2, 0)
+	// nothing changed
+	src.Sync()
+	checkApplied(t, a, 0, 0)
+	// update c1 and delete c2
+	writeFile(t, path, `[
+		{"name":"c1","type":"SIMPLE","lb_type":"LB_ROUNDROBIN","hosts":[{"address":"127.0.0.1:8080"}]}
+	]`)
+	src.Sync()
+	checkApplied(t, a, 1, 1)
+	// the updated cluster is recorded with the file version
+	data, _ := ioutil.ReadFile(path)
+	if v, ok := configmanager.GetResourceVersion(configmanager.CfgTypeCluster, "c1"); !ok ||
+		v.Source != configmanager.SourceFile || v.VersionInfo != fileVersion(data) {
+		t.Fatalf("unexpected cluster version: %+v", v)
+	}
+	// parse errors are rejected
+	for _, content := range []string{
+		`[{"name":"c1"`,
+		`[{"name":"c3"},{"name":"c3"}]`,
+		`[{"type":"SIMPLE"}]`,
+	} {
+		writeFile(t, path, content)
+		src.Sync()
+		checkApplied(t, a, 0, 0)
+		if src.Errors()[path] == nil {
+			t.Fatalf("%s should be rejected", content)
+		}
+	}
+	// the clusters not created by the source are not deleted
+	configmanager.SetClusterConfig(v2.Cluster{Name: "static"})
+	writeFile(t, path, `[]`)
+	src.Sync()
+	checkApplied(t, a, 0, 1)
+	if len(src.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", src.Errors())
+	}
+	configmanager.HandleMOSNConfig(configmanager.CfgTypeCluster, func(v interface{}) {
+		if _, ok := v.(map[string]v2.Cluster)["static"]; !ok {
+			t.Fatal("static cluster should not be deleted")
+		}
+	})
+}
+
+func TestApplyInvalidResource(t *testing.T) {
+	configmanager.Reset()
+	dir, err := ioutil.TempDir("", "filesource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clusters := filepath.Join(dir, "clusters.json")
+	listeners := filepath.Join(dir, "listeners.json")
+	writeFile(t, clusters, `[
+		{"name":"c1","type":"SIMPLE","lb_type":"LB_RANDOM"},
+		{"name":"c2","type":"SIMPLE","lb_type":"LB_RANDOM"}
+	]`)
+	writeFile(t, listeners, `[
+		{"name":"l1","address":"127.0.0.1:2045"},
+		{"name":"c2","address":"127.0.0.1:2046"}
+	]`)
+	a := newRecordApplier()
+	a.invalid = "c2"
+	src, err := NewSource(&Config{Clusters: clusters, Listeners: listeners}, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the valid resources before the invalid one are not applied
+	src.Sync()
+	checkApplied(t, a, 0, 0)
+	if errs := src.Errors(); errs[clusters] == nil || errs[listeners] == nil {
+		t.Fatalf("the files should be rejected, errors: %v", errs)
+	}
+	configmanager.HandleMOSNConfig(configmanager.CfgTypeCluster, func(v interface{}) {
+		if _, ok := v.(map[string]v2.Cluster)["c1"]; ok {
+			t.Fatal("cluster c1 should not be applied")
+		}
+	})
+	configmanager.HandleMOSNConfig(configmanager.CfgTypeListener, func(v interface{}) {
+		if _, ok := v.(map[string]v2.Listener)["l1"]; ok {
+			t.Fatal("listener l1 should not be applied")
+		}
+	})
+	// the files are applied after the invalid resources are fixed
+	a.invalid = ""
+	src.Sync()
+	checkApplied(t, a, 4, 0)
+	if len(src.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", src.Errors())
+	}
+}
+
+func TestApplyYaml(t *testing.T) {
+	configmanager.Reset()
+	dir, err := ioutil.TempDir("", "filesource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	routers := filepath.Join(dir, "routers.yaml")
+	listeners := filepath.Join(dir, "listeners.yml")
+	writeFile(t, routers, `
+- router_config_name: r1
+  virtual_hosts:
+  - name: vh1
+    domains: ["*"]
+    routers:
+    - match:
+        prefix: /
+      route:
+        cluster_name: c1
+`)
+	writeFile(t, listeners, `
+- name: l1
+  address: 127.0.0.1:2045
+  filter_chains:
+  - filters:
+    - type: proxy
+      config:
+        router_config_name: r1
+`)
+	a := newRecordApplier()
+	src, err := NewSource(&Config{Routers: routers, Listeners: listeners}, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Sync()
+	u, _ := a.reset()
+	// routers are applied before listeners
+	if len(u) != 2 || u[0] != "r1" || u[1] != "l1" {
+		t.Fatalf("unexpected updates: %v", u)
+	}
+	writeFile(t, listeners, `[]`)
+	src.Sync()
+	checkApplied(t, a, 0, 1)
+}
+
+func TestWatchFiles(t *testing.T) {
+	configmanager.Reset()
+	dir, err := ioutil.TempDir("", "filesource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clusters.json")
+	writeFile(t, path, `[{"name":"c1","type":"SIMPLE","lb_type":"LB_RANDOM"}]`)
+	a := newRecordApplier()
+	src, err := NewSource(&Config{Clusters: path}, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer src.Stop()
+	checkApplied(t, a, 1, 0)
+	writeFile(t, path, `[{"name":"c1","type":"SIMPLE","lb_type":"LB_RANDOM"},{"name":"c2","type":"SIMPLE","lb_type":"LB_RANDOM"}]`)
+	select {
+	case <-a.changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("file changes are not applied")
+	}
+	checkApplied(t, a, 1, 0)
+}
@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/pkg/utils"
+)
+
+// watcher watches the parent directories of the files by inotify, so the files
+// replaced by rename can be watched too.
+type watcher struct {
+	events    chan string
+	file      *os.File
+	files     map[string]struct{}
+	dirs      map[int32]string
+	closeOnce sync.Once
+}
+
+const watchMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM
+
+func newWatcher(paths []string) (*watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{
+		events: make(chan string, 16),
+		// the non-blocking fd is added into the runtime poller, so the read can be
+		// interrupted by close
+		file:  os.NewFile(uintptr(fd), "inotify"),
+		files: make(map[string]struct{}, len(paths)),
+		dirs:  make(map[int32]string, len(paths)),
+	}
+	for _, path := range paths {
+		w.files[path] = struct{}{}
+		dir := filepath.Dir(path)
+		wd, err := unix.InotifyAddWatch(fd, dir, watchMask)
+		if err != nil {
+			w.file.Close()
+			return nil, err
+		}
+		w.dirs[int32(wd)] = dir
+	}
+	utils.GoWithRecover(w.readEvents, nil)
+	return w, nil
+}
+
+func (w *watcher) readEvents() {
+	defer close(w.events)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.PathMax))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !os.IsTimeout(err) {
+				log.DefaultLogger.Infof("[config] [file source] stop watching files: %v", err)
+			}
+			return
+		}
+		offset := 0
+		for offset+unix.SizeofInotifyEvent <= n {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameLen := int(event.Len)
+			start := offset + unix.SizeofInotifyEvent
+			offset = start + nameLen
+			if nameLen == 0 || offset > n {
+				continue
+			}
+			dir, ok := w.dirs[event.Wd]
+			if !ok {
+				continue
+			}
+			name := string(buf[start:offset])
+			for i := 0; i < len(name); i++ {
+				if name[i] == 0 {
+					name = name[:i]
+					break
+				}
+			}
+			path := filepath.Join(dir, name)
+			if _, ok := w.files[path]; ok {
+				w.events <- path
+			}
+		}
+	}
+}
+
+func (w *watcher) close() {
+	w.closeOnce.Do(func() {
+		w.file.Close()
+	})
+}
@@ -0,0 +1,85 @@
+// +build !linux
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"mosn.io/pkg/utils"
+)
+
+const pollInterval = time.Second
+
+// watcher polls the modification time and size of the files
+type watcher struct {
+	events    chan string
+	stop      chan struct{}
+	stats     map[string]os.FileInfo
+	closeOnce sync.Once
+}
+
+func newWatcher(paths []string) (*watcher, error) {
+	w := &watcher{
+		events: make(chan string, 16),
+		stop:   make(chan struct{}),
+		stats:  make(map[string]os.FileInfo, len(paths)),
+	}
+	for _, path := range paths {
+		info, _ := os.Stat(path)
+		w.stats[path] = info
+	}
+	utils.GoWithRecover(w.poll, nil)
+	return w, nil
+}
+
+func changed(old, info os.FileInfo) bool {
+	if old == nil || info == nil {
+		return old != info
+	}
+	return !old.ModTime().Equal(info.ModTime()) || old.Size() != info.Size()
+}
+
+func (w *watcher) poll() {
+	defer close(w.events)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			for path, old := range w.stats {
+				info, _ := os.Stat(path)
+				if changed(old, info) {
+					w.stats[path] = info
+					w.events <- path
+				}
+			}
+		}
+	}
+}
+
+func (w *watcher) close() {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+	})
+}
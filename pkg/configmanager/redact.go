@@ -0,0 +1,186 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configmanager
+
+import (
+	"encoding/json"
+
+	"mosn.io/mosn/pkg/config/v2"
+)
+
+// RedactedValue replaces the secrets in the dumped configs
+const RedactedValue = "[redacted]"
+
+// RedactTLSConfig returns a copy of the tls config, the private key,
+// the session ticket key and the extend verify config are redacted.
+func RedactTLSConfig(cfg v2.TLSConfig) v2.TLSConfig {
+	if cfg.PrivateKey != "" {
+		cfg.PrivateKey = RedactedValue
+	}
+	if cfg.Ticket != "" {
+		cfg.Ticket = RedactedValue
+	}
+	if len(cfg.ExtendVerify) > 0 {
+		verify := make(map[string]interface{}, len(cfg.ExtendVerify))
+		for k := range cfg.ExtendVerify {
+			verify[k] = RedactedValue
+		}
+		cfg.ExtendVerify = verify
+	}
+	return cfg
+}
+
+func redactTLSConfigs(cfgs []v2.TLSConfig) []v2.TLSConfig {
+	if cfgs == nil {
+		return nil
+	}
+	redacted := make([]v2.TLSConfig, len(cfgs))
+	for i := range cfgs {
+		redacted[i] = RedactTLSConfig(cfgs[i])
+	}
+	return redacted
+}
+
+func redactListener(ln v2.Listener) v2.Listener {
+	if ln.FilterChains == nil {
+		return ln
+	}
+	chains := make([]v2.FilterChain, len(ln.FilterChains))
+	for i, fc := range ln.FilterChains {
+		if fc.TLSConfig != nil {
+			tlsConfig := RedactTLSConfig(*fc.TLSConfig)
+			fc.TLSConfig = &tlsConfig
+		}
+		fc.TLSConfigs = redactTLSConfigs(fc.TLSConfigs)
+		fc.TLSContexts = redactTLSConfigs(fc.TLSContexts)
+		chains[i] = fc
+	}
+	ln.FilterChains = chains
+	return ln
+}
+
+func redactCluster(c v2.Cluster) v2.Cluster {
+	c.TLS = RedactTLSConfig(c.TLS)
+	return c
+}
+
+func redactClusters(clusters []v2.Cluster) []v2.Cluster {
+	if clusters == nil {
+		return nil
+	}
+	redacted := make([]v2.Cluster, len(clusters))
+	for i := range clusters {
+		redacted[i] = redactCluster(clusters[i])
+	}
+	return redacted
+}
+
+// redactWriteToken removes the admin write token, it is kept in the config file
+// but never dumped by the admin api, even if the secrets are included.
+func redactWriteToken(c v2.MOSNConfig) v2.MOSNConfig {
+	if c.RawAdmin != nil && c.RawAdmin.WriteToken != "" {
+		admin := *c.RawAdmin
+		admin.WriteToken = ""
+		c.RawAdmin = &admin
+	}
+	return c
+}
+
+func redactMOSNConfig(c v2.MOSNConfig) v2.MOSNConfig {
+	c = redactWriteToken(c)
+	c.ClusterManager.TLSContext = RedactTLSConfig(c.ClusterManager.TLSContext)
+	c.ClusterManager.Clusters = redactClusters(c.ClusterManager.Clusters)
+	c.ClusterManager.ClustersJson = redactClusters(c.ClusterManager.ClustersJson)
+	if c.Servers != nil {
+		servers := make([]v2.ServerConfig, len(c.Servers))
+		for i, s := range c.Servers {
+			if s.Listeners != nil {
+				listeners := make([]v2.Listener, len(s.Listeners))
+				for j := range s.Listeners {
+					listeners[j] = redactListener(s.Listeners[j])
+				}
+				s.Listeners = listeners
+			}
+			servers[i] = s
+		}
+		c.Servers = servers
+	}
+	return c
+}
+
+// RedactSecrets returns a copy of the config with the secrets in the tls configs redacted.
+// It supports the configs handled by HandleMOSNConfig, and a single listener or cluster,
+// other values are returned directly.
+func RedactSecrets(v interface{}) interface{} {
+	switch c := v.(type) {
+	case v2.MOSNConfig:
+		return redactMOSNConfig(c)
+	case v2.Listener:
+		return redactListener(c)
+	case v2.Cluster:
+		return redactCluster(c)
+	case map[string]v2.Listener:
+		listeners := make(map[string]v2.Listener, len(c))
+		for name, ln := range c {
+			listeners[name] = redactListener(ln)
+		}
+		return listeners
+	case map[string]v2.Cluster:
+		clusters := make(map[string]v2.Cluster, len(c))
+		for name, cluster := range c {
+			clusters[name] = redactCluster(cluster)
+		}
+		return clusters
+	default:
+		return v
+	}
+}
+
+// RedactWriteToken returns a copy of the mosn config without the admin write token,
+// other values are returned directly.
+func RedactWriteToken(v interface{}) interface{} {
+	if c, ok := v.(v2.MOSNConfig); ok {
+		return redactWriteToken(c)
+	}
+	return v
+}
+
+// DumpSecretsJSON marshals the effectiveConfig to bytes like DumpJSON, the secrets in
+// the tls configs are kept but the admin write token is removed
+func DumpSecretsJSON() ([]byte, error) {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	c := conf
+	c.MosnConfig = redactWriteToken(conf.MosnConfig)
+	return json.Marshal(c)
+}
+
+// DumpRedactedJSON marshals the effectiveConfig to bytes like DumpJSON,
+// but the secrets in the tls configs are redacted
+func DumpRedactedJSON() ([]byte, error) {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	redacted := effectiveConfig{
+		MosnConfig:    redactMOSNConfig(conf.MosnConfig),
+		Listener:      RedactSecrets(conf.Listener).(map[string]v2.Listener),
+		Cluster:       RedactSecrets(conf.Cluster).(map[string]v2.Cluster),
+		Routers:       conf.Routers,
+		ExtendConfigs: conf.ExtendConfigs,
+	}
+	return json.Marshal(redacted)
+}
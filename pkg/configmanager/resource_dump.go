@@ -0,0 +1,131 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configmanager
+
+import (
+	"mosn.io/mosn/pkg/config/v2"
+)
+
+// DumpOptions selects the configs to dump
+type DumpOptions struct {
+	// Types are the config types, such as CfgTypeListener, all the types are dumped if it is empty
+	Types []string
+	// Names are the names of the listeners, clusters and routers, all of them are dumped if it is empty
+	Names []string
+	// IncludeSecrets dumps the secrets in the tls configs, they are redacted by default
+	IncludeSecrets bool
+}
+
+// ResourceDump is a dumped listener, cluster or router with its version
+type ResourceDump struct {
+	Config  interface{}     `json:"config"`
+	Version ResourceVersion `json:"version"`
+}
+
+// ResourcesDump contains the selected configs
+type ResourcesDump struct {
+	MosnConfig *v2.MOSNConfig           `json:"mosn_config,omitempty"`
+	Listeners  map[string]*ResourceDump `json:"listeners,omitempty"`
+	Clusters   map[string]*ResourceDump `json:"clusters,omitempty"`
+	Routers    map[string]*ResourceDump `json:"routers,omitempty"`
+	Extends    []v2.ExtendConfig        `json:"extends,omitempty"`
+}
+
+// Empty returns true if no configs are selected
+func (d *ResourcesDump) Empty() bool {
+	return d.MosnConfig == nil && len(d.Listeners) == 0 && len(d.Clusters) == 0 &&
+		len(d.Routers) == 0 && len(d.Extends) == 0
+}
+
+// DumpResources returns the configs selected by the options,
+// the listeners, clusters and routers are dumped with their versions.
+func DumpResources(opts DumpOptions) *ResourcesDump {
+	selected := func(typ string) bool {
+		if len(opts.Types) == 0 {
+			return true
+		}
+		for _, t := range opts.Types {
+			if t == typ {
+				return true
+			}
+		}
+		return false
+	}
+	names := make(map[string]struct{}, len(opts.Names))
+	for _, name := range opts.Names {
+		names[name] = struct{}{}
+	}
+	matched := func(name string) bool {
+		if len(names) == 0 {
+			return true
+		}
+		_, ok := names[name]
+		return ok
+	}
+	redact := func(v interface{}) interface{} {
+		if opts.IncludeSecrets {
+			return RedactWriteToken(v)
+		}
+		return RedactSecrets(v)
+	}
+	configLock.RLock()
+	defer configLock.RUnlock()
+	dump := &ResourcesDump{}
+	// the mosn config and extends have no names
+	if len(names) == 0 {
+		if selected(CfgTypeMOSN) {
+			c := redact(conf.MosnConfig).(v2.MOSNConfig)
+			dump.MosnConfig = &c
+		}
+		if selected(CfgTypeExtend) && len(conf.ExtendConfigs) > 0 {
+			dump.Extends = make([]v2.ExtendConfig, len(conf.ExtendConfigs))
+			copy(dump.Extends, conf.ExtendConfigs)
+		}
+	}
+	resource := func(typ, name string, v interface{}) *ResourceDump {
+		return &ResourceDump{
+			Config:  redact(v),
+			Version: conf.versions[typ][name],
+		}
+	}
+	if selected(CfgTypeListener) {
+		dump.Listeners = map[string]*ResourceDump{}
+		for name, ln := range conf.Listener {
+			if matched(name) {
+				dump.Listeners[name] = resource(CfgTypeListener, name, ln)
+			}
+		}
+	}
+	if selected(CfgTypeCluster) {
+		dump.Clusters = map[string]*ResourceDump{}
+		for name, c := range conf.Cluster {
+			if matched(name) {
+				dump.Clusters[name] = resource(CfgTypeCluster, name, c)
+			}
+		}
+	}
+	if selected(CfgTypeRouter) {
+		dump.Routers = map[string]*ResourceDump{}
+		for name, r := range conf.Routers {
+			if matched(name) {
+				dump.Routers[name] = resource(CfgTypeRouter, name, r)
+			}
+		}
+	}
+	return dump
+}
@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configmanager
+
+import (
+	"testing"
+
+	"mosn.io/mosn/pkg/config/v2"
+)
+
+func TestResourceVersion(t *testing.T) {
+	Reset()
+	defer Reset()
+	// the resources without version
+	SetClusterConfig(v2.Cluster{Name: "static"})
+	v, ok := GetResourceVersion(CfgTypeCluster, "static")
+	if !ok || v.Source != "" || v.LastUpdated.IsZero() {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+	xdsVersion := ResourceVersion{Source: SourceXds, VersionInfo: "v1", Nonce: "n1"}
+	SetClusterConfig(v2.Cluster{Name: "cluster"})
+	SetResourceVersion(CfgTypeCluster, "cluster", xdsVersion)
+	SetRouter(v2.RouterConfiguration{RouterConfigurationConfig: v2.RouterConfigurationConfig{RouterConfigName: "router"}})
+	SetResourceVersion(CfgTypeRouter, "router", xdsVersion)
+	for _, r := range []struct {
+		typ  string
+		name string
+	}{
+		{CfgTypeCluster, "cluster"},
+		{CfgTypeRouter, "router"},
+	} {
+		v, ok := GetResourceVersion(r.typ, r.name)
+		if !ok || v.Source != SourceXds || v.VersionInfo != "v1" || v.Nonce != "n1" || v.LastUpdated.IsZero() {
+			t.Fatalf("unexpected %s version: %+v", r.typ, v)
+		}
+	}
+	// update hosts with a new version
+	SetHosts("cluster", []v2.Host{{HostConfig: v2.HostConfig{Address: "127.0.0.1:8080"}}})
+	SetResourceVersion(CfgTypeCluster, "cluster", ResourceVersion{Source: SourceAdmin, VersionInfo: "v2"})
+	if v, _ := GetResourceVersion(CfgTypeCluster, "cluster"); v.Source != SourceAdmin || v.VersionInfo != "v2" {
+		t.Fatalf("unexpected version after hosts updated: %+v", v)
+	}
+	// the version is not kept after update
+	SetClusterConfig(v2.Cluster{Name: "cluster"})
+	if v, _ := GetResourceVersion(CfgTypeCluster, "cluster"); v.Source != "" {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+	SetRemoveClusterConfig("cluster")
+	if _, ok := GetResourceVersion(CfgTypeCluster, "cluster"); ok {
+		t.Fatal("version of removed cluster should be removed")
+	}
+}
+
+func TestSetResourceVersion(t *testing.T) {
+	Reset()
+	defer Reset()
+	SetClusterConfig(v2.Cluster{Name: "cluster"})
+	SetResourceVersion(CfgTypeCluster, "cluster", ResourceVersion{Source: SourceAdmin, VersionInfo: "v1"})
+	if v, _ := GetResourceVersion(CfgTypeCluster, "cluster"); v.Source != SourceAdmin || v.VersionInfo != "v1" || v.LastUpdated.IsZero() {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+	// the version of the resource not exists is not recorded
+	SetResourceVersion(CfgTypeCluster, "not_exists", ResourceVersion{Source: SourceAdmin, VersionInfo: "v1"})
+	if _, ok := GetResourceVersion(CfgTypeCluster, "not_exists"); ok {
+		t.Fatal("version of the resource not exists should not be recorded")
+	}
+}
+
+func TestDumpResources(t *testing.T) {
+	Reset()
+	defer Reset()
+	SetMosnConfig(&v2.MOSNConfig{})
+	SetListenerConfig(v2.Listener{ListenerConfig: v2.ListenerConfig{Name: "listener"}})
+	SetResourceVersion(CfgTypeListener, "listener", ResourceVersion{Source: SourceXds, VersionInfo: "v1"})
+	SetClusterConfig(v2.Cluster{Name: "cluster1", TLS: v2.TLSConfig{PrivateKey: "key"}})
+	SetClusterConfig(v2.Cluster{Name: "cluster2"})
+	SetExtend("extend", []byte(`{}`))
+	// dump all
+	dump := DumpResources(DumpOptions{})
+	if dump.MosnConfig == nil || len(dump.Listeners) != 1 || len(dump.Clusters) != 2 || len(dump.Extends) != 1 {
+		t.Fatalf("unexpected dump: %+v", dump)
+	}
+	if dump.Listeners["listener"].Version.VersionInfo != "v1" {
+		t.Fatalf("unexpected listener version: %+v", dump.Listeners["listener"].Version)
+	}
+	// select by type
+	dump = DumpResources(DumpOptions{Types: []string{CfgTypeCluster}})
+	if dump.MosnConfig != nil || len(dump.Listeners) != 0 || len(dump.Clusters) != 2 || len(dump.Extends) != 0 {
+		t.Fatalf("unexpected dump: %+v", dump)
+	}
+	if c := dump.Clusters["cluster1"].Config.(v2.Cluster); c.TLS.PrivateKey != RedactedValue {
+		t.Fatalf("cluster secrets are not redacted: %+v", c.TLS)
+	}
+	// select by name
+	dump = DumpResources(DumpOptions{Names: []string{"cluster1"}, IncludeSecrets: true})
+	if dump.MosnConfig != nil || len(dump.Listeners) != 0 || len(dump.Clusters) != 1 || len(dump.Extends) != 0 {
+		t.Fatalf("unexpected dump: %+v", dump)
+	}
+	if c := dump.Clusters["cluster1"].Config.(v2.Cluster); c.TLS.PrivateKey != "key" {
+		t.Fatalf("cluster secrets should be included: %+v", c.TLS)
+	}
+	if dump = DumpResources(DumpOptions{Names: []string{"unknown"}}); !dump.Empty() {
+		t.Fatalf("unexpected dump: %+v", dump)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configmanager
+
+import "time"
+
+// The sources that the resources are updated from
+const (
+	SourceXds   = "xds"
+	SourceAdmin = "admin"
+	SourceFile  = "file"
+)
+
+// ResourceVersion records where a listener, cluster or router is last updated from.
+// The resources loaded from the config file when mosn starts, or updated without
+// a version, have no source.
+type ResourceVersion struct {
+	Source      string    `json:"source,omitempty"`
+	VersionInfo string    `json:"version_info,omitempty"`
+	Nonce       string    `json:"nonce,omitempty"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// setVersion records the update time of the updated resource, the previous version
+// is cleared, should be called with the configLock
+func setVersion(typ, name string) {
+	conf.versions[typ][name] = ResourceVersion{
+		LastUpdated: time.Now(),
+	}
+}
+
+// SetResourceVersion records the version of an updated resource, it should be called
+// by the updaters after the resource is updated.
+// The version is not recorded if the resource does not exist.
+func SetResourceVersion(typ, name string, version ResourceVersion) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	if _, ok := conf.versions[typ][name]; !ok {
+		return
+	}
+	version.LastUpdated = time.Now()
+	conf.versions[typ][name] = version
+}
+
+// removeVersion removes the version of the deleted resource, should be called with the configLock
+func removeVersion(typ, name string) {
+	delete(conf.versions[typ], name)
+}
+
+func newVersions() map[string]map[string]ResourceVersion {
+	return map[string]map[string]ResourceVersion{
+		CfgTypeListener: {},
+		CfgTypeCluster:  {},
+		CfgTypeRouter:   {},
+	}
+}
+
+// GetResourceVersion returns the version of the listener, cluster or router
+func GetResourceVersion(typ, name string) (ResourceVersion, bool) {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	v, ok := conf.versions[typ][name]
+	return v, ok
+}
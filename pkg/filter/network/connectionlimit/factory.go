@@ -0,0 +1,126 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connectionlimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	api.RegisterNetwork(v2.CONNECTION_LIMIT, CreateConnectionLimitFactory)
+}
+
+// Config is the config of the connection_limit filter
+//
+//	{
+//		"type": "connection_limit",
+//		"config": {
+//			"max_connections": 100,
+//			"delay": "1s"
+//		}
+//	}
+type Config struct {
+	// MaxConnections is the max concurrent connections of a source ip
+	MaxConnections uint32 `json:"max_connections"`
+	// Delay is the duration before closing an over-limit connection, the data
+	// received during the delay is discarded. A delay slows down the clients
+	// that reconnect immediately after closed.
+	Delay api.DurationConfig `json:"delay,omitempty"`
+}
+
+// ParseConfig parses the config of the connection_limit filter
+func ParseConfig(cfg map[string]interface{}) (*Config, error) {
+	c := &Config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if c.MaxConnections == 0 {
+		return nil, errors.New("max_connections is required")
+	}
+	return c, nil
+}
+
+// connectionLimitFactory counts the connections by the source ip, the counts
+// are shared by the connections created by the factory, so a listener update
+// that changes the filter config starts with new counts.
+type connectionLimitFactory struct {
+	config *Config
+
+	mux    sync.Mutex
+	counts map[string]uint32
+}
+
+// CreateConnectionLimitFactory creates the factory of the connection_limit filter
+func CreateConnectionLimitFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	c, err := ParseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &connectionLimitFactory{
+		config: c,
+		counts: map[string]uint32{},
+	}, nil
+}
+
+func (f *connectionLimitFactory) CreateFilterChain(ctx context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	var limited gometrics.Counter
+	if name, ok := mosnctx.Get(ctx, types.ContextKeyListenerName).(string); ok {
+		limited = metrics.NewListenerStats(name).Counter(metrics.DownstreamConnectionLimited)
+	}
+	callbacks.AddReadFilter(newConnectionLimitFilter(f, limited))
+}
+
+func (f *connectionLimitFactory) acquire(ip string) bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.counts[ip] >= f.config.MaxConnections {
+		return false
+	}
+	f.counts[ip]++
+	return true
+}
+
+func (f *connectionLimitFactory) release(ip string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.counts[ip] <= 1 {
+		delete(f.counts, ip)
+		return
+	}
+	f.counts[ip]--
+}
+
+func (f *connectionLimitFactory) count(ip string) uint32 {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.counts[ip]
+}
@@ -0,0 +1,112 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connectionlimit
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+)
+
+type connectionLimitFilter struct {
+	factory       *connectionLimitFactory
+	limited       gometrics.Counter
+	readCallbacks api.ReadFilterCallbacks
+
+	ip          string
+	accepted    bool
+	releaseOnce sync.Once
+}
+
+func newConnectionLimitFilter(factory *connectionLimitFactory, limited gometrics.Counter) *connectionLimitFilter {
+	return &connectionLimitFilter{
+		factory: factory,
+		limited: limited,
+	}
+}
+
+// sourceIP returns the ip of the remote address, the connections without an ip are keyed by the address
+func sourceIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP.String()
+	case *net.UDPAddr:
+		return a.IP.String()
+	}
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func (f *connectionLimitFilter) OnNewConnection() api.FilterStatus {
+	conn := f.readCallbacks.Connection()
+	f.ip = sourceIP(conn.RemoteAddr())
+	if f.factory.acquire(f.ip) {
+		f.accepted = true
+		conn.AddConnectionEventListener(f)
+		return api.Continue
+	}
+	if f.limited != nil {
+		f.limited.Inc(1)
+	}
+	delay := f.factory.config.Delay.Duration
+	// the rejections are counted by the metrics, logs every rejection in debug level only
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[connection limit] source ip %s reaches the max connections %d, close the connection after %v",
+			f.ip, f.factory.config.MaxConnections, delay)
+	}
+	if delay <= 0 {
+		conn.Close(api.NoFlush, api.LocalClose)
+		return api.Stop
+	}
+	time.AfterFunc(delay, func() {
+		conn.Close(api.NoFlush, api.LocalClose)
+	})
+	return api.Stop
+}
+
+// OnData discards the data of the over-limit connections during the delay
+func (f *connectionLimitFilter) OnData(buf api.IoBuffer) api.FilterStatus {
+	if !f.accepted {
+		buf.Drain(buf.Len())
+		return api.Stop
+	}
+	return api.Continue
+}
+
+func (f *connectionLimitFilter) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	f.readCallbacks = cb
+}
+
+// OnEvent releases the count of the source ip when the connection is closed
+func (f *connectionLimitFilter) OnEvent(event api.ConnectionEvent) {
+	if event.IsClose() {
+		f.releaseOnce.Do(func() {
+			f.factory.release(f.ip)
+		})
+	}
+}
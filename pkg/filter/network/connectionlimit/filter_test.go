@@ -0,0 +1,150 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connectionlimit
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+)
+
+type mockConnection struct {
+	api.Connection
+	remote    net.Addr
+	listeners []api.ConnectionEventListener
+	closed    int32
+}
+
+func (c *mockConnection) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *mockConnection) AddConnectionEventListener(listener api.ConnectionEventListener) {
+	c.listeners = append(c.listeners, listener)
+}
+
+func (c *mockConnection) Close(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		for _, l := range c.listeners {
+			l.OnEvent(eventType)
+		}
+	}
+	return nil
+}
+
+func (c *mockConnection) isClosed() bool {
+	return atomic.LoadInt32(&c.closed) == 1
+}
+
+type mockReadFilterCallbacks struct {
+	api.ReadFilterCallbacks
+	conn *mockConnection
+}
+
+func (cb *mockReadFilterCallbacks) Connection() api.Connection {
+	return cb.conn
+}
+
+func newConnection(f *connectionLimitFactory, ip string) (*connectionLimitFilter, *mockConnection, api.FilterStatus) {
+	conn := &mockConnection{
+		remote: &net.TCPAddr{IP: net.ParseIP(ip), Port: 12345},
+	}
+	filter := newConnectionLimitFilter(f, nil)
+	filter.InitializeReadFilterCallbacks(&mockReadFilterCallbacks{conn: conn})
+	return filter, conn, filter.OnNewConnection()
+}
+
+func TestParseConfig(t *testing.T) {
+	if _, err := ParseConfig(map[string]interface{}{}); err == nil {
+		t.Fatal("max_connections should be required")
+	}
+	c, err := ParseConfig(map[string]interface{}{
+		"max_connections": 10,
+		"delay":           "1s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxConnections != 10 || c.Delay.Duration != time.Second {
+		t.Fatalf("unexpected config: %+v", c)
+	}
+}
+
+func TestConnectionLimit(t *testing.T) {
+	factory, err := CreateConnectionLimitFactory(map[string]interface{}{
+		"max_connections": 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := factory.(*connectionLimitFactory)
+	_, first, s1 := newConnection(f, "10.0.0.1")
+	_, _, s2 := newConnection(f, "10.0.0.1")
+	_, over, s3 := newConnection(f, "10.0.0.1")
+	_, other, s4 := newConnection(f, "10.0.0.2")
+	if s1 != api.Continue || s2 != api.Continue || s4 != api.Continue || other.isClosed() {
+		t.Fatal("connections under the limit should be accepted")
+	}
+	if s3 != api.Stop || !over.isClosed() {
+		t.Fatal("over-limit connection should be closed immediately")
+	}
+	if f.count("10.0.0.1") != 2 {
+		t.Fatalf("unexpected count: %d", f.count("10.0.0.1"))
+	}
+	// the count is released once by the close events
+	first.Close(api.NoFlush, api.RemoteClose)
+	first.listeners[0].OnEvent(api.LocalClose)
+	if f.count("10.0.0.1") != 1 {
+		t.Fatalf("unexpected count after closed: %d", f.count("10.0.0.1"))
+	}
+	if _, _, s := newConnection(f, "10.0.0.1"); s != api.Continue {
+		t.Fatal("connection should be accepted after released")
+	}
+}
+
+func TestConnectionLimitDelay(t *testing.T) {
+	factory, err := CreateConnectionLimitFactory(map[string]interface{}{
+		"max_connections": 1,
+		"delay":           "100ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := factory.(*connectionLimitFactory)
+	newConnection(f, "10.0.0.1")
+	filter, over, s := newConnection(f, "10.0.0.1")
+	if s != api.Stop || over.isClosed() {
+		t.Fatal("over-limit connection should be closed after the delay")
+	}
+	buf := buffer.NewIoBufferString("data")
+	if filter.OnData(buf) != api.Stop || buf.Len() != 0 {
+		t.Fatal("data of over-limit connection should be discarded")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !over.isClosed() {
+		t.Fatal("over-limit connection is not closed after the delay")
+	}
+	// the over-limit connection is not counted
+	if f.count("10.0.0.1") != 1 {
+		t.Fatalf("unexpected count: %d", f.count("10.0.0.1"))
+	}
+}
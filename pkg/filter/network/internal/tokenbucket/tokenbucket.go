@@ -0,0 +1,144 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tokenbucket implements the token buckets shared by the connections of a client,
+// which are used by the network filters to limit the requests of the clients.
+package tokenbucket
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is refilled at rate tokens per second and holds burst tokens at most.
+type Bucket struct {
+	mux    sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// New returns a full bucket
+func New(rate float64, burst int, now time.Time) *Bucket {
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// refill adds the tokens since the last refill, should be called with the lock
+func (b *Bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+}
+
+// Allow takes a token if there is any, returns false if the request should be rejected
+func (b *Bucket) Allow(now time.Time) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Reserve always takes a token, returns the duration to wait before the request is sent.
+// The tokens can be borrowed and the borrower waits until repaid, at most burst tokens
+// are borrowed, so the wait is limited to burst/rate.
+func (b *Bucket) Reserve(now time.Time) time.Duration {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	if b.tokens < -b.burst {
+		b.tokens = -b.burst
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+type sharedBucket struct {
+	bucket *Bucket
+	refs   int
+}
+
+// Buckets are the buckets shared by the connections of the same key, such as the client id,
+// so a client cannot reset its bucket by reconnecting while it is still connected.
+type Buckets struct {
+	rate  float64
+	burst int
+
+	mux     sync.Mutex
+	buckets map[string]*sharedBucket
+}
+
+// NewBuckets returns the buckets with the same rate and burst
+func NewBuckets(rate float64, burst int) *Buckets {
+	return &Buckets{
+		rate:    rate,
+		burst:   burst,
+		buckets: map[string]*sharedBucket{},
+	}
+}
+
+// Acquire returns the bucket of the key, the bucket should be released
+// by Release when the connection is closed.
+func (bs *Buckets) Acquire(key string) *Bucket {
+	bs.mux.Lock()
+	defer bs.mux.Unlock()
+	b, ok := bs.buckets[key]
+	if !ok {
+		b = &sharedBucket{bucket: New(bs.rate, bs.burst, time.Now())}
+		bs.buckets[key] = b
+	}
+	b.refs++
+	return b.bucket
+}
+
+// Release releases the bucket of the key, the bucket is removed if it is not acquired any more
+func (bs *Buckets) Release(key string) {
+	bs.mux.Lock()
+	defer bs.mux.Unlock()
+	b, ok := bs.buckets[key]
+	if !ok {
+		return
+	}
+	if b.refs <= 1 {
+		delete(bs.buckets, key)
+		return
+	}
+	b.refs--
+}
+
+// Len returns the number of the keys that hold a bucket
+func (bs *Buckets) Len() int {
+	bs.mux.Lock()
+	defer bs.mux.Unlock()
+	return len(bs.buckets)
+}
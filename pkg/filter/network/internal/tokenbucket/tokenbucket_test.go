@@ -0,0 +1,86 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenbucket
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Now()
+	b := New(10, 1, now)
+	if !b.Allow(now) || b.Allow(now) {
+		t.Fatal("burst should be 1")
+	}
+	if b.Allow(now.Add(50 * time.Millisecond)) {
+		t.Fatal("half a token should not be allowed")
+	}
+	if !b.Allow(now.Add(150 * time.Millisecond)) {
+		t.Fatal("a token should be refilled")
+	}
+	if !b.Allow(now.Add(time.Hour)) || b.Allow(now.Add(time.Hour)) {
+		t.Fatal("tokens should not exceed the burst")
+	}
+}
+
+func TestReserve(t *testing.T) {
+	now := time.Now()
+	b := New(10, 1, now)
+	if b.Reserve(now) != 0 {
+		t.Fatal("burst should be 1")
+	}
+	if wait := b.Reserve(now); wait < 99*time.Millisecond || wait > 101*time.Millisecond {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+	if wait := b.Reserve(now.Add(time.Hour)); wait != 0 {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+	// the borrowed tokens are limited by the burst
+	for i := 0; i < 100; i++ {
+		b.Reserve(now.Add(time.Hour))
+	}
+	if wait := b.Reserve(now.Add(time.Hour)); wait > 101*time.Millisecond {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	bs := NewBuckets(10, 1)
+	b := bs.Acquire("client-1")
+	if bs.Acquire("client-1") != b {
+		t.Fatal("bucket should be shared by the key")
+	}
+	if bs.Acquire("client-2") == b || bs.Len() != 2 {
+		t.Fatal("keys should not share the bucket")
+	}
+	bs.Release("client-1")
+	if bs.Len() != 2 {
+		t.Fatal("bucket is still acquired")
+	}
+	bs.Release("client-1")
+	bs.Release("client-2")
+	bs.Release("unknown")
+	if bs.Len() != 0 {
+		t.Fatal("buckets should be released")
+	}
+	// a released bucket is not reused
+	if bs.Acquire("client-1") == b {
+		t.Fatal("a new bucket should be created")
+	}
+}
@@ -0,0 +1,256 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/tokenbucket"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/types"
+)
+
+func init() {
+	api.RegisterNetwork(v2.KAFKA, CreateKafkaFilterFactory)
+}
+
+const (
+	// defaultMaxRequestSize is the same as the default socket.request.max.bytes of the broker
+	defaultMaxRequestSize = 100 * 1024 * 1024
+	defaultMaxTopicStats  = 1000
+	// topicOverflowName is the topic label of the stats shared by the topics exceeded max_topic_stats
+	topicOverflowName = "overflow"
+)
+
+// Config is the config of the kafka filter. The filter decodes the kafka requests and responses
+// without changing them, it should be placed before the tcp_proxy filter.
+//
+//	{
+//		"type": "kafka",
+//		"config": {
+//			"stat_prefix": "kafka_cluster_a",
+//			"produce_allow": ["orders", "logs.*"],
+//			"throttle": {
+//				"rate": 100,
+//				"burst": 200,
+//				"api_keys": ["Produce", "Fetch"]
+//			}
+//		}
+//	}
+type Config struct {
+	// StatPrefix is the label of the metrics, the default is kafka
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	// ProduceAllow is the topics can be produced to, a name ends with * is a prefix, empty means all topics.
+	// A produce request contains any denied topic is rejected with TOPIC_AUTHORIZATION_FAILED for all its partitions.
+	ProduceAllow []string `json:"produce_allow,omitempty"`
+	// Throttle limits the request rate of each client id, nil means no limit
+	Throttle *Throttle `json:"throttle,omitempty"`
+	// MaxRequestSize limits the size of a request, the connection is closed if exceeded
+	MaxRequestSize int `json:"max_request_size,omitempty"`
+	// MaxTopicStats limits the count of the topic stats, the exceeded topics share the overflow stats
+	MaxTopicStats int `json:"max_topic_stats,omitempty"`
+}
+
+// Throttle is a token bucket limit of the requests of a client id. Like the broker quota,
+// a throttled client is delayed by stopping reading from the connection instead of failing.
+type Throttle struct {
+	// Rate is the requests per second
+	Rate float64 `json:"rate"`
+	// Burst is the max requests in a burst, the default is the rate rounded up
+	Burst int `json:"burst,omitempty"`
+	// APIKeys are the names or the numbers of the throttled api keys, the default is Produce and Fetch
+	APIKeys []string `json:"api_keys,omitempty"`
+}
+
+// ParseConfig parses the config of the kafka filter
+func ParseConfig(cfg map[string]interface{}) (*Config, error) {
+	c := &Config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if c.StatPrefix == "" {
+		c.StatPrefix = v2.KAFKA
+	}
+	for _, topic := range c.ProduceAllow {
+		if topic == "" || strings.Contains(strings.TrimSuffix(topic, "*"), "*") {
+			return nil, fmt.Errorf("invalid topic %q in produce_allow", topic)
+		}
+	}
+	if t := c.Throttle; t != nil {
+		if t.Rate <= 0 {
+			return nil, errors.New("throttle.rate should be positive")
+		}
+		if t.Burst <= 0 {
+			t.Burst = int(math.Ceil(t.Rate))
+		}
+		if len(t.APIKeys) == 0 {
+			t.APIKeys = []string{apiName(Produce), apiName(Fetch)}
+		}
+		for _, name := range t.APIKeys {
+			if _, ok := apiKey(name); !ok {
+				return nil, fmt.Errorf("unknown api key %s in throttle", name)
+			}
+		}
+	}
+	if c.MaxRequestSize <= 0 {
+		c.MaxRequestSize = defaultMaxRequestSize
+	}
+	if c.MaxTopicStats <= 0 {
+		c.MaxTopicStats = defaultMaxTopicStats
+	}
+	return c, nil
+}
+
+// requestStats is the metrics of the requests of an api key or a topic
+type requestStats struct {
+	requestTotal     gometrics.Counter
+	requestBytes     gometrics.Counter
+	responseBytes    gometrics.Counter
+	requestTime      gometrics.Histogram
+	requestRejected  gometrics.Counter
+	requestThrottled gometrics.Counter
+}
+
+func newRequestStats(s types.Metrics) *requestStats {
+	return &requestStats{
+		requestTotal:     s.Counter(metrics.KafkaRequestTotal),
+		requestBytes:     s.Counter(metrics.KafkaRequestBytes),
+		responseBytes:    s.Counter(metrics.KafkaResponseBytes),
+		requestTime:      s.Histogram(metrics.KafkaRequestTime),
+		requestRejected:  s.Counter(metrics.KafkaRequestRejected),
+		requestThrottled: s.Counter(metrics.KafkaRequestThrottled),
+	}
+}
+
+// kafkaFilterFactory holds the state shared by the connections created by the factory,
+// the stats and the throttle buckets of the connected clients.
+type kafkaFilterFactory struct {
+	config        *Config
+	throttledKeys map[int16]bool
+
+	mux        sync.Mutex
+	apiStats   map[string]*requestStats
+	topicStats map[string]*requestStats
+	// buckets is nil if the throttle is not configured
+	buckets *tokenbucket.Buckets
+}
+
+// CreateKafkaFilterFactory creates the factory of the kafka filter
+func CreateKafkaFilterFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	c, err := ParseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return newKafkaFilterFactory(c), nil
+}
+
+func newKafkaFilterFactory(c *Config) *kafkaFilterFactory {
+	f := &kafkaFilterFactory{
+		config:        c,
+		throttledKeys: map[int16]bool{},
+		apiStats:      map[string]*requestStats{},
+		topicStats:    map[string]*requestStats{},
+	}
+	if c.Throttle != nil {
+		f.buckets = tokenbucket.NewBuckets(c.Throttle.Rate, c.Throttle.Burst)
+		for _, name := range c.Throttle.APIKeys {
+			key, _ := apiKey(name)
+			f.throttledKeys[key] = true
+		}
+	}
+	return f
+}
+
+func (f *kafkaFilterFactory) CreateFilterChain(ctx context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	filter := newKafkaFilter(f)
+	callbacks.AddReadFilter(filter)
+	callbacks.AddWriteFilter(filter)
+}
+
+// produceAllowed returns true if the topic can be produced to
+func (f *kafkaFilterFactory) produceAllowed(topic string) bool {
+	if len(f.config.ProduceAllow) == 0 {
+		return true
+	}
+	for _, allow := range f.config.ProduceAllow {
+		if prefix := strings.TrimSuffix(allow, "*"); prefix != allow {
+			if strings.HasPrefix(topic, prefix) {
+				return true
+			}
+		} else if topic == allow {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *kafkaFilterFactory) getAPIStats(key int16) *requestStats {
+	name := apiName(key)
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	s, ok := f.apiStats[name]
+	if !ok {
+		s = newRequestStats(metrics.NewKafkaAPIStats(f.config.StatPrefix, name))
+		f.apiStats[name] = s
+	}
+	return s
+}
+
+// getTopicStats returns the stats of a topic, the topics exceeded the limit share the overflow stats
+func (f *kafkaFilterFactory) getTopicStats(topic string) *requestStats {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if s, ok := f.topicStats[topic]; ok {
+		return s
+	}
+	if len(f.topicStats) >= f.config.MaxTopicStats {
+		topic = topicOverflowName
+		if s, ok := f.topicStats[topic]; ok {
+			return s
+		}
+	}
+	s := newRequestStats(metrics.NewKafkaTopicStats(f.config.StatPrefix, topic))
+	f.topicStats[topic] = s
+	return s
+}
+
+func (f *kafkaFilterFactory) throttled(key int16) bool {
+	return f.throttledKeys[key]
+}
+
+// acquireBucket returns the throttle bucket of a client id
+func (f *kafkaFilterFactory) acquireBucket(clientID string) *tokenbucket.Bucket {
+	return f.buckets.Acquire(clientID)
+}
+
+func (f *kafkaFilterFactory) releaseBucket(clientID string) {
+	f.buckets.Release(clientID)
+}
@@ -0,0 +1,471 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/filter/network/internal/tokenbucket"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/pkg/buffer"
+)
+
+var errUnsupportedVersion = errors.New("kafka: produce version is not supported by produce_allow")
+
+// inflightRequest is a request waiting for the response
+type inflightRequest struct {
+	correlationID int32
+	start         time.Time
+	stats         []*requestStats
+	// replace is the response replaces the broker response of a rejected request
+	replace []byte
+}
+
+// throttledRequest is a request delayed by the throttle, it is forwarded when the wait is over
+type throttledRequest struct {
+	frame []byte
+	// request is nil if no response is expected
+	request *inflightRequest
+}
+
+// kafkaFilter is a ReadFilter and a WriteFilter that inspects a kafka connection.
+// The requests are forwarded by frames, a rejected request is replaced by an ApiVersions
+// request with the same correlation id, so the broker keeps the order of the responses,
+// and the response of the ApiVersions is replaced by the error response of the rejected one.
+type kafkaFilter struct {
+	factory       *kafkaFilterFactory
+	readCallbacks api.ReadFilterCallbacks
+
+	// the request state is accessed by the reading of the connection and the throttle timer
+	readMux sync.Mutex
+	// pending is the request data not forming a frame
+	pending []byte
+	// buckets are the throttle buckets of the client ids in the connection
+	buckets     map[string]*tokenbucket.Bucket
+	releaseOnce sync.Once
+	// throttled is the request waiting for the throttle, the connection is not read until it is forwarded
+	throttled *throttledRequest
+	// the timer of the throttled request is stopped when the connection is closed,
+	// timerMux is not held by the reading as the connection may be closed in it
+	timerMux sync.Mutex
+	timer    *time.Timer
+	closed   bool
+
+	// the response state is accessed by the writers of the connection
+	mux      sync.Mutex
+	inflight []*inflightRequest
+	// header is the response header being read
+	header []byte
+	// remaining is the bytes of the current response frame after the header
+	remaining int
+	// replacing is true if the current response frame is being replaced
+	replacing *inflightRequest
+	// broken is true if the responses are out of sync, the responses are not inspected any more
+	broken bool
+}
+
+func newKafkaFilter(f *kafkaFilterFactory) *kafkaFilter {
+	return &kafkaFilter{
+		factory: f,
+		buckets: map[string]*tokenbucket.Bucket{},
+	}
+}
+
+func (f *kafkaFilter) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	f.readCallbacks = cb
+	f.readCallbacks.Connection().AddConnectionEventListener(f)
+}
+
+func (f *kafkaFilter) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+// OnData keeps the incomplete request frame, the complete frames are left in the buffer for the next filters
+func (f *kafkaFilter) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	f.readMux.Lock()
+	defer f.readMux.Unlock()
+	f.pending = append(f.pending, buf.Bytes()...)
+	buf.Drain(buf.Len())
+	// the data is kept until the throttled request is forwarded
+	if f.throttled != nil || !f.onFrames(buf) || buf.Len() == 0 {
+		return api.Stop
+	}
+	return api.Continue
+}
+
+// onFrames handles the complete frames in pending until a request is throttled,
+// returns false if the connection is closed
+func (f *kafkaFilter) onFrames(buf buffer.IoBuffer) bool {
+	for f.throttled == nil {
+		frame, err := readFrame(f.pending, f.factory.config.MaxRequestSize)
+		if err == errNeedMore {
+			break
+		}
+		var forward []byte
+		if err == nil {
+			f.pending = f.pending[len(frame):]
+			forward, err = f.onRequest(frame)
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[kafka] close the connection from %s: %v", f.readCallbacks.Connection().RemoteAddr(), err)
+			f.readCallbacks.Connection().Close(api.NoFlush, api.LocalClose)
+			return false
+		}
+		buf.Write(forward)
+	}
+	if len(f.pending) == 0 {
+		f.pending = nil
+	}
+	return true
+}
+
+// onRequest returns the frame forwarded to the broker, nil if the request is dropped
+func (f *kafkaFilter) onRequest(frame []byte) ([]byte, error) {
+	header, d, err := decodeRequestHeader(frame)
+	if err != nil {
+		return nil, err
+	}
+	apiStats := f.factory.getAPIStats(header.APIKey)
+	apiStats.requestTotal.Inc(1)
+	apiStats.requestBytes.Inc(int64(len(frame)))
+	request := &inflightRequest{
+		correlationID: header.CorrelationID,
+		stats:         []*requestStats{apiStats},
+	}
+
+	var produce *ProduceRequest
+	switch header.APIKey {
+	case Produce:
+		if produce, err = f.decodeProduce(d, header.APIVersion); err != nil {
+			return nil, err
+		}
+		if produce != nil {
+			for _, t := range produce.Topics {
+				s := f.factory.getTopicStats(t.Name)
+				s.requestTotal.Inc(1)
+				s.requestBytes.Inc(int64(t.RecordsSize))
+				request.stats = append(request.stats, s)
+			}
+		}
+	case Fetch:
+		// the topics are only for the stats, a fetch request is forwarded even if the topics cannot be decoded
+		if header.APIVersion <= maxFetchVersion {
+			topics, _ := decodeFetchTopics(d, header.APIVersion)
+			for _, t := range topics {
+				s := f.factory.getTopicStats(t)
+				s.requestTotal.Inc(1)
+				request.stats = append(request.stats, s)
+			}
+		}
+	}
+
+	if produce != nil && f.rejectProduce(produce) {
+		apiStats.requestRejected.Inc(1)
+		if produce.Acks == noAcks {
+			return nil, nil
+		}
+		request.replace = encodeProduceResponse(header.CorrelationID, header.APIVersion, produce.Topics, ErrorTopicAuthorizationFailed)
+		request.start = time.Now()
+		if !f.pushInflight(request) {
+			return nil, errors.New("kafka: cannot reject a request when the responses are out of sync")
+		}
+		return apiVersionsRequest(header.CorrelationID), nil
+	}
+
+	if produce != nil && produce.Acks == noAcks {
+		request = nil
+	}
+	if f.factory.throttled(header.APIKey) {
+		if wait := f.bucket(header.ClientID).Reserve(time.Now()); wait > 0 {
+			apiStats.requestThrottled.Inc(1)
+			f.throttle(&throttledRequest{frame: frame, request: request}, wait)
+			return nil, nil
+		}
+	}
+	return f.forward(frame, request), nil
+}
+
+// forward returns the frame forwarded to the broker, the request waits for the response if not nil
+func (f *kafkaFilter) forward(frame []byte, request *inflightRequest) []byte {
+	if request != nil {
+		request.start = time.Now()
+		f.pushInflight(request)
+	}
+	return frame
+}
+
+// throttle stops reading the connection like the broker mutes a throttled client,
+// the reading goes on after the request is forwarded
+func (f *kafkaFilter) throttle(t *throttledRequest, wait time.Duration) {
+	f.timerMux.Lock()
+	defer f.timerMux.Unlock()
+	if f.closed {
+		return
+	}
+	f.throttled = t
+	f.readCallbacks.Connection().SetReadDisable(true)
+	f.timer = time.AfterFunc(wait, f.resume)
+}
+
+// resume forwards the throttled request and the requests after it to the next filters
+func (f *kafkaFilter) resume() {
+	f.timerMux.Lock()
+	closed := f.closed
+	f.timer = nil
+	f.timerMux.Unlock()
+	if closed {
+		return
+	}
+	f.readMux.Lock()
+	defer f.readMux.Unlock()
+	conn := f.readCallbacks.Connection()
+	buf := conn.GetReadBuffer()
+	// the data in the read buffer is not handled by the filters while the reading is disabled
+	f.pending = append(f.pending, buf.Bytes()...)
+	buf.Drain(buf.Len())
+	t := f.throttled
+	f.throttled = nil
+	buf.Write(f.forward(t.frame, t.request))
+	if !f.onFrames(buf) {
+		return
+	}
+	f.readCallbacks.ContinueReading()
+	if f.throttled == nil {
+		conn.SetReadDisable(false)
+	}
+}
+
+// decodeProduce decodes the produce request, returns nil if the request cannot be decoded and
+// the topics are only used for the stats.
+func (f *kafkaFilter) decodeProduce(d *decoder, version int16) (*ProduceRequest, error) {
+	enforced := len(f.factory.config.ProduceAllow) > 0
+	if version > maxProduceVersion {
+		if enforced {
+			return nil, errUnsupportedVersion
+		}
+		return nil, nil
+	}
+	produce, err := decodeProduce(d, version)
+	if err != nil && enforced {
+		return nil, err
+	}
+	return produce, nil
+}
+
+// rejectProduce returns true if the request contains any denied topic
+func (f *kafkaFilter) rejectProduce(produce *ProduceRequest) bool {
+	rejected := false
+	for _, t := range produce.Topics {
+		if !f.factory.produceAllowed(t.Name) {
+			rejected = true
+			f.factory.getTopicStats(t.Name).requestRejected.Inc(1)
+		}
+	}
+	return rejected
+}
+
+func (f *kafkaFilter) bucket(clientID string) *tokenbucket.Bucket {
+	b, ok := f.buckets[clientID]
+	if !ok {
+		b = f.factory.acquireBucket(clientID)
+		f.buckets[clientID] = b
+	}
+	return b
+}
+
+// pushInflight returns false if the responses are out of sync
+func (f *kafkaFilter) pushInflight(request *inflightRequest) bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.broken {
+		return false
+	}
+	f.inflight = append(f.inflight, request)
+	return true
+}
+
+// apiVersionsRequest returns an ApiVersions v0 request, all the brokers support it
+func apiVersionsRequest(correlationID int32) []byte {
+	frame := make([]byte, 14)
+	binary.BigEndian.PutUint32(frame, 10)
+	binary.BigEndian.PutUint16(frame[4:], uint16(ApiVersions))
+	binary.BigEndian.PutUint32(frame[8:], uint32(correlationID))
+	binary.BigEndian.PutUint16(frame[12:], 0xffff) // null client id
+	return frame
+}
+
+// OnWrite inspects the responses written to the client
+func (f *kafkaFilter) OnWrite(buffers []buffer.IoBuffer) api.FilterStatus {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for _, b := range buffers {
+		if f.broken {
+			break
+		}
+		if out, modified := f.onResponseData(b.Bytes()); modified {
+			b.Reset()
+			b.Write(out)
+		}
+	}
+	return api.Continue
+}
+
+// responseWriter builds the output of a buffer, the buffer is copied
+// only if the output is different from the buffer.
+type responseWriter struct {
+	data     []byte
+	out      []byte
+	modified bool
+}
+
+// keep keeps data[from:to] in the output, data[:from] has been handled
+func (w *responseWriter) keep(from, to int) {
+	if w.modified {
+		w.out = append(w.out, w.data[from:to]...)
+	}
+}
+
+// modify starts the output at the position, data[:at] is kept
+func (w *responseWriter) modify(at int) {
+	if !w.modified {
+		w.out = append([]byte(nil), w.data[:at]...)
+		w.modified = true
+	}
+}
+
+func (w *responseWriter) insert(at int, b []byte) {
+	w.modify(at)
+	w.out = append(w.out, b...)
+}
+
+// onResponseData returns the output of a buffer written to the client and whether it is modified
+func (f *kafkaFilter) onResponseData(data []byte) ([]byte, bool) {
+	w := &responseWriter{data: data}
+	i := 0
+	for i < len(data) {
+		if f.broken {
+			w.keep(i, len(data))
+			break
+		}
+		if f.remaining > 0 {
+			n := f.remaining
+			if n > len(data)-i {
+				n = len(data) - i
+			}
+			if f.replacing != nil {
+				w.modify(i)
+			} else {
+				w.keep(i, i+n)
+			}
+			i += n
+			f.remaining -= n
+			if f.remaining == 0 {
+				f.onResponseEnd(w, i)
+			}
+			continue
+		}
+		// the header bytes are held until the header is complete
+		start := i
+		n := 8 - len(f.header)
+		if n > len(data)-i {
+			n = len(data) - i
+		}
+		f.header = append(f.header, data[i:i+n]...)
+		i += n
+		if len(f.header) < 8 {
+			w.modify(start)
+			break
+		}
+		f.onResponseHeader()
+		if f.replacing != nil {
+			w.modify(start)
+		} else if i-start == len(f.header) {
+			w.keep(start, i)
+		} else {
+			w.insert(start, f.header)
+		}
+		f.header = f.header[:0]
+		if f.remaining == 0 {
+			f.onResponseEnd(w, i)
+		}
+	}
+	if w.modified {
+		return w.out, true
+	}
+	return data, false
+}
+
+// onResponseHeader matches the response with the first inflight request
+func (f *kafkaFilter) onResponseHeader() {
+	size := int(int32(binary.BigEndian.Uint32(f.header)))
+	correlationID := int32(binary.BigEndian.Uint32(f.header[4:]))
+	if size < 4 || len(f.inflight) == 0 || f.inflight[0].correlationID != correlationID {
+		log.DefaultLogger.Errorf("[kafka] unexpected response of correlation id %d, stop inspecting the responses", correlationID)
+		f.broken = true
+		f.inflight = nil
+		return
+	}
+	request := f.inflight[0]
+	f.remaining = size - 4
+	if request.replace != nil {
+		f.replacing = request
+		return
+	}
+	cost := time.Since(request.start)
+	for i, s := range request.stats {
+		// the response size is only counted by the api stats
+		if i == 0 {
+			s.responseBytes.Inc(int64(size + 4))
+		}
+		s.requestTime.Update(int64(cost))
+	}
+}
+
+// onResponseEnd pops the inflight request, the replacing response is written at the position
+func (f *kafkaFilter) onResponseEnd(w *responseWriter, at int) {
+	if f.broken {
+		return
+	}
+	if f.replacing != nil {
+		w.insert(at, f.replacing.replace)
+		f.replacing = nil
+	}
+	f.inflight[0] = nil
+	f.inflight = f.inflight[1:]
+}
+
+// OnEvent releases the throttle buckets when the connection is closed
+func (f *kafkaFilter) OnEvent(event api.ConnectionEvent) {
+	if event.IsClose() {
+		f.timerMux.Lock()
+		f.closed = true
+		if f.timer != nil {
+			f.timer.Stop()
+		}
+		f.timerMux.Unlock()
+		f.releaseOnce.Do(func() {
+			for clientID := range f.buckets {
+				f.factory.releaseBucket(clientID)
+			}
+		})
+	}
+}
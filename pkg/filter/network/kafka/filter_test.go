@@ -0,0 +1,393 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+)
+
+type mockConnection struct {
+	api.Connection
+	listeners    []api.ConnectionEventListener
+	closed       bool
+	readDisabled bool
+	readBuffer   buffer.IoBuffer
+	// readEnabled is notified when the reading is enabled again
+	readEnabled chan struct{}
+}
+
+func (c *mockConnection) SetReadDisable(disable bool) {
+	c.readDisabled = disable
+	if !disable {
+		c.readEnabled <- struct{}{}
+	}
+}
+
+func (c *mockConnection) GetReadBuffer() buffer.IoBuffer {
+	return c.readBuffer
+}
+
+func (c *mockConnection) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 12345}
+}
+
+func (c *mockConnection) AddConnectionEventListener(listener api.ConnectionEventListener) {
+	c.listeners = append(c.listeners, listener)
+}
+
+func (c *mockConnection) Close(ccType api.ConnectionCloseType, eventType api.ConnectionEvent) error {
+	if !c.closed {
+		c.closed = true
+		for _, l := range c.listeners {
+			l.OnEvent(eventType)
+		}
+	}
+	return nil
+}
+
+type mockReadFilterCallbacks struct {
+	api.ReadFilterCallbacks
+	conn *mockConnection
+	// continued receives the data passed to the next filters by ContinueReading
+	continued chan []byte
+}
+
+func (cb *mockReadFilterCallbacks) Connection() api.Connection {
+	return cb.conn
+}
+
+func (cb *mockReadFilterCallbacks) ContinueReading() {
+	buf := cb.conn.readBuffer
+	data := append([]byte(nil), buf.Bytes()...)
+	buf.Drain(buf.Len())
+	cb.continued <- data
+}
+
+func newTestFilter(t *testing.T, conf map[string]interface{}) (*kafkaFilter, *mockConnection) {
+	c, err := ParseConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &mockConnection{readBuffer: buffer.GetIoBuffer(0), readEnabled: make(chan struct{}, 1)}
+	f := newKafkaFilter(newKafkaFilterFactory(c))
+	f.InitializeReadFilterCallbacks(&mockReadFilterCallbacks{conn: conn, continued: make(chan []byte, 1)})
+	return f, conn
+}
+
+func buildResponse(correlationID int32, body string) []byte {
+	frame := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(4+len(body)))
+	binary.BigEndian.PutUint32(frame[4:], uint32(correlationID))
+	return append(frame, body...)
+}
+
+// writeResponses writes the responses split by the chunk size, returns the data written to the client
+func writeResponses(t *testing.T, f *kafkaFilter, data []byte, chunk int) []byte {
+	var buffers []buffer.IoBuffer
+	for len(data) > 0 {
+		n := chunk
+		if n > len(data) {
+			n = len(data)
+		}
+		buffers = append(buffers, buffer.NewIoBufferBytes(append([]byte(nil), data[:n]...)))
+		data = data[n:]
+	}
+	// two buffers in a write
+	var out []byte
+	for i := 0; i < len(buffers); i += 2 {
+		end := i + 2
+		if end > len(buffers) {
+			end = len(buffers)
+		}
+		if status := f.OnWrite(buffers[i:end]); status != api.Continue {
+			t.Fatalf("unexpected status %v", status)
+		}
+		for _, b := range buffers[i:end] {
+			out = append(out, b.Bytes()...)
+		}
+	}
+	return out
+}
+
+func TestParseConfig(t *testing.T) {
+	for _, conf := range []map[string]interface{}{
+		{"produce_allow": []string{""}},
+		{"produce_allow": []string{"a*b"}},
+		{"throttle": map[string]interface{}{"rate": 0}},
+		{"throttle": map[string]interface{}{"rate": 1, "api_keys": []string{"Unknown"}}},
+	} {
+		if _, err := ParseConfig(conf); err == nil {
+			t.Fatalf("config %v should be invalid", conf)
+		}
+	}
+	c, err := ParseConfig(map[string]interface{}{
+		"throttle": map[string]interface{}{"rate": 1.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.StatPrefix != "kafka" || c.MaxRequestSize != defaultMaxRequestSize || c.MaxTopicStats != defaultMaxTopicStats ||
+		c.Throttle.Burst != 2 || len(c.Throttle.APIKeys) != 2 {
+		t.Fatalf("unexpected config %+v", c)
+	}
+	f := newKafkaFilterFactory(c)
+	if !f.throttled(Produce) || !f.throttled(Fetch) || f.throttled(Metadata) {
+		t.Fatal("unexpected throttled api keys")
+	}
+}
+
+func TestProduceAllowed(t *testing.T) {
+	f := newKafkaFilterFactory(&Config{ProduceAllow: []string{"orders", "logs.*"}})
+	for topic, expect := range map[string]bool{
+		"orders":     true,
+		"orders.dlq": false,
+		"logs.app":   true,
+		"logs":       false,
+		"secret":     false,
+	} {
+		if f.produceAllowed(topic) != expect {
+			t.Errorf("topic %s expected %v", topic, expect)
+		}
+	}
+	if !newKafkaFilterFactory(&Config{}).produceAllowed("any") {
+		t.Error("empty produce_allow should allow all")
+	}
+}
+
+func TestForwardFrames(t *testing.T) {
+	f, conn := newTestFilter(t, map[string]interface{}{"stat_prefix": "test_forward_frames"})
+	produce := buildProduce(3, 1, "producer", 1, "orders")
+	fetch := buildFetch(11, 2, "orders")
+	metadata := finishFrame(func() *encoder {
+		e := &encoder{data: make([]byte, 4)}
+		writeRequestHeader(e, Metadata, 1, 3, "producer")
+		e.writeArrayLen(0)
+		return e
+	}())
+	data := append(append(append([]byte(nil), produce...), fetch...), metadata...)
+
+	var forwarded []byte
+	for i := 0; i < len(data); i += 7 {
+		end := i + 7
+		if end > len(data) {
+			end = len(data)
+		}
+		buf := buffer.NewIoBufferBytes(append([]byte(nil), data[i:end]...))
+		status := f.OnData(buf)
+		// only the complete frames are left for the next filters
+		if (status == api.Continue) != (buf.Len() > 0) {
+			t.Fatalf("unexpected status %v with %d bytes", status, buf.Len())
+		}
+		forwarded = append(forwarded, buf.Bytes()...)
+	}
+	if !bytes.Equal(forwarded, data) || conn.closed {
+		t.Fatal("requests should be forwarded as they are")
+	}
+	if len(f.inflight) != 3 {
+		t.Fatalf("unexpected inflight requests %d", len(f.inflight))
+	}
+
+	responses := append(append(buildResponse(1, "produced"), buildResponse(2, "fetched")...), buildResponse(3, "metadata")...)
+	if out := writeResponses(t, f, responses, 5); !bytes.Equal(out, responses) {
+		t.Fatal("responses should be written as they are")
+	}
+	if len(f.inflight) != 0 || f.broken {
+		t.Fatal("all the responses should be matched")
+	}
+
+	produceStats := f.factory.getAPIStats(Produce)
+	if produceStats.requestTotal.Count() != 1 || produceStats.requestBytes.Count() != int64(len(produce)) ||
+		produceStats.responseBytes.Count() != int64(len(buildResponse(1, "produced"))) || produceStats.requestTime.Count() != 1 {
+		t.Fatal("unexpected produce stats")
+	}
+	topicStats := f.factory.getTopicStats("orders")
+	if topicStats.requestTotal.Count() != 2 || topicStats.requestBytes.Count() != 14 || topicStats.requestTime.Count() != 2 {
+		t.Fatal("unexpected topic stats")
+	}
+	if f.factory.getAPIStats(Metadata).requestTotal.Count() != 1 {
+		t.Fatal("unexpected metadata stats")
+	}
+}
+
+func TestRejectProduce(t *testing.T) {
+	for _, version := range []int16{2, 9} {
+		for _, chunk := range []int{1, 3, 1024} {
+			f, conn := newTestFilter(t, map[string]interface{}{
+				"stat_prefix":   "test_reject_produce",
+				"produce_allow": []string{"orders"},
+			})
+			allowed := buildProduce(version, 1, "producer", 1, "orders")
+			denied := buildProduce(version, 2, "producer", 1, "orders", "secret")
+			buf := buffer.NewIoBufferBytes(append(append([]byte(nil), allowed...), denied...))
+			if f.OnData(buf) != api.Continue || conn.closed {
+				t.Fatal("unexpected status")
+			}
+			// the denied request is replaced by an ApiVersions request
+			if !bytes.Equal(buf.Bytes(), append(append([]byte(nil), allowed...), apiVersionsRequest(2)...)) {
+				t.Fatalf("unexpected forwarded data %x", buf.Bytes())
+			}
+
+			responses := append(buildResponse(1, "produced"), buildResponse(2, "api versions")...)
+			out := writeResponses(t, f, responses, chunk)
+			rejected := encodeProduceResponse(2, version, []ProduceTopic{
+				{Name: "orders", Partitions: []int32{0, 1}},
+				{Name: "secret", Partitions: []int32{0, 1}},
+			}, ErrorTopicAuthorizationFailed)
+			if !bytes.Equal(out, append(buildResponse(1, "produced"), rejected...)) {
+				t.Fatalf("chunk %d unexpected responses %x", chunk, out)
+			}
+			if len(f.inflight) != 0 || f.broken {
+				t.Fatal("all the responses should be matched")
+			}
+		}
+	}
+	f, _ := newTestFilter(t, map[string]interface{}{"stat_prefix": "test_reject_produce", "produce_allow": []string{"orders"}})
+	if f.factory.getAPIStats(Produce).requestRejected.Count() != 6 || f.factory.getTopicStats("secret").requestRejected.Count() != 6 {
+		t.Fatal("unexpected rejected stats")
+	}
+}
+
+func TestRejectProduceNoAcks(t *testing.T) {
+	f, conn := newTestFilter(t, map[string]interface{}{
+		"stat_prefix":   "test_reject_produce_no_acks",
+		"produce_allow": []string{"orders"},
+	})
+	buf := buffer.NewIoBufferBytes(buildProduce(3, 1, "producer", 0, "secret"))
+	if f.OnData(buf) != api.Stop || buf.Len() != 0 || conn.closed {
+		t.Fatal("the request without acks should be dropped")
+	}
+	allowed := buildProduce(3, 2, "producer", 0, "orders")
+	buf = buffer.NewIoBufferBytes(append([]byte(nil), allowed...))
+	if f.OnData(buf) != api.Continue || !bytes.Equal(buf.Bytes(), allowed) {
+		t.Fatal("the request without acks should be forwarded")
+	}
+	if len(f.inflight) != 0 {
+		t.Fatal("the request without acks has no response")
+	}
+}
+
+func TestUnsupportedProduceVersion(t *testing.T) {
+	f, conn := newTestFilter(t, map[string]interface{}{
+		"stat_prefix":   "test_unsupported_produce_version",
+		"produce_allow": []string{"orders"},
+	})
+	frame := buildProduce(3, 1, "producer", 1, "orders")
+	binary.BigEndian.PutUint16(frame[6:], uint16(maxProduceVersion+1))
+	if f.OnData(buffer.NewIoBufferBytes(frame)) != api.Stop || !conn.closed {
+		t.Fatal("the connection should be closed")
+	}
+	// without produce_allow, the request is forwarded
+	f, conn = newTestFilter(t, map[string]interface{}{"stat_prefix": "test_unsupported_produce_version"})
+	if f.OnData(buffer.NewIoBufferBytes(frame)) != api.Continue || conn.closed {
+		t.Fatal("the request should be forwarded")
+	}
+}
+
+func TestResponseOutOfSync(t *testing.T) {
+	f, conn := newTestFilter(t, map[string]interface{}{
+		"stat_prefix":   "test_response_out_of_sync",
+		"produce_allow": []string{"orders"},
+	})
+	f.OnData(buffer.NewIoBufferBytes(buildProduce(3, 1, "producer", 1, "orders")))
+	responses := append(buildResponse(5, "unexpected"), buildResponse(1, "produced")...)
+	if out := writeResponses(t, f, responses, 3); !bytes.Equal(out, responses) || !f.broken {
+		t.Fatal("the responses should be written as they are")
+	}
+	// cannot reject any more
+	if f.OnData(buffer.NewIoBufferBytes(buildProduce(3, 2, "producer", 1, "secret"))) != api.Stop || !conn.closed {
+		t.Fatal("the connection should be closed")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	f, conn := newTestFilter(t, map[string]interface{}{
+		"stat_prefix": "test_throttle",
+		"throttle":    map[string]interface{}{"rate": 10, "burst": 1},
+	})
+	first := buildProduce(3, 1, "producer", 1, "orders")
+	if buf := buffer.NewIoBufferBytes(first); f.OnData(buf) != api.Continue || !bytes.Equal(buf.Bytes(), first) {
+		t.Fatal("the first request should be forwarded")
+	}
+	// the second request is delayed without blocking, the data after it is kept
+	second := buildProduce(3, 2, "producer", 1, "orders")
+	third := buildProduce(3, 3, "other", 1, "orders")
+	buf := buffer.NewIoBufferBytes(append(append([]byte(nil), second...), third...))
+	if f.OnData(buf) != api.Stop || buf.Len() != 0 || !conn.readDisabled {
+		t.Fatal("the throttled request should stop reading the connection")
+	}
+	if f.factory.getAPIStats(Produce).requestThrottled.Count() != 1 {
+		t.Fatal("unexpected throttled stats")
+	}
+	cb := f.readCallbacks.(*mockReadFilterCallbacks)
+	select {
+	case data := <-cb.continued:
+		if !bytes.Equal(data, append(append([]byte(nil), second...), third...)) {
+			t.Fatal("the throttled requests should be forwarded in order")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the throttled request is not forwarded")
+	}
+	<-conn.readEnabled
+	if len(f.inflight) != 3 {
+		t.Fatal("unexpected inflight requests")
+	}
+	if f.factory.buckets.Len() != 2 {
+		t.Fatal("unexpected buckets")
+	}
+	conn.Close(api.NoFlush, api.RemoteClose)
+	if f.factory.buckets.Len() != 0 {
+		t.Fatal("buckets should be released")
+	}
+}
+
+func TestThrottleClosed(t *testing.T) {
+	f, conn := newTestFilter(t, map[string]interface{}{
+		"stat_prefix": "test_throttle_closed",
+		"throttle":    map[string]interface{}{"rate": 10, "burst": 1},
+	})
+	f.OnData(buffer.NewIoBufferBytes(buildProduce(3, 1, "producer", 1, "orders")))
+	f.OnData(buffer.NewIoBufferBytes(buildProduce(3, 2, "producer", 1, "orders")))
+	if f.throttled == nil {
+		t.Fatal("the request should be throttled")
+	}
+	conn.Close(api.NoFlush, api.RemoteClose)
+	select {
+	case <-f.readCallbacks.(*mockReadFilterCallbacks).continued:
+		t.Fatal("the throttled request should not be forwarded after the connection is closed")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestTopicStatsOverflow(t *testing.T) {
+	f := newKafkaFilterFactory(&Config{StatPrefix: "test_topic_stats_overflow", MaxTopicStats: 1})
+	a := f.getTopicStats("a")
+	if f.getTopicStats("a") != a {
+		t.Fatal("topic stats should be reused")
+	}
+	overflow := f.getTopicStats("b")
+	if overflow == a || f.getTopicStats("c") != overflow {
+		t.Fatal("exceeded topics should share the overflow stats")
+	}
+}
@@ -0,0 +1,454 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+)
+
+// api keys of the common kafka requests
+const (
+	Produce            int16 = 0
+	Fetch              int16 = 1
+	ListOffsets        int16 = 2
+	Metadata           int16 = 3
+	OffsetCommit       int16 = 8
+	OffsetFetch        int16 = 9
+	FindCoordinator    int16 = 10
+	JoinGroup          int16 = 11
+	Heartbeat          int16 = 12
+	LeaveGroup         int16 = 13
+	SyncGroup          int16 = 14
+	DescribeGroups     int16 = 15
+	ListGroups         int16 = 16
+	SaslHandshake      int16 = 17
+	ApiVersions        int16 = 18
+	CreateTopics       int16 = 19
+	DeleteTopics       int16 = 20
+	InitProducerId     int16 = 22
+	AddPartitionsToTxn int16 = 24
+	EndTxn             int16 = 26
+	SaslAuthenticate   int16 = 36
+)
+
+var apiNames = map[int16]string{
+	Produce:            "Produce",
+	Fetch:              "Fetch",
+	ListOffsets:        "ListOffsets",
+	Metadata:           "Metadata",
+	OffsetCommit:       "OffsetCommit",
+	OffsetFetch:        "OffsetFetch",
+	FindCoordinator:    "FindCoordinator",
+	JoinGroup:          "JoinGroup",
+	Heartbeat:          "Heartbeat",
+	LeaveGroup:         "LeaveGroup",
+	SyncGroup:          "SyncGroup",
+	DescribeGroups:     "DescribeGroups",
+	ListGroups:         "ListGroups",
+	SaslHandshake:      "SaslHandshake",
+	ApiVersions:        "ApiVersions",
+	CreateTopics:       "CreateTopics",
+	DeleteTopics:       "DeleteTopics",
+	InitProducerId:     "InitProducerId",
+	AddPartitionsToTxn: "AddPartitionsToTxn",
+	EndTxn:             "EndTxn",
+	SaslAuthenticate:   "SaslAuthenticate",
+}
+
+// unknownAPIName is the metrics label of the api keys not in apiNames
+const unknownAPIName = "unknown"
+
+func apiName(key int16) string {
+	if name, ok := apiNames[key]; ok {
+		return name
+	}
+	return unknownAPIName
+}
+
+// apiKey returns the api key of a name, the name can also be the number of the key
+func apiKey(name string) (int16, bool) {
+	for key, n := range apiNames {
+		if n == name {
+			return key, true
+		}
+	}
+	key, err := strconv.ParseInt(name, 10, 16)
+	return int16(key), err == nil
+}
+
+// the first flexible versions, the flexible versions use compact strings, compact arrays and tagged fields
+const (
+	produceFlexibleVersion int16 = 9
+	fetchFlexibleVersion   int16 = 12
+)
+
+// the max versions that the topics can be decoded, the later versions use topic ids instead of names
+const (
+	maxProduceVersion int16 = 12
+	maxFetchVersion   int16 = 12
+)
+
+// ErrorTopicAuthorizationFailed is the error code of the rejected produce partitions
+const ErrorTopicAuthorizationFailed int16 = 29
+
+// noAcks is the acks of the produce requests that have no response
+const noAcks int16 = 0
+
+var (
+	errNeedMore         = errors.New("kafka: need more data")
+	ErrMalformedRequest = errors.New("kafka: malformed request")
+	ErrRequestTooLarge  = errors.New("kafka: request too large")
+)
+
+// readFrame reads a size delimited frame, the returned frame includes the size
+func readFrame(data []byte, maxSize int) ([]byte, error) {
+	if len(data) < 4 {
+		return nil, errNeedMore
+	}
+	size := int(int32(binary.BigEndian.Uint32(data)))
+	if size < 0 {
+		return nil, ErrMalformedRequest
+	}
+	if maxSize > 0 && size > maxSize {
+		return nil, ErrRequestTooLarge
+	}
+	if len(data) < 4+size {
+		return nil, errNeedMore
+	}
+	return data[:4+size], nil
+}
+
+// decoder reads the fields of a message, the first error is kept
+// and the following reads return zero values.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.data) < n {
+		d.err = ErrMalformedRequest
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) readInt8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) readInt16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) readInt32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) readInt64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) readUvarint() int {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 || v > uint64(len(d.data))+1 {
+		// a length cannot be larger than the data, except the null/empty marker
+		d.err = ErrMalformedRequest
+		return 0
+	}
+	d.data = d.data[n:]
+	return int(v)
+}
+
+// readString reads a nullable string, null is returned as empty
+func (d *decoder) readString(flexible bool) string {
+	var n int
+	if flexible {
+		n = d.readUvarint() - 1
+	} else {
+		n = int(d.readInt16())
+	}
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(n))
+}
+
+// skipBytes skips a nullable bytes, returns the length of the bytes
+func (d *decoder) skipBytes(flexible bool) int {
+	var n int
+	if flexible {
+		n = d.readUvarint() - 1
+	} else {
+		n = int(d.readInt32())
+	}
+	if n < 0 {
+		return 0
+	}
+	d.next(n)
+	return n
+}
+
+// readArrayLen reads the length of a nullable array, null is returned as zero
+func (d *decoder) readArrayLen(flexible bool) int {
+	var n int
+	if flexible {
+		n = d.readUvarint() - 1
+	} else {
+		n = int(d.readInt32())
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// skipTaggedFields skips the tagged fields of a flexible version
+func (d *decoder) skipTaggedFields(flexible bool) {
+	if !flexible {
+		return
+	}
+	for n := d.readUvarint(); n > 0 && d.err == nil; n-- {
+		d.readUvarint()
+		d.next(d.readUvarint())
+	}
+}
+
+// RequestHeader is the header of a kafka request
+type RequestHeader struct {
+	APIKey        int16
+	APIVersion    int16
+	CorrelationID int32
+	ClientID      string
+}
+
+// decodeRequestHeader decodes the header of a request frame, the returned decoder
+// is positioned after the client id, the tagged fields of a flexible header is not read.
+func decodeRequestHeader(frame []byte) (*RequestHeader, *decoder, error) {
+	d := &decoder{data: frame[4:]}
+	h := &RequestHeader{
+		APIKey:        d.readInt16(),
+		APIVersion:    d.readInt16(),
+		CorrelationID: d.readInt32(),
+	}
+	// the client id is a nullable string in all the header versions
+	h.ClientID = d.readString(false)
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	return h, d, nil
+}
+
+// ProduceTopic is the topic data in a produce request
+type ProduceTopic struct {
+	Name        string
+	Partitions  []int32
+	RecordsSize int
+}
+
+// ProduceRequest is a decoded produce request without the records
+type ProduceRequest struct {
+	TransactionalID string
+	Acks            int16
+	Topics          []ProduceTopic
+}
+
+func decodeProduce(d *decoder, version int16) (*ProduceRequest, error) {
+	flexible := version >= produceFlexibleVersion
+	d.skipTaggedFields(flexible)
+	r := &ProduceRequest{}
+	if version >= 3 {
+		r.TransactionalID = d.readString(flexible)
+	}
+	r.Acks = d.readInt16()
+	d.readInt32() // timeout
+	for n := d.readArrayLen(flexible); n > 0 && d.err == nil; n-- {
+		t := ProduceTopic{Name: d.readString(flexible)}
+		for m := d.readArrayLen(flexible); m > 0 && d.err == nil; m-- {
+			t.Partitions = append(t.Partitions, d.readInt32())
+			t.RecordsSize += d.skipBytes(flexible)
+			d.skipTaggedFields(flexible)
+		}
+		d.skipTaggedFields(flexible)
+		r.Topics = append(r.Topics, t)
+	}
+	d.skipTaggedFields(flexible)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return r, nil
+}
+
+// decodeFetchTopics returns the topic names in a fetch request
+func decodeFetchTopics(d *decoder, version int16) ([]string, error) {
+	flexible := version >= fetchFlexibleVersion
+	d.skipTaggedFields(flexible)
+	d.readInt32() // replica id
+	d.readInt32() // max wait
+	d.readInt32() // min bytes
+	if version >= 3 {
+		d.readInt32() // max bytes
+	}
+	if version >= 4 {
+		d.readInt8() // isolation level
+	}
+	if version >= 7 {
+		d.readInt32() // session id
+		d.readInt32() // session epoch
+	}
+	var topics []string
+	for n := d.readArrayLen(flexible); n > 0 && d.err == nil; n-- {
+		topics = append(topics, d.readString(flexible))
+		for m := d.readArrayLen(flexible); m > 0 && d.err == nil; m-- {
+			d.readInt32() // partition
+			if version >= 9 {
+				d.readInt32() // current leader epoch
+			}
+			d.readInt64() // fetch offset
+			if version >= 12 {
+				d.readInt32() // last fetched epoch
+			}
+			if version >= 5 {
+				d.readInt64() // log start offset
+			}
+			d.readInt32() // partition max bytes
+			d.skipTaggedFields(flexible)
+		}
+		d.skipTaggedFields(flexible)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return topics, nil
+}
+
+// encoder writes the fields of a message
+type encoder struct {
+	data     []byte
+	flexible bool
+}
+
+func (e *encoder) writeInt16(v int16) {
+	e.data = append(e.data, byte(v>>8), byte(v))
+}
+
+func (e *encoder) writeInt32(v int32) {
+	e.data = append(e.data, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func (e *encoder) writeInt64(v int64) {
+	e.writeInt32(int32(v >> 32))
+	e.writeInt32(int32(v))
+}
+
+func (e *encoder) writeUvarint(v int) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], uint64(v))
+	e.data = append(e.data, b[:n]...)
+}
+
+func (e *encoder) writeArrayLen(n int) {
+	if e.flexible {
+		e.writeUvarint(n + 1)
+		return
+	}
+	e.writeInt32(int32(n))
+}
+
+func (e *encoder) writeString(s string) {
+	if e.flexible {
+		e.writeUvarint(len(s) + 1)
+	} else {
+		e.writeInt16(int16(len(s)))
+	}
+	e.data = append(e.data, s...)
+}
+
+func (e *encoder) writeNullString() {
+	if e.flexible {
+		e.data = append(e.data, 0)
+		return
+	}
+	e.writeInt16(-1)
+}
+
+func (e *encoder) writeTaggedFields() {
+	if e.flexible {
+		e.data = append(e.data, 0)
+	}
+}
+
+// encodeProduceResponse encodes a produce response that all the partitions are failed with the error code
+func encodeProduceResponse(correlationID int32, version int16, topics []ProduceTopic, errorCode int16) []byte {
+	e := &encoder{
+		data:     make([]byte, 4, 64),
+		flexible: version >= produceFlexibleVersion,
+	}
+	e.writeInt32(correlationID)
+	e.writeTaggedFields()
+	e.writeArrayLen(len(topics))
+	for _, t := range topics {
+		e.writeString(t.Name)
+		e.writeArrayLen(len(t.Partitions))
+		for _, p := range t.Partitions {
+			e.writeInt32(p)
+			e.writeInt16(errorCode)
+			e.writeInt64(-1) // base offset
+			if version >= 2 {
+				e.writeInt64(-1) // log append time
+			}
+			if version >= 5 {
+				e.writeInt64(-1) // log start offset
+			}
+			if version >= 8 {
+				e.writeArrayLen(0) // record errors
+				e.writeNullString()
+			}
+			e.writeTaggedFields()
+		}
+		e.writeTaggedFields()
+	}
+	if version >= 1 {
+		e.writeInt32(0) // throttle time
+	}
+	e.writeTaggedFields()
+	binary.BigEndian.PutUint32(e.data, uint32(len(e.data)-4))
+	return e.data
+}
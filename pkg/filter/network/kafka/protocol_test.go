@@ -0,0 +1,244 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func writeRequestHeader(e *encoder, key, version int16, correlationID int32, clientID string) {
+	e.writeInt16(key)
+	e.writeInt16(version)
+	e.writeInt32(correlationID)
+	flexible := e.flexible
+	// the client id is not a compact string in the flexible header
+	e.flexible = false
+	e.writeString(clientID)
+	e.flexible = flexible
+	e.writeTaggedFields()
+}
+
+// writeTestTaggedFields writes a tagged field, so the decoder should skip it
+func writeTestTaggedFields(e *encoder) {
+	if e.flexible {
+		e.data = append(e.data, 1, 0, 2, 'x', 'y')
+	}
+}
+
+func finishFrame(e *encoder) []byte {
+	binary.BigEndian.PutUint32(e.data, uint32(len(e.data)-4))
+	return e.data
+}
+
+func buildProduce(version int16, correlationID int32, clientID string, acks int16, topics ...string) []byte {
+	e := &encoder{data: make([]byte, 4), flexible: version >= produceFlexibleVersion}
+	writeRequestHeader(e, Produce, version, correlationID, clientID)
+	if version >= 3 {
+		e.writeNullString()
+	}
+	e.writeInt16(acks)
+	e.writeInt32(30000)
+	e.writeArrayLen(len(topics))
+	records := []byte("records")
+	for _, t := range topics {
+		e.writeString(t)
+		e.writeArrayLen(2)
+		for p := int32(0); p < 2; p++ {
+			e.writeInt32(p)
+			if e.flexible {
+				e.writeUvarint(len(records) + 1)
+			} else {
+				e.writeInt32(int32(len(records)))
+			}
+			e.data = append(e.data, records...)
+			e.writeTaggedFields()
+		}
+		e.writeTaggedFields()
+	}
+	writeTestTaggedFields(e)
+	return finishFrame(e)
+}
+
+func buildFetch(version int16, correlationID int32, topics ...string) []byte {
+	e := &encoder{data: make([]byte, 4), flexible: version >= fetchFlexibleVersion}
+	writeRequestHeader(e, Fetch, version, correlationID, "consumer")
+	e.writeInt32(-1)
+	e.writeInt32(500)
+	e.writeInt32(1)
+	if version >= 3 {
+		e.writeInt32(1 << 20)
+	}
+	if version >= 4 {
+		e.data = append(e.data, 0)
+	}
+	if version >= 7 {
+		e.writeInt32(0)
+		e.writeInt32(-1)
+	}
+	e.writeArrayLen(len(topics))
+	for _, t := range topics {
+		e.writeString(t)
+		e.writeArrayLen(1)
+		e.writeInt32(0)
+		if version >= 9 {
+			e.writeInt32(-1)
+		}
+		e.writeInt64(100)
+		if version >= 12 {
+			e.writeInt32(-1)
+		}
+		if version >= 5 {
+			e.writeInt64(-1)
+		}
+		e.writeInt32(1 << 20)
+		e.writeTaggedFields()
+		e.writeTaggedFields()
+	}
+	if version >= 7 {
+		e.writeArrayLen(0) // forgotten topics
+	}
+	if version >= 11 {
+		e.writeString("")
+	}
+	writeTestTaggedFields(e)
+	return finishFrame(e)
+}
+
+func TestReadFrame(t *testing.T) {
+	frame := buildProduce(3, 1, "producer", 1, "orders")
+	for i := 0; i < len(frame); i++ {
+		if _, err := readFrame(frame[:i], 0); err != errNeedMore {
+			t.Fatalf("read %d bytes, expected need more, but got %v", i, err)
+		}
+	}
+	read, err := readFrame(append(frame, 0, 0), 0)
+	if err != nil || len(read) != len(frame) {
+		t.Fatalf("unexpected frame, %v", err)
+	}
+	if _, err := readFrame(frame, 10); err != ErrRequestTooLarge {
+		t.Fatalf("expected request too large, but got %v", err)
+	}
+	if _, err := readFrame([]byte{0xff, 0xff, 0xff, 0xff}, 0); err != ErrMalformedRequest {
+		t.Fatalf("expected malformed request, but got %v", err)
+	}
+}
+
+func TestDecodeProduce(t *testing.T) {
+	for _, version := range []int16{0, 3, 8, 9, 12} {
+		frame := buildProduce(version, 7, "producer", -1, "orders", "logs")
+		header, d, err := decodeRequestHeader(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.APIKey != Produce || header.APIVersion != version || header.CorrelationID != 7 || header.ClientID != "producer" {
+			t.Fatalf("version %d unexpected header %+v", version, header)
+		}
+		produce, err := decodeProduce(d, version)
+		if err != nil {
+			t.Fatalf("version %d decode failed: %v", version, err)
+		}
+		if produce.Acks != -1 || len(produce.Topics) != 2 || produce.Topics[1].Name != "logs" ||
+			len(produce.Topics[0].Partitions) != 2 || produce.Topics[0].RecordsSize != 14 {
+			t.Fatalf("version %d unexpected produce %+v", version, produce)
+		}
+		if len(d.data) != 0 {
+			t.Fatalf("version %d has %d bytes left", version, len(d.data))
+		}
+		// truncated
+		_, d, _ = decodeRequestHeader(frame[:len(frame)-10])
+		if _, err := decodeProduce(d, version); err != ErrMalformedRequest {
+			t.Fatalf("version %d expected malformed request, but got %v", version, err)
+		}
+	}
+}
+
+func TestDecodeFetchTopics(t *testing.T) {
+	for _, version := range []int16{0, 4, 7, 9, 11, 12} {
+		_, d, err := decodeRequestHeader(buildFetch(version, 1, "orders", "logs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics, err := decodeFetchTopics(d, version)
+		if err != nil {
+			t.Fatalf("version %d decode failed: %v", version, err)
+		}
+		if len(topics) != 2 || topics[0] != "orders" || topics[1] != "logs" {
+			t.Fatalf("version %d unexpected topics %v", version, topics)
+		}
+	}
+}
+
+func TestEncodeProduceResponse(t *testing.T) {
+	topics := []ProduceTopic{{Name: "orders", Partitions: []int32{0, 3}}}
+	for _, version := range []int16{0, 1, 2, 5, 8, 9, 12} {
+		frame := encodeProduceResponse(5, version, topics, ErrorTopicAuthorizationFailed)
+		flexible := version >= produceFlexibleVersion
+		if int(binary.BigEndian.Uint32(frame)) != len(frame)-4 {
+			t.Fatalf("version %d unexpected size", version)
+		}
+		d := &decoder{data: frame[4:]}
+		if d.readInt32() != 5 {
+			t.Fatalf("version %d unexpected correlation id", version)
+		}
+		d.skipTaggedFields(flexible)
+		if d.readArrayLen(flexible) != 1 || d.readString(flexible) != "orders" || d.readArrayLen(flexible) != 2 {
+			t.Fatalf("version %d unexpected topics", version)
+		}
+		for _, p := range []int32{0, 3} {
+			if d.readInt32() != p || d.readInt16() != ErrorTopicAuthorizationFailed || d.readInt64() != -1 {
+				t.Fatalf("version %d unexpected partition", version)
+			}
+			if version >= 2 {
+				d.readInt64()
+			}
+			if version >= 5 {
+				d.readInt64()
+			}
+			if version >= 8 {
+				if d.readArrayLen(flexible) != 0 || d.readString(flexible) != "" {
+					t.Fatalf("version %d unexpected record errors", version)
+				}
+			}
+			d.skipTaggedFields(flexible)
+		}
+		d.skipTaggedFields(flexible)
+		if version >= 1 && d.readInt32() != 0 {
+			t.Fatalf("version %d unexpected throttle time", version)
+		}
+		d.skipTaggedFields(flexible)
+		if d.err != nil || len(d.data) != 0 {
+			t.Fatalf("version %d unexpected response, %v, %d bytes left", version, d.err, len(d.data))
+		}
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	if key, ok := apiKey("Fetch"); !ok || key != Fetch {
+		t.Fatal("unexpected api key of Fetch")
+	}
+	if key, ok := apiKey("50"); !ok || key != 50 {
+		t.Fatal("unexpected api key of 50")
+	}
+	if _, ok := apiKey("Unknown"); ok {
+		t.Fatal("unexpected api key of Unknown")
+	}
+	if apiName(50) != unknownAPIName {
+		t.Fatal("unexpected api name of 50")
+	}
+}
@@ -0,0 +1,269 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/filter/network/internal/tokenbucket"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+func init() {
+	api.RegisterNetwork(v2.MQTT_PROXY, CreateMQTTProxyFactory)
+}
+
+// hash keys to select the broker cluster
+const (
+	HashByClientID = "client_id"
+	HashByUsername = "username"
+)
+
+// otherTopicPrefix is the topic prefix label of the topics not matched by any configured prefixes
+const otherTopicPrefix = "other"
+
+// Config is the config of the mqtt_proxy filter
+//
+//	{
+//		"type": "mqtt_proxy",
+//		"config": {
+//			"stat_prefix": "iot",
+//			"clusters": ["broker_a", "broker_b"],
+//			"hash_key": "client_id",
+//			"publish_allow": ["devices/+/telemetry", "devices/+/status"],
+//			"subscribe_allow": ["devices/+/commands/#"],
+//			"publish_rate_limit": {
+//				"rate": 10,
+//				"burst": 20
+//			},
+//			"topic_prefixes": ["devices/"]
+//		}
+//	}
+type Config struct {
+	// StatPrefix is the label of the metrics, the default is mqtt_proxy
+	StatPrefix string `json:"stat_prefix,omitempty"`
+	// Clusters are the broker clusters, a connection is routed to one of them by the hash of the HashKey
+	Clusters []string `json:"clusters"`
+	// HashKey is client_id or username, the default is client_id.
+	// The connections without a username are routed by the client id.
+	HashKey string `json:"hash_key,omitempty"`
+	// PublishAllow is the topic filters a client can publish to, empty means all topics
+	PublishAllow []string `json:"publish_allow,omitempty"`
+	// SubscribeAllow is the topic filters a client can subscribe to, empty means all topics.
+	// A subscription is allowed only if all the topics it matches are allowed.
+	SubscribeAllow []string `json:"subscribe_allow,omitempty"`
+	// PublishRateLimit limits the publish rate of each client id, nil means no limit
+	PublishRateLimit *RateLimit `json:"publish_rate_limit,omitempty"`
+	// TopicPrefixes groups the topic metrics by the longest matched prefix,
+	// the topics not matched are grouped as other.
+	TopicPrefixes []string `json:"topic_prefixes,omitempty"`
+	// MaxPacketSize limits the remaining length of a packet, the default is 1MB
+	MaxPacketSize int `json:"max_packet_size,omitempty"`
+}
+
+// RateLimit is a token bucket limit
+type RateLimit struct {
+	// Rate is the publish packets per second
+	Rate float64 `json:"rate"`
+	// Burst is the max publish packets in a burst, the default is the rate rounded up
+	Burst int `json:"burst,omitempty"`
+}
+
+// ParseConfig parses the config of the mqtt_proxy filter
+func ParseConfig(cfg map[string]interface{}) (*Config, error) {
+	c := &Config{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if len(c.Clusters) == 0 {
+		return nil, errors.New("clusters is required")
+	}
+	switch c.HashKey {
+	case "":
+		c.HashKey = HashByClientID
+	case HashByClientID, HashByUsername:
+	default:
+		return nil, fmt.Errorf("unknown hash_key %s", c.HashKey)
+	}
+	if c.StatPrefix == "" {
+		c.StatPrefix = v2.MQTT_PROXY
+	}
+	for _, filter := range append(append([]string{}, c.PublishAllow...), c.SubscribeAllow...) {
+		if err := validateFilter(filter); err != nil {
+			return nil, err
+		}
+	}
+	if rl := c.PublishRateLimit; rl != nil {
+		if rl.Rate <= 0 {
+			return nil, errors.New("publish_rate_limit.rate should be positive")
+		}
+		if rl.Burst <= 0 {
+			rl.Burst = int(math.Ceil(rl.Rate))
+		}
+	}
+	if c.MaxPacketSize < 0 || c.MaxPacketSize > maxRemainingLength {
+		return nil, fmt.Errorf("max_packet_size should be in [0, %d]", maxRemainingLength)
+	}
+	if c.MaxPacketSize == 0 {
+		c.MaxPacketSize = defaultMaxPacketSize
+	}
+	return c, nil
+}
+
+// validateFilter checks the wildcards in a topic filter
+func validateFilter(filter string) error {
+	if filter == "" {
+		return errors.New("empty topic filter")
+	}
+	levels := strings.Split(filter, "/")
+	for i, l := range levels {
+		if strings.Contains(l, "#") && (l != "#" || i != len(levels)-1) {
+			return fmt.Errorf("invalid topic filter %s, # should be the last level", filter)
+		}
+		if strings.Contains(l, "+") && l != "+" {
+			return fmt.Errorf("invalid topic filter %s, + should occupy a level", filter)
+		}
+	}
+	return nil
+}
+
+// topicStats is the metrics of the topics under a prefix
+type topicStats struct {
+	publishTotal       gometrics.Counter
+	publishBytes       gometrics.Counter
+	publishDenied      gometrics.Counter
+	publishRateLimited gometrics.Counter
+	subscribeTotal     gometrics.Counter
+	subscribeDenied    gometrics.Counter
+}
+
+func newTopicStats(statPrefix, topicPrefix string) *topicStats {
+	s := metrics.NewMQTTTopicStats(statPrefix, topicPrefix)
+	return &topicStats{
+		publishTotal:       s.Counter(metrics.MQTTPublishTotal),
+		publishBytes:       s.Counter(metrics.MQTTPublishBytes),
+		publishDenied:      s.Counter(metrics.MQTTPublishDenied),
+		publishRateLimited: s.Counter(metrics.MQTTPublishRateLimited),
+		subscribeTotal:     s.Counter(metrics.MQTTSubscribeTotal),
+		subscribeDenied:    s.Counter(metrics.MQTTSubscribeDenied),
+	}
+}
+
+// mqttProxyFactory holds the state shared by the connections created by the factory,
+// the topic stats and the publish limiters of the connected clients.
+type mqttProxyFactory struct {
+	config *Config
+
+	// prefixes are sorted by length in descending order, so the first matched is the longest
+	prefixes []string
+	stats    map[string]*topicStats
+
+	// limiters is nil if the publish rate is not limited
+	limiters *tokenbucket.Buckets
+}
+
+// CreateMQTTProxyFactory creates the factory of the mqtt_proxy filter
+func CreateMQTTProxyFactory(conf map[string]interface{}) (api.NetworkFilterChainFactory, error) {
+	c, err := ParseConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	return newMQTTProxyFactory(c), nil
+}
+
+func newMQTTProxyFactory(c *Config) *mqttProxyFactory {
+	f := &mqttProxyFactory{
+		config: c,
+		stats:  map[string]*topicStats{},
+	}
+	if rl := c.PublishRateLimit; rl != nil {
+		f.limiters = tokenbucket.NewBuckets(rl.Rate, rl.Burst)
+	}
+	for _, prefix := range append(append([]string{}, c.TopicPrefixes...), otherTopicPrefix) {
+		if _, ok := f.stats[prefix]; ok {
+			continue
+		}
+		f.stats[prefix] = newTopicStats(c.StatPrefix, prefix)
+		if prefix != otherTopicPrefix {
+			f.prefixes = append(f.prefixes, prefix)
+		}
+	}
+	sort.SliceStable(f.prefixes, func(i, j int) bool {
+		return len(f.prefixes[i]) > len(f.prefixes[j])
+	})
+	return f
+}
+
+func (f *mqttProxyFactory) CreateFilterChain(ctx context.Context, callbacks api.NetWorkFilterChainFactoryCallbacks) {
+	callbacks.AddReadFilter(newProxy(ctx, f))
+}
+
+// topicStats returns the stats of the longest prefix matched by the topic
+func (f *mqttProxyFactory) topicStats(topic string) *topicStats {
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(topic, prefix) {
+			return f.stats[prefix]
+		}
+	}
+	return f.stats[otherTopicPrefix]
+}
+
+// selectCluster returns the broker cluster of a connection
+func (f *mqttProxyFactory) selectCluster(c *Connect) string {
+	key := c.ClientID
+	if f.config.HashKey == HashByUsername && c.Username != "" {
+		key = c.Username
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return f.config.Clusters[h.Sum32()%uint32(len(f.config.Clusters))]
+}
+
+// acquireLimiter returns the publish limiter of a client id, nil if the rate is not limited
+func (f *mqttProxyFactory) acquireLimiter(clientID string) *tokenbucket.Bucket {
+	rl := f.config.PublishRateLimit
+	if rl == nil {
+		return nil
+	}
+	// the clients without a client id cannot be told apart, each connection has its own bucket
+	if clientID == "" {
+		return tokenbucket.New(rl.Rate, rl.Burst, time.Now())
+	}
+	return f.limiters.Acquire(clientID)
+}
+
+func (f *mqttProxyFactory) releaseLimiter(clientID string) {
+	if f.limiters != nil {
+		f.limiters.Release(clientID)
+	}
+}
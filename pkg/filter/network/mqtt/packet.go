@@ -0,0 +1,467 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// packet types of mqtt control packets
+const (
+	CONNECT     byte = 1
+	CONNACK     byte = 2
+	PUBLISH     byte = 3
+	PUBACK      byte = 4
+	PUBREC      byte = 5
+	PUBREL      byte = 6
+	PUBCOMP     byte = 7
+	SUBSCRIBE   byte = 8
+	SUBACK      byte = 9
+	UNSUBSCRIBE byte = 10
+	UNSUBACK    byte = 11
+	PINGREQ     byte = 12
+	PINGRESP    byte = 13
+	DISCONNECT  byte = 14
+	AUTH        byte = 15
+)
+
+// protocol levels in the CONNECT packet
+const (
+	Version31  byte = 3
+	Version311 byte = 4
+	Version5   byte = 5
+)
+
+// reason codes used by the proxy
+const (
+	// SubackFailure is the failure return code of a 3.1.1 SUBACK
+	SubackFailure byte = 0x80
+	// ReasonNotAuthorized is the 5.0 reason code of the denied topics
+	ReasonNotAuthorized byte = 0x87
+	// ReasonMessageRateTooHigh is the 5.0 reason code of the rate limited publishes
+	ReasonMessageRateTooHigh byte = 0x96
+)
+
+// maxRemainingLength is the max remaining length can be encoded in 4 bytes
+const maxRemainingLength = 268435455
+
+// maxConnectSize limits the remaining length of the CONNECT, it is buffered
+// before the client is known.
+const maxConnectSize = 64 * 1024
+
+// propertyTopicAlias is the identifier of the 5.0 topic alias property
+const propertyTopicAlias byte = 0x23
+
+var (
+	errNeedMore         = errors.New("mqtt: need more data")
+	ErrMalformedPacket  = errors.New("mqtt: malformed packet")
+	ErrPacketTooLarge   = errors.New("mqtt: packet too large")
+	ErrUnknownProperty  = errors.New("mqtt: unknown property")
+	ErrProtocolViolated = errors.New("mqtt: protocol violated")
+)
+
+// packet is a mqtt control packet, raw is the whole packet and body is the
+// variable header and payload in raw.
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+	raw   []byte
+}
+
+// readPacket reads a packet from the data, returns errNeedMore if the data is not complete.
+// maxSize limits the remaining length, zero means the protocol limit.
+func readPacket(data []byte, maxSize int) (*packet, error) {
+	if len(data) < 2 {
+		return nil, errNeedMore
+	}
+	remaining, n, err := readVarint(data[1:])
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && remaining > maxSize {
+		return nil, ErrPacketTooLarge
+	}
+	total := 1 + n + remaining
+	if len(data) < total {
+		return nil, errNeedMore
+	}
+	return &packet{
+		typ:   data[0] >> 4,
+		flags: data[0] & 0x0f,
+		body:  data[1+n : total],
+		raw:   data[:total],
+	}, nil
+}
+
+// readVarint reads a variable byte integer, returns the value and the bytes it takes
+func readVarint(data []byte) (int, int, error) {
+	value, multiplier := 0, 1
+	for i := 0; i < 4; i++ {
+		if i >= len(data) {
+			return 0, 0, errNeedMore
+		}
+		value += int(data[i]&0x7f) * multiplier
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+		multiplier *= 128
+	}
+	return 0, 0, ErrMalformedPacket
+}
+
+func appendVarint(b []byte, v int) []byte {
+	for {
+		c := byte(v % 128)
+		v /= 128
+		if v > 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+// appendPacket appends the fixed header and the body
+func appendPacket(b []byte, typ, flags byte, body []byte) []byte {
+	b = append(b, typ<<4|flags&0x0f)
+	b = appendVarint(b, len(body))
+	return append(b, body...)
+}
+
+// decoder reads the fields of a packet body, the first error is kept
+// and the following reads return zero values.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = ErrMalformedPacket
+	}
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.data) < n {
+		d.fail()
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) readByte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) readUint16() uint16 {
+	if b := d.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) readBinary() []byte {
+	return d.next(int(d.readUint16()))
+}
+
+func (d *decoder) readString() string {
+	return string(d.readBinary())
+}
+
+func (d *decoder) readVarint() int {
+	if d.err != nil {
+		return 0
+	}
+	v, n, err := readVarint(d.data)
+	if err != nil {
+		d.fail()
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// readProperties reads the properties of a 5.0 packet, returns the raw properties
+// without the length.
+func (d *decoder) readProperties(version byte) []byte {
+	if version < Version5 {
+		return nil
+	}
+	return d.next(d.readVarint())
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+func appendProperties(b []byte, version byte, props []byte) []byte {
+	if version < Version5 {
+		return b
+	}
+	b = appendVarint(b, len(props))
+	return append(b, props...)
+}
+
+// walkProperties calls f with the identifier and the value of each property
+func walkProperties(props []byte, f func(id byte, value []byte)) error {
+	d := &decoder{data: props}
+	for len(d.data) > 0 && d.err == nil {
+		id := d.readByte()
+		start := d.data
+		switch id {
+		case 0x01, 0x17, 0x19, 0x24, 0x25, 0x28, 0x29, 0x2a:
+			d.next(1)
+		case 0x13, 0x21, 0x22, 0x23:
+			d.next(2)
+		case 0x02, 0x11, 0x18, 0x27:
+			d.next(4)
+		case 0x03, 0x08, 0x09, 0x12, 0x15, 0x16, 0x1a, 0x1c, 0x1f:
+			d.readBinary()
+		case 0x0b:
+			d.readVarint()
+		case 0x26:
+			d.readBinary()
+			d.readBinary()
+		default:
+			return ErrUnknownProperty
+		}
+		if d.err != nil {
+			return d.err
+		}
+		f(id, start[:len(start)-len(d.data)])
+	}
+	return d.err
+}
+
+// Connect is a decoded CONNECT packet
+type Connect struct {
+	ProtocolName string
+	Version      byte
+	Flags        byte
+	KeepAlive    uint16
+	ClientID     string
+	Username     string
+}
+
+func decodeConnect(p *packet) (*Connect, error) {
+	d := &decoder{data: p.body}
+	c := &Connect{
+		ProtocolName: d.readString(),
+		Version:      d.readByte(),
+		Flags:        d.readByte(),
+		KeepAlive:    d.readUint16(),
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	switch c.Version {
+	case Version31, Version311, Version5:
+	default:
+		return nil, fmt.Errorf("mqtt: unsupported protocol level %d", c.Version)
+	}
+	d.readProperties(c.Version)
+	c.ClientID = d.readString()
+	if c.Flags&0x04 != 0 { // will flag
+		d.readProperties(c.Version)
+		d.readString()
+		d.readBinary()
+	}
+	if c.Flags&0x80 != 0 { // username flag
+		c.Username = d.readString()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return c, nil
+}
+
+// Connack is a decoded CONNACK packet
+type Connack struct {
+	SessionPresent bool
+	ReturnCode     byte
+}
+
+func decodeConnack(p *packet) (*Connack, error) {
+	d := &decoder{data: p.body}
+	c := &Connack{
+		SessionPresent: d.readByte()&0x01 != 0,
+		ReturnCode:     d.readByte(),
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return c, nil
+}
+
+// Publish is a decoded PUBLISH packet, the topic is empty if the
+// 5.0 packet uses a topic alias only.
+type Publish struct {
+	Topic      string
+	QoS        byte
+	Dup        bool
+	Retain     bool
+	PacketID   uint16
+	TopicAlias uint16
+	Payload    []byte
+}
+
+func decodePublish(p *packet, version byte) (*Publish, error) {
+	d := &decoder{data: p.body}
+	pub := &Publish{
+		QoS:    (p.flags >> 1) & 0x03,
+		Dup:    p.flags&0x08 != 0,
+		Retain: p.flags&0x01 != 0,
+		Topic:  d.readString(),
+	}
+	if pub.QoS > 2 {
+		return nil, ErrMalformedPacket
+	}
+	if pub.QoS > 0 {
+		pub.PacketID = d.readUint16()
+	}
+	props := d.readProperties(version)
+	if d.err != nil {
+		return nil, d.err
+	}
+	if err := walkProperties(props, func(id byte, value []byte) {
+		if id == propertyTopicAlias {
+			pub.TopicAlias = binary.BigEndian.Uint16(value)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	pub.Payload = d.data
+	return pub, nil
+}
+
+// Ack is a decoded PUBACK, PUBREC, PUBREL, PUBCOMP or UNSUBACK packet,
+// the reason code of a 3.1.1 packet is always zero.
+type Ack struct {
+	PacketID   uint16
+	ReasonCode byte
+}
+
+func decodeAck(p *packet) (*Ack, error) {
+	d := &decoder{data: p.body}
+	a := &Ack{PacketID: d.readUint16()}
+	if len(d.data) > 0 {
+		a.ReasonCode = d.readByte()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return a, nil
+}
+
+// encodeAck encodes a PUBACK or PUBREC of the packet id, the reason code is
+// only encoded in 5.0.
+func encodeAck(typ byte, packetID uint16, reason byte, version byte) []byte {
+	body := []byte{byte(packetID >> 8), byte(packetID)}
+	if version >= Version5 && reason != 0 {
+		body = append(body, reason)
+	}
+	return appendPacket(nil, typ, 0, body)
+}
+
+// Subscription is a topic filter and its options in a SUBSCRIBE packet
+type Subscription struct {
+	Filter  string
+	Options byte
+}
+
+// Subscribe is a decoded SUBSCRIBE packet
+type Subscribe struct {
+	PacketID      uint16
+	Properties    []byte
+	Subscriptions []Subscription
+}
+
+func decodeSubscribe(p *packet, version byte) (*Subscribe, error) {
+	if p.flags != 0x02 {
+		return nil, ErrMalformedPacket
+	}
+	d := &decoder{data: p.body}
+	s := &Subscribe{
+		PacketID:   d.readUint16(),
+		Properties: d.readProperties(version),
+	}
+	for len(d.data) > 0 && d.err == nil {
+		s.Subscriptions = append(s.Subscriptions, Subscription{
+			Filter:  d.readString(),
+			Options: d.readByte(),
+		})
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(s.Subscriptions) == 0 {
+		return nil, ErrProtocolViolated
+	}
+	return s, nil
+}
+
+func encodeSubscribe(s *Subscribe, version byte) []byte {
+	body := []byte{byte(s.PacketID >> 8), byte(s.PacketID)}
+	body = appendProperties(body, version, s.Properties)
+	for _, sub := range s.Subscriptions {
+		body = appendString(body, sub.Filter)
+		body = append(body, sub.Options)
+	}
+	return appendPacket(nil, SUBSCRIBE, 0x02, body)
+}
+
+// Suback is a decoded SUBACK packet, a return code for each subscription
+type Suback struct {
+	PacketID    uint16
+	Properties  []byte
+	ReturnCodes []byte
+}
+
+func decodeSuback(p *packet, version byte) (*Suback, error) {
+	d := &decoder{data: p.body}
+	s := &Suback{
+		PacketID:   d.readUint16(),
+		Properties: d.readProperties(version),
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	s.ReturnCodes = d.data
+	return s, nil
+}
+
+func encodeSuback(s *Suback, version byte) []byte {
+	body := []byte{byte(s.PacketID >> 8), byte(s.PacketID)}
+	body = appendProperties(body, version, s.Properties)
+	body = append(body, s.ReturnCodes...)
+	return appendPacket(nil, SUBACK, 0, body)
+}
@@ -0,0 +1,238 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func buildConnect(version byte, clientID, username string) []byte {
+	name := "MQTT"
+	if version == Version31 {
+		name = "MQIsdp"
+	}
+	body := appendString(nil, name)
+	flags := byte(0x02) // clean session
+	if username != "" {
+		flags |= 0x80
+	}
+	// a will message to check the will fields are skipped
+	flags |= 0x04
+	body = append(body, version, flags, 0, 60)
+	body = appendProperties(body, version, []byte{0x11, 0, 0, 0, 10}) // session expiry
+	body = appendString(body, clientID)
+	body = appendProperties(body, version, []byte{0x18, 0, 0, 0, 5}) // will delay
+	body = appendString(body, "will/topic")
+	body = appendString(body, "bye")
+	if username != "" {
+		body = appendString(body, username)
+	}
+	return appendPacket(nil, CONNECT, 0, body)
+}
+
+func buildPublish(version byte, topic string, qos byte, packetID, alias uint16, payload string) []byte {
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = append(body, byte(packetID>>8), byte(packetID))
+	}
+	var props []byte
+	if alias != 0 {
+		props = []byte{propertyTopicAlias, byte(alias >> 8), byte(alias)}
+	}
+	body = appendProperties(body, version, props)
+	body = append(body, payload...)
+	return appendPacket(nil, PUBLISH, qos<<1, body)
+}
+
+func buildSubscribe(version byte, packetID uint16, filters ...string) []byte {
+	s := &Subscribe{PacketID: packetID}
+	for _, f := range filters {
+		s.Subscriptions = append(s.Subscriptions, Subscription{Filter: f, Options: 1})
+	}
+	return encodeSubscribe(s, version)
+}
+
+func mustReadPacket(t *testing.T, data []byte) *packet {
+	pkt, err := readPacket(data, 0)
+	if err != nil {
+		t.Fatalf("read packet failed: %v", err)
+	}
+	return pkt
+}
+
+func TestReadPacket(t *testing.T) {
+	data := buildPublish(Version311, "a/b", 0, 0, 0, string(make([]byte, 200)))
+	// the remaining length takes 2 bytes
+	if data[1]&0x80 == 0 {
+		t.Fatalf("unexpected remaining length %x", data[1])
+	}
+	for i := 0; i < len(data); i++ {
+		if _, err := readPacket(data[:i], 0); err != errNeedMore {
+			t.Fatalf("read %d bytes, expected need more, but got %v", i, err)
+		}
+	}
+	pkt, err := readPacket(append(data, 0xc0, 0), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkt.typ != PUBLISH || !bytes.Equal(pkt.raw, data) || len(pkt.body) != len(data)-3 {
+		t.Fatalf("unexpected packet %+v", pkt)
+	}
+	if _, err := readPacket(data, 100); err != ErrPacketTooLarge {
+		t.Fatalf("expected packet too large, but got %v", err)
+	}
+	if _, err := readPacket([]byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01}, 0); err != ErrMalformedPacket {
+		t.Fatalf("expected malformed packet, but got %v", err)
+	}
+}
+
+func TestDecodeConnect(t *testing.T) {
+	for _, version := range []byte{Version31, Version311, Version5} {
+		c, err := decodeConnect(mustReadPacket(t, buildConnect(version, "device-1", "alice")))
+		if err != nil {
+			t.Fatalf("version %d decode failed: %v", version, err)
+		}
+		if c.Version != version || c.ClientID != "device-1" || c.Username != "alice" || c.KeepAlive != 60 {
+			t.Fatalf("version %d unexpected connect %+v", version, c)
+		}
+	}
+	// protocol level 6 is not supported
+	data := buildConnect(Version311, "device-1", "")
+	data[2+6] = 6
+	if _, err := decodeConnect(mustReadPacket(t, data)); err == nil {
+		t.Fatal("expected unsupported protocol level")
+	}
+	// truncated body
+	if _, err := decodeConnect(&packet{typ: CONNECT, body: []byte{0, 4, 'M', 'Q'}}); err != ErrMalformedPacket {
+		t.Fatalf("expected malformed packet, but got %v", err)
+	}
+}
+
+func TestDecodePublish(t *testing.T) {
+	pub, err := decodePublish(mustReadPacket(t, buildPublish(Version5, "a/b", 1, 7, 3, "hello")), Version5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.Topic != "a/b" || pub.QoS != 1 || pub.PacketID != 7 || pub.TopicAlias != 3 || string(pub.Payload) != "hello" {
+		t.Fatalf("unexpected publish %+v", pub)
+	}
+	pub, err = decodePublish(mustReadPacket(t, buildPublish(Version311, "a/b", 0, 0, 0, "hello")), Version311)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.Topic != "a/b" || pub.QoS != 0 || string(pub.Payload) != "hello" {
+		t.Fatalf("unexpected publish %+v", pub)
+	}
+	// qos 3 is invalid
+	if _, err := decodePublish(mustReadPacket(t, appendPacket(nil, PUBLISH, 0x06, appendString(nil, "a"))), Version311); err != ErrMalformedPacket {
+		t.Fatalf("expected malformed packet, but got %v", err)
+	}
+	// unknown property
+	data := appendPacket(nil, PUBLISH, 0, append(appendString(nil, "a"), 2, 0x7f, 0))
+	if _, err := decodePublish(mustReadPacket(t, data), Version5); err != ErrUnknownProperty {
+		t.Fatalf("expected unknown property, but got %v", err)
+	}
+}
+
+func TestSubscribeAndSuback(t *testing.T) {
+	for _, version := range []byte{Version311, Version5} {
+		data := buildSubscribe(version, 9, "a/+", "b/#")
+		sub, err := decodeSubscribe(mustReadPacket(t, data), version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub.PacketID != 9 || len(sub.Subscriptions) != 2 || sub.Subscriptions[1].Filter != "b/#" {
+			t.Fatalf("unexpected subscribe %+v", sub)
+		}
+		if !bytes.Equal(encodeSubscribe(sub, version), data) {
+			t.Fatal("encode subscribe is not the same as the original")
+		}
+		ackData := encodeSuback(&Suback{PacketID: 9, ReturnCodes: []byte{0, 1}}, version)
+		ack, err := decodeSuback(mustReadPacket(t, ackData), version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ack.PacketID != 9 || !bytes.Equal(ack.ReturnCodes, []byte{0, 1}) {
+			t.Fatalf("unexpected suback %+v", ack)
+		}
+	}
+	// the reserved flags of SUBSCRIBE must be 0x02
+	if _, err := decodeSubscribe(&packet{typ: SUBSCRIBE, body: []byte{0, 1, 0, 1, 'a', 0}}, Version311); err != ErrMalformedPacket {
+		t.Fatalf("expected malformed packet, but got %v", err)
+	}
+	// a SUBSCRIBE without filters
+	if _, err := decodeSubscribe(&packet{typ: SUBSCRIBE, flags: 0x02, body: []byte{0, 1}}, Version311); err != ErrProtocolViolated {
+		t.Fatalf("expected protocol violated, but got %v", err)
+	}
+}
+
+func TestAcks(t *testing.T) {
+	ack, err := decodeAck(mustReadPacket(t, encodeAck(PUBACK, 5, ReasonNotAuthorized, Version5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack.PacketID != 5 || ack.ReasonCode != ReasonNotAuthorized {
+		t.Fatalf("unexpected ack %+v", ack)
+	}
+	// the reason code is not encoded in 3.1.1
+	if data := encodeAck(PUBREC, 5, ReasonNotAuthorized, Version311); !bytes.Equal(data, []byte{0x50, 2, 0, 5}) {
+		t.Fatalf("unexpected ack %x", data)
+	}
+	connack, err := decodeConnack(mustReadPacket(t, []byte{0x20, 2, 1, 5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !connack.SessionPresent || connack.ReturnCode != 5 {
+		t.Fatalf("unexpected connack %+v", connack)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	cases := []struct {
+		pattern string
+		filter  string
+		expect  bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/+", true},
+		{"a/+", "a/#", false},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a/+/c", true},
+		{"a/b", "a/+", false},
+		{"#", "a/b", true},
+		{"#", "$SYS/uptime", false},
+		{"+/uptime", "$SYS/uptime", false},
+		{"$SYS/#", "$SYS/uptime", true},
+	}
+	for _, c := range cases {
+		if covers(c.pattern, c.filter) != c.expect {
+			t.Errorf("covers(%s, %s) expected %v", c.pattern, c.filter, c.expect)
+		}
+	}
+	if !allowed(nil, "any") {
+		t.Error("empty patterns should allow all")
+	}
+	if unshare("$share/group/a/b") != "a/b" || unshare("a/b") != "a/b" {
+		t.Error("unexpected unshare result")
+	}
+}
@@ -0,0 +1,497 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/filter/network/internal/tokenbucket"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+	"mosn.io/pkg/buffer"
+)
+
+var (
+	errNoCluster       = errors.New("mqtt: no broker cluster")
+	errNoUpstream      = errors.New("mqtt: no healthy broker")
+	errPublishRejected = errors.New("mqtt: publish rejected")
+)
+
+const defaultConnectRetryTimes = 3
+
+const defaultMaxPacketSize = 1024 * 1024
+
+// proxy is a ReadFilter that proxies a mqtt connection to a broker.
+// The downstream data is buffered until the CONNECT packet is received,
+// the broker cluster is selected by the CONNECT and the packets are inspected
+// in both directions after the upstream connection is created.
+type proxy struct {
+	factory        *mqttProxyFactory
+	ctx            context.Context
+	clusterManager types.ClusterManager
+	readCallbacks  api.ReadFilterCallbacks
+
+	upstreamConnection types.ClientConnection
+	clusterInfo        types.ClusterInfo
+
+	downstreamData []byte
+	upstreamData   []byte
+
+	// connect is the CONNECT of the client, nil before the CONNECT is received
+	connect *Connect
+	limiter *tokenbucket.Bucket
+	// aliases are the topic aliases of the forwarded publishes
+	aliases     map[uint16]string
+	releaseOnce sync.Once
+
+	// deniedSubscriptions records the denied filters of the forwarded SUBSCRIBE by the packet id,
+	// the return codes of the denied filters are merged into the SUBACK from the broker
+	mux                 sync.Mutex
+	deniedSubscriptions map[uint16][]bool
+}
+
+func newProxy(ctx context.Context, f *mqttProxyFactory) *proxy {
+	return &proxy{
+		factory:             f,
+		ctx:                 ctx,
+		clusterManager:      cluster.GetClusterMngAdapterInstance().ClusterManager,
+		aliases:             map[uint16]string{},
+		deniedSubscriptions: map[uint16][]bool{},
+	}
+}
+
+func (p *proxy) version() byte {
+	if p.connect == nil {
+		return Version311
+	}
+	return p.connect.Version
+}
+
+func (p *proxy) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {
+	p.readCallbacks = cb
+	p.readCallbacks.Connection().AddConnectionEventListener(&downstreamCallbacks{proxy: p})
+}
+
+// OnNewConnection does nothing, the upstream connection is created after the CONNECT is received
+func (p *proxy) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (p *proxy) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	p.downstreamData = append(p.downstreamData, buf.Bytes()...)
+	buf.Drain(buf.Len())
+
+	var toUpstream, toDownstream []byte
+	for {
+		pkt, err := p.readDownstreamPacket()
+		if err == errNeedMore {
+			break
+		}
+		if err == nil {
+			p.downstreamData = p.downstreamData[len(pkt.raw):]
+			if p.connect == nil {
+				err = p.onConnect(pkt)
+				toUpstream = append(toUpstream, pkt.raw...)
+			} else {
+				var forward, reply []byte
+				forward, reply, err = p.onDownstreamPacket(pkt)
+				toUpstream = append(toUpstream, forward...)
+				toDownstream = append(toDownstream, reply...)
+			}
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[mqtt proxy] close the connection from %s: %v", p.readCallbacks.Connection().RemoteAddr(), err)
+			p.readCallbacks.Connection().Close(api.NoFlush, api.LocalClose)
+			return api.Stop
+		}
+	}
+	if len(p.downstreamData) == 0 {
+		p.downstreamData = nil
+	}
+	if len(toUpstream) > 0 {
+		p.upstreamConnection.Write(buffer.NewIoBufferBytes(toUpstream))
+	}
+	if len(toDownstream) > 0 {
+		p.readCallbacks.Connection().Write(buffer.NewIoBufferBytes(toDownstream))
+	}
+	return api.Stop
+}
+
+// readDownstreamPacket reads a packet from the downstream data. The first packet
+// should be a CONNECT, which is checked by the fixed header before the packet
+// is buffered, and its size is limited by maxConnectSize.
+func (p *proxy) readDownstreamPacket() (*packet, error) {
+	if p.connect == nil {
+		if len(p.downstreamData) > 0 && p.downstreamData[0]>>4 != CONNECT {
+			return nil, ErrProtocolViolated
+		}
+		return readPacket(p.downstreamData, maxConnectSize)
+	}
+	return readPacket(p.downstreamData, p.factory.config.MaxPacketSize)
+}
+
+// onConnect handles the first packet of the connection, which should be a CONNECT
+func (p *proxy) onConnect(pkt *packet) error {
+	if pkt.typ != CONNECT {
+		return ErrProtocolViolated
+	}
+	c, err := decodeConnect(pkt)
+	if err != nil {
+		return err
+	}
+	p.connect = c
+	p.limiter = p.factory.acquireLimiter(c.ClientID)
+	clusterName := p.factory.selectCluster(c)
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[mqtt proxy] client %s, username %s, protocol level %d, route to cluster %s",
+			c.ClientID, c.Username, c.Version, clusterName)
+	}
+	return p.initializeUpstreamConnection(clusterName)
+}
+
+// onDownstreamPacket returns the data forwarded to the broker and the data replied to the client
+func (p *proxy) onDownstreamPacket(pkt *packet) ([]byte, []byte, error) {
+	switch pkt.typ {
+	case CONNECT:
+		return nil, nil, ErrProtocolViolated
+	case PUBLISH:
+		return p.onPublish(pkt)
+	case SUBSCRIBE:
+		return p.onSubscribe(pkt)
+	}
+	return pkt.raw, nil, nil
+}
+
+func (p *proxy) onPublish(pkt *packet) ([]byte, []byte, error) {
+	pub, err := decodePublish(pkt, p.version())
+	if err != nil {
+		return nil, nil, err
+	}
+	topic := pub.Topic
+	if topic == "" {
+		var ok bool
+		if topic, ok = p.aliases[pub.TopicAlias]; !ok {
+			return nil, nil, ErrProtocolViolated
+		}
+	}
+	stats := p.factory.topicStats(topic)
+	if !allowed(p.factory.config.PublishAllow, topic) {
+		stats.publishDenied.Inc(1)
+		return p.rejectPublish(pub, ReasonNotAuthorized)
+	}
+	if p.limiter != nil && !p.limiter.Allow(time.Now()) {
+		stats.publishRateLimited.Inc(1)
+		return p.rejectPublish(pub, ReasonMessageRateTooHigh)
+	}
+	stats.publishTotal.Inc(1)
+	stats.publishBytes.Inc(int64(len(pub.Payload)))
+	// only the aliases of the forwarded publishes are recorded, so the aliases are the same as the broker
+	if pub.Topic != "" && pub.TopicAlias != 0 {
+		p.aliases[pub.TopicAlias] = pub.Topic
+	}
+	return pkt.raw, nil, nil
+}
+
+// rejectPublish drops a QoS 0 publish. A QoS 1 or 2 publish is acknowledged with
+// the reason code in 5.0, there is no negative ack in 3.1.1 so the connection is closed.
+func (p *proxy) rejectPublish(pub *Publish, reason byte) ([]byte, []byte, error) {
+	if pub.QoS == 0 {
+		return nil, nil, nil
+	}
+	if p.version() < Version5 {
+		return nil, nil, errPublishRejected
+	}
+	typ := PUBACK
+	if pub.QoS == 2 {
+		typ = PUBREC
+	}
+	return nil, encodeAck(typ, pub.PacketID, reason, p.version()), nil
+}
+
+func (p *proxy) failureCode() byte {
+	if p.version() < Version5 {
+		return SubackFailure
+	}
+	return ReasonNotAuthorized
+}
+
+// onSubscribe removes the denied filters from the SUBSCRIBE, the SUBACK is replied
+// to the client directly if all the filters are denied.
+func (p *proxy) onSubscribe(pkt *packet) ([]byte, []byte, error) {
+	sub, err := decodeSubscribe(pkt, p.version())
+	if err != nil {
+		return nil, nil, err
+	}
+	denied := make([]bool, len(sub.Subscriptions))
+	subscriptions := make([]Subscription, 0, len(sub.Subscriptions))
+	for i, s := range sub.Subscriptions {
+		filter := unshare(s.Filter)
+		stats := p.factory.topicStats(filter)
+		stats.subscribeTotal.Inc(1)
+		if !allowed(p.factory.config.SubscribeAllow, filter) {
+			stats.subscribeDenied.Inc(1)
+			denied[i] = true
+			continue
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	if len(subscriptions) == len(sub.Subscriptions) {
+		return pkt.raw, nil, nil
+	}
+	if len(subscriptions) == 0 {
+		codes := make([]byte, len(sub.Subscriptions))
+		for i := range codes {
+			codes[i] = p.failureCode()
+		}
+		return nil, encodeSuback(&Suback{PacketID: sub.PacketID, ReturnCodes: codes}, p.version()), nil
+	}
+	p.mux.Lock()
+	p.deniedSubscriptions[sub.PacketID] = denied
+	p.mux.Unlock()
+	sub.Subscriptions = subscriptions
+	return encodeSubscribe(sub, p.version()), nil, nil
+}
+
+// onUpstreamPacket returns the data forwarded to the client
+func (p *proxy) onUpstreamPacket(pkt *packet) ([]byte, error) {
+	switch pkt.typ {
+	case CONNACK:
+		ack, err := decodeConnack(pkt)
+		if err != nil {
+			return nil, err
+		}
+		if ack.ReturnCode != 0 {
+			log.DefaultLogger.Warnf("[mqtt proxy] client %s is refused by the broker, return code %d", p.connect.ClientID, ack.ReturnCode)
+		}
+	case SUBACK:
+		return p.onSuback(pkt)
+	}
+	return pkt.raw, nil
+}
+
+// onSuback merges the return codes of the denied filters into the SUBACK
+func (p *proxy) onSuback(pkt *packet) ([]byte, error) {
+	ack, err := decodeSuback(pkt, p.version())
+	if err != nil {
+		return nil, err
+	}
+	p.mux.Lock()
+	denied, ok := p.deniedSubscriptions[ack.PacketID]
+	delete(p.deniedSubscriptions, ack.PacketID)
+	p.mux.Unlock()
+	if !ok {
+		return pkt.raw, nil
+	}
+	codes := make([]byte, len(denied))
+	j := 0
+	for i, d := range denied {
+		if d {
+			codes[i] = p.failureCode()
+			continue
+		}
+		if j >= len(ack.ReturnCodes) {
+			return nil, ErrProtocolViolated
+		}
+		codes[i] = ack.ReturnCodes[j]
+		j++
+	}
+	ack.ReturnCodes = codes
+	return encodeSuback(ack, p.version()), nil
+}
+
+func (p *proxy) onUpstreamData(buf buffer.IoBuffer) {
+	p.upstreamData = append(p.upstreamData, buf.Bytes()...)
+	buf.Drain(buf.Len())
+
+	var toDownstream []byte
+	for {
+		pkt, err := readPacket(p.upstreamData, 0)
+		if err == errNeedMore {
+			break
+		}
+		if err == nil {
+			p.upstreamData = p.upstreamData[len(pkt.raw):]
+			var forward []byte
+			forward, err = p.onUpstreamPacket(pkt)
+			toDownstream = append(toDownstream, forward...)
+		}
+		if err != nil {
+			log.DefaultLogger.Errorf("[mqtt proxy] close the connection to broker %s: %v", p.upstreamConnection.RemoteAddr(), err)
+			p.upstreamConnection.Close(api.NoFlush, api.LocalClose)
+			return
+		}
+	}
+	if len(p.upstreamData) == 0 {
+		p.upstreamData = nil
+	}
+	if len(toDownstream) > 0 {
+		p.readCallbacks.Connection().Write(buffer.NewIoBufferBytes(toDownstream))
+	}
+}
+
+func (p *proxy) initializeUpstreamConnection(clusterName string) error {
+	snapshot := p.clusterManager.GetClusterSnapshot(context.Background(), clusterName)
+	if snapshot == nil || reflect.ValueOf(snapshot).IsNil() {
+		return errNoCluster
+	}
+	p.clusterInfo = snapshot.ClusterInfo()
+	connectionResource := p.clusterInfo.ResourceManager().Connections()
+	if !connectionResource.CanCreate() {
+		return errNoUpstream
+	}
+	ctx := &lbContext{
+		conn:    p.readCallbacks,
+		ctx:     p.ctx,
+		cluster: p.clusterInfo,
+	}
+	retryTime := snapshot.HostSet().Size()
+	if retryTime > defaultConnectRetryTimes {
+		retryTime = defaultConnectRetryTimes
+	}
+	for i := 0; i < retryTime; i++ {
+		connectionData := p.clusterManager.TCPConnForCluster(ctx, snapshot)
+		if connectionData.Connection == nil {
+			continue
+		}
+		upstreamConnection := connectionData.Connection
+		upstreamCallbacks := &upstreamCallbacks{proxy: p}
+		upstreamConnection.AddConnectionEventListener(upstreamCallbacks)
+		upstreamConnection.FilterManager().AddReadFilter(upstreamCallbacks)
+		p.upstreamConnection = upstreamConnection
+		if err := upstreamConnection.Connect(); err != nil {
+			p.clusterInfo.Stats().UpstreamConnectionRetry.Inc(1)
+			log.DefaultLogger.Errorf("[mqtt proxy] connect to broker %s failed: %v", connectionData.Host.AddressString(), err)
+			continue
+		}
+		connectionResource.Increase()
+		upstreamConnection.SetCollector(p.clusterInfo.Stats().UpstreamBytesReadTotal, p.clusterInfo.Stats().UpstreamBytesWriteTotal)
+		p.readCallbacks.SetUpstreamHost(connectionData.Host)
+		p.clusterInfo.Stats().UpstreamConnectionActive.Inc(1)
+		p.clusterInfo.Stats().UpstreamConnectionTotal.Inc(1)
+		return nil
+	}
+	p.upstreamConnection = nil
+	p.clusterInfo.Stats().UpstreamConnectionConFail.Inc(1)
+	return errNoUpstream
+}
+
+func (p *proxy) onUpstreamEvent(event api.ConnectionEvent) {
+	switch event {
+	case api.Connected:
+		p.upstreamConnection.SetNoDelay(true)
+		p.upstreamConnection.SetReadDisable(false)
+	case api.RemoteClose, api.OnWriteTimeout, api.OnWriteErrClose:
+		p.finalizeUpstreamConnectionStats()
+		p.readCallbacks.Connection().Close(api.FlushWrite, api.RemoteClose)
+	case api.LocalClose, api.OnReadErrClose:
+		p.finalizeUpstreamConnectionStats()
+		p.readCallbacks.Connection().Close(api.NoFlush, api.LocalClose)
+	}
+}
+
+func (p *proxy) finalizeUpstreamConnectionStats() {
+	p.clusterInfo.ResourceManager().Connections().Decrease()
+	p.clusterInfo.Stats().UpstreamConnectionActive.Dec(1)
+}
+
+func (p *proxy) onDownstreamEvent(event api.ConnectionEvent) {
+	if !event.IsClose() {
+		return
+	}
+	p.releaseOnce.Do(func() {
+		if p.connect != nil && p.limiter != nil {
+			p.factory.releaseLimiter(p.connect.ClientID)
+		}
+	})
+	if p.upstreamConnection == nil {
+		return
+	}
+	switch event {
+	case api.RemoteClose, api.OnWriteTimeout, api.OnWriteErrClose:
+		p.upstreamConnection.Close(api.FlushWrite, api.LocalClose)
+	default:
+		p.upstreamConnection.Close(api.NoFlush, api.LocalClose)
+	}
+}
+
+// upstreamCallbacks is the ConnectionEventListener and the ReadFilter of the upstream connection
+type upstreamCallbacks struct {
+	proxy *proxy
+}
+
+func (uc *upstreamCallbacks) OnEvent(event api.ConnectionEvent) {
+	uc.proxy.onUpstreamEvent(event)
+}
+
+func (uc *upstreamCallbacks) OnData(buf buffer.IoBuffer) api.FilterStatus {
+	uc.proxy.onUpstreamData(buf)
+	return api.Stop
+}
+
+func (uc *upstreamCallbacks) OnNewConnection() api.FilterStatus {
+	return api.Continue
+}
+
+func (uc *upstreamCallbacks) InitializeReadFilterCallbacks(cb api.ReadFilterCallbacks) {}
+
+// downstreamCallbacks is the ConnectionEventListener of the downstream connection
+type downstreamCallbacks struct {
+	proxy *proxy
+}
+
+func (dc *downstreamCallbacks) OnEvent(event api.ConnectionEvent) {
+	dc.proxy.onDownstreamEvent(event)
+}
+
+// lbContext is a types.LoadBalancerContext implementation
+type lbContext struct {
+	conn    api.ReadFilterCallbacks
+	ctx     context.Context
+	cluster types.ClusterInfo
+}
+
+func (c *lbContext) MetadataMatchCriteria() api.MetadataMatchCriteria {
+	return nil
+}
+
+func (c *lbContext) DownstreamConnection() net.Conn {
+	return c.conn.Connection().RawConn()
+}
+
+func (c *lbContext) DownstreamHeaders() api.HeaderMap {
+	return nil
+}
+
+func (c *lbContext) DownstreamContext() context.Context {
+	return c.ctx
+}
+
+func (c *lbContext) DownstreamCluster() types.ClusterInfo {
+	return c.cluster
+}
+
+func (c *lbContext) DownstreamRoute() api.Route {
+	return nil
+}
@@ -0,0 +1,283 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func newTestProxy(t *testing.T, conf map[string]interface{}, version byte) *proxy {
+	c, err := ParseConfig(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProxy(context.Background(), newMQTTProxyFactory(c))
+	p.connect = &Connect{Version: version, ClientID: "device-1"}
+	p.limiter = p.factory.acquireLimiter(p.connect.ClientID)
+	return p
+}
+
+func TestParseConfig(t *testing.T) {
+	if _, err := ParseConfig(map[string]interface{}{}); err == nil {
+		t.Fatal("clusters should be required")
+	}
+	for _, conf := range []map[string]interface{}{
+		{"clusters": []string{"a"}, "hash_key": "ip"},
+		{"clusters": []string{"a"}, "publish_allow": []string{"a/#/b"}},
+		{"clusters": []string{"a"}, "subscribe_allow": []string{"a/b+"}},
+		{"clusters": []string{"a"}, "publish_rate_limit": map[string]interface{}{"rate": 0}},
+		{"clusters": []string{"a"}, "max_packet_size": maxRemainingLength + 1},
+	} {
+		if _, err := ParseConfig(conf); err == nil {
+			t.Fatalf("config %v should be invalid", conf)
+		}
+	}
+	c, err := ParseConfig(map[string]interface{}{
+		"clusters":           []string{"a"},
+		"publish_rate_limit": map[string]interface{}{"rate": 2.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.HashKey != HashByClientID || c.StatPrefix != "mqtt_proxy" || c.PublishRateLimit.Burst != 3 ||
+		c.MaxPacketSize != defaultMaxPacketSize {
+		t.Fatalf("unexpected config %+v", c)
+	}
+}
+
+func TestSelectCluster(t *testing.T) {
+	clusters := []string{"broker_a", "broker_b", "broker_c"}
+	byClient := newMQTTProxyFactory(&Config{Clusters: clusters, HashKey: HashByClientID})
+	byUser := newMQTTProxyFactory(&Config{Clusters: clusters, HashKey: HashByUsername})
+	selected := map[string]bool{}
+	for _, id := range []string{"d1", "d2", "d3", "d4", "d5", "d6", "d7", "d8"} {
+		c := &Connect{ClientID: id, Username: "alice"}
+		cluster := byClient.selectCluster(c)
+		if cluster != byClient.selectCluster(&Connect{ClientID: id}) {
+			t.Fatalf("client %s is routed to different clusters", id)
+		}
+		selected[cluster] = true
+		// all the connections of a user are routed to the same cluster
+		if byUser.selectCluster(c) != byUser.selectCluster(&Connect{ClientID: "other", Username: "alice"}) {
+			t.Fatal("user alice is routed to different clusters")
+		}
+		// no username, routed by the client id
+		if byUser.selectCluster(&Connect{ClientID: id}) != cluster {
+			t.Fatalf("client %s without username is not routed by the client id", id)
+		}
+	}
+	if len(selected) < 2 {
+		t.Fatalf("clients are not distributed, %v", selected)
+	}
+}
+
+func TestPublishAllow(t *testing.T) {
+	conf := map[string]interface{}{
+		"stat_prefix":    "test_publish_allow",
+		"clusters":       []string{"a"},
+		"publish_allow":  []string{"devices/+/telemetry"},
+		"topic_prefixes": []string{"devices/", "devices/1/"},
+	}
+	p := newTestProxy(t, conf, Version5)
+	data := buildPublish(Version5, "devices/1/telemetry", 1, 1, 0, "12.5")
+	forward, reply, err := p.onDownstreamPacket(mustReadPacket(t, data))
+	if err != nil || !bytes.Equal(forward, data) || reply != nil {
+		t.Fatalf("publish should be forwarded, %v", err)
+	}
+	// denied qos 0 is dropped
+	forward, reply, err = p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version5, "devices/1/config", 0, 0, 0, "x")))
+	if err != nil || forward != nil || reply != nil {
+		t.Fatalf("denied qos 0 publish should be dropped, %v", err)
+	}
+	// denied qos 1 is acked with not authorized
+	_, reply, err = p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version5, "devices/1/config", 1, 2, 0, "x")))
+	if err != nil || !bytes.Equal(reply, encodeAck(PUBACK, 2, ReasonNotAuthorized, Version5)) {
+		t.Fatalf("unexpected reply %x, %v", reply, err)
+	}
+	// denied qos 2 is acked by PUBREC
+	_, reply, err = p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version5, "devices/1/config", 2, 3, 0, "x")))
+	if err != nil || !bytes.Equal(reply, encodeAck(PUBREC, 3, ReasonNotAuthorized, Version5)) {
+		t.Fatalf("unexpected reply %x, %v", reply, err)
+	}
+	stats := p.factory.topicStats("devices/1/telemetry")
+	if stats != p.factory.stats["devices/1/"] {
+		t.Fatal("topic stats should be the longest prefix")
+	}
+	if stats.publishTotal.Count() != 1 || stats.publishBytes.Count() != 4 || stats.publishDenied.Count() != 3 {
+		t.Fatalf("unexpected stats %d %d %d", stats.publishTotal.Count(), stats.publishBytes.Count(), stats.publishDenied.Count())
+	}
+	if p.factory.topicStats("sensors/1") != p.factory.stats[otherTopicPrefix] {
+		t.Fatal("topic without prefix should be grouped as other")
+	}
+
+	// no negative ack in 3.1.1
+	p = newTestProxy(t, conf, Version311)
+	if _, _, err := p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version311, "devices/1/config", 1, 2, 0, "x"))); err != errPublishRejected {
+		t.Fatalf("expected publish rejected, but got %v", err)
+	}
+}
+
+func TestPublishRateLimit(t *testing.T) {
+	p := newTestProxy(t, map[string]interface{}{
+		"stat_prefix":        "test_publish_rate_limit",
+		"clusters":           []string{"a"},
+		"publish_rate_limit": map[string]interface{}{"rate": 1, "burst": 2},
+	}, Version5)
+	for i := uint16(1); i <= 2; i++ {
+		if forward, _, err := p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version5, "a", 1, i, 0, "x"))); err != nil || forward == nil {
+			t.Fatalf("publish %d should be forwarded, %v", i, err)
+		}
+	}
+	_, reply, err := p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version5, "a", 1, 3, 0, "x")))
+	if err != nil || !bytes.Equal(reply, encodeAck(PUBACK, 3, ReasonMessageRateTooHigh, Version5)) {
+		t.Fatalf("unexpected reply %x, %v", reply, err)
+	}
+	if p.factory.topicStats("a").publishRateLimited.Count() != 1 {
+		t.Fatal("unexpected rate limited stats")
+	}
+	// the connections of a client id share the limiter
+	if p.factory.acquireLimiter("device-1") != p.limiter {
+		t.Fatal("limiter should be shared by the client id")
+	}
+	p.factory.releaseLimiter("device-1")
+	p.factory.releaseLimiter("device-1")
+	if p.factory.limiters.Len() != 0 {
+		t.Fatal("limiter should be released")
+	}
+	if p.factory.acquireLimiter("") == p.factory.acquireLimiter("") {
+		t.Fatal("clients without client id should not share the limiter")
+	}
+}
+
+func TestTopicAlias(t *testing.T) {
+	p := newTestProxy(t, map[string]interface{}{
+		"stat_prefix":   "test_topic_alias",
+		"clusters":      []string{"a"},
+		"publish_allow": []string{"allowed"},
+	}, Version5)
+	// unknown alias
+	if _, _, err := p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version5, "", 0, 0, 1, "x"))); err != ErrProtocolViolated {
+		t.Fatalf("expected protocol violated, but got %v", err)
+	}
+	if forward, _, _ := p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version5, "allowed", 0, 0, 1, "x"))); forward == nil {
+		t.Fatal("publish should be forwarded")
+	}
+	// the alias of a denied publish is not recorded, the broker does not know it either
+	if forward, _, _ := p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version5, "denied", 0, 0, 1, "x"))); forward != nil {
+		t.Fatal("publish should be denied")
+	}
+	if forward, _, _ := p.onDownstreamPacket(mustReadPacket(t, buildPublish(Version5, "", 0, 0, 1, "x"))); forward == nil {
+		t.Fatal("publish by the alias should be forwarded")
+	}
+	if p.factory.topicStats("allowed").publishTotal.Count() != 2 {
+		t.Fatal("unexpected publish stats")
+	}
+}
+
+func TestSubscribeAllow(t *testing.T) {
+	for _, version := range []byte{Version311, Version5} {
+		p := newTestProxy(t, map[string]interface{}{
+			"stat_prefix":     "test_subscribe_allow",
+			"clusters":        []string{"a"},
+			"subscribe_allow": []string{"devices/1/#"},
+		}, version)
+		failure := p.failureCode()
+
+		data := buildSubscribe(version, 1, "devices/1/commands", "$share/g/devices/1/+")
+		if forward, _, err := p.onDownstreamPacket(mustReadPacket(t, data)); err != nil || !bytes.Equal(forward, data) {
+			t.Fatalf("subscribe should be forwarded, %v", err)
+		}
+
+		// all denied, replied by the proxy
+		_, reply, err := p.onDownstreamPacket(mustReadPacket(t, buildSubscribe(version, 2, "devices/#", "devices/2/commands")))
+		expected := encodeSuback(&Suback{PacketID: 2, ReturnCodes: []byte{failure, failure}}, version)
+		if err != nil || !bytes.Equal(reply, expected) {
+			t.Fatalf("unexpected reply %x, %v", reply, err)
+		}
+
+		// partly denied, the denied filters are removed
+		forward, _, err := p.onDownstreamPacket(mustReadPacket(t, buildSubscribe(version, 3, "devices/2/commands", "devices/1/commands", "devices/+/status")))
+		if err != nil || !bytes.Equal(forward, buildSubscribe(version, 3, "devices/1/commands")) {
+			t.Fatalf("unexpected forward %x, %v", forward, err)
+		}
+		// the SUBACK from the broker is merged with the denied filters
+		suback := encodeSuback(&Suback{PacketID: 3, ReturnCodes: []byte{1}}, version)
+		merged, err := p.onUpstreamPacket(mustReadPacket(t, suback))
+		expected = encodeSuback(&Suback{PacketID: 3, ReturnCodes: []byte{failure, 1, failure}}, version)
+		if err != nil || !bytes.Equal(merged, expected) {
+			t.Fatalf("unexpected suback %x, %v", merged, err)
+		}
+		// other SUBACK is forwarded as it is
+		if forward, err := p.onUpstreamPacket(mustReadPacket(t, suback)); err != nil || !bytes.Equal(forward, suback) {
+			t.Fatalf("unexpected suback %x, %v", forward, err)
+		}
+	}
+	stats := newMQTTProxyFactory(&Config{StatPrefix: "test_subscribe_allow"}).topicStats("devices/1")
+	if stats.subscribeTotal.Count() != 14 || stats.subscribeDenied.Count() != 8 {
+		t.Fatalf("unexpected subscribe stats %d %d", stats.subscribeTotal.Count(), stats.subscribeDenied.Count())
+	}
+}
+
+func TestSecondConnect(t *testing.T) {
+	p := newTestProxy(t, map[string]interface{}{"clusters": []string{"a"}}, Version311)
+	if _, _, err := p.onDownstreamPacket(mustReadPacket(t, buildConnect(Version311, "device-1", ""))); err != ErrProtocolViolated {
+		t.Fatalf("expected protocol violated, but got %v", err)
+	}
+	if forward, _, _ := p.onDownstreamPacket(mustReadPacket(t, []byte{0xc0, 0})); !bytes.Equal(forward, []byte{0xc0, 0}) {
+		t.Fatal("PINGREQ should be forwarded")
+	}
+}
+
+func TestReadDownstreamPacket(t *testing.T) {
+	c, err := ParseConfig(map[string]interface{}{"clusters": []string{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newProxy(context.Background(), newMQTTProxyFactory(c))
+	// the first packet is not a CONNECT, rejected by the fixed header
+	p.downstreamData = []byte{0x30}
+	if _, err := p.readDownstreamPacket(); err != ErrProtocolViolated {
+		t.Fatalf("expected protocol violated, but got %v", err)
+	}
+	// a large CONNECT is rejected before it is buffered
+	p.downstreamData = appendVarint([]byte{CONNECT << 4}, maxConnectSize+1)
+	if _, err := p.readDownstreamPacket(); err != ErrPacketTooLarge {
+		t.Fatalf("expected packet too large, but got %v", err)
+	}
+	connect := buildConnect(Version311, "device-1", "")
+	p.downstreamData = connect[:len(connect)-1]
+	if _, err := p.readDownstreamPacket(); err != errNeedMore {
+		t.Fatalf("expected need more, but got %v", err)
+	}
+	p.downstreamData = connect
+	if pkt, err := p.readDownstreamPacket(); err != nil || pkt.typ != CONNECT {
+		t.Fatalf("read connect failed, %v", err)
+	}
+	// the packets after the CONNECT are limited by the max packet size
+	p.connect = &Connect{Version: Version311, ClientID: "device-1"}
+	p.downstreamData = appendVarint([]byte{PUBLISH << 4}, maxConnectSize+1)
+	if _, err := p.readDownstreamPacket(); err != errNeedMore {
+		t.Fatalf("expected need more, but got %v", err)
+	}
+	p.downstreamData = appendVarint([]byte{PUBLISH << 4}, defaultMaxPacketSize+1)
+	if _, err := p.readDownstreamPacket(); err != ErrPacketTooLarge {
+		t.Fatalf("expected packet too large, but got %v", err)
+	}
+}
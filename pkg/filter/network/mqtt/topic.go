@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"strings"
+)
+
+// sharePrefix is the prefix of the 5.0 shared subscriptions, $share/{group}/{filter}
+const sharePrefix = "$share/"
+
+// covers returns true if all the topics matched by the filter are matched by the pattern.
+// A topic name is a filter without wildcards, so covers also matches a topic name.
+func covers(pattern, filter string) bool {
+	patternLevels := strings.Split(pattern, "/")
+	filterLevels := strings.Split(filter, "/")
+	for i, pl := range patternLevels {
+		// the wildcards at the first level do not match the topics starting with $
+		system := i == 0 && strings.HasPrefix(filter, "$")
+		if pl == "#" {
+			return !system
+		}
+		if i >= len(filterLevels) {
+			return false
+		}
+		fl := filterLevels[i]
+		switch pl {
+		case "+":
+			if fl == "#" || system {
+				return false
+			}
+		default:
+			if fl != pl {
+				return false
+			}
+		}
+	}
+	return len(patternLevels) == len(filterLevels)
+}
+
+// allowed returns true if the filter is covered by any of the patterns, an empty patterns allows all
+func allowed(patterns []string, filter string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if covers(p, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// unshare returns the topic filter of a shared subscription
+func unshare(filter string) string {
+	if !strings.HasPrefix(filter, sharePrefix) {
+		return filter
+	}
+	rest := filter[len(sharePrefix):]
+	if idx := strings.IndexByte(rest, '/'); idx >= 0 {
+		return rest[idx+1:]
+	}
+	return filter
+}
@@ -25,6 +25,7 @@ import (
 	mosnctx "mosn.io/mosn/pkg/context"
 	"mosn.io/mosn/pkg/istio"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol/triple"
 	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/mosn/pkg/variable"
@@ -61,7 +62,7 @@ func (d *dubboFilter) OnDestroy() {}
 func (d *dubboFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
 
 	proto := mosnctx.Get(ctx, types.ContextKeyDownStreamProtocol)
-	if proto == nil || dubbo.ProtocolName != proto {
+	if proto == nil || (dubbo.ProtocolName != proto && triple.ProtocolName != proto) {
 		return api.StreamFilterContinue
 	}
 
@@ -75,8 +76,11 @@ func (d *dubboFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf
 
 	// adapt dubbo service to http host
 	variable.SetString(ctx, types.VarHost, service)
-	// because use http rule, so should add default path
-	variable.SetString(ctx, types.VarPath, "/")
+	// because use http rule, so should add default path,
+	// the path of the triple request is kept, it is sent to the upstream
+	if proto == dubbo.ProtocolName {
+		variable.SetString(ctx, types.VarPath, "/")
+	}
 
 	method, _ := headers.Get(dubbo.MethodNameHeader)
 	stats := getStats(listener, service, method)
@@ -121,6 +125,10 @@ func (d *dubboFilter) Append(ctx context.Context, headers api.HeaderMap, buf buf
 	case *dubbo.Frame:
 		isSuccess = frame.GetStatusCode() == dubbo.RespStatusOK
 	default:
+		if mosnctx.Get(ctx, types.ContextKeyDownStreamProtocol) == triple.ProtocolName {
+			isSuccess = triple.IsSuccess(headers, trailers)
+			break
+		}
 		log.DefaultLogger.Errorf("this filter {%s} just for dubbo protocol, please check your config.", v2.DubboStream)
 		return api.StreamFiltertermination
 	}
@@ -27,10 +27,55 @@ import (
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/runtime"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/buffer"
 )
 
+// The runtime keys override the fault inject config, so the faults can be turned on or off
+// without a config push. The keys are prefixed by the runtime key prefix of the filter,
+// the filters without a prefix share the default keys.
+const (
+	DefaultRuntimeKeyPrefix = "fault_inject"
+
+	RuntimeKeyDelayPercent  = DefaultRuntimeKeyPrefix + runtimeKeyDelayPercent
+	RuntimeKeyDelayDuration = DefaultRuntimeKeyPrefix + runtimeKeyDelayDuration
+	RuntimeKeyAbortPercent  = DefaultRuntimeKeyPrefix + runtimeKeyAbortPercent
+	RuntimeKeyAbortStatus   = DefaultRuntimeKeyPrefix + runtimeKeyAbortStatus
+)
+
+const (
+	runtimeKeyDelayPercent  = ".delay.percent"
+	runtimeKeyDelayDuration = ".delay.duration"
+	runtimeKeyAbortPercent  = ".abort.percent"
+	runtimeKeyAbortStatus   = ".abort.status"
+)
+
+// the abort status overridden by the runtime should be a valid http status
+const (
+	minAbortStatus = 200
+	maxAbortStatus = 599
+)
+
+type runtimeKeys struct {
+	delayPercent  string
+	delayDuration string
+	abortPercent  string
+	abortStatus   string
+}
+
+func newRuntimeKeys(prefix string) runtimeKeys {
+	if prefix == "" {
+		prefix = DefaultRuntimeKeyPrefix
+	}
+	return runtimeKeys{
+		delayPercent:  prefix + runtimeKeyDelayPercent,
+		delayDuration: prefix + runtimeKeyDelayDuration,
+		abortPercent:  prefix + runtimeKeyAbortPercent,
+		abortStatus:   prefix + runtimeKeyAbortStatus,
+	}
+}
+
 // faultInjectConfig is parsed from v2.StreamFaultInject
 type faultInjectConfig struct {
 	fixedDelay   time.Duration
@@ -39,12 +84,14 @@ type faultInjectConfig struct {
 	abortPercent uint32
 	upstream     string
 	headers      types.HeaderMatcher
+	runtimeKeys  runtimeKeys
 }
 
 func makefaultInjectConfig(cfg *v2.StreamFaultInject) *faultInjectConfig {
 	faultConfig := &faultInjectConfig{
-		upstream: cfg.UpstreamCluster,
-		headers:  router.CreateHTTPHeaderMatcher(cfg.Headers),
+		upstream:    cfg.UpstreamCluster,
+		headers:     router.CreateHTTPHeaderMatcher(cfg.Headers),
+		runtimeKeys: newRuntimeKeys(cfg.RuntimeKeyPrefix),
 	}
 	if cfg.Delay != nil {
 		faultConfig.fixedDelay = cfg.Delay.Delay
@@ -184,32 +231,35 @@ func (f *streamFaultInjectFilter) matchUpstream() bool {
 }
 
 func (f *streamFaultInjectFilter) getDelayDuration() time.Duration {
+	delayPercent := runtime.GetPercent(f.config.runtimeKeys.delayPercent, f.config.delayPercent)
+	fixedDelay := runtime.GetDuration(f.config.runtimeKeys.delayDuration, f.config.fixedDelay)
 	// percent is 0 or delay is 0 means no delay
-	if f.config.delayPercent == 0 || f.config.fixedDelay == 0 {
+	if delayPercent == 0 || fixedDelay == 0 {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] no delay inject")
 		}
 		return 0
 	}
 	// rander generates 0~99, if greater than percent means no delay
-	if (f.rander.Uint32() % 100) >= f.config.delayPercent {
+	if (f.rander.Uint32() % 100) >= delayPercent {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] delay percent is not matched")
 		}
 		return 0
 	}
-	return f.config.fixedDelay
+	return fixedDelay
 }
 
 func (f *streamFaultInjectFilter) isAbort() bool {
+	abortPercent := runtime.GetPercent(f.config.runtimeKeys.abortPercent, f.config.abortPercent)
 	// percent is 0 means no abort
-	if f.config.abortPercent == 0 {
+	if abortPercent == 0 {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] no abort inject")
 		}
 		return false
 	}
-	if (f.rander.Uint32() % 100) >= f.config.abortPercent {
+	if (f.rander.Uint32() % 100) >= abortPercent {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
 			log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] abort percent is not matched")
 		}
@@ -224,5 +274,12 @@ func (f *streamFaultInjectFilter) abort(headers api.HeaderMap) {
 		log.Proxy.Debugf(f.ctx, "[stream filter] [fault inject] abort inject")
 	}
 	f.handler.RequestInfo().SetResponseFlag(api.FaultInjected)
-	f.handler.SendHijackReply(f.config.abortStatus, headers)
+	status := f.config.abortStatus
+	// zero means the runtime key is not set
+	if v := runtime.GetInt(f.config.runtimeKeys.abortStatus, 0); v >= minAbortStatus && v <= maxAbortStatus {
+		status = int(v)
+	} else if v != 0 {
+		log.Proxy.Warnf(f.ctx, "[stream filter] [fault inject] invalid runtime abort status %d, use the configured status %d", v, status)
+	}
+	f.handler.SendHijackReply(status, headers)
 }
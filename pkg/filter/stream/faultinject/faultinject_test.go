@@ -29,6 +29,7 @@ import (
 	"mosn.io/mosn/pkg/config/v2"
 	mlog "mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/runtime"
 	"mosn.io/pkg/log"
 )
 
@@ -285,6 +286,89 @@ func TestFaultInject_AllAbortWithoutDelay(t *testing.T) {
 	}
 }
 
+func TestFaultInject_RuntimeOverride(t *testing.T) {
+	runtime.Reset()
+	defer runtime.Reset()
+	cfg := &v2.StreamFaultInject{
+		Abort: &v2.AbortInject{
+			Percent: 0,
+			Status:  500,
+		},
+	}
+	newCallbacks := func() *mockStreamReceiverFilterCallbacks {
+		return &mockStreamReceiverFilterCallbacks{
+			info: &mockRequestInfo{},
+			route: &mockRoute{
+				rule: &mockRouteRule{},
+			},
+			called: make(chan int, 1),
+		}
+	}
+	f := NewFilter(context.Background(), cfg)
+	f.SetReceiveFilterHandler(newCallbacks())
+	if status := f.OnReceive(context.TODO(), nil, nil, nil); status != api.StreamFilterContinue {
+		t.Fatal("fault inject should not be matched")
+	}
+	// turn on the abort by the runtime
+	if err := runtime.Set(map[string]string{
+		RuntimeKeyAbortPercent: "100",
+		RuntimeKeyAbortStatus:  "503",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cb := newCallbacks()
+	f = NewFilter(context.Background(), cfg)
+	f.SetReceiveFilterHandler(cb)
+	if status := f.OnReceive(context.TODO(), nil, nil, nil); status != api.StreamFilterStop {
+		t.Fatal("fault inject should be matched")
+	}
+	select {
+	case <-cb.called:
+		if cb.hijackCode != 503 {
+			t.Fatalf("abort status is not overridden: %d", cb.hijackCode)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+	// the invalid status falls back to the configured status
+	if err := runtime.Set(map[string]string{
+		RuntimeKeyAbortPercent: "100",
+		RuntimeKeyAbortStatus:  "999",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	cb = newCallbacks()
+	f = NewFilter(context.Background(), cfg)
+	f.SetReceiveFilterHandler(cb)
+	f.OnReceive(context.TODO(), nil, nil, nil)
+	select {
+	case <-cb.called:
+		if cb.hijackCode != 500 {
+			t.Fatalf("invalid abort status is used: %d", cb.hijackCode)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+	// the filter with a runtime key prefix is not overridden by the default keys
+	prefixed := *cfg
+	prefixed.RuntimeKeyPrefix = "service_a"
+	f = NewFilter(context.Background(), &prefixed)
+	f.SetReceiveFilterHandler(newCallbacks())
+	if status := f.OnReceive(context.TODO(), nil, nil, nil); status != api.StreamFilterContinue {
+		t.Fatal("fault inject should not be overridden by the default keys")
+	}
+	if err := runtime.Set(map[string]string{
+		"service_a.abort.percent": "100",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	f = NewFilter(context.Background(), &prefixed)
+	f.SetReceiveFilterHandler(newCallbacks())
+	if status := f.OnReceive(context.TODO(), nil, nil, nil); status != api.StreamFilterStop {
+		t.Fatal("fault inject should be overridden by the prefixed keys")
+	}
+}
+
 func TestFaultInject_MatchedUpstream(t *testing.T) {
 	cfg := &v2.StreamFaultInject{
 		Delay: &v2.DelayInject{
@@ -0,0 +1,46 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcweb
+
+import (
+	"context"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+func init() {
+	api.RegisterStream(v2.GrpcWeb, CreateGrpcWebFilterFactory)
+}
+
+type filterConfigFactory struct{}
+
+// CreateGrpcWebFilterFactory creates the grpc_web stream filter factory, the filter has no config
+//
+//	"stream_filters": [{
+//		"type": "grpc_web"
+//	}]
+func CreateGrpcWebFilterFactory(conf map[string]interface{}) (api.StreamFilterChainFactory, error) {
+	return &filterConfigFactory{}, nil
+}
+
+func (f *filterConfigFactory) CreateFilterChain(context context.Context, callbacks api.StreamFilterChainFactoryCallbacks) {
+	filter := NewStreamFilter()
+	callbacks.AddStreamReceiverFilter(filter, api.BeforeRoute)
+	callbacks.AddStreamSenderFilter(filter, api.BeforeSend)
+}
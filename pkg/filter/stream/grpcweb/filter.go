@@ -0,0 +1,209 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package grpcweb translates the grpc-web requests of the browsers into the grpc requests.
+//
+// The request body of the application/grpc-web content type is the same as grpc, and the body of
+// the application/grpc-web-text content type is base64 encoded. The requests over http1 are sent
+// to the upstream by http2. The grpc trailers of the response are encoded into the body as a frame
+// with the 0x80 flag, see https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+const (
+	contentTypeGrpc    = "application/grpc"
+	contentTypeWeb     = "application/grpc-web"
+	contentTypeWebText = "application/grpc-web-text"
+
+	headerContentType   = "content-type"
+	headerContentLength = "content-length"
+	headerTE            = "te"
+
+	// trailerFrameFlag is the flag of the frame that carries the trailers
+	trailerFrameFlag = 0x80
+)
+
+var errInvalidTextBody = errors.New("invalid grpc-web-text body")
+
+// streamFilter implements api.StreamReceiverFilter and api.StreamSenderFilter
+type streamFilter struct {
+	receiveHandler api.StreamReceiverFilterHandler
+	sendHandler    api.StreamSenderFilterHandler
+	// web is set if the request is grpc-web, text is set if the body is base64 encoded
+	web  bool
+	text bool
+}
+
+func NewStreamFilter() *streamFilter {
+	return &streamFilter{}
+}
+
+// parseContentType returns the grpc-web content type and the suffix, such as +proto
+func parseContentType(contentType string) (web bool, text bool, suffix string) {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+	switch {
+	case strings.HasPrefix(contentType, contentTypeWebText):
+		return true, true, strings.TrimPrefix(contentType, contentTypeWebText)
+	case strings.HasPrefix(contentType, contentTypeWeb):
+		return true, false, strings.TrimPrefix(contentType, contentTypeWeb)
+	}
+	return false, false, ""
+}
+
+func (f *streamFilter) SetReceiveFilterHandler(handler api.StreamReceiverFilterHandler) {
+	f.receiveHandler = handler
+}
+
+func (f *streamFilter) OnReceive(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	contentType, _ := headers.Get(headerContentType)
+	web, text, suffix := parseContentType(contentType)
+	if !web {
+		return api.StreamFilterContinue
+	}
+	f.web, f.text = true, text
+
+	if text && buf != nil && buf.Len() > 0 {
+		data, err := decodeText(buf.Bytes())
+		if err != nil {
+			log.Proxy.Errorf(ctx, "[stream filter] [grpc_web] decode request body failed: %v", err)
+			f.receiveHandler.SendHijackReply(http.StatusBadRequest, headers)
+			return api.StreamFilterStop
+		}
+		f.receiveHandler.SetRequestData(buffer.NewIoBufferBytes(data))
+		headers.Set(headerContentLength, strconv.Itoa(len(data)))
+	}
+
+	// the http1 request is sent to the upstream by http2, the same as the httpTohttp2 transcoder
+	if httpHeader, ok := headers.(mosnhttp.RequestHeader); ok {
+		cheader := make(map[string]string, httpHeader.Len())
+		httpHeader.VisitAll(func(key, value []byte) {
+			cheader[strings.ToLower(string(key))] = string(value)
+		})
+		headers = protocol.CommonHeader(cheader)
+		f.receiveHandler.SetRequestHeaders(headers)
+		mosnctx.WithValue(ctx, types.ContextKeyUpStreamProtocol, protocol.HTTP2)
+	}
+	headers.Set(headerContentType, contentTypeGrpc+suffix)
+	headers.Set(headerTE, "trailers")
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter] [grpc_web] translate the grpc-web request, text: %v", text)
+	}
+	return api.StreamFilterContinue
+}
+
+func (f *streamFilter) SetSenderFilterHandler(handler api.StreamSenderFilterHandler) {
+	f.sendHandler = handler
+}
+
+func (f *streamFilter) Append(ctx context.Context, headers api.HeaderMap, buf buffer.IoBuffer, trailers api.HeaderMap) api.StreamFilterStatus {
+	if !f.web {
+		return api.StreamFilterContinue
+	}
+	contentType, _ := headers.Get(headerContentType)
+	contentType = strings.ToLower(contentType)
+	// the response is not grpc, such as the direct response
+	if !strings.HasPrefix(contentType, contentTypeGrpc) {
+		return api.StreamFilterContinue
+	}
+	webContentType := contentTypeWeb
+	if f.text {
+		webContentType = contentTypeWebText
+	}
+	headers.Set(headerContentType, webContentType+strings.TrimPrefix(contentType, contentTypeGrpc))
+	headers.Del(headerContentLength)
+
+	if trailers == nil && !f.text {
+		return api.StreamFilterContinue
+	}
+	body := &bytes.Buffer{}
+	if buf != nil {
+		body.Write(buf.Bytes())
+	}
+	if trailers != nil {
+		body.Write(encodeTrailers(trailers))
+		f.sendHandler.SetResponseTrailers(nil)
+	}
+	data := body.Bytes()
+	if f.text {
+		data = []byte(base64.StdEncoding.EncodeToString(data))
+	}
+	f.sendHandler.SetResponseData(buffer.NewIoBufferBytes(data))
+	return api.StreamFilterContinue
+}
+
+func (f *streamFilter) OnDestroy() {}
+
+// encodeTrailers encodes the trailers into a frame, the trailers are in the http1 header format
+// with the lower case keys, and the keys are sorted to keep the output stable
+func encodeTrailers(trailers api.HeaderMap) []byte {
+	lines := []string{}
+	trailers.Range(func(key, value string) bool {
+		lines = append(lines, strings.ToLower(key)+":"+value+"\r\n")
+		return true
+	})
+	sort.Strings(lines)
+	payload := strings.Join(lines, "")
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = trailerFrameFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// decodeText decodes the base64 body, the body may be the concatenated chunks that are padded respectively
+func decodeText(data []byte) ([]byte, error) {
+	out := make([]byte, 0, base64.StdEncoding.DecodedLen(len(data)))
+	for len(data) > 0 {
+		n := len(data)
+		if i := bytes.IndexByte(data, '='); i >= 0 {
+			n = i + 1
+			for n < len(data) && data[n] == '=' {
+				n++
+			}
+		}
+		chunk := make([]byte, base64.StdEncoding.DecodedLen(n))
+		m, err := base64.StdEncoding.Decode(chunk, data[:n])
+		if err != nil {
+			return nil, errInvalidTextBody
+		}
+		out = append(out, chunk[:m]...)
+		data = data[n:]
+	}
+	return out, nil
+}
@@ -0,0 +1,175 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpcweb
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/protocol"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+type mockReceiveHandler struct {
+	api.StreamReceiverFilterHandler
+	headers api.HeaderMap
+	data    buffer.IoBuffer
+	code    int
+}
+
+func (h *mockReceiveHandler) SetRequestHeaders(headers api.HeaderMap) {
+	h.headers = headers
+}
+
+func (h *mockReceiveHandler) SetRequestData(data buffer.IoBuffer) {
+	h.data = data
+}
+
+func (h *mockReceiveHandler) SendHijackReply(code int, headers api.HeaderMap) {
+	h.code = code
+}
+
+type mockSendHandler struct {
+	api.StreamSenderFilterHandler
+	data     buffer.IoBuffer
+	trailers api.HeaderMap
+}
+
+func (h *mockSendHandler) SetResponseData(data buffer.IoBuffer) {
+	h.data = data
+}
+
+func (h *mockSendHandler) SetResponseTrailers(trailers api.HeaderMap) {
+	h.trailers = trailers
+}
+
+// message is a grpc message frame of the payload
+func message(payload string) []byte {
+	return append([]byte{0, 0, 0, 0, byte(len(payload))}, payload...)
+}
+
+func TestParseContentType(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		web, text   bool
+		suffix      string
+	}{
+		{"application/grpc-web", true, false, ""},
+		{"application/grpc-web+proto", true, false, "+proto"},
+		{"Application/gRPC-Web-Text+proto; charset=utf-8", true, true, "+proto"},
+		{"application/grpc", false, false, ""},
+		{"application/json", false, false, ""},
+	}
+	for _, tc := range testCases {
+		web, text, suffix := parseContentType(tc.contentType)
+		assert.Equal(t, tc.web, web, tc.contentType)
+		assert.Equal(t, tc.text, text, tc.contentType)
+		assert.Equal(t, tc.suffix, suffix, tc.contentType)
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	// the concatenated chunks are padded respectively
+	data, err := decodeText([]byte(base64.StdEncoding.EncodeToString([]byte("a")) + base64.StdEncoding.EncodeToString([]byte("bcd"))))
+	assert.Nil(t, err)
+	assert.Equal(t, "abcd", string(data))
+	_, err = decodeText([]byte("not base64!"))
+	assert.Equal(t, errInvalidTextBody, err)
+}
+
+func TestGrpcWebRequest(t *testing.T) {
+	// http1 text request
+	header := mosnhttp.RequestHeader{&fasthttp.RequestHeader{}}
+	header.Set("Content-Type", "application/grpc-web-text+proto")
+	header.Set("X-Grpc-Web", "1")
+	body := base64.StdEncoding.EncodeToString(message("hello"))
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyUpStreamProtocol, protocol.HTTP1)
+	f := NewStreamFilter()
+	handler := &mockReceiveHandler{}
+	f.SetReceiveFilterHandler(handler)
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(ctx, header, buffer.NewIoBufferString(body), nil))
+	assert.True(t, f.web)
+	assert.True(t, f.text)
+	assert.Equal(t, string(message("hello")), handler.data.String())
+	headers, ok := handler.headers.(protocol.CommonHeader)
+	assert.True(t, ok)
+	assert.Equal(t, "application/grpc+proto", headers["content-type"])
+	assert.Equal(t, "trailers", headers["te"])
+	assert.Equal(t, "10", headers["content-length"])
+	assert.Equal(t, "1", headers["x-grpc-web"])
+	assert.Equal(t, protocol.HTTP2, mosnctx.Get(ctx, types.ContextKeyUpStreamProtocol))
+
+	// invalid text body
+	f = NewStreamFilter()
+	handler = &mockReceiveHandler{}
+	f.SetReceiveFilterHandler(handler)
+	headers = protocol.CommonHeader{"content-type": "application/grpc-web-text"}
+	assert.Equal(t, api.StreamFilterStop, f.OnReceive(context.Background(), headers, buffer.NewIoBufferString("!!"), nil))
+	assert.Equal(t, 400, handler.code)
+
+	// not grpc-web
+	f = NewStreamFilter()
+	f.SetReceiveFilterHandler(&mockReceiveHandler{})
+	headers = protocol.CommonHeader{"content-type": "application/grpc"}
+	assert.Equal(t, api.StreamFilterContinue, f.OnReceive(context.Background(), headers, nil, nil))
+	assert.False(t, f.web)
+	assert.Equal(t, "application/grpc", headers["content-type"])
+}
+
+func TestGrpcWebResponse(t *testing.T) {
+	trailers := protocol.CommonHeader{"Grpc-Status": "0", "grpc-message": "ok"}
+	expected := append(message("world"), 0x80, 0, 0, 0, 32)
+	expected = append(expected, "grpc-message:ok\r\ngrpc-status:0\r\n"...)
+
+	// binary
+	f := &streamFilter{web: true}
+	handler := &mockSendHandler{trailers: trailers}
+	f.SetSenderFilterHandler(handler)
+	headers := protocol.CommonHeader{"content-type": "application/grpc+proto", "content-length": "10"}
+	f.Append(context.Background(), headers, buffer.NewIoBufferBytes(message("world")), trailers)
+	assert.Equal(t, "application/grpc-web+proto", headers["content-type"])
+	_, ok := headers["content-length"]
+	assert.False(t, ok)
+	assert.Equal(t, expected, handler.data.Bytes())
+	assert.Nil(t, handler.trailers)
+
+	// text
+	f = &streamFilter{web: true, text: true}
+	handler = &mockSendHandler{trailers: trailers}
+	f.SetSenderFilterHandler(handler)
+	headers = protocol.CommonHeader{"content-type": "application/grpc"}
+	f.Append(context.Background(), headers, buffer.NewIoBufferBytes(message("world")), trailers)
+	assert.Equal(t, "application/grpc-web-text", headers["content-type"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString(expected), handler.data.String())
+
+	// not grpc response
+	f = &streamFilter{web: true}
+	handler = &mockSendHandler{}
+	f.SetSenderFilterHandler(handler)
+	headers = protocol.CommonHeader{"content-type": "text/plain"}
+	f.Append(context.Background(), headers, buffer.NewIoBufferString("error"), nil)
+	assert.Equal(t, "text/plain", headers["content-type"])
+	assert.Nil(t, handler.data)
+}
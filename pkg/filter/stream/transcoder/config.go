@@ -19,6 +19,8 @@ package transcoder
 
 import (
 	"encoding/json"
+	"fmt"
+
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/filter/stream/transcoder/matcher"
 	"mosn.io/mosn/pkg/filter/stream/transcoder/simplematcher"
@@ -29,6 +31,17 @@ type config struct {
 	Rules       []*matcher.TransferRule       `json:"-"`
 	Trans       map[string]interface{}        `json:"trans,omitempty"`
 	RuleConfigs []*matcher.TransferRuleConfig `json:"rules,omitempty"`
+	// factories are the factories created by the factory creators of the rules
+	factories map[*matcher.RuleInfo]TranscoderFactory
+}
+
+// getTranscoderFactory returns the factory of the rule, the factory created when
+// the config is parsed is preferred to the registered one.
+func (c *config) getTranscoderFactory(ruleInfo *matcher.RuleInfo, srcPro api.ProtocolName) TranscoderFactory {
+	if tf, ok := c.factories[ruleInfo]; ok {
+		return tf
+	}
+	return GetTranscoderFactory(ruleInfo.GetType(srcPro))
 }
 
 type transcodeGoPluginConfig struct {
@@ -61,6 +74,20 @@ func parseConfig(cfg interface{}) (*config, error) {
 			Matcher:  matcher.NewMatcher(rc.MatcherConfig),
 			RuleInfo: rc.RuleInfo,
 		})
+		if rc.RuleInfo == nil {
+			continue
+		}
+		// the type of the rule without a configured type depends on the downstream protocol
+		if creator := GetTranscoderFactoryCreator(rc.RuleInfo.Type); rc.RuleInfo.Type != "" && creator != nil {
+			tf, err := creator(rc.RuleInfo.Config)
+			if err != nil {
+				return nil, fmt.Errorf("invalid config of the transcoder %s: %v", rc.RuleInfo.Type, err)
+			}
+			if filterConfig.factories == nil {
+				filterConfig.factories = map[*matcher.RuleInfo]TranscoderFactory{}
+			}
+			filterConfig.factories[rc.RuleInfo] = tf
+		}
 	}
 
 	if filterConfig.Type != "" {
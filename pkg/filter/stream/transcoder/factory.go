@@ -76,3 +76,27 @@ func GetTranscoderFactory(typ string) TranscoderFactory {
 	}
 	return nil
 }
+
+// TranscoderFactoryCreator parses the config of a rule once when the filter config is parsed,
+// the returned factory creates the transcoders of the requests matched the rule.
+type TranscoderFactoryCreator func(cfg map[string]interface{}) (TranscoderFactory, error)
+
+// transcoder factory creator
+var transcoderFactoryCreator = make(map[string]TranscoderFactoryCreator)
+
+// MustRegisterFactoryCreator registers the factory creator of a transcoder type, it is used instead of
+// the registered factory for the rules with the type configured.
+func MustRegisterFactoryCreator(typ string, creator TranscoderFactoryCreator) {
+	if transcoderFactoryCreator[typ] != nil {
+		panic("target stream transcoder factory creator already exists: " + typ)
+	}
+
+	transcoderFactoryCreator[typ] = creator
+}
+
+func GetTranscoderFactoryCreator(typ string) TranscoderFactoryCreator {
+	if tc, ok := transcoderFactoryCreator[typ]; ok {
+		return tc
+	}
+	return nil
+}
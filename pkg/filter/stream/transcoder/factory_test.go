@@ -19,6 +19,7 @@ package transcoder
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"mosn.io/api/extensions/transcoder"
@@ -96,3 +97,39 @@ func TestCreateFilter(t *testing.T) {
 		assert.Equal(t, tcase.expectReceiver, mock.receiverPhase)
 	}
 }
+
+func TestFactoryCreator(t *testing.T) {
+	created := 0
+	MustRegisterFactoryCreator("creator_simple", func(cfg map[string]interface{}) (TranscoderFactory, error) {
+		if cfg["invalid"] != nil {
+			return nil, errors.New("invalid config")
+		}
+		created++
+		return func(config map[string]interface{}) transcoder.Transcoder { return &tt{} }, nil
+	})
+	newConfig := func(ruleConfig map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"rules": []interface{}{map[string]interface{}{
+				"matcher_config": map[string]interface{}{"matcher_type": "simpleMatcher"},
+				"rule_info": map[string]interface{}{
+					"type":   "creator_simple",
+					"config": ruleConfig,
+				},
+			}},
+		}
+	}
+	cfg, err := parseConfig(newConfig(map[string]interface{}{"key": "value"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, created)
+	// the factory is created once and used by the requests
+	ruleInfo := cfg.Rules[0].RuleInfo
+	for i := 0; i < 3; i++ {
+		tf := cfg.getTranscoderFactory(ruleInfo, api.ProtocolName("Http1"))
+		assert.NotNil(t, tf)
+		assert.NotNil(t, tf(ruleInfo.Config))
+	}
+	assert.Equal(t, 1, created)
+	// the invalid rule config is rejected when the config is parsed
+	_, err = parseConfig(newConfig(map[string]interface{}{"invalid": true}))
+	assert.Error(t, err)
+}
@@ -81,7 +81,7 @@ func (f *transcodeFilter) OnReceive(ctx context.Context, headers types.HeaderMap
 	}
 	srcPro := mosnctx.Get(ctx, types.ContextKeyDownStreamProtocol).(api.ProtocolName)
 	//select transcoder
-	transcoderFactory := GetTranscoderFactory(ruleInfo.GetType(srcPro))
+	transcoderFactory := f.cfg.getTranscoderFactory(ruleInfo, srcPro)
 	if transcoderFactory == nil {
 		log.Proxy.Errorf(ctx, "[stream filter][transcoder] cloud not found transcoderFactory")
 		return api.StreamFilterContinue
@@ -0,0 +1,377 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package http2dubbo transcodes the http requests into the dubbo generic invocations.
+//
+// The path of the request is /{interface}/{method}, and the json body declares the argument
+// types and values, the attachments are optional:
+//
+//	POST /org.apache.dubbo.demo.DemoService/sayHello
+//	{"types": ["java.lang.String"], "args": ["mosn"], "attachments": {"tag": "gray"}}
+//
+// The request is sent as a $invoke call with the generic attachment, the return value or the
+// exception of the dubbo response is converted into json with the mapped http status.
+package http2dubbo
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/valyala/fasthttp"
+	apit "mosn.io/api/extensions/transcoder"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/log"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+	"mosn.io/pkg/buffer"
+)
+
+// TranscoderType is the type of the transcoder
+const TranscoderType = "http2dubbo_generic"
+
+const (
+	dubboVersion = "2.0.2"
+	// genericMethod is the method of the generic invocation: $invoke(String method, String[] types, Object[] args)
+	genericMethod    = "$invoke"
+	genericTypesDesc = "Ljava/lang/String;[Ljava/lang/String;[Ljava/lang/Object;"
+
+	// the headers override the version and the group in the config
+	headerVersion = "X-Dubbo-Version"
+	headerGroup   = "X-Dubbo-Group"
+
+	contentTypeJSON = "application/json"
+)
+
+// the response flags of the dubbo response payload
+const (
+	responseWithException = iota
+	responseValue
+	responseNullValue
+	responseWithExceptionWithAttachments
+	responseValueWithAttachments
+	responseNullValueWithAttachments
+)
+
+var (
+	errInvalidPath  = errors.New("the request path should be /{interface}/{method}")
+	errArgsMismatch = errors.New("the count of the types and the args are not the same")
+)
+
+func init() {
+	transcoder.MustRegister(TranscoderType, NewTranscoder)
+}
+
+// config is the config of the rule info
+type config struct {
+	// PathPrefix is trimmed from the request path before it is mapped to the interface and method
+	PathPrefix string `json:"path_prefix,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Group      string `json:"group,omitempty"`
+}
+
+// invocation is the json body of the request
+type invocation struct {
+	Types       []string               `json:"types"`
+	Args        []json.RawMessage      `json:"args"`
+	Attachments map[string]interface{} `json:"attachments,omitempty"`
+}
+
+type http2dubbo struct {
+	cfg *config
+}
+
+func NewTranscoder(cfg map[string]interface{}) apit.Transcoder {
+	c := &config{}
+	if data, err := json.Marshal(cfg); err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			log.DefaultLogger.Errorf("[stream filter][transcoder][http2dubbo] invalid config: %v", err)
+		}
+	}
+	return &http2dubbo{cfg: c}
+}
+
+func (t *http2dubbo) Accept(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) bool {
+	_, ok := headers.(mosnhttp.RequestHeader)
+	return ok
+}
+
+func (t *http2dubbo) TranscodingRequest(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	path, _ := variable.GetString(ctx, types.VarPath)
+	iface, method, err := t.parsePath(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	inv := &invocation{}
+	if buf != nil && buf.Len() > 0 {
+		if err := json.Unmarshal(buf.Bytes(), inv); err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid request body: %v", err)
+		}
+	}
+	if len(inv.Types) != len(inv.Args) {
+		return nil, nil, nil, errArgsMismatch
+	}
+	args := make([]interface{}, len(inv.Args))
+	for i := range inv.Args {
+		if args[i], err = convertArg(inv.Types[i], inv.Args[i]); err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid arg %d: %v", i, err)
+		}
+	}
+
+	version, group := t.cfg.Version, t.cfg.Group
+	if v, ok := headers.Get(headerVersion); ok {
+		version = v
+	}
+	if g, ok := headers.Get(headerGroup); ok {
+		group = g
+	}
+	attachments := map[interface{}]interface{}{}
+	for k, v := range inv.Attachments {
+		attachments[k] = toHessian(v)
+	}
+	attachments["path"] = iface
+	attachments["interface"] = iface
+	attachments["version"] = version
+	attachments["generic"] = "true"
+	if group != "" {
+		attachments["group"] = group
+	}
+
+	encoder := hessian.NewEncoder()
+	for _, v := range []interface{}{
+		dubboVersion, iface, version, genericMethod, genericTypesDesc,
+		method, inv.Types, args, attachments,
+	} {
+		if err := encoder.Encode(v); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	request := dubbo.NewRpcRequest(nil, buffer.NewIoBufferBytes(requestFrame(encoder.Buffer())))
+	if request == nil {
+		return nil, nil, nil, errors.New("encode dubbo request failed")
+	}
+	if group != "" {
+		request.Set(dubbo.GroupNameHeader, group)
+	}
+
+	mosnctx.WithValue(ctx, types.ContextKeyUpStreamProtocol, dubbo.ProtocolName)
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter][transcoder][http2dubbo] transcode request to %s.%s", iface, method)
+	}
+	return request, request.GetData(), nil, nil
+}
+
+// parsePath maps the path /{interface}/{method} to the interface and the method
+func (t *http2dubbo) parsePath(path string) (string, string, error) {
+	path = strings.Trim(strings.TrimPrefix(path, t.cfg.PathPrefix), "/")
+	i := strings.LastIndexByte(path, '/')
+	if i <= 0 || i == len(path)-1 {
+		return "", "", errInvalidPath
+	}
+	return path[:i], path[i+1:], nil
+}
+
+// requestFrame encodes the two way hessian2 request frame, the request id is set by the stream
+func requestFrame(payload []byte) []byte {
+	frame := make([]byte, dubbo.HeaderLen, dubbo.HeaderLen+len(payload))
+	copy(frame, dubbo.MagicTag)
+	frame[dubbo.FlagIdx] = 0xc2
+	binary.BigEndian.PutUint32(frame[dubbo.DataLenIdx:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// convertArg converts the json value into the hessian value of the declared java type
+func convertArg(typ string, raw json.RawMessage) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	number, isNumber := v.(json.Number)
+	switch typ {
+	case "int", "java.lang.Integer", "short", "java.lang.Short", "byte", "java.lang.Byte":
+		if !isNumber {
+			return nil, fmt.Errorf("%s is not a %s", raw, typ)
+		}
+		n, err := number.Int64()
+		return int32(n), err
+	case "long", "java.lang.Long":
+		if !isNumber {
+			return nil, fmt.Errorf("%s is not a %s", raw, typ)
+		}
+		return number.Int64()
+	case "double", "java.lang.Double", "float", "java.lang.Float":
+		if !isNumber {
+			return nil, fmt.Errorf("%s is not a %s", raw, typ)
+		}
+		return number.Float64()
+	case "boolean", "java.lang.Boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a %s", raw, typ)
+		}
+		return b, nil
+	case "java.lang.String", "char", "java.lang.Character":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a %s", raw, typ)
+		}
+		return s, nil
+	}
+	// the pojo is a map in the generic invocation, the class can be set by the "class" key
+	return toHessian(v), nil
+}
+
+// toHessian converts the json objects and arrays into the types that hessian encodes
+func toHessian(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(value))
+		for k, e := range value {
+			m[k] = toHessian(e)
+		}
+		return m
+	case []interface{}:
+		for i := range value {
+			value[i] = toHessian(value[i])
+		}
+		return value
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return n
+		}
+		f, _ := value.Float64()
+		return f
+	}
+	return v
+}
+
+func (t *http2dubbo) TranscodingResponse(ctx context.Context, headers types.HeaderMap, buf types.IoBuffer, trailers types.HeaderMap) (types.HeaderMap, types.IoBuffer, types.HeaderMap, error) {
+	frame, ok := headers.(*dubbo.Frame)
+	if !ok {
+		// if the response is not dubbo response, it maybe come from hijack or send directly response.
+		// so we just returns the original data
+		return headers, buf, trailers, nil
+	}
+	var payload []byte
+	if data := frame.GetData(); data != nil {
+		payload = data.Bytes()
+	}
+	status, body, err := decodeResponse(frame.Status, payload)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	response := fasthttp.Response{}
+	response.SetStatusCode(status)
+	response.Header.SetContentType(contentTypeJSON)
+	return mosnhttp.ResponseHeader{ResponseHeader: &response.Header}, buffer.NewIoBufferBytes(data), nil, nil
+}
+
+// decodeResponse decodes the dubbo response into the http status and the json body
+func decodeResponse(status byte, payload []byte) (int, interface{}, error) {
+	decoder := hessian.NewDecoder(payload)
+	if status != dubbo.RespStatusOK {
+		// the payload of the failed response is the error message
+		message, _ := decoder.Decode()
+		return httpStatus(status), map[string]interface{}{
+			"status":  status,
+			"message": fmt.Sprint(message),
+		}, nil
+	}
+	field, err := decoder.Decode()
+	if err != nil {
+		return 0, nil, fmt.Errorf("decode response flag failed: %v", err)
+	}
+	flag, ok := field.(int32)
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid response flag: %v", field)
+	}
+	switch flag {
+	case responseNullValue, responseNullValueWithAttachments:
+		return http.StatusOK, nil, nil
+	case responseValue, responseValueWithAttachments:
+		value, err := decoder.Decode()
+		if err != nil {
+			return 0, nil, fmt.Errorf("decode response value failed: %v", err)
+		}
+		return http.StatusOK, toJSON(value), nil
+	case responseWithException, responseWithExceptionWithAttachments:
+		exception, err := decoder.Decode()
+		if err != nil {
+			return 0, nil, fmt.Errorf("decode response exception failed: %v", err)
+		}
+		return http.StatusInternalServerError, map[string]interface{}{
+			"exception": toJSON(exception),
+		}, nil
+	}
+	return 0, nil, fmt.Errorf("unknown response flag: %d", flag)
+}
+
+// toJSON converts the hessian values into the types that json marshals
+func toJSON(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			m[fmt.Sprint(k)] = toJSON(e)
+		}
+		return m
+	case []interface{}:
+		for i := range value {
+			value[i] = toJSON(value[i])
+		}
+		return value
+	case error:
+		return value.Error()
+	}
+	return v
+}
+
+// httpStatus maps the dubbo response status to the http status
+func httpStatus(status byte) int {
+	switch status {
+	case dubbo.RespStatusClientTimeout, dubbo.RespStatusServerTimeout:
+		return http.StatusGatewayTimeout
+	case dubbo.RespStatusBadRequest:
+		return http.StatusBadRequest
+	case dubbo.RespStatusBadResponse:
+		return http.StatusBadGateway
+	case dubbo.RespStatusServiceNotFound:
+		return http.StatusNotFound
+	case dubbo.RespStatusServerThreadpoolExhaustedError:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
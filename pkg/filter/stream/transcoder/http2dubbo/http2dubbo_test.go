@@ -0,0 +1,198 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http2dubbo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"mosn.io/mosn/pkg/protocol/xprotocol/bolt"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+	"mosn.io/pkg/buffer"
+	mosnhttp "mosn.io/pkg/protocol/http"
+)
+
+func newRequestContext(path string) context.Context {
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarPath, path)
+	return ctx
+}
+
+func TestAccept(t *testing.T) {
+	tr := NewTranscoder(nil)
+	assert.True(t, tr.Accept(context.Background(), mosnhttp.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}, nil, nil))
+	assert.False(t, tr.Accept(context.Background(), &bolt.Request{}, nil, nil))
+}
+
+func TestParsePath(t *testing.T) {
+	tr := NewTranscoder(map[string]interface{}{"path_prefix": "/dubbo"}).(*http2dubbo)
+	iface, method, err := tr.parsePath("/dubbo/org.apache.dubbo.demo.DemoService/sayHello")
+	assert.Nil(t, err)
+	assert.Equal(t, "org.apache.dubbo.demo.DemoService", iface)
+	assert.Equal(t, "sayHello", method)
+	for _, path := range []string{"/dubbo/sayHello", "/dubbo/org.apache.dubbo.demo.DemoService/", "/"} {
+		_, _, err := tr.parsePath(path)
+		assert.Equal(t, errInvalidPath, err, path)
+	}
+}
+
+func TestConvertArg(t *testing.T) {
+	cases := []struct {
+		typ  string
+		raw  string
+		want interface{}
+	}{
+		{"int", "1", int32(1)},
+		{"java.lang.Long", "10000000000", int64(10000000000)},
+		{"double", "1.5", float64(1.5)},
+		{"boolean", "true", true},
+		{"java.lang.String", `"mosn"`, "mosn"},
+		{"java.lang.String", "null", nil},
+		{"java.util.List", `[1, "a"]`, []interface{}{int64(1), "a"}},
+		{"org.apache.dubbo.demo.User", `{"name": "mosn", "age": 3}`, map[interface{}]interface{}{"name": "mosn", "age": int64(3)}},
+	}
+	for _, c := range cases {
+		got, err := convertArg(c.typ, []byte(c.raw))
+		assert.Nil(t, err, c.raw)
+		assert.Equal(t, c.want, got, c.raw)
+	}
+	for typ, raw := range map[string]string{
+		"int":              `"1"`,
+		"long":             "1.5",
+		"boolean":          "1",
+		"java.lang.String": "1",
+	} {
+		_, err := convertArg(typ, []byte(raw))
+		assert.NotNil(t, err, typ)
+	}
+}
+
+func TestTranscodingRequest(t *testing.T) {
+	tr := NewTranscoder(map[string]interface{}{"version": "1.0.0", "group": "default"})
+	headers := mosnhttp.RequestHeader{RequestHeader: &fasthttp.RequestHeader{}}
+	headers.Set(headerGroup, "gray")
+	body := buffer.NewIoBufferString(`{"types": ["java.lang.String", "int"], "args": ["mosn", 3], "attachments": {"tag": "a"}}`)
+	ctx := newRequestContext("/org.apache.dubbo.demo.DemoService/sayHello")
+
+	h, buf, _, err := tr.TranscodingRequest(ctx, headers, body, nil)
+	assert.Nil(t, err)
+	frame, ok := h.(*dubbo.Frame)
+	assert.True(t, ok)
+	assert.True(t, frame.IsTwoWay)
+	assert.Equal(t, dubbo.EventRequest, frame.Direction)
+	service, _ := frame.Get(dubbo.ServiceNameHeader)
+	assert.Equal(t, "org.apache.dubbo.demo.DemoService", service)
+	method, _ := frame.Get(dubbo.MethodNameHeader)
+	assert.Equal(t, genericMethod, method)
+	group, _ := frame.Get(dubbo.GroupNameHeader)
+	assert.Equal(t, "gray", group)
+	assert.Equal(t, dubbo.ProtocolName, ctx.Value(types.ContextKeyUpStreamProtocol))
+
+	decoder := hessian.NewDecoder(buf.Bytes())
+	var fields []interface{}
+	for i := 0; i < 9; i++ {
+		v, err := decoder.Decode()
+		assert.Nil(t, err)
+		fields = append(fields, v)
+	}
+	assert.Equal(t, []interface{}{dubboVersion, "org.apache.dubbo.demo.DemoService", "1.0.0", genericMethod, genericTypesDesc, "sayHello"}, fields[:6])
+	assert.Equal(t, "[java.lang.String int]", fmt.Sprint(fields[6]))
+	assert.Equal(t, "[mosn 3]", fmt.Sprint(fields[7]))
+	attachments := fields[8].(map[interface{}]interface{})
+	assert.Equal(t, "true", attachments["generic"])
+	assert.Equal(t, "gray", attachments["group"])
+	assert.Equal(t, "a", attachments["tag"])
+
+	// invalid requests
+	for path, data := range map[string]string{
+		"/org.apache.dubbo.demo.DemoService/sayHello": `{"types": ["int"], "args": []}`,
+		"/org.apache.dubbo.demo.DemoService/sayHi":    `{"types": ["int"], "args": ["a"]}`,
+		"/sayHello": `{}`,
+	} {
+		_, _, _, err := tr.TranscodingRequest(newRequestContext(path), headers, buffer.NewIoBufferString(data), nil)
+		assert.NotNil(t, err, path)
+	}
+}
+
+func newResponse(status byte, values ...interface{}) *dubbo.Frame {
+	encoder := hessian.NewEncoder()
+	for _, v := range values {
+		encoder.Encode(v)
+	}
+	frame := &dubbo.Frame{}
+	frame.Status = status
+	frame.SetData(buffer.NewIoBufferBytes(encoder.Buffer()))
+	return frame
+}
+
+func TestTranscodingResponse(t *testing.T) {
+	tr := NewTranscoder(nil)
+	cases := []struct {
+		response *dubbo.Frame
+		status   int
+		body     string
+	}{
+		{
+			response: newResponse(dubbo.RespStatusOK, int32(responseValue), map[interface{}]interface{}{"name": "mosn"}),
+			status:   http.StatusOK,
+			body:     `{"name":"mosn"}`,
+		},
+		{
+			response: newResponse(dubbo.RespStatusOK, int32(responseNullValueWithAttachments), map[interface{}]interface{}{}),
+			status:   http.StatusOK,
+			body:     `null`,
+		},
+		{
+			response: newResponse(dubbo.RespStatusOK, int32(responseWithException), "java.lang.IllegalStateException: closed"),
+			status:   http.StatusInternalServerError,
+			body:     `{"exception":"java.lang.IllegalStateException: closed"}`,
+		},
+		{
+			response: newResponse(dubbo.RespStatusServiceNotFound, "service not found"),
+			status:   http.StatusNotFound,
+			body:     `{"message":"service not found","status":60}`,
+		},
+		{
+			response: newResponse(dubbo.RespStatusServerTimeout, "timeout"),
+			status:   http.StatusGatewayTimeout,
+			body:     `{"message":"timeout","status":31}`,
+		},
+	}
+	for _, c := range cases {
+		h, buf, _, err := tr.TranscodingResponse(context.Background(), c.response, c.response.GetData(), nil)
+		assert.Nil(t, err)
+		header, ok := h.(mosnhttp.ResponseHeader)
+		assert.True(t, ok)
+		assert.Equal(t, c.status, header.StatusCode())
+		assert.Equal(t, contentTypeJSON, string(header.ContentType()))
+		assert.Equal(t, c.body, buf.String())
+	}
+
+	// non dubbo response is passed through
+	header := mosnhttp.ResponseHeader{ResponseHeader: &fasthttp.ResponseHeader{}}
+	h, _, _, err := tr.TranscodingResponse(context.Background(), header, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, header, h)
+}
@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsongrpc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// findField finds the field of the field path, the fields except the last one must be messages
+func findField(md protoreflect.MessageDescriptor, fieldPath []string) (protoreflect.FieldDescriptor, error) {
+	var fd protoreflect.FieldDescriptor
+	for i, name := range fieldPath {
+		if md == nil {
+			return nil, fmt.Errorf("field %s is not a message", strings.Join(fieldPath[:i], "."))
+		}
+		fd = md.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = md.Fields().ByJSONName(name)
+		}
+		if fd == nil {
+			return nil, fmt.Errorf("unknown field %s", strings.Join(fieldPath[:i+1], "."))
+		}
+		md = nil
+		if fd.Kind() == protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() {
+			md = fd.Message()
+		}
+	}
+	return fd, nil
+}
+
+// setField sets the field of the field path by the string value of the path variable or the query parameter,
+// the value is appended if the field is repeated
+func setField(msg protoreflect.Message, fieldPath []string, value string) error {
+	fd, err := findField(msg.Descriptor(), fieldPath)
+	if err != nil {
+		return err
+	}
+	// the parent messages are created if they are not set
+	for _, name := range fieldPath[:len(fieldPath)-1] {
+		parent := msg.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if parent == nil {
+			parent = msg.Descriptor().Fields().ByJSONName(name)
+		}
+		msg = msg.Mutable(parent).Message()
+	}
+	if fd.IsMap() || (fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind) {
+		return fmt.Errorf("field %s is not a scalar", strings.Join(fieldPath, "."))
+	}
+	v, err := parseScalar(fd, value)
+	if err != nil {
+		return fmt.Errorf("invalid value of field %s: %v", strings.Join(fieldPath, "."), err)
+	}
+	if fd.IsList() {
+		msg.Mutable(fd).List().Append(v)
+		return nil
+	}
+	msg.Set(fd, v)
+	return nil
+}
+
+func parseScalar(fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			b, err = base64.URLEncoding.DecodeString(value)
+		}
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported kind %s", fd.Kind())
+}
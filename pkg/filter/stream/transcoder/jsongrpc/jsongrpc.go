@@ -0,0 +1,433 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package jsongrpc transcodes the json/rest requests into the grpc requests.
+//
+// The grpc methods are mapped to the http verbs and paths by the google.api.http annotations
+// in a FileDescriptorSet, the json request body, the path variables and the query parameters
+// are converted into the grpc request message, and the grpc response messages are converted
+// into json. The messages of the server streaming response are converted into a json array.
+//
+//	"stream_filters": [{
+//		"type": "transcoder",
+//		"config": {
+//			"rules": [{
+//				"matcher_config": {"matcher_type": "simpleMatcher"},
+//				"rule_info": {
+//					"type": "jsonTogrpc",
+//					"upstream_protocol": "Http2",
+//					"description": "json to grpc",
+//					"config": {
+//						"descriptor_file": "/home/admin/mosn/bookstore.pb",
+//						"services": ["bookstore.Bookstore"]
+//					}
+//				}
+//			}]
+//		}
+//	}]
+package jsongrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"mosn.io/api"
+	apitran "mosn.io/api/extensions/transcoder"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/filter/stream/transcoder"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/http2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+	"mosn.io/pkg/buffer"
+)
+
+// TranscoderType is the type of the transcoder
+const TranscoderType = "jsonTogrpc"
+
+const (
+	contentTypeGrpc = "application/grpc"
+	contentTypeJSON = "application/json"
+	// frameHeaderLen is the length of the grpc message frame header, a compressed flag and a 4 bytes length
+	frameHeaderLen = 5
+)
+
+var (
+	errNoRoute            = errors.New("no grpc method matches the request")
+	errCompressedResponse = errors.New("compressed grpc response is not supported")
+	errInvalidFrame       = errors.New("invalid grpc message frame")
+)
+
+func init() {
+	transcoder.MustRegister(TranscoderType, NewJSONToGrpc)
+	transcoder.MustRegisterFactoryCreator(TranscoderType, newFactory)
+}
+
+// config is the config of the rule info
+type config struct {
+	// DescriptorFile is the FileDescriptorSet that contains the services and the imports
+	DescriptorFile string `json:"descriptor_file"`
+	// Services are the exposed services, all the services are exposed if it is empty
+	Services []string `json:"services,omitempty"`
+	// IgnoreUnknownQueryParameters ignores the query parameters that are not the request fields
+	IgnoreUnknownQueryParameters bool `json:"ignore_unknown_query_parameters,omitempty"`
+	// EmitUnpopulated outputs the fields with the zero values in the response json
+	EmitUnpopulated bool `json:"emit_unpopulated,omitempty"`
+	// UseProtoNames outputs the proto field names rather than the lower camel case names
+	UseProtoNames bool `json:"use_proto_names,omitempty"`
+}
+
+type jsonToGrpc struct {
+	cfg *config
+	reg *registry
+
+	// the matched route and the path variables of the request
+	route  *route
+	values map[string]string
+}
+
+// NewJSONToGrpc creates the transcoder, it returns nil if the descriptor set can not be loaded.
+// The rules of the jsonTogrpc type use the factory created by newFactory, the config is parsed once.
+func NewJSONToGrpc(cfg map[string]interface{}) apitran.Transcoder {
+	factory, err := newFactory(cfg)
+	if err != nil {
+		log.DefaultLogger.Errorf("[stream filter][transcoder][jsonTogrpc] create transcoder failed: %v", err)
+		return nil
+	}
+	return factory(cfg)
+}
+
+// newFactory parses the config and loads the descriptor set, the transcoders created by
+// the factory share them.
+func newFactory(cfg map[string]interface{}) (transcoder.TranscoderFactory, error) {
+	c := &config{}
+	data, err := json.Marshal(cfg)
+	if err == nil {
+		err = json.Unmarshal(data, c)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	reg, err := loadRegistry(c.DescriptorFile, c.Services)
+	if err != nil {
+		return nil, fmt.Errorf("load descriptor %s failed: %v", c.DescriptorFile, err)
+	}
+	return func(map[string]interface{}) apitran.Transcoder {
+		return &jsonToGrpc{
+			cfg: c,
+			reg: reg,
+		}
+	}, nil
+}
+
+// Accept checks the http verb and path of the request matches a grpc method
+func (t *jsonToGrpc) Accept(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) bool {
+	// the grpc request is not transcoded
+	if contentType, _ := headers.Get("content-type"); strings.HasPrefix(contentType, contentTypeGrpc) {
+		return false
+	}
+	method, _ := variable.GetString(ctx, types.VarMethod)
+	path, _ := variable.GetString(ctx, types.VarPath)
+	route, values, ok := t.reg.match(method, path)
+	if !ok {
+		return false
+	}
+	t.route, t.values = route, values
+	return true
+}
+
+// TranscodingRequest converts the json body, the path variables and the query parameters into the grpc request
+func (t *jsonToGrpc) TranscodingRequest(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	if t.route == nil {
+		return nil, nil, nil, errNoRoute
+	}
+	payload, err := t.requestMessage(ctx, buf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cheader := protocol.CommonHeader{}
+	headers.Range(func(key, value string) bool {
+		key = strings.ToLower(key)
+		if !strings.HasPrefix(key, ":") && !skippedRequestHeaders[key] {
+			cheader[key] = value
+		}
+		return true
+	})
+	cheader["content-type"] = contentTypeGrpc
+	cheader["te"] = "trailers"
+
+	path := t.route.grpcPath()
+	variable.SetString(ctx, types.VarMethod, http.MethodPost)
+	variable.SetString(ctx, types.VarPath, path)
+	variable.SetString(ctx, types.VarPathOriginal, path)
+	variable.SetString(ctx, types.VarQueryString, "")
+	mosnctx.WithValue(ctx, types.ContextKeyUpStreamProtocol, protocol.HTTP2)
+
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(ctx, "[stream filter][transcoder][jsonTogrpc] transcode request to %s", path)
+	}
+	return cheader, buffer.NewIoBufferBytes(frame(payload)), nil, nil
+}
+
+var skippedRequestHeaders = map[string]bool{
+	"host":              true,
+	"content-type":      true,
+	"content-length":    true,
+	"accept-encoding":   true,
+	"connection":        true,
+	"keep-alive":        true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"te":                true,
+}
+
+func (t *jsonToGrpc) requestMessage(ctx context.Context, buf api.IoBuffer) ([]byte, error) {
+	input := t.route.method.Input()
+	msg := dynamicpb.NewMessage(input)
+
+	// the body is unmarshaled first, the unmarshal resets the message
+	if body := t.route.body; body != "" && buf != nil && buf.Len() > 0 {
+		data := buf.Bytes()
+		if body != "*" {
+			// the body is the value of the field
+			wrapped := make([]byte, 0, len(data)+len(body)+8)
+			wrapped = append(wrapped, `{"`+body+`":`...)
+			wrapped = append(wrapped, data...)
+			data = append(wrapped, '}')
+		}
+		if err := protojson.Unmarshal(data, msg); err != nil {
+			return nil, fmt.Errorf("invalid request body: %v", err)
+		}
+	}
+
+	if t.route.body != "*" {
+		query, _ := variable.GetString(ctx, types.VarQueryString)
+		params, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query string: %v", err)
+		}
+		for key, values := range params {
+			// the path variables take precedence
+			if _, ok := t.values[key]; ok {
+				continue
+			}
+			fieldPath := strings.Split(key, ".")
+			if _, err := findField(input, fieldPath); err != nil {
+				if t.cfg.IgnoreUnknownQueryParameters {
+					continue
+				}
+				return nil, fmt.Errorf("invalid query parameter %s: %v", key, err)
+			}
+			for _, value := range values {
+				if err := setField(msg, fieldPath, value); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for key, value := range t.values {
+		if err := setField(msg, strings.Split(key, "."), value); err != nil {
+			return nil, err
+		}
+	}
+	return proto.Marshal(msg)
+}
+
+// frame encodes the message into the uncompressed grpc message frame
+func frame(payload []byte) []byte {
+	data := make([]byte, frameHeaderLen, frameHeaderLen+len(payload))
+	binary.BigEndian.PutUint32(data[1:], uint32(len(payload)))
+	return append(data, payload...)
+}
+
+// TranscodingResponse converts the grpc response messages into json, the grpc error is converted
+// into the http status and a json body of the code and the message
+func (t *jsonToGrpc) TranscodingResponse(ctx context.Context, headers api.HeaderMap, buf api.IoBuffer, trailers api.HeaderMap) (api.HeaderMap, api.IoBuffer, api.HeaderMap, error) {
+	if _, ok := headers.(*http2.RspHeader); !ok || t.route == nil {
+		// the response maybe comes from hijack or direct response, returns the original data
+		return headers, buf, trailers, nil
+	}
+
+	code, message := grpcStatus(headers, trailers)
+	var body []byte
+	status := http.StatusOK
+	if code != codes.OK {
+		status = httpStatusFromCode(code)
+		body, _ = json.Marshal(map[string]interface{}{
+			"code":    code,
+			"message": message,
+		})
+	} else {
+		var err error
+		if body, err = t.responseJSON(buf); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	cheader := protocol.CommonHeader{}
+	headers.Range(func(key, value string) bool {
+		key = strings.ToLower(key)
+		if !strings.HasPrefix(key, ":") && !strings.HasPrefix(key, "grpc-") &&
+			key != "content-type" && key != "content-length" {
+			cheader[key] = value
+		}
+		return true
+	})
+	cheader["content-type"] = contentTypeJSON
+	variable.SetString(ctx, types.VarHeaderStatus, strconv.Itoa(status))
+	return cheader, buffer.NewIoBufferBytes(body), nil, nil
+}
+
+func (t *jsonToGrpc) responseJSON(buf api.IoBuffer) ([]byte, error) {
+	opts := protojson.MarshalOptions{
+		EmitUnpopulated: t.cfg.EmitUnpopulated,
+		UseProtoNames:   t.cfg.UseProtoNames,
+	}
+	messages := [][]byte{}
+	var data []byte
+	if buf != nil {
+		data = buf.Bytes()
+	}
+	for len(data) > 0 {
+		if len(data) < frameHeaderLen {
+			return nil, errInvalidFrame
+		}
+		if data[0] != 0 {
+			return nil, errCompressedResponse
+		}
+		n := int(binary.BigEndian.Uint32(data[1:frameHeaderLen]))
+		if len(data) < frameHeaderLen+n {
+			return nil, errInvalidFrame
+		}
+		msg := dynamicpb.NewMessage(t.route.method.Output())
+		if err := proto.Unmarshal(data[frameHeaderLen:frameHeaderLen+n], msg); err != nil {
+			return nil, err
+		}
+		out, err := opts.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		if t.route.responseBody != "" {
+			if out, err = t.responseBodyField(msg, out); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, out)
+		data = data[frameHeaderLen+n:]
+	}
+
+	if t.route.method.IsStreamingServer() {
+		return append(append([]byte{'['}, joinJSON(messages)...), ']'), nil
+	}
+	if len(messages) == 0 {
+		return []byte("{}"), nil
+	}
+	return messages[0], nil
+}
+
+// responseBodyField returns the json of the response body field
+func (t *jsonToGrpc) responseBodyField(msg *dynamicpb.Message, out []byte) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(out, &fields); err != nil {
+		return nil, err
+	}
+	fd := msg.Descriptor().Fields().ByName(protoreflect.Name(t.route.responseBody))
+	name := fd.JSONName()
+	if t.cfg.UseProtoNames {
+		name = string(fd.Name())
+	}
+	if field, ok := fields[name]; ok {
+		return field, nil
+	}
+	return []byte("null"), nil
+}
+
+func joinJSON(messages [][]byte) []byte {
+	out := []byte{}
+	for i, m := range messages {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, m...)
+	}
+	return out
+}
+
+// grpcStatus returns the grpc status of the trailers, or the headers of the trailers-only response
+func grpcStatus(headers, trailers api.HeaderMap) (codes.Code, string) {
+	for _, h := range []api.HeaderMap{trailers, headers} {
+		if h == nil {
+			continue
+		}
+		if value, ok := h.Get("grpc-status"); ok {
+			code, err := strconv.Atoi(value)
+			if err != nil {
+				return codes.Unknown, "invalid grpc-status: " + value
+			}
+			message, _ := h.Get("grpc-message")
+			if unescaped, err := url.PathUnescape(message); err == nil {
+				message = unescaped
+			}
+			return codes.Code(code), message
+		}
+	}
+	return codes.Unknown, "missing grpc-status"
+}
+
+// httpStatusFromCode maps the grpc code to the http status, the same as the grpc-gateway
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
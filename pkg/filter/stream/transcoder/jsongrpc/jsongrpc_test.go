@@ -0,0 +1,360 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsongrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/protocol"
+	mhttp2 "mosn.io/mosn/pkg/protocol/http2"
+	_ "mosn.io/mosn/pkg/proxy"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+	"mosn.io/pkg/buffer"
+)
+
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		Type:     typ.Enum(),
+		Label:    label.Enum(),
+		JsonName: proto.String(protojsonName(name)),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+func protojsonName(name string) string {
+	out := []byte{}
+	upper := false
+	for i := 0; i < len(name); i++ {
+		if name[i] == '_' {
+			upper = true
+			continue
+		}
+		c := name[i]
+		if upper && c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		out = append(out, c)
+	}
+	return string(out)
+}
+
+func method(name, input, output string, serverStreaming bool, rule *annotations.HttpRule) *descriptorpb.MethodDescriptorProto {
+	opts := &descriptorpb.MethodOptions{}
+	proto.SetExtension(opts, annotations.E_Http, rule)
+	return &descriptorpb.MethodDescriptorProto{
+		Name:            proto.String(name),
+		InputType:       proto.String(input),
+		OutputType:      proto.String(output),
+		ServerStreaming: proto.Bool(serverStreaming),
+		Options:         opts,
+	}
+}
+
+// testDescriptorSet is the descriptor set of a bookstore service
+func testDescriptorSet(t *testing.T) []byte {
+	const (
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i64 = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		msg = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("bookstore.proto"),
+		Package: proto.String("bookstore"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Author"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, str, "", false),
+			}},
+			{Name: proto.String("Book"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, i64, "", false),
+				field("title", 2, str, "", false),
+				field("tags", 3, str, "", true),
+				field("author", 4, msg, ".bookstore.Author", false),
+			}},
+			{Name: proto.String("GetBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, i64, "", false),
+				field("view_type", 2, str, "", false),
+			}},
+			{Name: proto.String("CreateBookRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("shelf", 1, str, "", false),
+				field("book", 2, msg, ".bookstore.Book", false),
+			}},
+			{Name: proto.String("ListBooksRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("shelf", 1, str, "", false),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Bookstore"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetBook", ".bookstore.GetBookRequest", ".bookstore.Book", false, &annotations.HttpRule{
+					Pattern:      &annotations.HttpRule_Get{Get: "/v1/books/{id}"},
+					ResponseBody: "author",
+				}),
+				method("CreateBook", ".bookstore.CreateBookRequest", ".bookstore.Book", false, &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Post{Post: "/v1/shelves/{shelf}/books"},
+					Body:    "book",
+					AdditionalBindings: []*annotations.HttpRule{{
+						Pattern: &annotations.HttpRule_Put{Put: "/v1/books:create"},
+						Body:    "*",
+					}},
+				}),
+				method("ListBooks", ".bookstore.ListBooksRequest", ".bookstore.Book", true, &annotations.HttpRule{
+					Pattern: &annotations.HttpRule_Get{Get: "/v1/{shelf=shelves/*}/books"},
+				}),
+			},
+		}},
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fd}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func newTestTranscoder(t *testing.T, cfg map[string]interface{}) *jsonToGrpc {
+	f, err := ioutil.TempFile("", "bookstore.pb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.Write(testDescriptorSet(t))
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	cfg["descriptor_file"] = f.Name()
+	tr, ok := NewJSONToGrpc(cfg).(*jsonToGrpc)
+	if !ok {
+		t.Fatal("create transcoder failed")
+	}
+	return tr
+}
+
+func newRequestContext(method, path, query string) context.Context {
+	ctx := variable.NewVariableContext(context.Background())
+	ctx = mosnctx.WithValue(ctx, types.ContextKeyUpStreamProtocol, protocol.HTTP1)
+	variable.SetString(ctx, types.VarMethod, method)
+	variable.SetString(ctx, types.VarPath, path)
+	variable.SetString(ctx, types.VarQueryString, query)
+	return ctx
+}
+
+// decodeRequest decodes the grpc request frame into a json string with the sorted keys
+func decodeRequest(t *testing.T, tr *jsonToGrpc, buf api.IoBuffer) string {
+	data := buf.Bytes()
+	assert.Equal(t, len(data)-frameHeaderLen, int(data[4]))
+	msg := dynamicpb.NewMessage(tr.route.method.Input())
+	if err := proto.Unmarshal(data[frameHeaderLen:], msg); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(out, &fields); err != nil {
+		t.Fatal(err)
+	}
+	sorted, _ := json.Marshal(fields)
+	return string(sorted)
+}
+
+// compact removes the spaces that protojson adds randomly
+func compact(t *testing.T, data []byte) string {
+	out := &bytes.Buffer{}
+	if err := json.Compact(out, data); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestParseTemplate(t *testing.T) {
+	testCases := []struct {
+		template string
+		path     string
+		match    bool
+		values   map[string]string
+	}{
+		{"/v1/books/{id}", "/v1/books/1", true, map[string]string{"id": "1"}},
+		{"/v1/books/{id}", "/v1/books/1/2", false, nil},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/a/books/b%2Fc", true, map[string]string{"name": "shelves/a/books/b/c"}},
+		{"/v1/{book.name=books/*}:publish", "/v1/books/a:publish", true, map[string]string{"book.name": "books/a"}},
+		{"/v1/{book.name=books/*}:publish", "/v1/books/a", false, nil},
+		{"/v1/files/{path=**}", "/v1/files/a/b/c", true, map[string]string{"path": "a/b/c"}},
+		{"/v1/*/books", "/v1/x/books", true, map[string]string{}},
+	}
+	for _, tc := range testCases {
+		tmpl, err := parseTemplate(tc.template)
+		if err != nil {
+			t.Fatalf("parse %s failed: %v", tc.template, err)
+		}
+		values, ok := tmpl.match(tc.path)
+		assert.Equal(t, tc.match, ok, tc.path)
+		if ok {
+			assert.Equal(t, tc.values, values, tc.path)
+		}
+	}
+	for _, invalid := range []string{"v1/books", "/v1/**/books", "/v1/{id", "/v1//books", "/v1/{=a}"} {
+		_, err := parseTemplate(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestTranscodingRequest(t *testing.T) {
+	tr := newTestTranscoder(t, map[string]interface{}{"services": []string{"bookstore.Bookstore"}})
+
+	// path variable, body field and query parameter
+	ctx := newRequestContext(http.MethodPost, "/v1/shelves/fiction/books", "book.tags=a&book.tags=b")
+	headers := protocol.CommonHeader{"Content-Type": "application/json", "Authorization": "token"}
+	assert.True(t, tr.Accept(ctx, headers, nil, nil))
+	outHeaders, outBuf, outTrailers, err := tr.TranscodingRequest(ctx, headers, buffer.NewIoBufferString(`{"title":"mosn","id":"7"}`), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, outTrailers)
+	assert.Equal(t, `{"book":{"id":"7","tags":["a","b"],"title":"mosn"},"shelf":"fiction"}`, decodeRequest(t, tr, outBuf))
+	h := outHeaders.(protocol.CommonHeader)
+	assert.Equal(t, "application/grpc", h["content-type"])
+	assert.Equal(t, "token", h["authorization"])
+	path, _ := variable.GetString(ctx, types.VarPath)
+	assert.Equal(t, "/bookstore.Bookstore/CreateBook", path)
+	method, _ := variable.GetString(ctx, types.VarMethod)
+	assert.Equal(t, http.MethodPost, method)
+	assert.Equal(t, protocol.HTTP2, mosnctx.Get(ctx, types.ContextKeyUpStreamProtocol))
+
+	// the additional binding with the whole body
+	ctx = newRequestContext(http.MethodPut, "/v1/books:create", "")
+	assert.True(t, tr.Accept(ctx, headers, nil, nil))
+	_, outBuf, _, err = tr.TranscodingRequest(ctx, headers, buffer.NewIoBufferString(`{"shelf":"s","book":{"title":"t"}}`), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"book":{"title":"t"},"shelf":"s"}`, decodeRequest(t, tr, outBuf))
+
+	// unknown query parameter
+	ctx = newRequestContext(http.MethodGet, "/v1/books/1", "unknown=1")
+	assert.True(t, tr.Accept(ctx, headers, nil, nil))
+	_, _, _, err = tr.TranscodingRequest(ctx, headers, nil, nil)
+	assert.NotNil(t, err)
+	tr.cfg.IgnoreUnknownQueryParameters = true
+	_, outBuf, _, err = tr.TranscodingRequest(ctx, headers, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"1"}`, decodeRequest(t, tr, outBuf))
+
+	// invalid path variable
+	ctx = newRequestContext(http.MethodGet, "/v1/books/abc", "")
+	assert.True(t, tr.Accept(ctx, headers, nil, nil))
+	_, _, _, err = tr.TranscodingRequest(ctx, headers, nil, nil)
+	assert.NotNil(t, err)
+
+	// not matched
+	ctx = newRequestContext(http.MethodDelete, "/v1/books/1", "")
+	assert.False(t, tr.Accept(ctx, headers, nil, nil))
+	ctx = newRequestContext(http.MethodPost, "/v1/shelves/fiction/books", "")
+	assert.False(t, tr.Accept(ctx, protocol.CommonHeader{"content-type": "application/grpc"}, nil, nil))
+}
+
+func encodeBook(t *testing.T, tr *jsonToGrpc, js string) []byte {
+	msg := dynamicpb.NewMessage(tr.route.method.Output())
+	if err := protojson.Unmarshal([]byte(js), msg); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := proto.Marshal(msg)
+	return frame(data)
+}
+
+func TestTranscodingResponse(t *testing.T) {
+	tr := newTestTranscoder(t, map[string]interface{}{})
+	ok := &mhttp2.HeaderMap{H: http.Header{"Grpc-Status": []string{"0"}}}
+
+	// server streaming
+	ctx := newRequestContext(http.MethodGet, "/v1/shelves/a/books", "")
+	assert.True(t, tr.Accept(ctx, protocol.CommonHeader{}, nil, nil))
+	body := append(encodeBook(t, tr, `{"id":"1"}`), encodeBook(t, tr, `{"id":"2"}`)...)
+	rsp := mhttp2.NewRspHeader(&http.Response{StatusCode: 200, Header: http.Header{"Content-Type": []string{"application/grpc"}}})
+	outHeaders, outBuf, outTrailers, err := tr.TranscodingResponse(ctx, rsp, buffer.NewIoBufferBytes(body), ok)
+	assert.Nil(t, err)
+	assert.Nil(t, outTrailers)
+	assert.Equal(t, `[{"id":"1"},{"id":"2"}]`, compact(t, outBuf.Bytes()))
+	assert.Equal(t, "application/json", outHeaders.(protocol.CommonHeader)["content-type"])
+	status, _ := variable.GetString(ctx, types.VarHeaderStatus)
+	assert.Equal(t, "200", status)
+
+	// response body field
+	ctx = newRequestContext(http.MethodGet, "/v1/books/1", "")
+	assert.True(t, tr.Accept(ctx, protocol.CommonHeader{}, nil, nil))
+	_, outBuf, _, err = tr.TranscodingResponse(ctx, rsp, buffer.NewIoBufferBytes(encodeBook(t, tr, `{"id":"1","author":{"name":"a"}}`)), ok)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":"a"}`, compact(t, outBuf.Bytes()))
+
+	// grpc error in the trailers-only response
+	rsp = mhttp2.NewRspHeader(&http.Response{StatusCode: 200, Header: http.Header{
+		"Grpc-Status":  []string{"5"},
+		"Grpc-Message": []string{"book%20not%20found"},
+	}})
+	_, outBuf, _, err = tr.TranscodingResponse(ctx, rsp, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"code":5,"message":"book not found"}`, outBuf.String())
+	status, _ = variable.GetString(ctx, types.VarHeaderStatus)
+	assert.Equal(t, "404", status)
+
+	// the hijack response is not transcoded
+	hijack := protocol.CommonHeader{}
+	outHeaders, _, _, err = tr.TranscodingResponse(ctx, hijack, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, hijack, outHeaders)
+}
+
+func TestLoadRegistry(t *testing.T) {
+	tr := newTestTranscoder(t, map[string]interface{}{})
+	path := tr.cfg.DescriptorFile
+	reg, err := loadRegistry(path, nil)
+	assert.Nil(t, err)
+	assert.True(t, reg == tr.reg, "the registry should be shared")
+
+	// the registry is loaded again after the file is modified
+	modTime := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(path, modTime, modTime))
+	reloaded, err := loadRegistry(path, nil)
+	assert.Nil(t, err)
+	assert.False(t, reloaded == reg, "the registry should be reloaded")
+
+	// the factory parses the config once
+	factory, err := newFactory(map[string]interface{}{"descriptor_file": path})
+	assert.Nil(t, err)
+	first, second := factory(nil).(*jsonToGrpc), factory(nil).(*jsonToGrpc)
+	assert.True(t, first.cfg == second.cfg && first.reg == reloaded)
+	_, err = newFactory(map[string]interface{}{"descriptor_file": path + ".notexists"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,207 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsongrpc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// route maps an http verb and path to a grpc method
+type route struct {
+	httpMethod   string
+	template     *pathTemplate
+	method       protoreflect.MethodDescriptor
+	body         string
+	responseBody string
+}
+
+// grpcPath returns the path of the grpc request, such as /package.Service/Method
+func (r *route) grpcPath() string {
+	return fmt.Sprintf("/%s/%s", r.method.Parent().FullName(), r.method.Name())
+}
+
+// registry is the routes of the services in a descriptor set
+type registry struct {
+	routes []*route
+}
+
+func (r *registry) match(httpMethod, path string) (*route, map[string]string, bool) {
+	for _, rt := range r.routes {
+		if rt.httpMethod != httpMethod {
+			continue
+		}
+		if values, ok := rt.template.match(path); ok {
+			return rt, values, true
+		}
+	}
+	return nil, nil, false
+}
+
+// newRegistry builds the routes of the google.api.http annotations in the FileDescriptorSet,
+// the descriptor set is generated by protoc with --include_imports and --descriptor_set_out.
+// All the services are exposed if the services are empty.
+func newRegistry(data []byte, services []string) (*registry, error) {
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, fds); err != nil {
+		return nil, err
+	}
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, err
+	}
+	exposed := make(map[string]bool, len(services))
+	for _, s := range services {
+		exposed[s] = true
+	}
+	reg := &registry{}
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len() && err == nil; i++ {
+			sd := fd.Services().Get(i)
+			if len(exposed) > 0 && !exposed[string(sd.FullName())] {
+				continue
+			}
+			for j := 0; j < sd.Methods().Len() && err == nil; j++ {
+				err = reg.addMethod(sd.Methods().Get(j))
+			}
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+func (r *registry) addMethod(md protoreflect.MethodDescriptor) error {
+	// the client streaming methods can not be mapped to a request body
+	if md.IsStreamingClient() {
+		return nil
+	}
+	opts, ok := md.Options().(*descriptorpb.MethodOptions)
+	if !ok || opts == nil || !proto.HasExtension(opts, annotations.E_Http) {
+		return nil
+	}
+	rule, ok := proto.GetExtension(opts, annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil {
+		return nil
+	}
+	if err := r.addRule(md, rule); err != nil {
+		return err
+	}
+	for _, binding := range rule.GetAdditionalBindings() {
+		if err := r.addRule(md, binding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *registry) addRule(md protoreflect.MethodDescriptor, rule *annotations.HttpRule) error {
+	var httpMethod, path string
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		httpMethod, path = http.MethodGet, pattern.Get
+	case *annotations.HttpRule_Put:
+		httpMethod, path = http.MethodPut, pattern.Put
+	case *annotations.HttpRule_Post:
+		httpMethod, path = http.MethodPost, pattern.Post
+	case *annotations.HttpRule_Delete:
+		httpMethod, path = http.MethodDelete, pattern.Delete
+	case *annotations.HttpRule_Patch:
+		httpMethod, path = http.MethodPatch, pattern.Patch
+	case *annotations.HttpRule_Custom:
+		httpMethod, path = pattern.Custom.GetKind(), pattern.Custom.GetPath()
+	default:
+		return nil
+	}
+	template, err := parseTemplate(path)
+	if err != nil {
+		return fmt.Errorf("method %s: %v: %s", md.FullName(), err, path)
+	}
+	for _, v := range template.variables {
+		if _, err := findField(md.Input(), v.fieldPath); err != nil {
+			return fmt.Errorf("method %s: %v", md.FullName(), err)
+		}
+	}
+	if rule.GetBody() != "" && rule.GetBody() != "*" && md.Input().Fields().ByName(protoreflect.Name(rule.GetBody())) == nil {
+		return fmt.Errorf("method %s: unknown body field %s", md.FullName(), rule.GetBody())
+	}
+	if rule.GetResponseBody() != "" && md.Output().Fields().ByName(protoreflect.Name(rule.GetResponseBody())) == nil {
+		return fmt.Errorf("method %s: unknown response body field %s", md.FullName(), rule.GetResponseBody())
+	}
+	r.routes = append(r.routes, &route{
+		httpMethod:   httpMethod,
+		template:     template,
+		method:       md,
+		body:         rule.GetBody(),
+		responseBody: rule.GetResponseBody(),
+	})
+	return nil
+}
+
+// loadedRegistry is a registry loaded from the descriptor set file of the modification time
+type loadedRegistry struct {
+	reg     *registry
+	modTime time.Time
+	size    int64
+}
+
+var (
+	registriesMux sync.Mutex
+	registries    = map[string]*loadedRegistry{}
+)
+
+// loadRegistry loads the descriptor set file, the registry is shared by the transcoders
+// until the file is modified.
+func loadRegistry(path string, services []string) (*registry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s%v", path, services)
+	registriesMux.Lock()
+	defer registriesMux.Unlock()
+	if loaded, ok := registries[key]; ok && loaded.modTime.Equal(info.ModTime()) && loaded.size == info.Size() {
+		return loaded.reg, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reg, err := newRegistry(data, services)
+	if err != nil {
+		return nil, err
+	}
+	registries[key] = &loadedRegistry{
+		reg:     reg,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+	return reg, nil
+}
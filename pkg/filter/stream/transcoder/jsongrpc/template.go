@@ -0,0 +1,207 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jsongrpc
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var errInvalidTemplate = errors.New("invalid http path template")
+
+const (
+	segmentLiteral = iota
+	segmentStar
+	segmentDoubleStar
+)
+
+type segment struct {
+	kind    int
+	literal string
+}
+
+// variable binds the segments in [start, end) to the field path, end is -1 if the variable ends with **
+type variable struct {
+	fieldPath []string
+	start     int
+	end       int
+}
+
+// pathTemplate is the parsed path of the google.api.http annotation:
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	Verb     = ":" LITERAL ;
+type pathTemplate struct {
+	segments  []segment
+	variables []variable
+	verb      string
+}
+
+func parseTemplate(template string) (*pathTemplate, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, errInvalidTemplate
+	}
+	t := &pathTemplate{}
+	rest := template[1:]
+	// the verb is after the last ':' that is not in a variable
+	if i := strings.LastIndexByte(rest, ':'); i >= 0 && !strings.Contains(rest[i:], "}") {
+		t.verb = rest[i+1:]
+		rest = rest[:i]
+	}
+	for len(rest) > 0 {
+		var part string
+		if rest[0] == '{' {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return nil, errInvalidTemplate
+			}
+			part, rest = rest[:end+1], rest[end+1:]
+			if err := t.parseVariable(part[1 : len(part)-1]); err != nil {
+				return nil, err
+			}
+		} else {
+			end := strings.IndexByte(rest, '/')
+			if end < 0 {
+				end = len(rest)
+			}
+			part, rest = rest[:end], rest[end:]
+			if err := t.addSegment(part); err != nil {
+				return nil, err
+			}
+		}
+		if len(rest) > 0 {
+			if rest[0] != '/' || len(rest) == 1 {
+				return nil, errInvalidTemplate
+			}
+			rest = rest[1:]
+		}
+	}
+	// ** matches the rest of the path, so it must be the last segment
+	for i, seg := range t.segments {
+		if seg.kind == segmentDoubleStar && i != len(t.segments)-1 {
+			return nil, errInvalidTemplate
+		}
+	}
+	return t, nil
+}
+
+func (t *pathTemplate) addSegment(part string) error {
+	switch part {
+	case "":
+		return errInvalidTemplate
+	case "*":
+		t.segments = append(t.segments, segment{kind: segmentStar})
+	case "**":
+		t.segments = append(t.segments, segment{kind: segmentDoubleStar})
+	default:
+		if strings.ContainsAny(part, "{}*=") {
+			return errInvalidTemplate
+		}
+		t.segments = append(t.segments, segment{kind: segmentLiteral, literal: part})
+	}
+	return nil
+}
+
+func (t *pathTemplate) parseVariable(v string) error {
+	fieldPath, segments := v, "*"
+	if i := strings.IndexByte(v, '='); i >= 0 {
+		fieldPath, segments = v[:i], v[i+1:]
+	}
+	if fieldPath == "" {
+		return errInvalidTemplate
+	}
+	start := len(t.segments)
+	for _, part := range strings.Split(segments, "/") {
+		if err := t.addSegment(part); err != nil {
+			return err
+		}
+	}
+	end := len(t.segments)
+	if t.segments[end-1].kind == segmentDoubleStar {
+		end = -1
+	}
+	t.variables = append(t.variables, variable{
+		fieldPath: strings.Split(fieldPath, "."),
+		start:     start,
+		end:       end,
+	})
+	return nil
+}
+
+// match matches the request path, and returns the unescaped values of the variables
+func (t *pathTemplate) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if t.verb != "" {
+		if !strings.HasSuffix(path, ":"+t.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+t.verb)
+	}
+	parts := []string{}
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	n := len(t.segments)
+	if n > 0 && t.segments[n-1].kind == segmentDoubleStar {
+		if len(parts) < n-1 {
+			return nil, false
+		}
+	} else if len(parts) != n {
+		return nil, false
+	}
+	for i, seg := range t.segments {
+		if seg.kind == segmentLiteral && parts[i] != seg.literal {
+			return nil, false
+		}
+	}
+	values := make(map[string]string, len(t.variables))
+	for _, v := range t.variables {
+		end := v.end
+		if end < 0 {
+			end = len(parts)
+		}
+		value := strings.Join(parts[v.start:end], "/")
+		// the single segment variable is unescaped completely, the multiple segments keep the '/'
+		if end-v.start == 1 {
+			unescaped, err := url.PathUnescape(value)
+			if err != nil {
+				return nil, false
+			}
+			value = unescaped
+		} else {
+			escaped := strings.Split(value, "/")
+			for i := range escaped {
+				unescaped, err := url.PathUnescape(escaped[i])
+				if err != nil {
+					return nil, false
+				}
+				escaped[i] = unescaped
+			}
+			value = strings.Join(escaped, "/")
+		}
+		values[strings.Join(v.fieldPath, ".")] = value
+	}
+	return values, true
+}
@@ -38,7 +38,8 @@ type MatcherConfig struct {
 }
 
 type RuleInfo struct {
-	Type             string                 `json:"-"`
+	// Type is the transcoder type, it is "<downstream protocol>_<upstream protocol>" if it is not configured
+	Type             string                 `json:"type,omitempty"`
 	UpstreamProtocol string                 `json:"upstream_protocol,omitempty"`
 	Description      string                 `json:"description,omitempty"`
 	Config           map[string]interface{} `json:"config,omitempty"`
@@ -29,6 +29,8 @@ const (
 	DownstreamConnectionTotal    = "connection_total"
 	DownstreamConnectionDestroy  = "connection_destroy"
 	DownstreamConnectionActive   = "connection_active"
+	DownstreamConnectionOverflow = "connection_overflow"
+	DownstreamConnectionLimited  = "connection_limited"
 	DownstreamBytesReadTotal     = "bytes_read_total"
 	DownstreamBytesReadBuffered  = "bytes_read_buffered"
 	DownstreamBytesWriteTotal    = "bytes_write_total"
@@ -57,6 +59,14 @@ const (
 	DownstreamRequestOtherTotal  = "request_other_code"
 )
 
+// metrics key of the listener drain progress
+const (
+	DownstreamDrainState                = "drain_state"
+	DownstreamDrainConnectionsNotified  = "drain_connections_notified"
+	DownstreamDrainConnectionsRemaining = "drain_connections_remaining"
+	DownstreamDrainRequestsActive       = "drain_requests_active"
+)
+
 // NewProxyStats returns a stats with namespace prefix proxy
 func NewProxyStats(proxyName string) types.Metrics {
 	metrics, _ := NewMetrics(DownstreamType, map[string]string{"proxy": proxyName})
@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// histogramBuckets is the explicit bucket upper bounds of latency histograms, in nanoseconds.
+// nil means the latency histograms are sample based only.
+var (
+	histogramBucketsMutex sync.RWMutex
+	histogramBuckets      []float64
+)
+
+// latencyHistogramKeys is the histograms that use explicit buckets when buckets are configured, type -> keys
+var latencyHistogramKeys = map[string]map[string]bool{
+	UpstreamType: {
+		UpstreamRequestDuration: true,
+	},
+	DownstreamType: {
+		DownstreamRequestTime: true,
+		DownstreamProcessTime: true,
+	},
+	RouteType: {
+		RouteRequestTime:             true,
+		RouteUpstreamRequestDuration: true,
+	},
+	KafkaType: {
+		KafkaRequestTime: true,
+	},
+}
+
+// SetHistogramBuckets sets the explicit bucket upper bounds of latency histograms.
+// The buckets only take effect on the histograms created after it is set.
+func SetHistogramBuckets(buckets []time.Duration) {
+	histogramBucketsMutex.Lock()
+	defer histogramBucketsMutex.Unlock()
+	if len(buckets) == 0 {
+		histogramBuckets = nil
+		return
+	}
+	bounds := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		bounds = append(bounds, float64(b.Nanoseconds()))
+	}
+	sort.Float64s(bounds)
+	histogramBuckets = bounds
+}
+
+func getHistogramBuckets(typ, key string) []float64 {
+	if !latencyHistogramKeys[typ][key] {
+		return nil
+	}
+	histogramBucketsMutex.RLock()
+	defer histogramBucketsMutex.RUnlock()
+	return histogramBuckets
+}
+
+// Exemplar is a sample value associated with a trace
+type Exemplar struct {
+	TraceID   string
+	Value     int64
+	Timestamp time.Time
+}
+
+// BucketHistogram is a histogram that counts values into explicit buckets, so it can be
+// aggregated across instances. It is also a go-metrics Histogram, so the sinks that do not
+// know buckets still works.
+type BucketHistogram interface {
+	gometrics.Histogram
+
+	// Buckets returns the upper bounds of the buckets, the +Inf bucket is not included
+	Buckets() []float64
+
+	// BucketCounts returns the cumulative count of each bucket, the last one is the +Inf bucket
+	BucketCounts() []int64
+
+	// Total returns the sum of all the sampled values, not only the values kept in the sample
+	Total() int64
+
+	// UpdateWithExemplar samples a new value, and records it as the exemplar of its bucket
+	UpdateWithExemplar(v int64, traceID string)
+
+	// Exemplars returns the latest exemplar of each bucket, the last one is the +Inf bucket.
+	// The exemplar is nil if the bucket never records one.
+	Exemplars() []*Exemplar
+}
+
+type bucketHistogram struct {
+	gometrics.Histogram
+
+	total     int64
+	bounds    []float64
+	counts    []int64        // non-cumulative, the last one is the +Inf bucket
+	exemplars []atomic.Value // *Exemplar
+}
+
+// NewBucketHistogram returns a BucketHistogram with the given upper bounds, the bounds must be sorted.
+func NewBucketHistogram(bounds []float64) BucketHistogram {
+	return &bucketHistogram{
+		// TODO: notice the histogram only keeps 100 values as we set
+		Histogram: gometrics.NewHistogram(gometrics.NewUniformSample(100)),
+		bounds:    bounds,
+		counts:    make([]int64, len(bounds)+1),
+		exemplars: make([]atomic.Value, len(bounds)+1),
+	}
+}
+
+func (h *bucketHistogram) index(v int64) int {
+	return sort.SearchFloat64s(h.bounds, float64(v))
+}
+
+func (h *bucketHistogram) Update(v int64) {
+	h.Histogram.Update(v)
+	atomic.AddInt64(&h.total, v)
+	atomic.AddInt64(&h.counts[h.index(v)], 1)
+}
+
+func (h *bucketHistogram) UpdateWithExemplar(v int64, traceID string) {
+	h.Histogram.Update(v)
+	atomic.AddInt64(&h.total, v)
+	idx := h.index(v)
+	atomic.AddInt64(&h.counts[idx], 1)
+	if traceID != "" {
+		h.exemplars[idx].Store(&Exemplar{
+			TraceID:   traceID,
+			Value:     v,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+func (h *bucketHistogram) Clear() {
+	h.Histogram.Clear()
+	atomic.StoreInt64(&h.total, 0)
+	for i := range h.counts {
+		atomic.StoreInt64(&h.counts[i], 0)
+	}
+}
+
+func (h *bucketHistogram) Buckets() []float64 {
+	return h.bounds
+}
+
+func (h *bucketHistogram) BucketCounts() []int64 {
+	counts := make([]int64, len(h.counts))
+	var total int64
+	for i := range h.counts {
+		total += atomic.LoadInt64(&h.counts[i])
+		counts[i] = total
+	}
+	return counts
+}
+
+func (h *bucketHistogram) Total() int64 {
+	return atomic.LoadInt64(&h.total)
+}
+
+func (h *bucketHistogram) Exemplars() []*Exemplar {
+	exemplars := make([]*Exemplar, len(h.exemplars))
+	for i := range h.exemplars {
+		if e, ok := h.exemplars[i].Load().(*Exemplar); ok {
+			exemplars[i] = e
+		}
+	}
+	return exemplars
+}
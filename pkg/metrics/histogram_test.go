@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBucketHistogram(t *testing.T) {
+	h := NewBucketHistogram([]float64{10, 100})
+	h.Update(5)
+	h.Update(10)
+	h.UpdateWithExemplar(50, "trace1")
+	h.Update(1000)
+
+	if counts := h.BucketCounts(); !reflect.DeepEqual(counts, []int64{2, 3, 4}) {
+		t.Errorf("unexpected bucket counts: %v", counts)
+	}
+	if h.Total() != 1065 || h.Count() != 4 {
+		t.Errorf("unexpected total: %d, count: %d", h.Total(), h.Count())
+	}
+	exemplars := h.Exemplars()
+	if exemplars[0] != nil || exemplars[2] != nil {
+		t.Errorf("unexpected exemplars: %v", exemplars)
+	}
+	if e := exemplars[1]; e == nil || e.TraceID != "trace1" || e.Value != 50 {
+		t.Errorf("unexpected exemplar: %v", e)
+	}
+
+	h.Clear()
+	if counts := h.BucketCounts(); !reflect.DeepEqual(counts, []int64{0, 0, 0}) || h.Total() != 0 {
+		t.Errorf("unexpected bucket counts after clear: %v", counts)
+	}
+}
+
+func TestLatencyHistogramBuckets(t *testing.T) {
+	ResetAll()
+	SetHistogramBuckets([]time.Duration{10 * time.Millisecond, time.Millisecond})
+	defer SetHistogramBuckets(nil)
+
+	s := NewClusterStats("test_buckets")
+	h, ok := s.Histogram(UpstreamRequestDuration).(BucketHistogram)
+	if !ok {
+		t.Fatal("latency histogram should have explicit buckets")
+	}
+	if buckets := h.Buckets(); !reflect.DeepEqual(buckets, []float64{1e6, 1e7}) {
+		t.Errorf("buckets should be sorted: %v", buckets)
+	}
+	// the same histogram is returned
+	if s.Histogram(UpstreamRequestDuration) != h {
+		t.Error("histogram should be registered")
+	}
+	if _, ok := s.Histogram("other_histogram").(BucketHistogram); ok {
+		t.Error("only latency histograms have explicit buckets")
+	}
+}
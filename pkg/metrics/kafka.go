@@ -0,0 +1,47 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"mosn.io/mosn/pkg/types"
+)
+
+// KafkaType represents the kafka filter metrics type
+const KafkaType = "kafka"
+
+// metrics key of the kafka filter, the stats are grouped by the api key or by the topic
+const (
+	KafkaRequestTotal     = "request_total"
+	KafkaRequestBytes     = "request_bytes"
+	KafkaResponseBytes    = "response_bytes"
+	KafkaRequestTime      = "request_time"
+	KafkaRequestRejected  = "request_rejected"
+	KafkaRequestThrottled = "request_throttled"
+)
+
+// NewKafkaAPIStats returns a stats of the requests of an api key
+func NewKafkaAPIStats(statPrefix, api string) types.Metrics {
+	metrics, _ := NewMetrics(KafkaType, map[string]string{"stat_prefix": statPrefix, "api": api})
+	return metrics
+}
+
+// NewKafkaTopicStats returns a stats of the requests of a topic
+func NewKafkaTopicStats(statPrefix, topic string) types.Metrics {
+	metrics, _ := NewMetrics(KafkaType, map[string]string{"stat_prefix": statPrefix, "topic": topic})
+	return metrics
+}
@@ -0,0 +1,41 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"mosn.io/mosn/pkg/types"
+)
+
+// MQTTType represents the mqtt proxy metrics type
+const MQTTType = "mqtt"
+
+// metrics key of the mqtt proxy, the stats are grouped by the configured topic prefixes
+const (
+	MQTTPublishTotal       = "publish_total"
+	MQTTPublishBytes       = "publish_bytes"
+	MQTTPublishDenied      = "publish_denied"
+	MQTTPublishRateLimited = "publish_rate_limited"
+	MQTTSubscribeTotal     = "subscribe_total"
+	MQTTSubscribeDenied    = "subscribe_denied"
+)
+
+// NewMQTTTopicStats returns a stats of the topics under a prefix
+func NewMQTTTopicStats(statPrefix, topicPrefix string) types.Metrics {
+	metrics, _ := NewMetrics(MQTTType, map[string]string{"stat_prefix": statPrefix, "topic_prefix": topicPrefix})
+	return metrics
+}
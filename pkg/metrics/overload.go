@@ -0,0 +1,49 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"mosn.io/mosn/pkg/types"
+)
+
+// OverloadType represents the overload manager metrics type
+const OverloadType = "overload"
+
+// metrics key of the overload manager
+const (
+	// OverloadActionState is the state of an action in percent, 100 means saturated
+	OverloadActionState = "state"
+	// OverloadMonitorUsage is the usage of a resource
+	OverloadMonitorUsage = "usage"
+	// OverloadMonitorPressure is the usage of a resource divided by the max, in percent
+	OverloadMonitorPressure = "pressure"
+	// OverloadRejectedRequests is the number of the requests rejected by reject_requests
+	OverloadRejectedRequests = "rejected_requests"
+)
+
+// NewOverloadActionStats returns a stats of an overload action
+func NewOverloadActionStats(action string) types.Metrics {
+	metrics, _ := NewMetrics(OverloadType, map[string]string{"action": action})
+	return metrics
+}
+
+// NewOverloadMonitorStats returns a stats of an overload resource monitor
+func NewOverloadMonitorStats(monitor string) types.Metrics {
+	metrics, _ := NewMetrics(OverloadType, map[string]string{"monitor": monitor})
+	return metrics
+}
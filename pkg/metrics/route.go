@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+// RouteType represents route metrics type
+const RouteType = "route"
+
+// metrics key in virtual host/route
+const (
+	RouteRequestTotal            = "request_total"
+	RouteRequest1xxTotal         = "request_1xx_total"
+	RouteRequest2xxTotal         = "request_2xx_total"
+	RouteRequest3xxTotal         = "request_3xx_total"
+	RouteRequest4xxTotal         = "request_4xx_total"
+	RouteRequest5xxTotal         = "request_5xx_total"
+	RouteRequestOtherTotal       = "request_other_code"
+	RouteRequestTime             = "request_time"
+	RouteUpstreamRequestDuration = "upstream_request_duration_time"
+	RouteRequestRetry            = "request_retry"
+)
+
+// RouteOverflowName is the virtual host and route name of the stats
+// that shared by all the routes exceeded the limit
+const RouteOverflowName = "overflow"
+
+const defaultMaxRouteStats = 1000
+
+var (
+	// routeStatsEnabled is checked on each request, so it is not guarded by the mutex
+	routeStatsEnabled int32
+	// routeStatsGeneration is changed when the route stats is reconfigured,
+	// the route stats cached by the users should be dropped
+	routeStatsGeneration uint64
+)
+
+// routeStats records the route stats scopes, the count of scopes is limited
+// so the misconfiguration cannot explode the store
+var routeStats = struct {
+	sync.Mutex
+	maxScopes int
+	scopes    map[string]types.Metrics
+}{
+	maxScopes: defaultMaxRouteStats,
+	scopes:    make(map[string]types.Metrics),
+}
+
+// SetRouteStats enables or disables the virtual host and route stats.
+// maxScopes limits the count of stats scopes, zero means the default limit.
+func SetRouteStats(enabled bool, maxScopes int) {
+	routeStats.Lock()
+	defer routeStats.Unlock()
+	if maxScopes <= 0 {
+		maxScopes = defaultMaxRouteStats
+	}
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&routeStatsEnabled, v)
+	atomic.AddUint64(&routeStatsGeneration, 1)
+	routeStats.maxScopes = maxScopes
+	routeStats.scopes = make(map[string]types.Metrics)
+}
+
+// RouteStatsEnabled returns whether the virtual host and route stats is enabled
+func RouteStatsEnabled() bool {
+	return atomic.LoadInt32(&routeStatsEnabled) == 1
+}
+
+// RouteStatsGeneration returns the generation of the route stats config,
+// it is changed by SetRouteStats, the stats cached by the older generation is outdated.
+func RouteStatsGeneration() uint64 {
+	return atomic.LoadUint64(&routeStatsGeneration)
+}
+
+// NewRouteStats returns a stats with namespace prefix virtual host and route.
+// If the route is empty, the stats is only scoped by virtual host.
+// The stats scoped by RouteOverflowName is returned when the limit is exceeded.
+func NewRouteStats(virtualHost, route string) types.Metrics {
+	routeStats.Lock()
+	defer routeStats.Unlock()
+	scope := virtualHost + "." + route
+	if m, ok := routeStats.scopes[scope]; ok {
+		return m
+	}
+	overflow := len(routeStats.scopes) >= routeStats.maxScopes
+	if overflow {
+		virtualHost, route = RouteOverflowName, RouteOverflowName
+	}
+	labels := map[string]string{"virtual_host": virtualHost}
+	if route != "" {
+		labels["route"] = route
+	}
+	m, _ := NewMetrics(RouteType, labels)
+	// the overflow stats is not counted in the limit
+	if !overflow {
+		routeStats.scopes[scope] = m
+	}
+	return m
+}
@@ -0,0 +1,53 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRouteStatsLimit(t *testing.T) {
+	ResetAll()
+	SetRouteStats(true, 2)
+	defer SetRouteStats(false, 0)
+
+	if !RouteStatsEnabled() {
+		t.Fatal("route stats should be enabled")
+	}
+	s1 := NewRouteStats("vh1", "r1")
+	s2 := NewRouteStats("vh1", "")
+	if s1 == s2 {
+		t.Fatal("route stats should be scoped by route")
+	}
+	if labels := s2.Labels(); len(labels) != 1 || labels["virtual_host"] != "vh1" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+	// the same scope returns the same stats
+	if NewRouteStats("vh1", "r1") != s1 {
+		t.Error("route stats should be reused")
+	}
+	// exceeds the limit
+	for i := 0; i < 3; i++ {
+		s := NewRouteStats("vh2", fmt.Sprintf("r%d", i))
+		labels := s.Labels()
+		if labels["virtual_host"] != RouteOverflowName || labels["route"] != RouteOverflowName {
+			t.Errorf("unexpected labels: %v", labels)
+		}
+	}
+}
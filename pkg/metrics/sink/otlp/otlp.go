@@ -0,0 +1,406 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/metrics/sink"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+var (
+	sinkType             = "otlp"
+	defaultPath          = "/v1/metrics"
+	defaultServiceName   = "mosn"
+	defaultFlushInterval = 10 * time.Second
+	defaultTimeout       = 5 * time.Second
+)
+
+// aggregationTemporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE in the otlp proto
+const aggregationTemporalityCumulative = 2
+
+func init() {
+	sink.RegisterSink(sinkType, builder)
+}
+
+// otlpConfig contains config for otlpSink
+type otlpConfig struct {
+	// Endpoints are the collector addresses, such as http://127.0.0.1:4318,
+	// metrics are pushed to them in turn, and the next one is tried if a push failed.
+	Endpoints          []string           `json:"endpoints"`
+	Path               string             `json:"path,omitempty"`
+	Headers            map[string]string  `json:"headers,omitempty"`
+	FlushInterval      api.DurationConfig `json:"flush_interval,omitempty"`
+	Timeout            api.DurationConfig `json:"timeout,omitempty"`
+	ServiceName        string             `json:"service_name,omitempty"`
+	ResourceAttributes map[string]string  `json:"resource_attributes,omitempty"`
+	Percentiles        []int              `json:"percentiles,omitempty"`
+	percentilesFloat   []float64          // not config, trans with Percentiles
+}
+
+// otlpSink pushes metrics to opentelemetry collectors with otlp/http json encoding
+type otlpSink struct {
+	config    *otlpConfig
+	resource  resource
+	startTime time.Time
+
+	client *http.Client
+	next   int
+	stopCh chan struct{}
+}
+
+// The following types are the json mapping of the otlp metrics proto,
+// 64 bit integers are encoded as strings according to the proto3 json mapping.
+type exportMetricsServiceRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type metric struct {
+	Name    string   `json:"name"`
+	Sum     *sum     `json:"sum,omitempty"`
+	Gauge   *gauge   `json:"gauge,omitempty"`
+	Summary *summary `json:"summary,omitempty"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type summary struct {
+	DataPoints []summaryDataPoint `json:"dataPoints"`
+}
+
+type numberDataPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsInt             string     `json:"asInt"`
+}
+
+type summaryDataPoint struct {
+	Attributes        []keyValue      `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	QuantileValues    []quantileValue `json:"quantileValues,omitempty"`
+}
+
+type quantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// ~ MetricsSink
+func (osink *otlpSink) Flush(writer io.Writer, ms []types.Metrics) {
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	start := strconv.FormatInt(osink.startTime.UnixNano(), 10)
+
+	// metrics with the same name are merged into one metric with multiple data points
+	index := make(map[string]int)
+	var out []metric
+	get := func(name string) *metric {
+		i, ok := index[name]
+		if !ok {
+			i = len(out)
+			index[name] = i
+			out = append(out, metric{Name: name})
+		}
+		return &out[i]
+	}
+
+	for _, m := range ms {
+		labelKeys, labelVals := m.SortedLabels()
+		if sink.IsExclusionLabels(labelKeys) {
+			continue
+		}
+		attrs := makeAttributes(labelKeys, labelVals)
+		prefix := m.Type() + "."
+
+		m.Each(func(name string, i interface{}) {
+			if sink.IsExclusionKeys(name) {
+				return
+			}
+			switch mt := i.(type) {
+			case gometrics.Counter:
+				// mosn counters can be decreased, so they are not monotonic
+				om := get(prefix + name)
+				if om.Sum == nil {
+					om.Sum = &sum{AggregationTemporality: aggregationTemporalityCumulative}
+				}
+				om.Sum.DataPoints = append(om.Sum.DataPoints, numberDataPoint{
+					Attributes:        attrs,
+					StartTimeUnixNano: start,
+					TimeUnixNano:      now,
+					AsInt:             strconv.FormatInt(mt.Count(), 10),
+				})
+			case gometrics.Gauge:
+				om := get(prefix + name)
+				if om.Gauge == nil {
+					om.Gauge = &gauge{}
+				}
+				om.Gauge.DataPoints = append(om.Gauge.DataPoints, numberDataPoint{
+					Attributes:   attrs,
+					TimeUnixNano: now,
+					AsInt:        strconv.FormatInt(mt.Value(), 10),
+				})
+			case gometrics.Histogram:
+				snapshot := mt.Snapshot()
+				dp := summaryDataPoint{
+					Attributes:        attrs,
+					StartTimeUnixNano: start,
+					TimeUnixNano:      now,
+					Count:             strconv.FormatInt(snapshot.Count(), 10),
+					Sum:               float64(snapshot.Sum()),
+				}
+				if len(osink.config.percentilesFloat) > 0 {
+					ps := snapshot.Percentiles(osink.config.percentilesFloat)
+					for idx, q := range osink.config.percentilesFloat {
+						dp.QuantileValues = append(dp.QuantileValues, quantileValue{Quantile: q, Value: ps[idx]})
+					}
+				}
+				om := get(prefix + name)
+				if om.Summary == nil {
+					om.Summary = &summary{}
+				}
+				om.Summary.DataPoints = append(om.Summary.DataPoints, dp)
+			}
+		})
+	}
+
+	req := &exportMetricsServiceRequest{
+		ResourceMetrics: []resourceMetrics{
+			{
+				Resource: osink.resource,
+				ScopeMetrics: []scopeMetrics{
+					{
+						Scope:   scope{Name: "mosn.io/mosn/pkg/metrics"},
+						Metrics: out,
+					},
+				},
+			},
+		},
+	}
+	if err := json.NewEncoder(writer).Encode(req); err != nil {
+		log.DefaultLogger.Errorf("[metrics] [sink] [otlp] encode metrics failed: %v", err)
+	}
+}
+
+func (osink *otlpSink) run() {
+	ticker := time.NewTicker(osink.config.FlushInterval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-osink.stopCh:
+			return
+		case <-ticker.C:
+			osink.push()
+		}
+	}
+}
+
+// push exports all metrics to one of the collectors
+func (osink *otlpSink) push() {
+	buf := &bytes.Buffer{}
+	osink.Flush(buf, metrics.GetAll())
+	data := buf.Bytes()
+
+	endpoints := osink.config.Endpoints
+	for i := 0; i < len(endpoints); i++ {
+		endpoint := endpoints[osink.next]
+		osink.next = (osink.next + 1) % len(endpoints)
+		err := osink.send(endpoint, data)
+		if err == nil {
+			return
+		}
+		log.DefaultLogger.Errorf("[metrics] [sink] [otlp] push metrics to %s failed: %v", endpoint, err)
+	}
+}
+
+func (osink *otlpSink) send(endpoint string, data []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint+osink.config.Path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range osink.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := osink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d, body: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// Stop stops the push goroutine
+func (osink *otlpSink) Stop() {
+	close(osink.stopCh)
+}
+
+// NewOtlpSink returns a metrics sink that pushes metrics to opentelemetry collectors
+func NewOtlpSink(config *otlpConfig) types.MetricsSink {
+	attrs := map[string]string{
+		"service.name": config.ServiceName,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		attrs["host.name"] = hostname
+	}
+	for k, v := range config.ResourceAttributes {
+		attrs[k] = v
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	vals := make([]string, len(keys))
+	for i, k := range keys {
+		vals[i] = attrs[k]
+	}
+
+	osink := &otlpSink{
+		config:    config,
+		resource:  resource{Attributes: makeAttributes(keys, vals)},
+		startTime: time.Now(),
+		client: &http.Client{
+			Timeout: config.Timeout.Duration,
+		},
+		stopCh: make(chan struct{}),
+	}
+	utils.GoWithRecover(osink.run, nil)
+	return osink
+}
+
+// factory
+func builder(cfg map[string]interface{}) (types.MetricsSink, error) {
+	// parse config
+	otlpCfg := &otlpConfig{}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing otlp sink error, err: %v, cfg: %v", err, cfg)
+	}
+	if err := json.Unmarshal(data, otlpCfg); err != nil {
+		return nil, fmt.Errorf("parsing otlp sink error, err: %v, cfg: %v", err, cfg)
+	}
+
+	if len(otlpCfg.Endpoints) == 0 {
+		return nil, errors.New("otlp sink's endpoints is not specified")
+	}
+	for i, endpoint := range otlpCfg.Endpoints {
+		if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+			return nil, fmt.Errorf("invalid endpoint format:%s", endpoint)
+		}
+		otlpCfg.Endpoints[i] = strings.TrimSuffix(endpoint, "/")
+	}
+	if otlpCfg.Path == "" {
+		otlpCfg.Path = defaultPath
+	} else if !strings.HasPrefix(otlpCfg.Path, "/") {
+		return nil, fmt.Errorf("invalid path format:%s", otlpCfg.Path)
+	}
+	if otlpCfg.FlushInterval.Duration <= 0 {
+		otlpCfg.FlushInterval.Duration = defaultFlushInterval
+	}
+	if otlpCfg.Timeout.Duration <= 0 {
+		otlpCfg.Timeout.Duration = defaultTimeout
+	}
+	if otlpCfg.ServiceName == "" {
+		otlpCfg.ServiceName = defaultServiceName
+	}
+
+	if len(otlpCfg.Percentiles) > 0 {
+		percentilesFloat := make([]float64, 0, len(otlpCfg.Percentiles))
+		for _, p := range otlpCfg.Percentiles {
+			// nolint
+			if p > 100 {
+				return nil, fmt.Errorf("percentile {%d} must le 100", p)
+			}
+			percentilesFloat = append(percentilesFloat, float64(p)/100)
+		}
+		otlpCfg.percentilesFloat = percentilesFloat
+	}
+
+	return NewOtlpSink(otlpCfg), nil
+}
+
+func makeAttributes(keys, values []string) []keyValue {
+	if len(keys) == 0 || len(keys) != len(values) {
+		return nil
+	}
+	attrs := make([]keyValue, len(keys))
+	for i := range keys {
+		attrs[i] = keyValue{Key: keys[i], Value: anyValue{StringValue: values[i]}}
+	}
+	return attrs
+}
@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/metrics"
+)
+
+func TestOtlpFlush(t *testing.T) {
+	metrics.ResetAll()
+	s1, _ := metrics.NewMetrics("t1", map[string]string{"lbk1": "lbv1"})
+	s2, _ := metrics.NewMetrics("t1", map[string]string{"lbk1": "lbv2"})
+	s1.Counter("k1").Inc(3)
+	s2.Counter("k1").Inc(4)
+	s1.Gauge("k2").Update(5)
+	s1.Histogram("k3").Update(2)
+	s1.Histogram("k3").Update(4)
+
+	osink, err := builder(map[string]interface{}{
+		"endpoints":   []string{"http://127.0.0.1:4318"},
+		"percentiles": []int{50},
+	})
+	if err != nil {
+		t.Fatalf("create otlp sink failed: %v", err)
+	}
+	defer osink.(*otlpSink).Stop()
+
+	buf := &bytes.Buffer{}
+	osink.Flush(buf, metrics.GetAll())
+	req := &exportMetricsServiceRequest{}
+	if err := json.Unmarshal(buf.Bytes(), req); err != nil {
+		t.Fatalf("unmarshal export request failed: %v", err)
+	}
+	ms := map[string]metric{}
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		ms[m.Name] = m
+	}
+	if m, ok := ms["t1.k1"]; !ok || m.Sum == nil || len(m.Sum.DataPoints) != 2 {
+		t.Errorf("unexpected counter: %+v", m)
+	}
+	if m, ok := ms["t1.k2"]; !ok || m.Gauge == nil || m.Gauge.DataPoints[0].AsInt != "5" {
+		t.Errorf("unexpected gauge: %+v", m)
+	}
+	m, ok := ms["t1.k3"]
+	if !ok || m.Summary == nil {
+		t.Fatalf("unexpected histogram: %+v", m)
+	}
+	dp := m.Summary.DataPoints[0]
+	if dp.Count != "2" || dp.Sum != 6 || len(dp.QuantileValues) != 1 || dp.QuantileValues[0].Value != 3 {
+		t.Errorf("unexpected summary data point: %+v", dp)
+	}
+}
+
+func TestOtlpPush(t *testing.T) {
+	metrics.ResetAll()
+	s, _ := metrics.NewMetrics("t1", nil)
+	s.Counter("k1").Inc(1)
+
+	received := make(chan []byte, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/metrics" || r.Header.Get("X-Token") != "token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- body
+	}))
+	defer srv.Close()
+
+	// the first endpoint is unreachable, the sink should failover to the second one
+	osink, err := builder(map[string]interface{}{
+		"endpoints":      []string{"http://127.0.0.1:1", srv.URL},
+		"headers":        map[string]string{"X-Token": "token"},
+		"flush_interval": "100ms",
+	})
+	if err != nil {
+		t.Fatalf("create otlp sink failed: %v", err)
+	}
+	defer osink.(*otlpSink).Stop()
+
+	select {
+	case body := <-received:
+		if !bytes.Contains(body, []byte(`"name":"t1.k1"`)) {
+			t.Errorf("unexpected body: %s", body)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no metrics received")
+	}
+}
+
+func TestOtlpConfigError(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{},
+		{"endpoints": []string{"127.0.0.1:4318"}},
+		{"endpoints": []string{"http://127.0.0.1:4318"}, "path": "v1/metrics"},
+		{"endpoints": []string{"http://127.0.0.1:4318"}, "percentiles": []int{101}},
+	} {
+		if _, err := builder(cfg); err == nil {
+			t.Errorf("expected error for config: %v", cfg)
+		}
+	}
+}
@@ -29,11 +29,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	gometrics "github.com/rcrowley/go-metrics"
 	"mosn.io/mosn/pkg/admin/store"
 	"mosn.io/mosn/pkg/metrics"
@@ -43,9 +45,10 @@ import (
 )
 
 var (
-	sinkType        = "prometheus"
-	defaultEndpoint = "/metrics"
-	numBufPool      = sync.Pool{
+	sinkType               = "prometheus"
+	defaultEndpoint        = "/metrics"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	numBufPool             = sync.Pool{
 		New: func() interface{} {
 			b := make([]byte, 0, 24)
 			return &b
@@ -65,6 +68,7 @@ type promConfig struct {
 	DisableCollectProcess bool      `json:"disable_collect_process"`
 	DisableCollectGo      bool      `json:"disable_collect_go"`
 	Percentiles           []int     `json:"percentiles,omitempty"`
+	OpenMetrics           bool      `json:"open_metrics,omitempty"` // exemplars are only exported in OpenMetrics format
 	percentilesFloat      []float64 // not config, trans with Percentiles
 }
 
@@ -73,6 +77,7 @@ type promSink struct {
 	config *promConfig
 
 	registry prometheus.Registerer //Prometheus registry
+	gatherer prometheus.Gatherer
 }
 
 type promHttpExporter struct {
@@ -81,6 +86,11 @@ type promHttpExporter struct {
 }
 
 func (exporter *promHttpExporter) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
+	if exporter.sink.config.OpenMetrics && strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text") {
+		exporter.serveOpenMetrics(rsp)
+		return
+	}
+
 	// 1. export process and go metrics
 	exporter.real.ServeHTTP(rsp, req)
 
@@ -88,8 +98,33 @@ func (exporter *promHttpExporter) ServeHTTP(rsp http.ResponseWriter, req *http.R
 	exporter.sink.Flush(rsp, metrics.GetAll())
 }
 
+func (exporter *promHttpExporter) serveOpenMetrics(rsp http.ResponseWriter) {
+	rsp.Header().Set("Content-Type", openMetricsContentType)
+
+	// 1. export process and go metrics
+	mfs, err := exporter.sink.gatherer.Gather()
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, mf := range mfs {
+		if _, err := expfmt.MetricFamilyToOpenMetrics(rsp, mf); err != nil {
+			return
+		}
+	}
+
+	// 2. mosn metrics
+	exporter.sink.flush(rsp, metrics.GetAll(), true)
+
+	io.WriteString(rsp, "# EOF\n")
+}
+
 // ~ MetricsSink
 func (psink *promSink) Flush(writer io.Writer, ms []types.Metrics) {
+	psink.flush(writer, ms, false)
+}
+
+func (psink *promSink) flush(writer io.Writer, ms []types.Metrics, openMetrics bool) {
 	w := writer
 
 	// mark whose TYPE/HELP text already printed
@@ -113,9 +148,15 @@ func (psink *promSink) Flush(writer io.Writer, ms []types.Metrics) {
 			}
 			switch metric := i.(type) {
 			case gometrics.Counter:
-				psink.flushCounter(tracker, buf, flattenKey(prefix+name), suffix, float64(metric.Count()))
+				if openMetrics {
+					psink.flushOpenMetricsCounter(tracker, buf, flattenKey(prefix+name), suffix, float64(metric.Count()))
+				} else {
+					psink.flushCounter(tracker, buf, flattenKey(prefix+name), suffix, float64(metric.Count()))
+				}
 			case gometrics.Gauge:
 				psink.flushGauge(tracker, buf, flattenKey(prefix+name), suffix, float64(metric.Value()))
+			case metrics.BucketHistogram:
+				psink.flushBucketHistogram(tracker, buf, flattenKey(prefix+name), suffix, metric, openMetrics)
 			case gometrics.Histogram:
 				psink.flushHistogram(tracker, buf, flattenKey(prefix+name), suffix, metric.Snapshot())
 			}
@@ -142,6 +183,74 @@ func (psink *promSink) flushHistogram(tracker map[string]bool, buf types.IoBuffe
 	}
 }
 
+// flushBucketHistogram flushes a histogram with explicit buckets as prometheus histogram.
+// the min and max are flushed as gauges, but percentiles are not, they are conflicted with the histogram name.
+func (psink *promSink) flushBucketHistogram(tracker map[string]bool, buf types.IoBuffer, name string, labels string, h metrics.BucketHistogram, openMetrics bool) {
+	snapshot := h.Snapshot()
+	psink.flushGauge(tracker, buf, name+"_min", labels, float64(snapshot.Min()))
+	psink.flushGauge(tracker, buf, name+"_max", labels, float64(snapshot.Max()))
+
+	// type
+	if !tracker[name] {
+		buf.WriteString("# TYPE ")
+		buf.WriteString(name)
+		buf.WriteString(" histogram\n")
+		tracker[name] = true
+	}
+
+	bucketLabels := "le="
+	if labels != "" {
+		bucketLabels = labels + ",le="
+	}
+	bounds := h.Buckets()
+	counts := h.BucketCounts()
+	var exemplars []*metrics.Exemplar
+	if openMetrics {
+		exemplars = h.Exemplars()
+	}
+	for i, count := range counts {
+		buf.WriteString(name)
+		buf.WriteString("_bucket{")
+		buf.WriteString(bucketLabels)
+		buf.WriteString("\"")
+		if i < len(bounds) {
+			writeFloat(buf, bounds[i])
+		} else {
+			buf.WriteString("+Inf")
+		}
+		buf.WriteString("\"} ")
+		writeFloat(buf, float64(count))
+		if exemplars != nil && exemplars[i] != nil {
+			writeExemplar(buf, exemplars[i])
+		}
+		buf.WriteString("\n")
+	}
+
+	// sum and count
+	buf.WriteString(name)
+	buf.WriteString("_sum{")
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	writeFloat(buf, float64(h.Total()))
+	buf.WriteString("\n")
+	buf.WriteString(name)
+	buf.WriteString("_count{")
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	writeFloat(buf, float64(counts[len(counts)-1]))
+	buf.WriteString("\n")
+}
+
+// writeExemplar writes an OpenMetrics exemplar, such as: # {trace_id="abc"} 0.67 1520879607.789
+func writeExemplar(buf types.IoBuffer, e *metrics.Exemplar) {
+	buf.WriteString(" # {trace_id=\"")
+	buf.WriteString(e.TraceID)
+	buf.WriteString("\"} ")
+	writeFloat(buf, float64(e.Value))
+	buf.WriteString(" ")
+	writeFloat(buf, float64(e.Timestamp.UnixNano())/float64(time.Second))
+}
+
 func (psink *promSink) flushGauge(tracker map[string]bool, buf types.IoBuffer, name string, labels string, val float64) {
 	// type
 	if !tracker[name] {
@@ -177,6 +286,31 @@ func (psink *promSink) flushCounter(tracker map[string]bool, buf types.IoBuffer,
 	buf.WriteString("\n")
 }
 
+// flushOpenMetricsCounter flushes a counter in OpenMetrics format, the counter
+// sample name must have the _total suffix, others are flushed as unknown type.
+func (psink *promSink) flushOpenMetricsCounter(tracker map[string]bool, buf types.IoBuffer, name string, labels string, val float64) {
+	// type
+	if !tracker[name] {
+		buf.WriteString("# TYPE ")
+		if family := strings.TrimSuffix(name, "_total"); family != name {
+			buf.WriteString(family)
+			buf.WriteString(" counter\n")
+		} else {
+			buf.WriteString(name)
+			buf.WriteString(" unknown\n")
+		}
+		tracker[name] = true
+	}
+
+	// metric
+	buf.WriteString(name)
+	buf.WriteString("{")
+	buf.WriteString(labels)
+	buf.WriteString("} ")
+	writeFloat(buf, val)
+	buf.WriteString("\n")
+}
+
 // NewPromeSink returns a metrics sink that produces Prometheus metrics using store data
 func NewPromeSink(config *promConfig) types.MetricsSink {
 	promReg := prometheus.NewRegistry()
@@ -191,6 +325,7 @@ func NewPromeSink(config *promConfig) types.MetricsSink {
 	promSink := &promSink{
 		config:   config,
 		registry: promReg,
+		gatherer: promReg,
 	}
 
 	// export http for prometheus
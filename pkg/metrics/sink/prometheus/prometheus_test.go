@@ -326,6 +326,91 @@ func TestPrometheusMetricsFilter(t *testing.T) {
 	}
 }
 
+func TestPrometheusBucketHistogram(t *testing.T) {
+	metrics.ResetAll()
+	sink.SetFilterLabels(nil)
+	sink.SetFilterKeys(nil)
+	metrics.SetHistogramBuckets([]time.Duration{time.Millisecond, 10 * time.Millisecond})
+	defer metrics.SetHistogramBuckets(nil)
+
+	s, _ := metrics.NewMetrics(metrics.UpstreamType, map[string]string{"cluster": "c1"})
+	h := s.Histogram(metrics.UpstreamRequestDuration).(metrics.BucketHistogram)
+	h.Update(int64(500 * time.Microsecond))
+	h.UpdateWithExemplar(int64(5*time.Millisecond), "trace1")
+	h.Update(int64(time.Second))
+
+	psink := NewPromeSink(&promConfig{
+		Port:        8088,
+		Endpoint:    "/metrics",
+		OpenMetrics: true,
+	}).(*promSink)
+
+	for _, tc := range []struct {
+		openMetrics bool
+		expect      []string
+		unexpect    []string
+	}{
+		{
+			openMetrics: false,
+			expect: []string{
+				"# TYPE upstream_request_duration_time histogram\n",
+				`upstream_request_duration_time_bucket{cluster="c1",le="1e+06"} 1.0`,
+				`upstream_request_duration_time_bucket{cluster="c1",le="1e+07"} 2.0`,
+				`upstream_request_duration_time_bucket{cluster="c1",le="+Inf"} 3.0`,
+				`upstream_request_duration_time_sum{cluster="c1"} 1.0055e+09`,
+				`upstream_request_duration_time_count{cluster="c1"} 3.0`,
+				`upstream_request_duration_time_max{cluster="c1"} 1e+09`,
+			},
+			unexpect: []string{"trace_id"},
+		},
+		{
+			openMetrics: true,
+			expect: []string{
+				`upstream_request_duration_time_bucket{cluster="c1",le="1e+07"} 2.0 # {trace_id="trace1"} 5e+06 `,
+			},
+		},
+	} {
+		buf := &bytes.Buffer{}
+		psink.flush(buf, metrics.GetAll(), tc.openMetrics)
+		for _, e := range tc.expect {
+			if !strings.Contains(buf.String(), e) {
+				t.Errorf("expected %q in output: %s", e, buf.String())
+			}
+		}
+		for _, e := range tc.unexpect {
+			if strings.Contains(buf.String(), e) {
+				t.Errorf("unexpected %q in output: %s", e, buf.String())
+			}
+		}
+	}
+}
+
+func TestPrometheusOpenMetricsCounter(t *testing.T) {
+	metrics.ResetAll()
+	sink.SetFilterLabels(nil)
+	sink.SetFilterKeys(nil)
+	s, _ := metrics.NewMetrics("t1", map[string]string{"lbk1": "lbv1"})
+	s.Counter("request_total").Inc(1)
+	s.Counter("request_active").Inc(1)
+
+	psink := NewPromeSink(&promConfig{
+		Port:        8088,
+		Endpoint:    "/metrics",
+		OpenMetrics: true,
+	}).(*promSink)
+	buf := &bytes.Buffer{}
+	psink.flush(buf, metrics.GetAll(), true)
+	for _, e := range []string{
+		"# TYPE t1_request counter\n",
+		`t1_request_total{lbk1="lbv1"} 1.0`,
+		"# TYPE t1_request_active unknown\n",
+	} {
+		if !strings.Contains(buf.String(), e) {
+			t.Errorf("expected %q in output: %s", e, buf.String())
+		}
+	}
+}
+
 func TestPrometheusFlatternKey(t *testing.T) {
 	testcase := []struct {
 		input  string
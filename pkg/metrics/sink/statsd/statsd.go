@@ -0,0 +1,300 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package statsd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/metrics/sink"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+var (
+	sinkType             = "statsd"
+	defaultFlushInterval = 10 * time.Second
+	defaultMaxPacketSize = 1432 // fits in a single ethernet frame with ip/udp headers
+)
+
+func init() {
+	sink.RegisterSink(sinkType, builder)
+}
+
+// statsdConfig contains config for statsdSink
+type statsdConfig struct {
+	Address       string             `json:"address"`
+	Prefix        string             `json:"prefix,omitempty"`
+	FlushInterval api.DurationConfig `json:"flush_interval,omitempty"`
+	// DogStatsD enables the datadog tag extension, labels are sent as tags
+	// instead of being flattened into the metric name.
+	DogStatsD        bool              `json:"dogstatsd,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"` // global tags, only used in dogstatsd mode
+	Percentiles      []int             `json:"percentiles,omitempty"`
+	MaxPacketSize    int               `json:"max_packet_size,omitempty"`
+	percentilesFloat []float64         // not config, trans with Percentiles
+}
+
+// statsdSink pushes metrics to a statsd server over udp with specified interval
+type statsdSink struct {
+	config     *statsdConfig
+	globalTags string
+
+	mutex sync.Mutex
+	// statsd counters are deltas, keeps the value reported by last flush
+	lastCounters map[string]int64
+
+	conn   net.Conn
+	stopCh chan struct{}
+}
+
+// ~ MetricsSink
+func (ssink *statsdSink) Flush(writer io.Writer, ms []types.Metrics) {
+	ssink.mutex.Lock()
+	defer ssink.mutex.Unlock()
+
+	buf := &bytes.Buffer{}
+	for _, m := range ms {
+		labelKeys, labelVals := m.SortedLabels()
+		if sink.IsExclusionLabels(labelKeys) {
+			continue
+		}
+		prefix, tags := ssink.makeNameAndTags(m.Type(), labelKeys, labelVals)
+
+		m.Each(func(name string, i interface{}) {
+			if sink.IsExclusionKeys(name) {
+				return
+			}
+			key := prefix + sanitize(name)
+			switch metric := i.(type) {
+			case gometrics.Counter:
+				ssink.flushCounter(buf, key, tags, metric.Count())
+			case gometrics.Gauge:
+				writeLine(buf, key, strconv.FormatInt(metric.Value(), 10), "g", tags)
+			case gometrics.Histogram:
+				ssink.flushHistogram(buf, key, tags, metric.Snapshot())
+			}
+			buf.WriteTo(writer)
+			buf.Reset()
+		})
+	}
+}
+
+func (ssink *statsdSink) flushCounter(buf *bytes.Buffer, key, tags string, count int64) {
+	id := key + "|" + tags
+	delta := count - ssink.lastCounters[id]
+	ssink.lastCounters[id] = count
+	writeLine(buf, key, strconv.FormatInt(delta, 10), "c", tags)
+}
+
+func (ssink *statsdSink) flushHistogram(buf *bytes.Buffer, key, tags string, snapshot gometrics.Histogram) {
+	writeLine(buf, key+".min", strconv.FormatInt(snapshot.Min(), 10), "g", tags)
+	writeLine(buf, key+".max", strconv.FormatInt(snapshot.Max(), 10), "g", tags)
+	if len(ssink.config.Percentiles) == 0 {
+		return
+	}
+	ps := snapshot.Percentiles(ssink.config.percentilesFloat)
+	if len(ps) == len(ssink.config.Percentiles) {
+		for i, p := range ssink.config.Percentiles {
+			writeLine(buf, key+".p"+strconv.Itoa(p), strconv.FormatFloat(ps[i], 'f', -1, 64), "g", tags)
+		}
+	}
+}
+
+// makeNameAndTags returns the metric name prefix and the dogstatsd tags of a metrics.
+// in plain statsd mode the labels are flattened into the name, such as
+// prefix.upstream.cluster.app1.
+func (ssink *statsdSink) makeNameAndTags(typ string, keys, vals []string) (string, string) {
+	var name strings.Builder
+	if ssink.config.Prefix != "" {
+		name.WriteString(ssink.config.Prefix)
+		name.WriteByte('.')
+	}
+	name.WriteString(sanitize(typ))
+	name.WriteByte('.')
+	if !ssink.config.DogStatsD {
+		for i := range keys {
+			name.WriteString(sanitize(keys[i]))
+			name.WriteByte('.')
+			name.WriteString(sanitize(vals[i]))
+			name.WriteByte('.')
+		}
+		return name.String(), ""
+	}
+	tags := make([]string, 0, len(keys)+1)
+	if ssink.globalTags != "" {
+		tags = append(tags, ssink.globalTags)
+	}
+	for i := range keys {
+		tags = append(tags, sanitizeTag(keys[i])+":"+sanitizeTag(vals[i]))
+	}
+	return name.String(), strings.Join(tags, ",")
+}
+
+func (ssink *statsdSink) run() {
+	ticker := time.NewTicker(ssink.config.FlushInterval.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ssink.stopCh:
+			return
+		case <-ticker.C:
+			ssink.push()
+		}
+	}
+}
+
+// push flushes all metrics and sends them as udp packets, a packet never
+// exceeds MaxPacketSize unless a single line does.
+func (ssink *statsdSink) push() {
+	buf := &bytes.Buffer{}
+	ssink.Flush(buf, metrics.GetAll())
+
+	packet := make([]byte, 0, ssink.config.MaxPacketSize)
+	for _, line := range bytes.SplitAfter(buf.Bytes(), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if len(packet) > 0 && len(packet)+len(line) > ssink.config.MaxPacketSize {
+			ssink.send(packet)
+			packet = packet[:0]
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		ssink.send(packet)
+	}
+}
+
+func (ssink *statsdSink) send(packet []byte) {
+	if _, err := ssink.conn.Write(packet); err != nil {
+		log.DefaultLogger.Errorf("[metrics] [sink] [statsd] write packet to %s failed: %v", ssink.config.Address, err)
+	}
+}
+
+// Stop stops the push goroutine and closes the connection
+func (ssink *statsdSink) Stop() {
+	close(ssink.stopCh)
+	ssink.conn.Close()
+}
+
+// NewStatsdSink returns a metrics sink that pushes metrics to a statsd server
+func NewStatsdSink(config *statsdConfig) (types.MetricsSink, error) {
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+	ssink := &statsdSink{
+		config:       config,
+		globalTags:   makeGlobalTags(config.Tags),
+		lastCounters: make(map[string]int64),
+		conn:         conn,
+		stopCh:       make(chan struct{}),
+	}
+	utils.GoWithRecover(ssink.run, nil)
+	return ssink, nil
+}
+
+// factory
+func builder(cfg map[string]interface{}) (types.MetricsSink, error) {
+	// parse config
+	statsdCfg := &statsdConfig{}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing statsd sink error, err: %v, cfg: %v", err, cfg)
+	}
+	if err := json.Unmarshal(data, statsdCfg); err != nil {
+		return nil, fmt.Errorf("parsing statsd sink error, err: %v, cfg: %v", err, cfg)
+	}
+
+	if statsdCfg.Address == "" {
+		return nil, errors.New("statsd sink's address is not specified")
+	}
+	if statsdCfg.FlushInterval.Duration <= 0 {
+		statsdCfg.FlushInterval.Duration = defaultFlushInterval
+	}
+	if statsdCfg.MaxPacketSize <= 0 {
+		statsdCfg.MaxPacketSize = defaultMaxPacketSize
+	}
+
+	if len(statsdCfg.Percentiles) > 0 {
+		percentilesFloat := make([]float64, 0, len(statsdCfg.Percentiles))
+		for _, p := range statsdCfg.Percentiles {
+			// nolint
+			if p > 100 {
+				return nil, fmt.Errorf("percentile {%d} must le 100", p)
+			}
+			percentilesFloat = append(percentilesFloat, float64(p)/100)
+		}
+		statsdCfg.percentilesFloat = percentilesFloat
+	}
+
+	return NewStatsdSink(statsdCfg)
+}
+
+// writeLine writes a line in statsd format: name:value|type|#tags
+func writeLine(buf *bytes.Buffer, name, value, typ, tags string) {
+	buf.WriteString(name)
+	buf.WriteByte(':')
+	buf.WriteString(value)
+	buf.WriteByte('|')
+	buf.WriteString(typ)
+	if tags != "" {
+		buf.WriteString("|#")
+		buf.WriteString(tags)
+	}
+	buf.WriteByte('\n')
+}
+
+// makeGlobalTags returns the global tags in stable order
+func makeGlobalTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, sanitizeTag(k)+":"+sanitizeTag(v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ':', '|', '@' and '#' are reserved in statsd protocol, '.' is the name separator.
+var nameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ".", "_", " ", "_", "\n", "_")
+
+func sanitize(s string) string {
+	return nameReplacer.Replace(s)
+}
+
+// tags can contain '.', but ',' separates the tags
+var tagReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", " ", "_", "\n", "_")
+
+func sanitizeTag(s string) string {
+	return tagReplacer.Replace(s)
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package statsd
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/metrics"
+)
+
+func TestStatsdFlush(t *testing.T) {
+	metrics.ResetAll()
+	s, _ := metrics.NewMetrics("t1", map[string]string{"lbk1": "lbv1"})
+	s.Counter("k1").Inc(3)
+	s.Gauge("k2").Update(5)
+	s.Histogram("k3").Update(2)
+	s.Histogram("k3").Update(4)
+
+	for _, tc := range []struct {
+		cfg    map[string]interface{}
+		expect []string
+	}{
+		{
+			cfg: map[string]interface{}{
+				"address":     "127.0.0.1:8125",
+				"prefix":      "mosn",
+				"percentiles": []int{50},
+			},
+			expect: []string{
+				"mosn.t1.lbk1.lbv1.k1:3|c\n",
+				"mosn.t1.lbk1.lbv1.k2:5|g\n",
+				"mosn.t1.lbk1.lbv1.k3.min:2|g\n",
+				"mosn.t1.lbk1.lbv1.k3.max:4|g\n",
+				"mosn.t1.lbk1.lbv1.k3.p50:3|g\n",
+			},
+		},
+		{
+			cfg: map[string]interface{}{
+				"address":   "127.0.0.1:8125",
+				"dogstatsd": true,
+				"tags":      map[string]string{"app": "demo"},
+			},
+			expect: []string{
+				"t1.k1:3|c|#app:demo,lbk1:lbv1\n",
+				"t1.k2:5|g|#app:demo,lbk1:lbv1\n",
+				"t1.k3.max:4|g|#app:demo,lbk1:lbv1\n",
+			},
+		},
+	} {
+		ssink, err := builder(tc.cfg)
+		if err != nil {
+			t.Fatalf("create statsd sink failed: %v", err)
+		}
+		buf := &bytes.Buffer{}
+		ssink.Flush(buf, metrics.GetAll())
+		for _, line := range tc.expect {
+			if !strings.Contains(buf.String(), line) {
+				t.Errorf("expected line %q in %q", line, buf.String())
+			}
+		}
+		ssink.(*statsdSink).Stop()
+	}
+}
+
+func TestStatsdCounterDelta(t *testing.T) {
+	metrics.ResetAll()
+	s, _ := metrics.NewMetrics("t1", nil)
+	ssink, err := builder(map[string]interface{}{
+		"address": "127.0.0.1:8125",
+	})
+	if err != nil {
+		t.Fatalf("create statsd sink failed: %v", err)
+	}
+	defer ssink.(*statsdSink).Stop()
+
+	s.Counter("k1").Inc(3)
+	buf := &bytes.Buffer{}
+	ssink.Flush(buf, metrics.GetAll())
+	if !strings.Contains(buf.String(), "t1.k1:3|c") {
+		t.Errorf("unexpected first flush: %s", buf.String())
+	}
+	s.Counter("k1").Inc(2)
+	buf.Reset()
+	ssink.Flush(buf, metrics.GetAll())
+	if !strings.Contains(buf.String(), "t1.k1:2|c") {
+		t.Errorf("unexpected second flush: %s", buf.String())
+	}
+}
+
+func TestStatsdPush(t *testing.T) {
+	metrics.ResetAll()
+	s, _ := metrics.NewMetrics("t1", nil)
+	s.Counter("k1").Inc(1)
+	s.Counter("k2").Inc(1)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp failed: %v", err)
+	}
+	defer conn.Close()
+
+	ssink, err := builder(map[string]interface{}{
+		"address":         conn.LocalAddr().String(),
+		"flush_interval":  "100ms",
+		"max_packet_size": 10,
+	})
+	if err != nil {
+		t.Fatalf("create statsd sink failed: %v", err)
+	}
+	defer ssink.(*statsdSink).Stop()
+
+	// max packet size is smaller than a line, each line is sent in its own packet
+	received := map[string]bool{}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	b := make([]byte, 1024)
+	for len(received) < 2 {
+		n, _, err := conn.ReadFrom(b)
+		if err != nil {
+			t.Fatalf("read packet failed: %v", err)
+		}
+		received[string(b[:n])] = true
+	}
+	if !received["t1.k1:1|c\n"] || !received["t1.k2:1|c\n"] {
+		t.Errorf("unexpected packets: %v", received)
+	}
+}
+
+func TestStatsdConfigError(t *testing.T) {
+	if _, err := builder(map[string]interface{}{}); err == nil {
+		t.Error("expected error for empty address")
+	}
+	if _, err := builder(map[string]interface{}{
+		"address":     "127.0.0.1:8125",
+		"percentiles": []int{101},
+	}); err == nil {
+		t.Error("expected error for invalid percentile")
+	}
+}
@@ -185,6 +185,11 @@ func (s *metrics) Histogram(key string) gometrics.Histogram {
 		return gometrics.NilHistogram{}
 	}
 
+	// histograms with explicit buckets are not lazy, the sinks need to know its type
+	if buckets := getHistogramBuckets(s.typ, key); len(buckets) > 0 {
+		return s.registry.GetOrRegister(key, func() gometrics.Histogram { return NewBucketHistogram(buckets) }).(gometrics.Histogram)
+	}
+
 	construct := func() gometrics.Histogram {
 		// TODO: notice the histogram only keeps 100 values as we set
 		return s.registry.GetOrRegister(key, func() gometrics.Histogram { return gometrics.NewHistogram(gometrics.NewUniformSample(100)) }).(gometrics.Histogram)
@@ -35,8 +35,26 @@ var (
 	ErrDepStreamID = errDepStreamID
 	//todo: support configuration
 	initialConnRecvWindowSize = int32(1 << 30)
+
+	errExtendedConnectNotSupported = errors.New("http2: extended CONNECT is not supported by the server")
 )
 
+// ProtocolPseudoHeader is the :protocol pseudo-header of the extended CONNECT (RFC 8441).
+// It is kept in the request header, the server sets it for the received requests,
+// and the client sends it with the :scheme and :path if the request method is CONNECT.
+const ProtocolPseudoHeader = ":protocol"
+
+// ExtendedConnectProtocol returns the :protocol of the extended CONNECT request, or empty
+func ExtendedConnectProtocol(req *http.Request) string {
+	if req.Method != "CONNECT" {
+		return ""
+	}
+	if v := req.Header[ProtocolPseudoHeader]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
 // Mstream is Http2 Server stream
 type MStream struct {
 	*stream
@@ -205,6 +223,40 @@ func (ms *MStream) WriteTrailers() error {
 	return err
 }
 
+// WriteTunnelHeader writes the response headers of an accepted CONNECT request,
+// the stream is kept open for the tunnel data, so the content length is not sent.
+func (ms *MStream) WriteTunnelHeader() error {
+	rsp := ms.Response
+	rsp.Header.Del("Content-Length")
+	ws := &writeResHeaders{
+		streamID:    ms.id,
+		httpResCode: rsp.StatusCode,
+		h:           rsp.Header,
+	}
+	return ms.conn.writeHeaders(ws)
+}
+
+// WriteTunnelData writes the tunnel data in the data frames, it blocks until the flow control allows
+func (ms *MStream) WriteTunnelData(data []byte) error {
+	for len(data) > 0 {
+		allowed, err := ms.awaitFlowControl(len(data))
+		if err != nil {
+			return err
+		}
+		if err := ms.conn.Framer.writeData(ms.id, false, data[:allowed]); err != nil {
+			return err
+		}
+		data = data[allowed:]
+	}
+	return nil
+}
+
+// EndTunnel ends the tunnel stream by an empty data frame with the END_STREAM flag
+func (ms *MStream) EndTunnel() error {
+	defer ms.conn.closeStream(ms.stream, nil)
+	return ms.conn.Framer.writeData(ms.id, true, nil)
+}
+
 func (ms *MStream) Reset() {
 	ev := streamError(ms.id, ErrCodeInternal)
 	ms.conn.resetStream(ev)
@@ -230,6 +282,10 @@ type MServerConn struct {
 
 	Framer *MFramer
 	api.Connection
+
+	// EnableConnectProtocol advertises SETTINGS_ENABLE_CONNECT_PROTOCOL,
+	// the extended CONNECT requests (RFC 8441) are accepted.
+	EnableConnectProtocol bool
 }
 
 // NewserverConn returns a Http2 Server Connection
@@ -272,6 +328,9 @@ func (sc *MServerConn) Init() error {
 		{SettingMaxHeaderListSize, http.DefaultMaxHeaderBytes},
 		{SettingInitialWindowSize, uint32(initialConnRecvWindowSize)},
 	}
+	if sc.EnableConnectProtocol {
+		settings = append(settings, Setting{SettingEnableConnectProtocol, 1})
+	}
 
 	err := sc.Framer.writeSettings(settings)
 	if err != nil {
@@ -579,9 +638,16 @@ func (sc *MServerConn) processRequest(st *stream, f *MetaHeadersFrame) (*http.Re
 		authority: f.PseudoValue("authority"),
 		path:      f.PseudoValue("path"),
 	}
+	protocol := f.PseudoValue("protocol")
 
 	isConnect := rp.method == "CONNECT"
-	if isConnect {
+	if protocol != "" {
+		// RFC 8441 4. The extended CONNECT carries the :scheme and :path like the other requests
+		if !isConnect || !sc.EnableConnectProtocol || rp.path == "" || rp.authority == "" ||
+			(rp.scheme != "https" && rp.scheme != "http") {
+			return nil, streamError(f.StreamID, ErrCodeProtocol)
+		}
+	} else if isConnect {
 		if rp.path != "" || rp.scheme != "" || rp.authority == "" {
 			return nil, streamError(f.StreamID, ErrCodeProtocol)
 		}
@@ -641,9 +707,14 @@ func (sc *MServerConn) processRequest(st *stream, f *MetaHeadersFrame) (*http.Re
 	}
 	delete(rp.header, "Trailer")
 
+	// the :protocol pseudo-header is kept in the header like golang does, see ProtocolPseudoHeader
+	if protocol != "" {
+		rp.header[ProtocolPseudoHeader] = []string{protocol}
+	}
+
 	var url_ *url.URL
 	var requestURI string
-	if rp.method == "CONNECT" {
+	if rp.method == "CONNECT" && protocol == "" {
 		url_ = &url.URL{Host: rp.authority}
 		requestURI = rp.authority // mimic HTTP/1 server behavior
 	} else {
@@ -996,6 +1067,11 @@ type MClientConn struct {
 	api.Connection
 
 	onceInitFrame sync.Once
+
+	// seenSettings is set after the first settings of the server is received,
+	// peerEnableConnectProtocol is set if the server accepts the extended CONNECT.
+	seenSettings              bool
+	peerEnableConnectProtocol bool
 }
 
 // NewClientConn return Http2 Client conncetion
@@ -1062,6 +1138,11 @@ func (cc *MClientConn) WriteHeaders(ctx context.Context, req *http.Request, trai
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
 
+	// the extended CONNECT is sent optimistically before the server settings are received
+	if ExtendedConnectProtocol(req) != "" && cc.seenSettings && !cc.peerEnableConnectProtocol {
+		return nil, errExtendedConnectNotSupported
+	}
+
 	cs := cc.newStream()
 	cs.req = req
 	cc.hmu.Lock()
@@ -1088,6 +1169,8 @@ type MClientStream struct {
 	SendData   buffer.IoBuffer
 	Trailer    *http.Header
 	UseStream  bool
+	// Tunnel keeps the request stream open after the headers, the data is written by WriteTunnelData
+	Tunnel     bool
 	sendHeader bool
 }
 
@@ -1112,7 +1195,7 @@ func (cc *MClientStream) RoundTrip(ctx context.Context) (err error) {
 			cc.Request.ContentLength, _ = strconv.ParseInt(cl[0], 10, 64)
 		}
 
-		endStream := cc.SendData == nil && cc.Trailer == nil
+		endStream := cc.SendData == nil && cc.Trailer == nil && !cc.Tunnel
 
 		//if WriteHeader err
 		cs, err := cc.conn.WriteHeaders(ctx, cc.Request, "", endStream)
@@ -1245,6 +1328,31 @@ func (cs *MClientStream) awaitFlowControl(maxBytes int) (taken int32, err error)
 	}
 }
 
+// WriteTunnelData writes the tunnel data in the data frames, it blocks until the flow control allows
+func (cs *MClientStream) WriteTunnelData(data []byte) error {
+	for len(data) > 0 {
+		allowed, err := cs.awaitFlowControl(len(data))
+		if err != nil {
+			return err
+		}
+		if err := cs.conn.Framer.writeData(cs.ID, false, data[:allowed]); err != nil {
+			return err
+		}
+		data = data[allowed:]
+	}
+	return nil
+}
+
+// EndTunnel ends the tunnel stream by an empty data frame with the END_STREAM flag,
+// the data received later is dropped.
+func (cs *MClientStream) EndTunnel() error {
+	if cs.clientStream == nil {
+		return nil
+	}
+	defer cs.conn.streamByID(cs.ID, true)
+	return cs.conn.Framer.writeData(cs.ID, true, nil)
+}
+
 func (ms *MClientStream) Reset() {
 	if ms.clientStream == nil {
 		return
@@ -1588,8 +1696,14 @@ func (cc *MClientConn) processSettings(f *SettingsFrame) error {
 		return ConnectionError(ErrCodeProtocol)
 	}
 
+	cc.seenSettings = true
 	err := f.ForeachSetting(func(s Setting) error {
 		switch s.ID {
+		case SettingEnableConnectProtocol:
+			if err := s.Valid(); err != nil {
+				return err
+			}
+			cc.peerEnableConnectProtocol = s.Val == 1
 		case SettingMaxFrameSize:
 			cc.maxFrameSize = s.Val
 		case SettingMaxConcurrentStreams:
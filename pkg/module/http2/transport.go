@@ -1376,8 +1376,10 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 		return nil, err
 	}
 
+	// the extended CONNECT carries the :scheme and :path, see RFC 8441
+	protocol := ExtendedConnectProtocol(req)
 	var path string
-	if req.Method != "CONNECT" {
+	if req.Method != "CONNECT" || protocol != "" {
 		path = req.URL.RequestURI()
 		if !validPseudoPath(path) {
 			orig := path
@@ -1396,6 +1398,9 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 	// potentially pollute our hpack state. (We want to be able to
 	// continue to reuse the hpack encoder for future requests)
 	for k, vv := range req.Header {
+		if k == ProtocolPseudoHeader {
+			continue
+		}
 		if !httpguts.ValidHeaderFieldName(k) {
 			return nil, fmt.Errorf("invalid HTTP header name %q", k)
 		}
@@ -1414,19 +1419,23 @@ func (cc *ClientConn) encodeHeaders(req *http.Request, addGzipHeader bool, trail
 		// [RFC3986]).
 		f(":authority", host)
 		f(":method", req.Method)
-		if req.Method != "CONNECT" {
+		if req.Method != "CONNECT" || protocol != "" {
 			f(":path", path)
 			f(":scheme", req.URL.Scheme)
 		}
+		if protocol != "" {
+			f(":protocol", protocol)
+		}
 		if trailers != "" {
 			f("trailer", trailers)
 		}
 
 		var didUA bool
 		for k, vv := range req.Header {
-			if strings.EqualFold(k, "host") || strings.EqualFold(k, "content-length") {
+			if strings.EqualFold(k, "host") || strings.EqualFold(k, "content-length") || k == ProtocolPseudoHeader {
 				// Host is :authority, already sent.
 				// Content-Length is automatic, set below.
+				// :protocol is a pseudo-header, already sent.
 				continue
 			} else if strings.EqualFold(k, "connection") || strings.EqualFold(k, "proxy-connection") ||
 				strings.EqualFold(k, "transfer-encoding") || strings.EqualFold(k, "upgrade") ||
@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"os"
 	goplugin "plugin"
+	"time"
 
 	"mosn.io/api"
 	"mosn.io/mosn/pkg/admin/store"
@@ -87,6 +88,14 @@ func InitializeMetrics(m *Mosn) {
 	statsMatcher := config.StatsMatcher
 	metrics.SetStatsMatcher(statsMatcher.RejectAll, statsMatcher.ExclusionLabels, statsMatcher.ExclusionKeys)
 	metrics.SetMetricsFeature(config.FlushMosn, config.LazyFlush)
+	if len(config.HistogramBuckets) > 0 {
+		buckets := make([]time.Duration, 0, len(config.HistogramBuckets))
+		for _, b := range config.HistogramBuckets {
+			buckets = append(buckets, b.Duration)
+		}
+		metrics.SetHistogramBuckets(buckets)
+	}
+	metrics.SetRouteStats(config.RouteStats.Enabled, config.RouteStats.MaxScopes)
 	// create sinks
 	for _, cfg := range config.SinkConfigs {
 		_, err := sink.CreateMetricsSink(cfg.Type, cfg.Config)
@@ -0,0 +1,437 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mosn
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/configmanager"
+	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/streamfilter"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/upstream/cluster"
+)
+
+// ConfigError is an invalid config found by the validation
+type ConfigError struct {
+	// Path is the json path of the invalid config, such as $.servers[0].listeners[1]
+	Path string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// ValidateConfig parses the config file like the mosn starts, and instantiates the
+// listeners, filter factories, routers, clusters, load balancers and tls contexts
+// without binding ports or dialing. All the invalid configs are returned.
+func ValidateConfig(path string) []*ConfigError {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []*ConfigError{{Path: "$", Err: err}}
+	}
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		if content, err = yaml.YAMLToJSON(content); err != nil {
+			return []*ConfigError{{Path: "$", Err: err}}
+		}
+	}
+	return ValidateConfigContent(content)
+}
+
+// ValidateConfigContent validates the json config content, see ValidateConfig
+func ValidateConfigContent(content []byte) []*ConfigError {
+	v := newValidator()
+	v.validate(content)
+	return v.errs
+}
+
+// ValidateCluster validates a cluster config the same as the clusters in the config file,
+// the dynamic config sources validate the resources before they are applied.
+func ValidateCluster(c v2.Cluster) error {
+	v := newValidator()
+	v.cluster("$", c)
+	return v.err()
+}
+
+// ValidateRouter validates a router config, see ValidateCluster
+func ValidateRouter(rc *v2.RouterConfiguration) error {
+	v := newValidator()
+	v.router("$", rc)
+	return v.err()
+}
+
+// ValidateListener validates a listener config, see ValidateCluster
+func ValidateListener(ln *v2.Listener) error {
+	v := newValidator()
+	v.listener("$", ln)
+	return v.err()
+}
+
+var (
+	errNoServer           = errors.New("no server found")
+	errMultipleServer     = errors.New("multiple server not supported yet")
+	errNoFilterChain      = errors.New("at least one filter chain is required")
+	errNilFilterFactory   = errors.New("filter factory is nil")
+	errNameRequired       = errors.New("name is required")
+	errRouterNameRequired = errors.New("router_config_name is required")
+)
+
+type validator struct {
+	errs []*ConfigError
+	// names and addresses to the json path that first defined them
+	listenerNames map[string]string
+	listenerAddrs map[string]string
+	routerNames   map[string]string
+	clusterNames  map[string]string
+}
+
+func newValidator() *validator {
+	return &validator{
+		listenerNames: map[string]string{},
+		listenerAddrs: map[string]string{},
+		routerNames:   map[string]string{},
+		clusterNames:  map[string]string{},
+	}
+}
+
+// err returns the first invalid config, or nil if the config is valid
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs[0]
+}
+
+func (v *validator) report(path string, err error) {
+	v.errs = append(v.errs, &ConfigError{Path: path, Err: err})
+}
+
+// decode unmarshals the data and reports the error with the json path
+func (v *validator) decode(path string, data []byte, out interface{}) bool {
+	if err := json.Unmarshal(data, out); err != nil {
+		if e, ok := err.(*json.UnmarshalTypeError); ok && e.Field != "" {
+			path = path + "." + e.Field
+		}
+		v.report(path, err)
+		return false
+	}
+	return true
+}
+
+// unique reports the duplicate names
+func (v *validator) unique(path string, kind string, name string, seen map[string]string) {
+	if first, ok := seen[name]; ok {
+		v.report(path, fmt.Errorf("duplicate %s %s, first defined at %s", kind, name, first))
+		return
+	}
+	seen[name] = path
+}
+
+func (v *validator) validate(content []byte) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(content, &top); err != nil {
+		if e, ok := err.(*json.SyntaxError); ok {
+			// the offset is the bytes read, including the invalid one
+			line, col := position(content, e.Offset-1)
+			err = fmt.Errorf("line %d, column %d: %v", line, col, err)
+		}
+		v.report("$", err)
+		return
+	}
+	keys := make([]string, 0, len(top))
+	for key := range top {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	// the sections except servers and cluster manager are validated as a whole
+	for _, key := range keys {
+		if key == "servers" || key == "cluster_manager" {
+			continue
+		}
+		data, _ := json.Marshal(map[string]json.RawMessage{key: top[key]})
+		v.decode("$."+key, data, &v2.MOSNConfig{})
+	}
+	// clusters first, so the errors are reported in the same order as the mosn starts
+	if data, ok := top["cluster_manager"]; ok {
+		v.clusterManager("$.cluster_manager", data)
+	}
+	xds := len(top["static_resources"]) > 0 && len(top["dynamic_resources"]) > 0
+	var servers []json.RawMessage
+	if data, ok := top["servers"]; ok {
+		if !v.decode("$.servers", data, &servers) {
+			return
+		}
+	}
+	if !xds {
+		if len(servers) == 0 {
+			v.report("$.servers", errNoServer)
+		} else if len(servers) > 1 {
+			v.report("$.servers", errMultipleServer)
+		}
+	}
+	for i, data := range servers {
+		v.server(fmt.Sprintf("$.servers[%d]", i), data)
+	}
+}
+
+func (v *validator) server(path string, data json.RawMessage) {
+	var fields map[string]json.RawMessage
+	if !v.decode(path, data, &fields) {
+		return
+	}
+	var listeners, routers []json.RawMessage
+	if raw, ok := fields["listeners"]; ok && !v.decode(path+".listeners", raw, &listeners) {
+		listeners = nil
+	}
+	if raw, ok := fields["routers"]; ok && !v.decode(path+".routers", raw, &routers) {
+		routers = nil
+	}
+	delete(fields, "listeners")
+	delete(fields, "routers")
+	serverData, _ := json.Marshal(fields)
+	sc := &v2.ServerConfig{}
+	if v.decode(path, serverData, sc) {
+		switch sc.Processor.(type) {
+		case nil, string, float64:
+		default:
+			v.report(path+".processor", fmt.Errorf("unsupported processor type %v, must be int or string", reflect.TypeOf(sc.Processor)))
+		}
+	}
+	for i, raw := range routers {
+		rpath := fmt.Sprintf("%s.routers[%d]", path, i)
+		rc := &v2.RouterConfiguration{}
+		if v.decode(rpath, raw, rc) {
+			v.router(rpath, rc)
+		}
+	}
+	for i, raw := range listeners {
+		lpath := fmt.Sprintf("%s.listeners[%d]", path, i)
+		ln := &v2.Listener{}
+		if v.decode(lpath, raw, ln) {
+			v.listener(lpath, ln)
+		}
+	}
+}
+
+func (v *validator) router(path string, rc *v2.RouterConfiguration) {
+	if rc.RouterConfigName == "" {
+		v.report(path, errRouterNameRequired)
+		return
+	}
+	v.unique(path, "router", rc.RouterConfigName, v.routerNames)
+	if _, err := router.NewRouters(rc); err != nil {
+		v.report(path, err)
+	}
+}
+
+func (v *validator) listener(path string, ln *v2.Listener) {
+	if ln.Name != "" {
+		v.unique(path, "listener", ln.Name, v.listenerNames)
+	}
+	if ln.BindToPort {
+		v.unique(path+".address", "listener address", ln.Network+"://"+ln.Addr.String(), v.listenerAddrs)
+	}
+	for i, f := range ln.ListenerFilters {
+		fpath := fmt.Sprintf("%s.listener_filters[%d]", path, i)
+		factory, err := api.CreateListenerFilterChainFactory(f.Type, f.Config)
+		v.factory(fpath, factory, err)
+	}
+	if len(ln.FilterChains) == 0 {
+		v.report(path+".filter_chains", errNoFilterChain)
+	}
+	for i := range ln.FilterChains {
+		fc := &ln.FilterChains[i]
+		cpath := fmt.Sprintf("%s.filter_chains[%d]", path, i)
+		for j := range fc.TLSContexts {
+			tpath := cpath + ".tls_context"
+			if len(fc.TLSConfigs) > 0 {
+				tpath = fmt.Sprintf("%s.tls_context_set[%d]", cpath, j)
+			}
+			v.tls(tpath, ln.Name, &fc.TLSContexts[j], true)
+		}
+		for j, f := range fc.Filters {
+			fpath := fmt.Sprintf("%s.filters[%d]", cpath, j)
+			// the factory initializer is not called, some of them start servers
+			factory, err := api.CreateNetworkFilterChainFactory(f.Type, f.Config)
+			v.factory(fpath, factory, err)
+		}
+		// deprecated: the router config in the connection manager filter
+		rc, err := configmanager.ParseRouterConfiguration(fc)
+		if err != nil {
+			v.report(cpath, err)
+		} else if rc.RouterConfigName != "" {
+			// the router may be updated by the routers config, no duplicate check
+			if _, err := router.NewRouters(rc); err != nil {
+				v.report(cpath, err)
+			}
+		}
+	}
+	for i, f := range ln.StreamFilters {
+		fpath := fmt.Sprintf("%s.stream_filters[%d]", path, i)
+		var factory api.StreamFilterChainFactory
+		var err error
+		if f.GoPluginConfig != nil {
+			factory, err = streamfilter.CreateFactoryByPlugin(f.GoPluginConfig, f.Config)
+		} else {
+			factory, err = api.CreateStreamFilterChainFactory(f.Type, f.Config)
+		}
+		v.factory(fpath, factory, err)
+	}
+}
+
+// factory reports the error of the filter factory creation
+func (v *validator) factory(path string, factory interface{}, err error) {
+	if err != nil {
+		v.report(path, err)
+		return
+	}
+	// the factory is an interface, needs to check by reflect
+	if factory == nil || reflect.ValueOf(factory).IsNil() {
+		v.report(path, errNilFilterFactory)
+	}
+}
+
+// tls creates the tls provider, the certificates are loaded and verified.
+// the sds config is checked only, it needs to connect the sds server.
+func (v *validator) tls(path string, name string, cfg *v2.TLSConfig, server bool) {
+	if !cfg.Status {
+		return
+	}
+	if cfg.SdsConfig != nil {
+		if !cfg.SdsConfig.Valid() {
+			v.report(path+".sds_source", mtls.ErrorNoCertConfigure)
+		}
+		return
+	}
+	provider, err := mtls.NewProvider("validate_"+name, cfg)
+	if err != nil {
+		v.report(path, err)
+		return
+	}
+	if server && provider != nil && provider.Empty() && !cfg.Fallback {
+		v.report(path, mtls.ErrorNoCertConfigure)
+	}
+}
+
+func (v *validator) clusterManager(path string, data json.RawMessage) {
+	var fields map[string]json.RawMessage
+	if !v.decode(path, data, &fields) {
+		return
+	}
+	if raw, ok := fields["tls_context"]; ok {
+		tlsConfig := &v2.TLSConfig{}
+		if v.decode(path+".tls_context", raw, tlsConfig) {
+			v.tls(path+".tls_context", "cluster_manager", tlsConfig, false)
+		}
+	}
+	if _, ok := fields["clusters_configs"]; ok {
+		// the clusters are loaded from the files in the path
+		cm := &v2.ClusterManagerConfig{}
+		if !v.decode(path, data, cm) {
+			return
+		}
+		for i, c := range cm.Clusters {
+			v.cluster(fmt.Sprintf("%s.clusters_configs[%d]", path, i), c)
+		}
+		return
+	}
+	var clusters []json.RawMessage
+	if raw, ok := fields["clusters"]; ok && !v.decode(path+".clusters", raw, &clusters) {
+		return
+	}
+	for i, raw := range clusters {
+		cpath := fmt.Sprintf("%s.clusters[%d]", path, i)
+		c := v2.Cluster{}
+		if v.decode(cpath, raw, &c) {
+			v.cluster(cpath, c)
+		}
+	}
+}
+
+// cluster types that are not registered, but they are created as simple cluster
+var simpleClusterTypes = map[v2.ClusterType]struct{}{
+	v2.STATIC_CLUSTER:      {},
+	v2.DYNAMIC_CLUSTER:     {},
+	v2.EDS_CLUSTER:         {},
+	v2.ORIGINALDST_CLUSTER: {},
+}
+
+func (v *validator) cluster(path string, c v2.Cluster) {
+	if c.Name == "" {
+		v.report(path+".name", errNameRequired)
+		return
+	}
+	v.unique(path+".name", "cluster", c.Name, v.clusterNames)
+	if _, ok := simpleClusterTypes[c.ClusterType]; !ok && c.ClusterType != "" && !cluster.IsClusterTypeRegistered(c.ClusterType) {
+		v.report(path+".type", fmt.Errorf("unknown cluster type %s", c.ClusterType))
+	}
+	if c.LbType != "" && !cluster.IsLBTypeRegistered(types.LoadBalancerType(c.LbType)) {
+		v.report(path+".lb_type", fmt.Errorf("unknown load balancer type %s", c.LbType))
+	}
+	for i, h := range c.Hosts {
+		// strict dns cluster supports the host without port
+		if c.ClusterType == v2.STRICT_DNS_CLUSTER && !strings.Contains(h.Address, ":") {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(h.Address); err != nil {
+			v.report(fmt.Sprintf("%s.hosts[%d].address", path, i), err)
+		}
+	}
+	if !c.ClusterManagerTLS {
+		v.tls(path+".tls_context", c.Name, &c.TLS, false)
+	}
+	// the cluster and the load balancer are created, but the hosts are not added,
+	// so the health check and dns resolve are not started.
+	// the tls context is validated above already.
+	c.ClusterManagerTLS = true
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				v.report(path, fmt.Errorf("create cluster failed: %v", r))
+			}
+		}()
+		cluster.NewCluster(c)
+	}()
+}
+
+// position returns the line and column of the byte at the offset
+func position(content []byte, offset int64) (line, col int) {
+	line, col = 1, 1
+	for i := int64(0); i < offset && i < int64(len(content)); i++ {
+		if content[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return
+}
@@ -0,0 +1,213 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mosn
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+	_ "mosn.io/mosn/pkg/stream/http"
+)
+
+const validateConfig = `{
+	"servers":[{
+		"default_log_path": "stdout",
+		"processor": 2,
+		"routers": [{
+			"router_config_name":"server_router",
+			"virtual_hosts":[{
+				"name": "vh",
+				"domains": ["*"],
+				"routers": [{
+					"match":{"prefix":"/"},
+					"route":{"cluster_name":"serverCluster"}
+				}]
+			}]
+		}],
+		"listeners":[{
+			"name":"serverListener",
+			"address": "127.0.0.1:8080",
+			"bind_port": true,
+			"filter_chains": [{
+				"filters": [{
+					"type": "proxy",
+					"config": {
+						"downstream_protocol": "Http1",
+						"upstream_protocol": "Http1",
+						"router_config_name":"server_router"
+					}
+				}]
+			}]
+		}]
+	}],
+	"cluster_manager":{
+		"clusters":[{
+			"name": "serverCluster",
+			"type": "SIMPLE",
+			"lb_type": "LB_RANDOM",
+			"hosts":[{"address": "127.0.0.1:8081"}]
+		}]
+	}
+}`
+
+const invalidConfig = `{
+	"servers":[{
+		"default_log_path": "stdout",
+		"processor": true,
+		"routers": [{
+			"router_config_name":"server_router",
+			"virtual_hosts":[]
+		}],
+		"listeners":[{
+			"name":"serverListener",
+			"address": "127.0.0.1:8080",
+			"bind_port": true,
+			"filter_chains": [{
+				"filters": [{
+					"type": "proxy",
+					"config": {
+						"downstream_protocol": "Unknown",
+						"router_config_name":"server_router"
+					}
+				}, {
+					"type": "unknown_filter"
+				}]
+			}],
+			"stream_filters": [{"type": "unknown_stream_filter"}]
+		}, {
+			"name":"serverListener",
+			"address": "127.0.0.1:8080",
+			"bind_port": true
+		}, {
+			"name":"noAddress"
+		}]
+	}],
+	"cluster_manager":{
+		"clusters":[{
+			"name": "serverCluster",
+			"type": "UNKNOWN",
+			"lb_type": "LB_UNKNOWN",
+			"hosts":[{"address": "127.0.0.1"}],
+			"tls_context": {
+				"status": true,
+				"ca_cert": "invalid"
+			}
+		}, {
+			"type": "SIMPLE"
+		}, {
+			"name": 1
+		}]
+	}
+}`
+
+func TestValidateConfig(t *testing.T) {
+	if errs := ValidateConfigContent([]byte(validateConfig)); len(errs) != 0 {
+		t.Fatalf("config should be valid, but got errors: %v", errs)
+	}
+	errs := ValidateConfigContent([]byte(invalidConfig))
+	paths := make([]string, 0, len(errs))
+	for _, err := range errs {
+		paths = append(paths, err.Path)
+	}
+	sort.Strings(paths)
+	expected := []string{
+		"$.cluster_manager.clusters[0].hosts[0].address",
+		"$.cluster_manager.clusters[0].lb_type",
+		"$.cluster_manager.clusters[0].tls_context",
+		"$.cluster_manager.clusters[0].type",
+		"$.cluster_manager.clusters[1].name",
+		"$.cluster_manager.clusters[2].name",
+		"$.servers[0].listeners[0].filter_chains[0].filters[0]",
+		"$.servers[0].listeners[0].filter_chains[0].filters[1]",
+		"$.servers[0].listeners[0].stream_filters[0]",
+		"$.servers[0].listeners[1]",
+		"$.servers[0].listeners[1].address",
+		"$.servers[0].listeners[1].filter_chains",
+		"$.servers[0].listeners[2]",
+		"$.servers[0].processor",
+		"$.servers[0].routers[0]",
+	}
+	if strings.Join(paths, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestValidateConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// syntax error is reported with the position
+	path := filepath.Join(dir, "mosn.json")
+	if err := ioutil.WriteFile(path, []byte("{\n\t\"servers\": [\n}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errs := ValidateConfig(path)
+	if len(errs) != 1 || errs[0].Path != "$" || !strings.Contains(errs[0].Error(), "line 3, column 1") {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	// yaml config
+	path = filepath.Join(dir, "mosn.yaml")
+	if err := ioutil.WriteFile(path, []byte(`
+servers:
+- listeners:
+  - name: yamlListener
+    address: 127.0.0.1:2046
+    filter_chains:
+    - filters:
+      - type: unknown_filter
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errs = ValidateConfig(path)
+	if len(errs) != 1 || errs[0].Path != "$.servers[0].listeners[0].filter_chains[0].filters[0]" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	// file not found
+	if errs := ValidateConfig(filepath.Join(dir, "not_exists.json")); len(errs) != 1 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestValidateResource(t *testing.T) {
+	if err := ValidateCluster(v2.Cluster{Name: "c1", LbType: v2.LB_RANDOM}); err != nil {
+		t.Fatalf("cluster should be valid, but got: %v", err)
+	}
+	if err := ValidateCluster(v2.Cluster{Name: "c1", LbType: "LB_UNKNOWN"}); err == nil {
+		t.Fatal("cluster with unknown load balancer should be invalid")
+	}
+	if err := ValidateRouter(&v2.RouterConfiguration{}); err == nil {
+		t.Fatal("router without name should be invalid")
+	}
+	ln := &v2.Listener{}
+	ln.Name = "l1"
+	ln.FilterChains = []v2.FilterChain{{
+		FilterChainConfig: v2.FilterChainConfig{
+			Filters: []v2.Filter{{Type: "unknown_filter"}},
+		},
+	}}
+	if err := ValidateListener(ln); err == nil {
+		t.Fatal("listener with unknown filter should be invalid")
+	}
+}
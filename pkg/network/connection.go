@@ -36,6 +36,7 @@ import (
 	mosnctx "mosn.io/mosn/pkg/context"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/tap/conntap"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/buffer"
 	"mosn.io/pkg/utils"
@@ -130,6 +131,7 @@ type connection struct {
 	writeSchedChan chan bool // writable if not scheduled yet.
 
 	stats              *types.ConnectionStats
+	tap                *conntap.Tap
 	readCollector      metrics.Counter
 	writeCollector     metrics.Counter
 	lastBytesSizeRead  int64
@@ -212,6 +214,12 @@ func newServerConnection(ctx context.Context, rawc net.Conn, stopChan chan struc
 
 	conn.filterManager = NewFilterManager(conn)
 
+	if conntap.Enabled() {
+		if name, ok := mosnctx.Get(ctx, types.ContextKeyListenerName).(string); ok {
+			conn.tap = conntap.NewTap(conntap.SourceListener, name, id, conn.localAddr, conn.remoteAddr)
+		}
+	}
+
 	return conn
 }
 
@@ -597,6 +605,11 @@ func (c *connection) OnRead(b buffer.IoBuffer) {
 }
 
 func (c *connection) onRead(bytesRead int64) {
+	if c.tap != nil && bytesRead > 0 && int(bytesRead) <= c.readBuffer.Len() {
+		data := c.readBuffer.Bytes()
+		c.tap.OnRead(data[len(data)-int(bytesRead):])
+	}
+
 	for _, cb := range c.bytesReadCallbacks {
 		cb(uint64(bytesRead))
 	}
@@ -852,6 +865,9 @@ func (c *connection) doWriteIo() (bytesSent int64, err error) {
 	for i, buf := range c.ioBuffers {
 		c.ioBuffers[i] = nil
 		c.writeBuffers[i] = nil
+		if c.tap != nil {
+			c.tap.OnWrite(buf.Bytes())
+		}
 		if buf.EOF() {
 			err = buffer.EOF
 		}
@@ -938,6 +954,10 @@ func (c *connection) Close(ccType api.ConnectionCloseType, eventType api.Connect
 
 	c.rawConnection.Close()
 
+	if c.tap != nil {
+		c.tap.Close()
+	}
+
 	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 		log.DefaultLogger.Debugf("[network] [close connection] Close connection %d, event %s, type %s", c.id, eventType, ccType)
 	}
@@ -1075,10 +1095,19 @@ type clientConnection struct {
 	connection
 
 	connectTimeout time.Duration
+	tapCluster     string
 
 	connectOnce sync.Once
 }
 
+// SetTapCluster sets the cluster name of the client connection, the connection
+// is captured after connected if there is a connection tap for the cluster.
+func SetTapCluster(conn types.ClientConnection, cluster string) {
+	if cc, ok := conn.(*clientConnection); ok {
+		cc.tapCluster = cluster
+	}
+}
+
 func newClientConnection(connectTimeout time.Duration, tlsMng types.TLSClientContextManager, remoteAddr net.Addr, stopChan chan struct{}) types.ClientConnection {
 	id := atomic.AddUint64(&idCounter, 1)
 
@@ -1188,6 +1217,9 @@ func (cc *clientConnection) Connect() (err error) {
 		var event api.ConnectionEvent
 		event, err = cc.tryConnect()
 		if err == nil {
+			if cc.tapCluster != "" && conntap.Enabled() {
+				cc.tap = conntap.NewTap(conntap.SourceCluster, cc.tapCluster, cc.id, cc.localAddr, cc.remoteAddr)
+			}
 			cc.Start(context.TODO())
 		}
 		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
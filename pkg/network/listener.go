@@ -30,6 +30,7 @@ import (
 	"mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/utils"
 )
@@ -79,6 +80,9 @@ type listener struct {
 	mutex                   sync.Mutex
 	// listener state indicates the listener's running state. The listener state effects if a listener binded to a port
 	state ListenerState
+	// pendingShutdown is set if the listener stops accepting by StopAccept,
+	// the existing connections are still graceful closed by the next Shutdown.
+	pendingShutdown bool
 }
 
 func NewListener(lc *v2.Listener) types.Listener {
@@ -141,6 +145,7 @@ func (l *listener) Start(lctx context.Context, restart bool) {
 				log.DefaultLogger.Debugf("[network] [listener start] %s is running", l.name)
 				return true
 			case ListenerStopped:
+				l.pendingShutdown = false
 				if err := l.setDeadline(time.Time{}); err != nil {
 					log.DefaultLogger.Alertf("listener.start", "[network] [listener start] [listen] %s reset deadline failed, %v", l.name, err)
 				}
@@ -187,8 +192,36 @@ func (l *listener) Start(lctx context.Context, restart bool) {
 	}
 }
 
+// overloadCheckInterval is the interval to check the overload state while the accepting is paused
+var overloadCheckInterval = 100 * time.Millisecond
+
+// waitOverload pauses accepting while the stop_accepting_connections action is saturated,
+// the new connections are queued in the backlog. It returns false if the listener is stopped
+// or closed during the pause.
+func (l *listener) waitOverload() bool {
+	if !overload.Saturated(overload.ActionStopAcceptingConnections) {
+		return true
+	}
+	log.DefaultLogger.Warnf("[network] [listener start] [accept] listener %s pauses accepting connections by overload", l.name)
+	for overload.Saturated(overload.ActionStopAcceptingConnections) {
+		time.Sleep(overloadCheckInterval)
+		l.mutex.Lock()
+		state := l.state
+		l.mutex.Unlock()
+		if state != ListenerRunning {
+			return false
+		}
+	}
+	log.DefaultLogger.Infof("[network] [listener start] [accept] listener %s resumes accepting connections", l.name)
+	return true
+}
+
 func (l *listener) acceptEventLoop(lctx context.Context) {
 	for {
+		if !l.waitOverload() {
+			log.DefaultLogger.Infof("[network] [listener start] [accept] listener %s stop accepting connections during the overload pause", l.name)
+			return
+		}
 		if err := l.accept(lctx); err != nil {
 			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
 				log.DefaultLogger.Infof("[network] [listener start] [accept] listener %s stop accepting connections by deadline", l.name)
@@ -224,12 +257,31 @@ func (l *listener) readMsgEventLoop(lctx context.Context) {
 // Shutdown stop accepting new connections and graceful close the existing connections
 func (l *listener) Shutdown() error {
 	changed, err := l.stopAccept()
-	if changed {
+	if changed || l.takePendingShutdown() {
 		l.cb.OnShutdown()
 	}
 	return err
 }
 
+// StopAccept stop accepting new connections, the existing connections are kept
+func (l *listener) StopAccept() error {
+	changed, err := l.stopAccept()
+	if changed {
+		l.mutex.Lock()
+		l.pendingShutdown = true
+		l.mutex.Unlock()
+	}
+	return err
+}
+
+func (l *listener) takePendingShutdown() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	pending := l.pendingShutdown
+	l.pendingShutdown = false
+	return pending && l.state == ListenerStopped
+}
+
 // stopAccept just stop accepting new connections
 func (l *listener) stopAccept() (changed bool, err error) {
 	l.mutex.Lock()
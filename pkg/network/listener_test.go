@@ -146,3 +146,37 @@ func TestUDSToFileListener(t *testing.T) {
 	assert.Equal(t, f.Name(), f1.Name())
 	l.Close()
 }
+
+type shutdownCountListener struct {
+	mockEventListener
+	shutdown int
+}
+
+func (e *shutdownCountListener) OnShutdown() {
+	e.shutdown++
+}
+
+func TestListenerStopAcceptAndShutdown(t *testing.T) {
+	ln := GetListenerFactory()(&v2.Listener{
+		ListenerConfig: v2.ListenerConfig{
+			Name: "test_stop_accept",
+		},
+	})
+	el := &shutdownCountListener{}
+	ln.SetListenerCallbacks(el)
+	if err := ln.StopAccept(); err != nil {
+		t.Fatal(err)
+	}
+	if el.shutdown != 0 {
+		t.Fatal("stop accept should not shutdown the connections")
+	}
+	// the connections are still graceful stopped by the shutdown after stop accept
+	for i := 0; i < 2; i++ {
+		if err := ln.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if el.shutdown != 1 {
+		t.Fatalf("expected shutdown once, but got %d", el.shutdown)
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package overload
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// ExtendConfigType is the extend config type of the overload manager
+//
+//	"extends": [{
+//		"type": "overload_manager",
+//		"config": {
+//			"refresh_interval": "1s",
+//			"resource_monitors": [
+//				{"name": "heap_size", "max": 2147483648},
+//				{"name": "file_descriptors"}
+//			],
+//			"actions": [{
+//				"name": "shrink_buffer_limits",
+//				"triggers": [{"monitor": "heap_size", "scaled": {"scaling_threshold": 0.8, "saturation_threshold": 0.95}}]
+//			}, {
+//				"name": "reject_requests",
+//				"triggers": [{"monitor": "heap_size", "threshold": 0.95}, {"monitor": "file_descriptors", "threshold": 0.9}]
+//			}]
+//		}
+//	}]
+const ExtendConfigType = "overload_manager"
+
+func init() {
+	v2.RegisterParseExtendConfig(ExtendConfigType, OnOverloadParsed)
+}
+
+// Config is the config of the overload manager
+type Config struct {
+	RefreshInterval  api.DurationConfig `json:"refresh_interval,omitempty"`
+	ResourceMonitors []MonitorConfig    `json:"resource_monitors,omitempty"`
+	Actions          []ActionConfig     `json:"actions,omitempty"`
+}
+
+// MonitorConfig is the config of a resource monitor
+type MonitorConfig struct {
+	Name string `json:"name"`
+	// Max is the max usage of the resource, the pressure is the usage divided by the max.
+	// The max of file_descriptors is the RLIMIT_NOFILE if it is zero.
+	Max uint64 `json:"max,omitempty"`
+}
+
+// ActionConfig is the config of an action, the state of the action is the max of the triggers
+type ActionConfig struct {
+	Name     string          `json:"name"`
+	Triggers []TriggerConfig `json:"triggers"`
+}
+
+// TriggerConfig is a trigger of an action, one of the threshold and the scaled should be set
+type TriggerConfig struct {
+	Monitor string `json:"monitor"`
+	// Threshold sets the state to 1 when the pressure reaches it
+	Threshold float64       `json:"threshold,omitempty"`
+	Scaled    *ScaledConfig `json:"scaled,omitempty"`
+}
+
+// ScaledConfig increases the state linearly from the scaling threshold to the saturation threshold
+type ScaledConfig struct {
+	ScalingThreshold    float64 `json:"scaling_threshold"`
+	SaturationThreshold float64 `json:"saturation_threshold"`
+}
+
+func (c *TriggerConfig) validate() error {
+	if c.Scaled == nil {
+		if c.Threshold <= 0 || c.Threshold > 1 {
+			return fmt.Errorf("threshold of monitor %s should be in (0, 1]", c.Monitor)
+		}
+		return nil
+	}
+	if c.Threshold != 0 {
+		return fmt.Errorf("trigger of monitor %s can not set both threshold and scaled", c.Monitor)
+	}
+	s := c.Scaled
+	if s.ScalingThreshold < 0 || s.ScalingThreshold >= s.SaturationThreshold || s.SaturationThreshold > 1 {
+		return fmt.Errorf("scaled thresholds of monitor %s should be 0 <= scaling < saturation <= 1", c.Monitor)
+	}
+	return nil
+}
+
+var (
+	managerMux sync.Mutex
+	manager    *Manager
+)
+
+// OnOverloadParsed starts the overload manager, the running one is replaced
+func OnOverloadParsed(data json.RawMessage) error {
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return err
+	}
+	m, err := NewManager(cfg)
+	if err != nil {
+		return err
+	}
+	managerMux.Lock()
+	defer managerMux.Unlock()
+	if manager != nil {
+		manager.Stop()
+	}
+	manager = m
+	manager.Start()
+	return nil
+}
+
+// Stop stops the running overload manager, all the actions are inactive after stopped
+func Stop() {
+	managerMux.Lock()
+	defer managerMux.Unlock()
+	if manager != nil {
+		manager.Stop()
+		manager = nil
+	}
+}
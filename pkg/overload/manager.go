@@ -0,0 +1,237 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package overload
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/pkg/utils"
+)
+
+const defaultRefreshInterval = time.Second
+
+type monitor struct {
+	name     string
+	max      uint64
+	pressure float64
+
+	usageGauge    gometrics.Gauge
+	pressureGauge gometrics.Gauge
+}
+
+// update refreshes the pressure, the last pressure is kept if the usage can not be read
+func (m *monitor) update() {
+	usage := getUsage(m.name)
+	if usage == nil {
+		return
+	}
+	value, err := usage()
+	if err != nil {
+		log.DefaultLogger.Errorf("[overload] read usage of monitor %s failed: %v", m.name, err)
+		return
+	}
+	m.pressure = float64(value) / float64(m.max)
+	m.usageGauge.Update(int64(value))
+	m.pressureGauge.Update(int64(m.pressure * 100))
+}
+
+type trigger struct {
+	monitor *monitor
+	config  TriggerConfig
+}
+
+func (t *trigger) value() float64 {
+	pressure := t.monitor.pressure
+	if t.config.Scaled == nil {
+		if pressure >= t.config.Threshold {
+			return 1
+		}
+		return 0
+	}
+	s := t.config.Scaled
+	switch {
+	case pressure < s.ScalingThreshold:
+		return 0
+	case pressure >= s.SaturationThreshold:
+		return 1
+	default:
+		return (pressure - s.ScalingThreshold) / (s.SaturationThreshold - s.ScalingThreshold)
+	}
+}
+
+type action struct {
+	name     string
+	triggers []*trigger
+	state    *actionState
+
+	stateGauge gometrics.Gauge
+}
+
+func (a *action) update() {
+	state := 0.0
+	for _, t := range a.triggers {
+		if v := t.value(); v > state {
+			state = v
+		}
+	}
+	last := a.state.load()
+	a.state.store(state)
+	a.stateGauge.Update(int64(state * 100))
+	switch {
+	case last <= 0 && state > 0:
+		log.DefaultLogger.Warnf("[overload] action %s is triggered, state: %.2f", a.name, state)
+	case last > 0 && state <= 0:
+		log.DefaultLogger.Infof("[overload] action %s is recovered", a.name)
+	}
+}
+
+// Manager refreshes the pressures of the resource monitors periodically, and updates the states of the actions
+type Manager struct {
+	interval  time.Duration
+	monitors  []*monitor
+	actions   []*action
+	stop      chan struct{}
+	closeOnce sync.Once
+
+	mux     sync.Mutex
+	stopped bool
+}
+
+// NewManager creates a overload manager by the config
+func NewManager(cfg *Config) (*Manager, error) {
+	m := &Manager{
+		interval: cfg.RefreshInterval.Duration,
+		stop:     make(chan struct{}),
+	}
+	if m.interval <= 0 {
+		m.interval = defaultRefreshInterval
+	}
+	monitors := make(map[string]*monitor, len(cfg.ResourceMonitors))
+	for _, mc := range cfg.ResourceMonitors {
+		if getUsage(mc.Name) == nil {
+			return nil, fmt.Errorf("unknown resource monitor: %s", mc.Name)
+		}
+		if _, ok := monitors[mc.Name]; ok {
+			return nil, fmt.Errorf("duplicated resource monitor: %s", mc.Name)
+		}
+		max := mc.Max
+		if max == 0 {
+			var err error
+			if max, err = defaultMax(mc.Name); err != nil {
+				return nil, fmt.Errorf("resource monitor %s: %v", mc.Name, err)
+			}
+		}
+		s := metrics.NewOverloadMonitorStats(mc.Name)
+		mon := &monitor{
+			name:          mc.Name,
+			max:           max,
+			usageGauge:    s.Gauge(metrics.OverloadMonitorUsage),
+			pressureGauge: s.Gauge(metrics.OverloadMonitorPressure),
+		}
+		monitors[mc.Name] = mon
+		m.monitors = append(m.monitors, mon)
+	}
+	configured := make(map[string]bool, len(cfg.Actions))
+	for _, ac := range cfg.Actions {
+		state, ok := states[ac.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown overload action: %s", ac.Name)
+		}
+		if configured[ac.Name] {
+			return nil, fmt.Errorf("duplicated overload action: %s", ac.Name)
+		}
+		configured[ac.Name] = true
+		if len(ac.Triggers) == 0 {
+			return nil, fmt.Errorf("overload action %s has no triggers", ac.Name)
+		}
+		a := &action{
+			name:       ac.Name,
+			state:      state,
+			stateGauge: metrics.NewOverloadActionStats(ac.Name).Gauge(metrics.OverloadActionState),
+		}
+		for _, tc := range ac.Triggers {
+			mon, ok := monitors[tc.Monitor]
+			if !ok {
+				return nil, fmt.Errorf("overload action %s is triggered by an unconfigured monitor: %s", ac.Name, tc.Monitor)
+			}
+			if err := tc.validate(); err != nil {
+				return nil, err
+			}
+			a.triggers = append(a.triggers, &trigger{
+				monitor: mon,
+				config:  tc,
+			})
+		}
+		m.actions = append(m.actions, a)
+	}
+	return m, nil
+}
+
+func (m *Manager) refresh() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.stopped {
+		return
+	}
+	for _, mon := range m.monitors {
+		mon.update()
+	}
+	for _, a := range m.actions {
+		a.update()
+	}
+}
+
+// Start refreshes the states immediately and then periodically
+func (m *Manager) Start() {
+	m.refresh()
+	utils.GoWithRecover(m.run, nil)
+	log.DefaultLogger.Infof("[overload] overload manager started, monitors: %d, actions: %d, interval: %v", len(m.monitors), len(m.actions), m.interval)
+}
+
+func (m *Manager) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.refresh()
+		}
+	}
+}
+
+// Stop stops refreshing and resets the states of the actions
+func (m *Manager) Stop() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		m.mux.Lock()
+		defer m.mux.Unlock()
+		m.stopped = true
+		for _, a := range m.actions {
+			a.state.store(0)
+			a.stateGauge.Update(0)
+		}
+		log.DefaultLogger.Infof("[overload] overload manager stopped")
+	})
+}
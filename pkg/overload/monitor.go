@@ -0,0 +1,77 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package overload
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// The resource monitors
+const (
+	MonitorHeapSize          = "heap_size"
+	MonitorGoroutines        = "goroutines"
+	MonitorActiveConnections = "active_connections"
+	MonitorFileDescriptors   = "file_descriptors"
+)
+
+// UsageFunc returns the current usage of a resource
+type UsageFunc func() (uint64, error)
+
+var (
+	usageMux   sync.RWMutex
+	usageFuncs = map[string]UsageFunc{
+		MonitorHeapSize:        heapSize,
+		MonitorGoroutines:      goroutines,
+		MonitorFileDescriptors: fileDescriptors,
+	}
+)
+
+// RegisterUsage registers the usage of a resource monitor, the usage of the
+// active connections is registered by the server.
+func RegisterUsage(monitor string, f UsageFunc) {
+	usageMux.Lock()
+	defer usageMux.Unlock()
+	usageFuncs[monitor] = f
+}
+
+func getUsage(monitor string) UsageFunc {
+	usageMux.RLock()
+	defer usageMux.RUnlock()
+	return usageFuncs[monitor]
+}
+
+// defaultMax returns the max of a resource if it is not configured,
+// only the file descriptors have a default max, the RLIMIT_NOFILE
+func defaultMax(monitor string) (uint64, error) {
+	if monitor == MonitorFileDescriptors {
+		return fileDescriptorsLimit()
+	}
+	return 0, errors.New("max is required")
+}
+
+func heapSize() (uint64, error) {
+	stats := &runtime.MemStats{}
+	runtime.ReadMemStats(stats)
+	return stats.HeapAlloc, nil
+}
+
+func goroutines() (uint64, error) {
+	return uint64(runtime.NumGoroutine()), nil
+}
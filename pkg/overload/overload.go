@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package overload protects mosn from the exhaustion of the resources.
+//
+// The resource monitors measure the pressure of the resources, the usage divided
+// by the configured max, and the actions are triggered by the pressures:
+//   - stop_accepting_connections: the listeners pause accepting new connections
+//   - disable_keepalive: the http1 connections are closed after the response, and
+//     the http2 connections are sent a goaway
+//   - shrink_buffer_limits: the buffer limits of the new connections are shrunk
+//   - reject_requests: the new requests are replied with 503 or the protocol equivalent
+//
+// The state of an action is in [0, 1], a threshold trigger sets the state to 1 when the
+// pressure reaches the threshold, and a scaled trigger increases the state linearly from
+// the scaling threshold to the saturation threshold. A scaled action is applied with the
+// probability of the state, for example, the half of the requests are rejected if the
+// state of reject_requests is 0.5.
+package overload
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+
+	"mosn.io/mosn/pkg/metrics"
+)
+
+// The actions
+const (
+	ActionStopAcceptingConnections = "stop_accepting_connections"
+	ActionDisableKeepalive         = "disable_keepalive"
+	ActionShrinkBufferLimits       = "shrink_buffer_limits"
+	ActionRejectRequests           = "reject_requests"
+)
+
+// minBufferLimit is the min buffer limit that shrink_buffer_limits shrinks to
+const minBufferLimit = 16 * 1024
+
+// actionState stores the float64 bits of a state
+type actionState struct {
+	bits uint64
+}
+
+func (s *actionState) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.bits))
+}
+
+func (s *actionState) store(state float64) {
+	atomic.StoreUint64(&s.bits, math.Float64bits(state))
+}
+
+// states is never changed after init, so it can be read without lock
+var states = map[string]*actionState{
+	ActionStopAcceptingConnections: {},
+	ActionDisableKeepalive:         {},
+	ActionShrinkBufferLimits:       {},
+	ActionRejectRequests:           {},
+}
+
+// State returns the state of the action in [0, 1]
+func State(action string) float64 {
+	s, ok := states[action]
+	if !ok {
+		return 0
+	}
+	return s.load()
+}
+
+// Saturated returns true if the state of the action is 1
+func Saturated(action string) bool {
+	return State(action) >= 1
+}
+
+// Triggered returns true if the action should be applied, a scaled action
+// is applied with the probability of the state
+func Triggered(action string) bool {
+	state := State(action)
+	switch {
+	case state <= 0:
+		return false
+	case state >= 1:
+		return true
+	default:
+		return rand.Float64() < state
+	}
+}
+
+// RecordRejectedRequest counts a request rejected by reject_requests
+func RecordRejectedRequest() {
+	metrics.NewOverloadActionStats(ActionRejectRequests).Counter(metrics.OverloadRejectedRequests).Inc(1)
+}
+
+// ScaleBufferLimit shrinks the buffer limit by the state of shrink_buffer_limits,
+// the limit is not shrunk below 16KB, and a zero limit (the default) is kept.
+func ScaleBufferLimit(limit uint32) uint32 {
+	state := State(ActionShrinkBufferLimits)
+	if state <= 0 || limit <= minBufferLimit {
+		return limit
+	}
+	scaled := uint32(float64(limit) * (1 - state))
+	if scaled < minBufferLimit {
+		scaled = minBufferLimit
+	}
+	return scaled
+}
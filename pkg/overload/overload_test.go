@@ -0,0 +1,135 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package overload
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/metrics"
+)
+
+const testMonitor = "test_resource"
+
+func registerTestUsage() *uint64 {
+	usage := new(uint64)
+	RegisterUsage(testMonitor, func() (uint64, error) {
+		return atomic.LoadUint64(usage), nil
+	})
+	return usage
+}
+
+func TestManager(t *testing.T) {
+	usage := registerTestUsage()
+	defer Stop()
+	cfg := `{
+		"refresh_interval": "10ms",
+		"resource_monitors": [{"name": "test_resource", "max": 100}, {"name": "goroutines", "max": 1000000}],
+		"actions": [{
+			"name": "reject_requests",
+			"triggers": [{"monitor": "test_resource", "threshold": 0.9}]
+		}, {
+			"name": "shrink_buffer_limits",
+			"triggers": [{"monitor": "test_resource", "scaled": {"scaling_threshold": 0.5, "saturation_threshold": 0.9}}]
+		}]
+	}`
+	atomic.StoreUint64(usage, 70)
+	if err := OnOverloadParsed([]byte(cfg)); err != nil {
+		t.Fatal(err)
+	}
+	if State(ActionRejectRequests) != 0 || Triggered(ActionRejectRequests) {
+		t.Fatal("reject_requests should not be triggered")
+	}
+	if s := State(ActionShrinkBufferLimits); s < 0.49 || s > 0.51 {
+		t.Fatalf("unexpected scaled state: %f", s)
+	}
+	if limit := ScaleBufferLimit(1 << 20); limit < 500*1024 || limit > 530*1024 {
+		t.Fatalf("unexpected scaled buffer limit: %d", limit)
+	}
+	atomic.StoreUint64(usage, 95)
+	for i := 0; i < 50 && !Saturated(ActionRejectRequests); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !Triggered(ActionRejectRequests) || !Saturated(ActionShrinkBufferLimits) {
+		t.Fatal("actions should be saturated")
+	}
+	if ScaleBufferLimit(1<<20) != minBufferLimit || ScaleBufferLimit(0) != 0 {
+		t.Fatal("buffer limit should be shrunk to the min, and the default should be kept")
+	}
+	if Triggered(ActionStopAcceptingConnections) {
+		t.Fatal("unconfigured action should not be triggered")
+	}
+	// the states are reset after stopped
+	Stop()
+	if State(ActionRejectRequests) != 0 || State(ActionShrinkBufferLimits) != 0 {
+		t.Fatal("states should be reset after stopped")
+	}
+}
+
+func TestRecordRejectedRequest(t *testing.T) {
+	counter := metrics.NewOverloadActionStats(ActionRejectRequests).Counter(metrics.OverloadRejectedRequests)
+	count := counter.Count()
+	RecordRejectedRequest()
+	RecordRejectedRequest()
+	if n := counter.Count() - count; n != 2 {
+		t.Fatalf("expected 2 rejected requests, but got %d", n)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	registerTestUsage()
+	for _, cfg := range []string{
+		`{"resource_monitors": [{"name": "unknown", "max": 1}]}`,
+		`{"resource_monitors": [{"name": "heap_size"}]}`,
+		`{"resource_monitors": [{"name": "test_resource", "max": 1}, {"name": "test_resource", "max": 1}]}`,
+		`{"actions": [{"name": "unknown", "triggers": [{"monitor": "test_resource", "threshold": 0.9}]}]}`,
+		`{"resource_monitors": [{"name": "test_resource", "max": 1}], "actions": [{"name": "reject_requests"}]}`,
+		`{"actions": [{"name": "reject_requests", "triggers": [{"monitor": "test_resource", "threshold": 0.9}]}]}`,
+		`{"resource_monitors": [{"name": "test_resource", "max": 1}], "actions": [{"name": "reject_requests", "triggers": [{"monitor": "test_resource", "threshold": 1.5}]}]}`,
+		`{"resource_monitors": [{"name": "test_resource", "max": 1}], "actions": [{"name": "reject_requests", "triggers": [{"monitor": "test_resource", "scaled": {"scaling_threshold": 0.9, "saturation_threshold": 0.5}}]}]}`,
+	} {
+		if err := OnOverloadParsed([]byte(cfg)); err == nil {
+			t.Fatalf("config should be failed: %s", cfg)
+		}
+	}
+}
+
+func TestTriggerValue(t *testing.T) {
+	mon := &monitor{}
+	threshold := &trigger{monitor: mon, config: TriggerConfig{Threshold: 0.8}}
+	scaled := &trigger{monitor: mon, config: TriggerConfig{Scaled: &ScaledConfig{ScalingThreshold: 0.6, SaturationThreshold: 0.8}}}
+	for _, c := range []struct {
+		pressure  float64
+		threshold float64
+		scaled    float64
+	}{
+		{0.5, 0, 0},
+		{0.7, 0, 0.5},
+		{0.8, 1, 1},
+		{1.2, 1, 1},
+	} {
+		mon.pressure = c.pressure
+		if v := threshold.value(); v != c.threshold {
+			t.Fatalf("pressure %f, unexpected threshold value: %f", c.pressure, v)
+		}
+		if v := scaled.value(); v < c.scaled-0.001 || v > c.scaled+0.001 {
+			t.Fatalf("pressure %f, unexpected scaled value: %f", c.pressure, v)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package triple defines Triple, the gRPC compatible protocol of Dubbo 3 over HTTP/2.
+//
+// The Triple requests are the gRPC requests with the path /{service}/{method}, the version
+// and the group of the service are carried in the tri-service-version and tri-service-group headers.
+// They are surfaced in the header keys of the dubbo protocol, so the dubbo routing rules,
+// stream filters and stats apply to the Triple requests unchanged. The dubbo header keys
+// are stripped before the requests are sent to the upstream.
+package triple
+
+import (
+	"strings"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+)
+
+// ProtocolName is the name of the Triple protocol
+const ProtocolName api.ProtocolName = "Triple"
+
+// the headers of the Triple protocol
+const (
+	HeaderServiceVersion = "tri-service-version"
+	HeaderServiceGroup   = "tri-service-group"
+	HeaderGrpcStatus     = "grpc-status"
+)
+
+// ParsePath returns the service and the method of the path /{service}/{method}
+func ParsePath(path string) (service, method string, ok bool) {
+	path = strings.TrimPrefix(path, "/")
+	i := strings.LastIndexByte(path, '/')
+	if i <= 0 || i == len(path)-1 {
+		return "", "", false
+	}
+	return path[:i], path[i+1:], true
+}
+
+// SetDubboHeaders sets the service, method, version and group of the Triple request
+// in the dubbo header keys, it returns false if the path is not a Triple path.
+func SetDubboHeaders(headers api.HeaderMap, path string) bool {
+	service, method, ok := ParsePath(path)
+	if !ok {
+		return false
+	}
+	headers.Set(dubbo.ServiceNameHeader, service)
+	headers.Set(dubbo.InterfaceNameHeader, service)
+	headers.Set(dubbo.MethodNameHeader, method)
+	if version, ok := headers.Get(HeaderServiceVersion); ok {
+		headers.Set(dubbo.VersionNameHeader, version)
+	}
+	if group, ok := headers.Get(HeaderServiceGroup); ok {
+		headers.Set(dubbo.GroupNameHeader, group)
+	}
+	return true
+}
+
+// dubboHeaders are the header keys set by SetDubboHeaders
+var dubboHeaders = []string{
+	dubbo.ServiceNameHeader,
+	dubbo.InterfaceNameHeader,
+	dubbo.MethodNameHeader,
+	dubbo.VersionNameHeader,
+	dubbo.GroupNameHeader,
+}
+
+// StripDubboHeaders removes the dubbo header keys set by SetDubboHeaders,
+// they are only used by the proxy and should not be sent to the upstream.
+func StripDubboHeaders(headers api.HeaderMap) {
+	for _, key := range dubboHeaders {
+		headers.Del(key)
+	}
+}
+
+// IsSuccess returns true if the grpc-status of the response is OK, the status is
+// in the trailers, or in the headers if the response is trailers-only.
+func IsSuccess(headers api.HeaderMap, trailers api.HeaderMap) bool {
+	if trailers != nil {
+		if status, ok := trailers.Get(HeaderGrpcStatus); ok {
+			return status == "0"
+		}
+	}
+	if headers != nil {
+		if status, ok := headers.Get(HeaderGrpcStatus); ok {
+			return status == "0"
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package triple
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+)
+
+func TestParsePath(t *testing.T) {
+	service, method, ok := ParsePath("/org.apache.dubbo.demo.Greeter/sayHello")
+	assert.True(t, ok)
+	assert.Equal(t, "org.apache.dubbo.demo.Greeter", service)
+	assert.Equal(t, "sayHello", method)
+	for _, path := range []string{"/", "/sayHello", "/org.apache.dubbo.demo.Greeter/"} {
+		_, _, ok := ParsePath(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestSetDubboHeaders(t *testing.T) {
+	headers := protocol.CommonHeader{
+		HeaderServiceVersion: "1.0.0",
+		HeaderServiceGroup:   "gray",
+	}
+	assert.True(t, SetDubboHeaders(headers, "/org.apache.dubbo.demo.Greeter/sayHello"))
+	assert.Equal(t, "org.apache.dubbo.demo.Greeter", headers[dubbo.ServiceNameHeader])
+	assert.Equal(t, "org.apache.dubbo.demo.Greeter", headers[dubbo.InterfaceNameHeader])
+	assert.Equal(t, "sayHello", headers[dubbo.MethodNameHeader])
+	assert.Equal(t, "1.0.0", headers[dubbo.VersionNameHeader])
+	assert.Equal(t, "gray", headers[dubbo.GroupNameHeader])
+
+	headers = protocol.CommonHeader{}
+	assert.False(t, SetDubboHeaders(headers, "/"))
+	assert.Empty(t, headers)
+}
+
+func TestIsSuccess(t *testing.T) {
+	assert.True(t, IsSuccess(protocol.CommonHeader{}, protocol.CommonHeader{HeaderGrpcStatus: "0"}))
+	assert.False(t, IsSuccess(protocol.CommonHeader{}, protocol.CommonHeader{HeaderGrpcStatus: "14"}))
+	// trailers-only response
+	assert.True(t, IsSuccess(protocol.CommonHeader{HeaderGrpcStatus: "0"}, nil))
+	assert.False(t, IsSuccess(protocol.CommonHeader{HeaderGrpcStatus: "5"}, nil))
+	assert.False(t, IsSuccess(protocol.CommonHeader{}, nil))
+}
@@ -0,0 +1,156 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+)
+
+var errNotRequest = errors.New("[xprotocol][dubbo] arguments only exist in the requests")
+
+// Arguments decodes the argument list of the request, the arguments are decoded
+// at the first call and cached in the frame, so the cost is paid only if they are referenced.
+// If an argument can not be decoded, the arguments before it are returned with the error.
+func (r *Frame) Arguments() ([]interface{}, error) {
+	if !r.argumentsDecoded {
+		r.arguments, r.argumentsErr = decodeArguments(r)
+		r.argumentsDecoded = true
+	}
+	return r.arguments, r.argumentsErr
+}
+
+func (r *Frame) resetArguments() {
+	r.arguments = nil
+	r.argumentsErr = nil
+	r.argumentsDecoded = false
+}
+
+func decodeArguments(frame *Frame) (args []interface{}, err error) {
+	if frame.IsEvent || frame.Direction != EventRequest {
+		return nil, errNotRequest
+	}
+	if frame.SerializationId != 2 {
+		return nil, fmt.Errorf("[xprotocol][dubbo] not hessian,do not support")
+	}
+	// decode arguments maybe panic, when dubbo payload have complex struct
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("[xprotocol][dubbo] decode arguments panic: %v", r)
+		}
+	}()
+
+	decoder := hessian.NewDecoder(frame.payload)
+	// framework version + path + version + method
+	for i := 0; i < 4; i++ {
+		if _, err := decoder.Decode(); err != nil {
+			return nil, fmt.Errorf("[xprotocol][dubbo] decode dubbo request error: %v", err)
+		}
+	}
+	field, err := decoder.Decode()
+	if err != nil {
+		return nil, fmt.Errorf("[xprotocol][dubbo] decode dubbo argument types error: %v", err)
+	}
+	desc, ok := field.(string)
+	if !ok {
+		return nil, fmt.Errorf("[xprotocol][dubbo] argument types {%v} type error", field)
+	}
+	count := getArgumentCount(desc)
+	args = make([]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		arg, err := decoder.Decode()
+		if err != nil {
+			return args, fmt.Errorf("[xprotocol][dubbo] decode dubbo argument %d error: %v", i, err)
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// lookupField returns the field of the value by the path, the path elements are the
+// fields of the POJOs, the keys of the maps or the indexes of the lists.
+func lookupField(value interface{}, path []string) (interface{}, bool) {
+	for _, name := range path {
+		if value == nil {
+			return nil, false
+		}
+		if m, ok := value.(map[interface{}]interface{}); ok {
+			if value, ok = m[name]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		v := reflect.ValueOf(value)
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, false
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			f, ok := structField(v, name)
+			if !ok {
+				return nil, false
+			}
+			v = f
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return nil, false
+			}
+		case reflect.Slice, reflect.Array:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= v.Len() {
+				return nil, false
+			}
+			v = v.Index(i)
+		default:
+			return nil, false
+		}
+		if !v.CanInterface() {
+			return nil, false
+		}
+		value = v.Interface()
+	}
+	return value, true
+}
+
+// structField finds the field by the hessian tag, or by the name case-insensitively,
+// as the java field name is the go field name with the first letter in lower case.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if tag := f.Tag.Get("hessian"); tag == name || (tag == "" && strings.EqualFold(f.Name, name)) {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -18,6 +18,8 @@
 package dubbo
 
 import (
+	"bytes"
+
 	"mosn.io/api"
 	"mosn.io/pkg/buffer"
 
@@ -46,9 +48,15 @@ type Frame struct {
 
 	data    types.IoBuffer // wrapper of data
 	content types.IoBuffer // wrapper of payload
+
+	// the arguments are decoded lazily, only if they are referenced
+	arguments        []interface{}
+	argumentsErr     error
+	argumentsDecoded bool
 }
 
 var _ api.XFrame = &Frame{}
+var _ api.GoAwayPredicate = &Frame{}
 
 // ~ XFrame
 func (r *Frame) GetRequestId() uint64 {
@@ -60,7 +68,12 @@ func (r *Frame) SetRequestId(id uint64) {
 }
 
 func (r *Frame) IsHeartbeatFrame() bool {
-	return r.Header.IsEvent
+	return r.Header.IsEvent && !r.IsGoAwayFrame()
+}
+
+// IsGoAwayFrame returns true if the frame is a readonly event
+func (r *Frame) IsGoAwayFrame() bool {
+	return r.Header.IsEvent && r.Direction == EventRequest && bytes.Equal(r.payload, readonlyEventPayload)
 }
 
 // dubbo frame returns default timeout
@@ -92,6 +105,7 @@ func (r *Frame) SetData(data types.IoBuffer) {
 	r.content = data
 	r.payload = data.Bytes()
 	r.DataLen = uint32(data.Len())
+	r.resetArguments()
 }
 
 func (r *Frame) GetStatusCode() uint32 {
@@ -114,6 +114,29 @@ func (proto dubboProtocol) Reply(ctx context.Context, request api.XFrame) api.XR
 	}
 }
 
+// GoAwayer
+// GoAway returns a readonly event, as the dubbo server does before it goes offline.
+func (proto dubboProtocol) GoAway(ctx context.Context) api.XFrame {
+	// GoAway is disabled
+	config := parseConfig(ctx)
+	if !config.EnableDubboGoAway {
+		return nil
+	}
+
+	return &Frame{
+		Header: Header{
+			Magic:           MagicTag,
+			Flag:            FlagReadonlyEvent,
+			Id:              0, // this would be overwrite by stream layer
+			DataLen:         uint32(len(readonlyEventPayload)),
+			IsEvent:         true,
+			Direction:       EventRequest,
+			SerializationId: 2,
+		},
+		payload: readonlyEventPayload,
+	}
+}
+
 // https://dubbo.apache.org/zh/docs/v2.7/dev/implementation/#%E8%BF%9C%E7%A8%8B%E9%80%9A%E8%AE%AF%E7%BB%86%E8%8A%82
 // hijacker
 func (proto dubboProtocol) Hijack(ctx context.Context, request api.XFrame, statusCode uint32) api.XRespFrame {
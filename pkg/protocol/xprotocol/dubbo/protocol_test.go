@@ -25,6 +25,8 @@ import (
 
 	hessian "github.com/apache/dubbo-go-hessian2"
 	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/types"
 )
 
 func Test_dubboProtocol_Hijack(t *testing.T) {
@@ -111,3 +113,46 @@ func Test_dubboProtocol_Hijack(t *testing.T) {
 		})
 	}
 }
+
+func Test_dubboProtocol_GoAway(t *testing.T) {
+	proto := &dubboProtocol{}
+	// the readonly event is not sent on an ordinary shutdown by default
+	if fr := proto.GoAway(context.TODO()); fr != nil {
+		t.Fatalf("goaway should be disabled by default: %v", fr)
+	}
+	extendConfig := map[api.ProtocolName]interface{}{
+		ProtocolName: ConfigHandler(map[string]interface{}{"enable_dubbo_goaway": true}),
+	}
+	ctx := mosnctx.WithValue(context.TODO(), types.ContextKeyProxyGeneralConfig, extendConfig)
+	fr := proto.GoAway(ctx).(*Frame)
+	fr.SetRequestId(10)
+	buf, err := proto.Encode(context.TODO(), fr)
+	if err != nil {
+		t.Fatalf("encode goaway frame failed: %v", err)
+	}
+	cmd, err := proto.Decode(context.TODO(), buf)
+	if err != nil || cmd == nil {
+		t.Fatalf("decode goaway frame failed: %v", err)
+	}
+	decoded := cmd.(*Frame)
+	if !decoded.IsGoAwayFrame() || decoded.IsHeartbeatFrame() || decoded.IsTwoWay ||
+		decoded.GetStreamType() != api.Request || decoded.GetRequestId() != 10 {
+		t.Fatalf("unexpected goaway frame: %+v", decoded.Header)
+	}
+	// the readonly event data is a hessian2 string
+	data, err := hessian.NewDecoder(decoded.payload).Decode()
+	if err != nil || data != ReadonlyEvent {
+		t.Fatalf("unexpected readonly event data: %v, %v", data, err)
+	}
+	// the heartbeat is not a goaway frame
+	heartbeat := &Frame{
+		Header: Header{
+			IsEvent:   true,
+			Direction: EventRequest,
+		},
+		payload: []byte{0x4e},
+	}
+	if heartbeat.IsGoAwayFrame() || !heartbeat.IsHeartbeatFrame() {
+		t.Fatal("heartbeat should not be a goaway frame")
+	}
+}
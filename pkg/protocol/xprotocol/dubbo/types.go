@@ -86,6 +86,11 @@ const (
 	InterfaceNameHeader        string = "interface"
 )
 
+// VarPrefixArgument is the prefix of the argument variables, the variable name is the prefix
+// and the index of the argument, followed by the fields of the POJO argument,
+// for example dubbo_request_argument_0 and dubbo_request_argument_1.user.tenantId
+const VarPrefixArgument = "dubbo_request_argument_"
+
 const (
 	EgressDubbo  string = "egress_dubbo"
 	IngressDubbo string = "ingress_dubbo"
@@ -95,6 +100,21 @@ const (
 	ResponseStatusSuccess uint16 = 0x14 // 0x14 response status
 )
 
+const (
+	// FlagReadonlyEvent is the flag of a readonly event: request, one way, event and hessian2
+	FlagReadonlyEvent byte = 0xa2
+	// ReadonlyEvent is the event data sent by the server which is going away,
+	// the clients stop sending new requests to the connection after receiving it.
+	ReadonlyEvent string = "R"
+)
+
+// readonlyEventPayload is the hessian2 encoded readonly event data
+var readonlyEventPayload = func() []byte {
+	encoder := hessian.NewEncoder()
+	encoder.Encode(ReadonlyEvent)
+	return encoder.Buffer()
+}()
+
 type dubboStatusInfo struct {
 	Status byte
 	Msg    string
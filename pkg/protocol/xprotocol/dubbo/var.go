@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+)
+
+var argumentIndex = len(VarPrefixArgument)
+
+func init() {
+	// register the argument variables, like dubbo_request_argument_0 and dubbo_request_argument_1.user.tenantId,
+	// the arguments are decoded only if the variables are referenced by the routes, the subsets or the filters.
+	variable.RegisterPrefix(VarPrefixArgument, variable.NewStringVariable(VarPrefixArgument, nil, argumentGetter, nil, 0))
+}
+
+// parseArgumentVariable parses the index of the argument and the path of the fields from the variable name
+func parseArgumentVariable(name string) (int, []string, bool) {
+	if len(name) <= argumentIndex {
+		return 0, nil, false
+	}
+	elements := strings.Split(name[argumentIndex:], ".")
+	index, err := strconv.Atoi(elements[0])
+	if err != nil || index < 0 {
+		return 0, nil, false
+	}
+	return index, elements[1:], true
+}
+
+func argumentGetter(ctx context.Context, value *variable.IndexedValue, data interface{}) (string, error) {
+	frame, ok := mosnctx.Get(ctx, types.ContextKeyDownStreamHeaders).(*Frame)
+	if !ok {
+		return variable.ValueNotFound, nil
+	}
+	name, ok := data.(string)
+	if !ok {
+		return variable.ValueNotFound, nil
+	}
+	index, path, ok := parseArgumentVariable(name)
+	if !ok {
+		return variable.ValueNotFound, nil
+	}
+	args, err := frame.Arguments()
+	if err != nil && log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[xprotocol][dubbo] get variable %s, decode arguments failed: %v", name, err)
+	}
+	if index >= len(args) {
+		return variable.ValueNotFound, nil
+	}
+	arg, found := lookupField(args[index], path)
+	if !found || arg == nil {
+		return variable.ValueNotFound, nil
+	}
+	if s, ok := arg.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(arg), nil
+}
@@ -0,0 +1,126 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dubbo
+
+import (
+	"context"
+	"testing"
+
+	hessian "github.com/apache/dubbo-go-hessian2"
+	"github.com/stretchr/testify/assert"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+	"mosn.io/pkg/buffer"
+)
+
+type testUser struct {
+	TenantId string `hessian:"tenantId"`
+	Name     string
+	Tags     []string
+}
+
+func (u *testUser) JavaClassName() string {
+	return "com.alipay.test.User"
+}
+
+func init() {
+	hessian.RegisterPOJO(&testUser{})
+}
+
+func buildArgumentsRequest(args ...interface{}) *Frame {
+	service := hessian.Service{
+		Path:    "com.alipay.test",
+		Version: "v1",
+		Method:  "testCall",
+	}
+	codec := hessian.NewHessianCodec(nil)
+	header := hessian.DubboHeader{
+		SerialID: 2,
+		Type:     hessian.PackageRequest,
+		ID:       1,
+	}
+	data, err := codec.Write(service, header, hessian.NewRequest(args, nil))
+	if err != nil {
+		return nil
+	}
+	return NewRpcRequest(nil, buffer.NewIoBufferBytes(data))
+}
+
+func TestArgumentVariables(t *testing.T) {
+	frame := buildArgumentsRequest("mosn", &testUser{TenantId: "t1", Name: "alice", Tags: []string{"a", "b"}}, int32(3))
+	assert.NotNil(t, frame)
+	ctx := mosnctx.WithValue(context.Background(), types.ContextKeyDownStreamHeaders, frame)
+
+	assert.False(t, frame.argumentsDecoded, "arguments should be decoded lazily")
+	cases := map[string]string{
+		VarPrefixArgument + "0":              "mosn",
+		VarPrefixArgument + "1.tenantId":     "t1",
+		VarPrefixArgument + "1.name":         "alice",
+		VarPrefixArgument + "1.Tags.1":       "b",
+		VarPrefixArgument + "2":              "3",
+		VarPrefixArgument + "3":              variable.ValueNotFound,
+		VarPrefixArgument + "1.unknown":      variable.ValueNotFound,
+		VarPrefixArgument + "1.Tags.2":       variable.ValueNotFound,
+		VarPrefixArgument + "0.length":       variable.ValueNotFound,
+		VarPrefixArgument + "tenantId":       variable.ValueNotFound,
+		VarPrefixArgument + "-1":             variable.ValueNotFound,
+		VarPrefixArgument + "1.tenantId.xxx": variable.ValueNotFound,
+	}
+	for name, want := range cases {
+		got, err := variable.GetString(ctx, name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+	assert.True(t, frame.argumentsDecoded)
+
+	// the cached arguments are reset if the payload is changed
+	changed := buildArgumentsRequest("changed")
+	frame.SetData(changed.GetData())
+	assert.False(t, frame.argumentsDecoded)
+	got, _ := variable.GetString(ctx, VarPrefixArgument+"0")
+	assert.Equal(t, "changed", got)
+
+	// no dubbo request in the context
+	got, _ = variable.GetString(context.Background(), VarPrefixArgument+"0")
+	assert.Equal(t, variable.ValueNotFound, got)
+}
+
+func TestArgumentsOfResponse(t *testing.T) {
+	frame := NewRpcResponse(nil, buffer.NewIoBufferBytes(buildDubboResponse(1)))
+	assert.NotNil(t, frame)
+	_, err := frame.Arguments()
+	assert.Equal(t, errNotRequest, err)
+}
+
+func TestLookupField(t *testing.T) {
+	value := map[interface{}]interface{}{
+		"user": map[string]interface{}{"id": int64(7)},
+		"list": []interface{}{"x"},
+	}
+	v, ok := lookupField(value, []string{"user", "id"})
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), v)
+	v, ok = lookupField(value, []string{"list", "0"})
+	assert.True(t, ok)
+	assert.Equal(t, "x", v)
+	_, ok = lookupField(value, []string{"user", "name"})
+	assert.False(t, ok)
+	_, ok = lookupField(nil, []string{"user"})
+	assert.False(t, ok)
+}
@@ -0,0 +1,47 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+
+	"mosn.io/api"
+)
+
+type XCodec struct {
+	mapping thriftStatusMapping
+}
+
+func (codec *XCodec) ProtocolName() api.ProtocolName {
+	return ProtocolName
+}
+
+// NewXProtocol returns a protocol for each connection, the unframed messages are scanned incrementally
+func (codec *XCodec) NewXProtocol(_ context.Context) api.XProtocol {
+	return thriftProtocol{scanner: &scanner{}}
+}
+
+func (codec *XCodec) ProtocolMatch() api.ProtocolMatch {
+	return thriftMatcher
+}
+
+func (codec *XCodec) HTTPMapping() api.HTTPMapping {
+	return codec.mapping
+}
+
+var _ api.XProtocolCodec = (*XCodec)(nil)
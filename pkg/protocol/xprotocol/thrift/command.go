@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"strconv"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+type Header struct {
+	Transport   Transport
+	Protocol    Protocol
+	MessageType byte
+	Method      string
+	SeqId       int32
+	// HeaderFlags is the flags of the THeader transport
+	HeaderFlags uint16
+	protocol.CommonHeader
+}
+
+type Frame struct {
+	Header
+	rawData []byte // raw data
+	message []byte // the thrift message without the transport
+
+	content types.IoBuffer // wrapper of message
+}
+
+var _ api.XFrame = &Frame{}
+var _ api.XRespFrame = &Frame{}
+
+// ~ XFrame
+func (r *Frame) GetRequestId() uint64 {
+	return uint64(uint32(r.SeqId))
+}
+
+// SetRequestId sets the seq id of the message, the requests are multiplexed by the seq ids
+func (r *Frame) SetRequestId(id uint64) {
+	r.SeqId = int32(uint32(id))
+	r.Set(SeqIdNameHeader, strconv.Itoa(int(r.SeqId)))
+}
+
+func (r *Frame) IsHeartbeatFrame() bool {
+	// un support
+	return false
+}
+
+// thrift use defualt timeout
+func (r *Frame) GetTimeout() int32 {
+	return 0
+}
+
+func (r *Frame) GetStreamType() api.StreamType {
+	switch r.MessageType {
+	case MessageTypeCall:
+		return api.Request
+	case MessageTypeOneway:
+		return api.RequestOneWay
+	default:
+		return api.Response
+	}
+}
+
+func (r *Frame) GetHeader() types.HeaderMap {
+	return r
+}
+
+func (r *Frame) GetData() types.IoBuffer {
+	return r.content
+}
+
+// SetData sets the thrift message, the seq id in the message is replaced when the frame is encoded
+func (r *Frame) SetData(data types.IoBuffer) {
+	r.content = data
+	r.message = data.Bytes()
+	r.rawData = nil
+}
+
+// GetStatusCode returns the message type, REPLY 2 and EXCEPTION 3
+func (r *Frame) GetStatusCode() uint32 {
+	return uint32(r.MessageType)
+}
+
+func (r *Frame) Clone() api.HeaderMap {
+	clone := &Frame{
+		rawData: make([]byte, len(r.rawData)),
+		message: make([]byte, len(r.message)),
+	}
+	clone.Header = r.Header
+	clone.CommonHeader = r.CommonHeader.Clone().(protocol.CommonHeader)
+	copy(clone.rawData, r.rawData)
+	copy(clone.message, r.message)
+	clone.content = buffer.NewIoBufferBytes(clone.message)
+	return clone
+}
+
+// isReservedHeader returns true if the header is set by the codec, rather than the THeader key/values
+func isReservedHeader(key string) bool {
+	return key == MethodNameHeader || key == SeqIdNameHeader || key == MessageTypeNameHeader
+}
@@ -0,0 +1,217 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+)
+
+// detect returns the transport and the protocol of the data
+func detect(data []byte) (Transport, Protocol, api.MatchResult) {
+	if len(data) < 2 {
+		return 0, 0, api.MatchAgain
+	}
+	if proto, ok := detectProtocol(data); ok {
+		return TransportUnframed, proto, api.MatchSuccess
+	}
+	if len(data) < FrameSizeLen+2 {
+		return 0, 0, api.MatchAgain
+	}
+	size := binary.BigEndian.Uint32(data)
+	if size == 0 || size > MaxFrameSize {
+		return 0, 0, api.MatchFailed
+	}
+	body := data[FrameSizeLen:]
+	if binary.BigEndian.Uint16(body) == headerMagic {
+		// the protocol is in the header
+		return TransportHeader, 0, api.MatchSuccess
+	}
+	if proto, ok := detectProtocol(body); ok {
+		return TransportFramed, proto, api.MatchSuccess
+	}
+	return 0, 0, api.MatchFailed
+}
+
+// detectProtocol checks the first two bytes of the message
+func detectProtocol(data []byte) (Protocol, bool) {
+	switch {
+	case data[0] == 0x80 && data[1] == 0x01:
+		return ProtocolBinary, true
+	case data[0] == compactProtocolID && data[1]&compactVersionMask == compactVersion:
+		return ProtocolCompact, true
+	}
+	return 0, false
+}
+
+// decodeFrame decodes a frame from the data, the scanner keeps the progress of the
+// unframed message between the calls, a new scanner is used if it is nil.
+func decodeFrame(ctx context.Context, data types.IoBuffer, s *scanner) (interface{}, error) {
+	dataBytes := data.Bytes()
+	transport, proto, result := detect(dataBytes)
+	switch result {
+	case api.MatchAgain:
+		return nil, nil
+	case api.MatchFailed:
+		return nil, ErrInvalidMessage
+	}
+	if s == nil {
+		s = &scanner{}
+	}
+
+	frame := &Frame{
+		Header: Header{
+			Transport:    transport,
+			Protocol:     proto,
+			CommonHeader: protocol.CommonHeader{},
+		},
+	}
+	var (
+		frameLen     int
+		messageStart int
+	)
+	if transport == TransportUnframed {
+		// the unframed message is scanned to find the end of it
+		n, err := s.scan(dataBytes, proto)
+		if err == errNeedMore {
+			return nil, nil
+		}
+		s.reset()
+		if err != nil {
+			return nil, err
+		}
+		frameLen = n
+	} else {
+		frameLen = FrameSizeLen + int(binary.BigEndian.Uint32(dataBytes))
+		if len(dataBytes) < frameLen {
+			return nil, nil
+		}
+		messageStart = FrameSizeLen
+	}
+
+	// the data is reused after drained, so the frame is copied
+	frame.rawData = make([]byte, frameLen)
+	copy(frame.rawData, dataBytes[:frameLen])
+	if transport == TransportHeader {
+		offset, err := decodeTHeader(frame, frame.rawData[FrameSizeLen:])
+		if err != nil {
+			return nil, err
+		}
+		messageStart += offset
+	}
+	frame.message = frame.rawData[messageStart:]
+
+	m, err := readMessageBegin(&reader{data: frame.message}, frame.Protocol)
+	if err != nil {
+		return nil, fmt.Errorf("[xprotocol][thrift] decode message begin fail: %v", err)
+	}
+	frame.Method = m.name
+	frame.MessageType = m.typ
+	frame.SeqId = m.seqId
+	frame.Set(MethodNameHeader, m.name)
+	frame.Set(SeqIdNameHeader, strconv.Itoa(int(m.seqId)))
+	frame.Set(MessageTypeNameHeader, strconv.Itoa(int(m.typ)))
+	frame.content = buffer.NewIoBufferBytes(frame.message)
+
+	switch frame.GetStreamType() {
+	case api.Request, api.RequestOneWay:
+		// notice: read-only!!! do not modify the raw data!!!
+		variable.Set(ctx, types.VarRequestRawData, frame.rawData)
+	case api.Response:
+		// notice: read-only!!! do not modify the raw data!!!
+		variable.Set(ctx, types.VarResponseRawData, frame.rawData)
+	}
+
+	data.Drain(frameLen)
+	return frame, nil
+}
+
+// decodeTHeader decodes the THeader, and returns the offset of the message in the frame.
+//
+//	+--------+--------+--------+--------+--------+--------+--------+--------+
+//	| magic 0x0fff    | flags           | seq id                            |
+//	+--------+--------+--------+--------+--------+--------+--------+--------+
+//	| header size/4   | protocol id (varint) | transforms (varint) | infos  |
+//	+--------+--------+--------+--------+--------+--------+--------+--------+
+//
+// The key/value infos are exposed as the headers, the transforms are not supported.
+func decodeTHeader(frame *Frame, body []byte) (int, error) {
+	if len(body) < headerFixedLen {
+		return 0, ErrInvalidMessage
+	}
+	frame.HeaderFlags = binary.BigEndian.Uint16(body[2:])
+	end := headerFixedLen + int(binary.BigEndian.Uint16(body[8:]))*4
+	if end > len(body) {
+		return 0, ErrInvalidMessage
+	}
+	r := &reader{data: body[:end], pos: headerFixedLen}
+	protoID, err := r.readUvarint()
+	if err != nil {
+		return 0, ErrInvalidMessage
+	}
+	switch protoID {
+	case headerProtocolBinary:
+		frame.Protocol = ProtocolBinary
+	case headerProtocolCompact:
+		frame.Protocol = ProtocolCompact
+	default:
+		return 0, fmt.Errorf("[xprotocol][thrift] unsupported THeader protocol: %d", protoID)
+	}
+	transforms, err := r.readUvarint()
+	if err != nil {
+		return 0, ErrInvalidMessage
+	}
+	if transforms != 0 {
+		return 0, fmt.Errorf("[xprotocol][thrift] THeader transforms are not supported")
+	}
+	for r.pos < end {
+		infoType, err := r.readUvarint()
+		if err != nil {
+			return 0, ErrInvalidMessage
+		}
+		// the padding, or the unknown info type that can not be skipped
+		if infoType != headerInfoKeyValue {
+			break
+		}
+		count, err := r.readUvarint()
+		if err != nil {
+			return 0, ErrInvalidMessage
+		}
+		for i := uint64(0); i < count; i++ {
+			key, err := r.readVarString()
+			if err != nil {
+				return 0, ErrInvalidMessage
+			}
+			value, err := r.readVarString()
+			if err != nil {
+				return 0, ErrInvalidMessage
+			}
+			frame.Set(key, value)
+		}
+	}
+	return end, nil
+}
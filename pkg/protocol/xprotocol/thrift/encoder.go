@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/types"
+)
+
+func encodeFrame(ctx context.Context, frame *Frame) (types.IoBuffer, error) {
+	// the seq id in the message is replaced by the seq id of the frame
+	m, err := readMessageBegin(&reader{data: frame.message}, frame.Protocol)
+	if err != nil {
+		return nil, err
+	}
+	w := &writer{buf: make([]byte, 0, len(frame.message)+64)}
+	switch frame.Transport {
+	case TransportFramed:
+		w.writeI32(0)
+	case TransportHeader:
+		if err := writeTHeader(w, frame); err != nil {
+			return nil, err
+		}
+	}
+	w.buf = append(w.buf, frame.message[:m.seqIdStart]...)
+	w.writeSeqId(frame.Protocol, frame.SeqId)
+	w.buf = append(w.buf, frame.message[m.seqIdEnd:]...)
+
+	if frame.Transport != TransportUnframed {
+		binary.BigEndian.PutUint32(w.buf, uint32(len(w.buf)-FrameSizeLen))
+	}
+	if frame.Transport == TransportHeader {
+		binary.BigEndian.PutUint32(w.buf[FrameSizeLen+4:], uint32(frame.SeqId))
+	}
+	return buffer.NewIoBufferBytes(w.buf), nil
+}
+
+// writeTHeader writes the frame size placeholder and the THeader,
+// the headers except the reserved ones are written as the key/value infos.
+// the header size is written in 4 bytes words as uint16, so the larger headers are rejected.
+func writeTHeader(w *writer, frame *Frame) error {
+	w.writeI32(0)
+	w.writeI16(headerMagic)
+	w.writeI16(int16(frame.HeaderFlags))
+	w.writeI32(frame.SeqId)
+	sizeIdx := len(w.buf)
+	w.writeI16(0)
+
+	headerStart := len(w.buf)
+	if frame.Protocol == ProtocolCompact {
+		w.writeUvarint(headerProtocolCompact)
+	} else {
+		w.writeUvarint(headerProtocolBinary)
+	}
+	// no transforms
+	w.writeUvarint(0)
+	keys := make([]string, 0, len(frame.CommonHeader))
+	for key := range frame.CommonHeader {
+		if !isReservedHeader(key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		w.writeUvarint(headerInfoKeyValue)
+		w.writeUvarint(uint64(len(keys)))
+		for _, key := range keys {
+			w.writeVarString(key)
+			w.writeVarString(frame.CommonHeader[key])
+		}
+	}
+	// the header is padded to 4 bytes
+	for (len(w.buf)-headerStart)%4 != 0 {
+		w.writeByte(0)
+	}
+	words := (len(w.buf) - headerStart) / 4
+	if words > math.MaxUint16 {
+		return ErrHeaderTooLarge
+	}
+	binary.BigEndian.PutUint16(w.buf[sizeIdx:], uint16(words))
+	return nil
+}
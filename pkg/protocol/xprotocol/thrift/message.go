@@ -0,0 +1,490 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"encoding/binary"
+)
+
+// reader reads the thrift messages from the bytes, errNeedMore is returned if the bytes are not enough
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	// a message larger than the max frame size is invalid, even if it is unframed
+	if n < 0 || n > MaxFrameSize || r.pos+n > MaxFrameSize {
+		return nil, ErrInvalidMessage
+	}
+	if len(r.data)-r.pos < n {
+		return nil, errNeedMore
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) readByte() (byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *reader) readI16() (int16, error) {
+	b, err := r.next(2)
+	if err != nil {
+		return 0, err
+	}
+	return int16(binary.BigEndian.Uint16(b)), nil
+}
+
+func (r *reader) readI32() (int32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.BigEndian.Uint32(b)), nil
+}
+
+func (r *reader) readUvarint() (uint64, error) {
+	var x uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		x |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return x, nil
+		}
+	}
+	return 0, ErrInvalidMessage
+}
+
+// readVarString reads the string with the varint length, used by the compact protocol and the THeader
+func (r *reader) readVarString() (string, error) {
+	n, err := r.readUvarint()
+	if err != nil {
+		return "", err
+	}
+	if n > MaxFrameSize {
+		return "", ErrInvalidMessage
+	}
+	b, err := r.next(int(n))
+	return string(b), err
+}
+
+// messageBegin is the beginning of a thrift message
+type messageBegin struct {
+	name  string
+	typ   byte
+	seqId int32
+	// the position of the seq id in the message
+	seqIdStart int
+	seqIdEnd   int
+}
+
+func readMessageBegin(r *reader, proto Protocol) (*messageBegin, error) {
+	m := &messageBegin{}
+	switch proto {
+	case ProtocolBinary:
+		version, err := r.readI32()
+		if err != nil {
+			return nil, err
+		}
+		// only the strict binary protocol is supported
+		if uint32(version)&binaryVersionMask != binaryVersion1 {
+			return nil, ErrInvalidMessage
+		}
+		m.typ = byte(version)
+		n, err := r.readI32()
+		if err != nil {
+			return nil, err
+		}
+		name, err := r.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		m.name = string(name)
+		m.seqIdStart = r.pos
+		if m.seqId, err = r.readI32(); err != nil {
+			return nil, err
+		}
+		m.seqIdEnd = r.pos
+	case ProtocolCompact:
+		id, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		versionAndType, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if id != compactProtocolID || versionAndType&compactVersionMask != compactVersion {
+			return nil, ErrInvalidMessage
+		}
+		m.typ = versionAndType >> compactTypeShift
+		m.seqIdStart = r.pos
+		seqId, err := r.readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		m.seqId = int32(uint32(seqId))
+		m.seqIdEnd = r.pos
+		if m.name, err = r.readVarString(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidMessage
+	}
+	if m.typ < MessageTypeCall || m.typ > MessageTypeOneway {
+		return nil, ErrInvalidMessage
+	}
+	return m, nil
+}
+
+// scanner finds the end of an unframed message by skipping the values of the message.
+// The scanned position and the nested structs and collections are kept when the data
+// is not enough, so the message is scanned once no matter how many reads it takes.
+type scanner struct {
+	// pos is the end of the scanned values
+	pos   int
+	begun bool
+	stack []scanState
+}
+
+// scanState is a struct, or a collection with the remaining values
+type scanState struct {
+	isStruct  bool
+	remaining uint64
+	types     [2]byte
+	ntypes    int
+	next      int
+}
+
+func (s *scanner) reset() {
+	s.pos = 0
+	s.begun = false
+	s.stack = s.stack[:0]
+}
+
+// scan returns the length of the message, errNeedMore is returned if the message is incomplete,
+// and the scanning is continued with the same data appended by the new bytes.
+func (s *scanner) scan(data []byte, proto Protocol) (int, error) {
+	r := &reader{data: data, pos: s.pos}
+	if !s.begun {
+		if _, err := readMessageBegin(r, proto); err != nil {
+			return 0, err
+		}
+		s.begun = true
+		s.stack = append(s.stack, scanState{isStruct: true})
+		s.pos = r.pos
+	}
+	for len(s.stack) > 0 {
+		var err error
+		if proto == ProtocolCompact {
+			err = s.stepCompact(r)
+		} else {
+			err = s.step(r)
+		}
+		if err != nil {
+			return 0, err
+		}
+		s.pos = r.pos
+	}
+	return s.pos, nil
+}
+
+func (s *scanner) push(state scanState) error {
+	if len(s.stack) >= maxSkipDepth {
+		return ErrInvalidMessage
+	}
+	s.stack = append(s.stack, state)
+	return nil
+}
+
+func (s *scanner) pushCollection(size uint64, types ...byte) error {
+	if size > MaxFrameSize {
+		return ErrInvalidMessage
+	}
+	if size == 0 {
+		return nil
+	}
+	state := scanState{remaining: size * uint64(len(types)), ntypes: len(types)}
+	copy(state.types[:], types)
+	return s.push(state)
+}
+
+// step skips a field of the struct or an element of the collection on the top of the stack,
+// the nested struct or collection is pushed to the stack.
+func (s *scanner) step(r *reader) error {
+	top := len(s.stack) - 1
+	state := &s.stack[top]
+	var typ byte
+	if state.isStruct {
+		fieldType, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		if fieldType == typeStop {
+			s.stack = s.stack[:top]
+			return nil
+		}
+		if _, err := r.readI16(); err != nil {
+			return err
+		}
+		typ = fieldType
+	} else {
+		if state.remaining == 0 {
+			s.stack = s.stack[:top]
+			return nil
+		}
+		typ = state.types[state.next]
+	}
+	if err := s.value(r, typ); err != nil {
+		return err
+	}
+	s.stack[top].advance()
+	return nil
+}
+
+func (s *scanner) value(r *reader, typ byte) error {
+	var err error
+	switch typ {
+	case typeBool, typeByte:
+		_, err = r.next(1)
+	case typeI16:
+		_, err = r.next(2)
+	case typeI32:
+		_, err = r.next(4)
+	case typeI64, typeDouble:
+		_, err = r.next(8)
+	case typeUUID:
+		_, err = r.next(16)
+	case typeString:
+		var n int32
+		if n, err = r.readI32(); err == nil {
+			_, err = r.next(int(n))
+		}
+	case typeStruct:
+		return s.push(scanState{isStruct: true})
+	case typeMap:
+		var kv []byte
+		if kv, err = r.next(2); err != nil {
+			return err
+		}
+		var size int32
+		if size, err = r.readI32(); err != nil {
+			return err
+		}
+		if size < 0 {
+			return ErrInvalidMessage
+		}
+		return s.pushCollection(uint64(size), kv...)
+	case typeSet, typeList:
+		var elemType byte
+		if elemType, err = r.readByte(); err != nil {
+			return err
+		}
+		var size int32
+		if size, err = r.readI32(); err != nil {
+			return err
+		}
+		if size < 0 {
+			return ErrInvalidMessage
+		}
+		return s.pushCollection(uint64(size), elemType)
+	default:
+		return ErrInvalidMessage
+	}
+	return err
+}
+
+func (s *scanner) stepCompact(r *reader) error {
+	top := len(s.stack) - 1
+	state := &s.stack[top]
+	var typ byte
+	if state.isStruct {
+		header, err := r.readByte()
+		if err != nil {
+			return err
+		}
+		if header == typeStop {
+			s.stack = s.stack[:top]
+			return nil
+		}
+		// the field id is written if the delta is zero
+		if header>>4 == 0 {
+			if _, err := r.readUvarint(); err != nil {
+				return err
+			}
+		}
+		typ = header & 0x0f
+		// the value of the bool field is in the field type
+		if typ == compactTypeBoolTrue || typ == compactTypeBoolFalse {
+			return nil
+		}
+	} else {
+		if state.remaining == 0 {
+			s.stack = s.stack[:top]
+			return nil
+		}
+		typ = state.types[state.next]
+	}
+	if err := s.valueCompact(r, typ); err != nil {
+		return err
+	}
+	s.stack[top].advance()
+	return nil
+}
+
+func (s *scanner) valueCompact(r *reader, typ byte) error {
+	var err error
+	switch typ {
+	case compactTypeBoolTrue, compactTypeBoolFalse, compactTypeByte:
+		// the bool elements of the collections are one byte
+		_, err = r.next(1)
+	case compactTypeI16, compactTypeI32, compactTypeI64:
+		_, err = r.readUvarint()
+	case compactTypeDouble:
+		_, err = r.next(8)
+	case compactTypeUUID:
+		_, err = r.next(16)
+	case compactTypeBinary:
+		var n uint64
+		if n, err = r.readUvarint(); err == nil {
+			if n > MaxFrameSize {
+				return ErrInvalidMessage
+			}
+			_, err = r.next(int(n))
+		}
+	case compactTypeStruct:
+		return s.push(scanState{isStruct: true})
+	case compactTypeList, compactTypeSet:
+		var header byte
+		if header, err = r.readByte(); err != nil {
+			return err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = r.readUvarint(); err != nil {
+				return err
+			}
+		}
+		return s.pushCollection(size, header&0x0f)
+	case compactTypeMap:
+		var size uint64
+		if size, err = r.readUvarint(); err != nil || size == 0 {
+			return err
+		}
+		var kv byte
+		if kv, err = r.readByte(); err != nil {
+			return err
+		}
+		return s.pushCollection(size, kv>>4, kv&0x0f)
+	default:
+		return ErrInvalidMessage
+	}
+	return err
+}
+
+// advance moves to the next value of the collection, the struct fields are ended by the stop field
+func (state *scanState) advance() {
+	if state.isStruct {
+		return
+	}
+	state.remaining--
+	state.next = (state.next + 1) % state.ntypes
+}
+
+// writer writes the thrift messages and the THeader
+type writer struct {
+	buf []byte
+}
+
+func (w *writer) writeByte(b byte) {
+	w.buf = append(w.buf, b)
+}
+
+func (w *writer) writeI16(v int16) {
+	w.buf = append(w.buf, byte(uint16(v)>>8), byte(v))
+}
+
+func (w *writer) writeI32(v int32) {
+	w.buf = append(w.buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(w.buf[len(w.buf)-4:], uint32(v))
+}
+
+func (w *writer) writeUvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf = append(w.buf, b[:n]...)
+}
+
+func (w *writer) writeVarString(s string) {
+	w.writeUvarint(uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+// writeSeqId writes the seq id in the encoding of the protocol
+func (w *writer) writeSeqId(proto Protocol, seqId int32) {
+	if proto == ProtocolCompact {
+		w.writeUvarint(uint64(uint32(seqId)))
+		return
+	}
+	w.writeI32(seqId)
+}
+
+func (w *writer) writeMessageBegin(proto Protocol, name string, typ byte, seqId int32) {
+	if proto == ProtocolCompact {
+		w.writeByte(compactProtocolID)
+		w.writeByte(typ<<compactTypeShift | compactVersion)
+		w.writeSeqId(proto, seqId)
+		w.writeVarString(name)
+		return
+	}
+	w.writeI32(int32(binaryVersion1 | uint32(typ)))
+	w.writeI32(int32(len(name)))
+	w.buf = append(w.buf, name...)
+	w.writeSeqId(proto, seqId)
+}
+
+// writeApplicationException writes the TApplicationException struct:
+// 1: string message, 2: i32 type
+func (w *writer) writeApplicationException(proto Protocol, msg string, typ int32) {
+	if proto == ProtocolCompact {
+		w.writeByte(1<<4 | compactTypeBinary)
+		w.writeVarString(msg)
+		w.writeByte(1<<4 | compactTypeI32)
+		// zigzag
+		w.writeUvarint(uint64(uint32((typ << 1) ^ (typ >> 31))))
+		w.writeByte(typeStop)
+		return
+	}
+	w.writeByte(typeString)
+	w.writeI16(1)
+	w.writeI32(int32(len(msg)))
+	w.buf = append(w.buf, msg...)
+	w.writeByte(typeI32)
+	w.writeI16(2)
+	w.writeI32(typ)
+	w.writeByte(typeStop)
+}
@@ -0,0 +1,136 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+)
+
+/**
+ * Apache Thrift codec, the transports and the protocols are detected from the data.
+ *
+ * Transports:
+ *   unframed: | message |
+ *   framed:   | frame size (4 bytes) | message |
+ *   THeader:  | frame size (4 bytes) | magic 0x0fff | flags | seq id | header size | header | message |
+ *
+ * Protocols:
+ *   binary (strict): | 0x8001 | 0x00 | message type | name length (4 bytes) | name | seq id (4 bytes) | body |
+ *   compact:         | 0x82 | message type << 5 | version | seq id (varint) | name length (varint) | name | body |
+ *
+ * The requests are multiplexed by the seq ids, the method name, the seq id and the
+ * key/values of the THeader are exposed as the headers.
+ */
+type thriftProtocol struct {
+	// scanner keeps the progress of the unframed message, it is created for each connection
+	scanner *scanner
+}
+
+func (proto thriftProtocol) Name() types.ProtocolName {
+	return ProtocolName
+}
+
+func (proto thriftProtocol) Encode(ctx context.Context, model interface{}) (types.IoBuffer, error) {
+	if frame, ok := model.(*Frame); ok {
+		return encodeFrame(ctx, frame)
+	}
+	log.Proxy.Errorf(ctx, "[protocol][thrift] encode with unknown command : %+v", model)
+	return nil, api.ErrUnknownType
+}
+
+func (proto thriftProtocol) Decode(ctx context.Context, data types.IoBuffer) (interface{}, error) {
+	return decodeFrame(ctx, data, proto.scanner)
+}
+
+// heartbeater
+func (proto thriftProtocol) Trigger(ctx context.Context, requestId uint64) api.XFrame {
+	// not support
+	return nil
+}
+
+// Reply replies an empty result, the heartbeat is not supported by thrift
+func (proto thriftProtocol) Reply(ctx context.Context, request api.XFrame) api.XRespFrame {
+	frame := request.(*Frame)
+	w := &writer{}
+	w.writeMessageBegin(frame.Protocol, frame.Method, MessageTypeReply, frame.SeqId)
+	w.writeByte(typeStop)
+	return newResponse(frame, MessageTypeReply, w.buf)
+}
+
+// Hijack replies a TApplicationException
+func (proto thriftProtocol) Hijack(ctx context.Context, request api.XFrame, statusCode uint32) api.XRespFrame {
+	frame := request.(*Frame)
+	status, ok := thriftMosnStatusMap[int(statusCode)]
+	if !ok {
+		status = thriftMosnStatusMap[api.UnknownCode]
+	}
+	w := &writer{}
+	w.writeMessageBegin(frame.Protocol, frame.Method, MessageTypeException, frame.SeqId)
+	w.writeApplicationException(frame.Protocol, status.Msg, status.Type)
+	return newResponse(frame, MessageTypeException, w.buf)
+}
+
+// newResponse creates the response of the request, the transport and the protocol are the same as the request
+func newResponse(request *Frame, typ byte, message []byte) *Frame {
+	response := &Frame{
+		Header: Header{
+			Transport:    request.Transport,
+			Protocol:     request.Protocol,
+			MessageType:  typ,
+			Method:       request.Method,
+			SeqId:        request.SeqId,
+			HeaderFlags:  request.HeaderFlags,
+			CommonHeader: protocol.CommonHeader{},
+		},
+		message: message,
+		content: buffer.NewIoBufferBytes(message),
+	}
+	request.Range(func(k, v string) bool {
+		response.Set(k, v)
+		return true
+	})
+	response.Set(MessageTypeNameHeader, strconv.Itoa(int(typ)))
+	return response
+}
+
+func (proto thriftProtocol) Mapping(httpStatusCode uint32) uint32 {
+	return httpStatusCode
+}
+
+// PoolMode returns whether pingpong or multiplex
+func (proto thriftProtocol) PoolMode() api.PoolMode {
+	return api.Multiplex
+}
+
+func (proto thriftProtocol) EnableWorkerPool() bool {
+	return true
+}
+
+// GenerateRequestID generates the seq ids, the seq id is 32 bits
+func (proto thriftProtocol) GenerateRequestID(streamID *uint64) uint64 {
+	return uint64(uint32(atomic.AddUint64(streamID, 1)))
+}
@@ -0,0 +1,278 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"mosn.io/api"
+	"mosn.io/pkg/buffer"
+
+	"mosn.io/mosn/pkg/protocol"
+)
+
+// buildMessage builds a message with the body:
+// 1: string, 2: list<i32>, 3: map<string, bool>, 4: struct {1: i64, 2: bool}
+func buildMessage(proto Protocol, typ byte, name string, seqId int32) []byte {
+	w := &writer{}
+	w.writeMessageBegin(proto, name, typ, seqId)
+	if proto == ProtocolCompact {
+		w.writeByte(1<<4 | compactTypeBinary)
+		w.writeVarString("mosn")
+		w.writeByte(1<<4 | compactTypeList)
+		w.writeByte(2<<4 | compactTypeI32)
+		w.writeUvarint(2)
+		w.writeUvarint(4)
+		w.writeByte(1<<4 | compactTypeMap)
+		w.writeUvarint(1)
+		w.writeByte(compactTypeBinary<<4 | compactTypeBoolTrue)
+		w.writeVarString("a")
+		w.writeByte(1)
+		w.writeByte(1<<4 | compactTypeStruct)
+		w.writeByte(1<<4 | compactTypeI64)
+		w.writeUvarint(14)
+		w.writeByte(1<<4 | compactTypeBoolTrue)
+		w.writeByte(typeStop)
+		w.writeByte(typeStop)
+		return w.buf
+	}
+	w.writeByte(typeString)
+	w.writeI16(1)
+	w.writeI32(4)
+	w.buf = append(w.buf, "mosn"...)
+	w.writeByte(typeList)
+	w.writeI16(2)
+	w.writeByte(typeI32)
+	w.writeI32(2)
+	w.writeI32(1)
+	w.writeI32(2)
+	w.writeByte(typeMap)
+	w.writeI16(3)
+	w.writeByte(typeString)
+	w.writeByte(typeBool)
+	w.writeI32(1)
+	w.writeI32(1)
+	w.buf = append(w.buf, "a"...)
+	w.writeByte(1)
+	w.writeByte(typeStruct)
+	w.writeI16(4)
+	w.writeByte(typeI64)
+	w.writeI16(1)
+	w.buf = append(w.buf, 0, 0, 0, 0, 0, 0, 0, 7)
+	w.writeByte(typeBool)
+	w.writeI16(2)
+	w.writeByte(1)
+	w.writeByte(typeStop)
+	w.writeByte(typeStop)
+	return w.buf
+}
+
+// messageBody returns the message after the seq id
+func messageBody(proto Protocol, message []byte) []byte {
+	m, _ := readMessageBegin(&reader{data: message}, proto)
+	return message[m.seqIdEnd:]
+}
+
+func buildFrame(t *testing.T, transport Transport, proto Protocol, typ byte, seqId int32) []byte {
+	frame := &Frame{
+		Header: Header{
+			Transport:    transport,
+			Protocol:     proto,
+			SeqId:        seqId,
+			CommonHeader: protocol.CommonHeader{},
+		},
+		message: buildMessage(proto, typ, "echo", seqId),
+	}
+	if transport == TransportHeader {
+		frame.Set("tenant", "alipay")
+		frame.Set("caller", "mosn")
+	}
+	buf, err := encodeFrame(context.Background(), frame)
+	assert.Nil(t, err)
+	return buf.Bytes()
+}
+
+func TestCodec(t *testing.T) {
+	// the unframed messages are scanned incrementally by the protocol of the connection
+	proto := (&XCodec{}).NewXProtocol(context.Background())
+	for _, transport := range []Transport{TransportUnframed, TransportFramed, TransportHeader} {
+		for _, p := range []Protocol{ProtocolBinary, ProtocolCompact} {
+			data := buildFrame(t, transport, p, MessageTypeCall, 7)
+			assert.Equal(t, api.MatchSuccess, thriftMatcher(data))
+
+			// the incomplete data is not decoded
+			for i := 0; i < len(data); i++ {
+				cmd, err := proto.Decode(context.Background(), buffer.NewIoBufferBytes(data[:i]))
+				assert.Nil(t, err, "transport %d protocol %d length %d", transport, p, i)
+				assert.Nil(t, cmd, "transport %d protocol %d length %d", transport, p, i)
+			}
+
+			next := buildFrame(t, transport, p, MessageTypeOneway, 8)
+			buf := buffer.NewIoBufferBytes(append(append([]byte{}, data...), next...))
+			cmd, err := proto.Decode(context.Background(), buf)
+			assert.Nil(t, err)
+			frame := cmd.(*Frame)
+			assert.Equal(t, transport, frame.Transport)
+			assert.Equal(t, p, frame.Protocol)
+			assert.Equal(t, "echo", frame.Method)
+			assert.Equal(t, uint64(7), frame.GetRequestId())
+			assert.Equal(t, api.Request, frame.GetStreamType())
+			method, _ := frame.Get(MethodNameHeader)
+			assert.Equal(t, "echo", method)
+			seqId, _ := frame.Get(SeqIdNameHeader)
+			assert.Equal(t, "7", seqId)
+			if transport == TransportHeader {
+				tenant, _ := frame.Get("tenant")
+				assert.Equal(t, "alipay", tenant)
+			}
+			assert.Equal(t, len(next), buf.Len())
+
+			cmd, err = proto.Decode(context.Background(), buf)
+			assert.Nil(t, err)
+			assert.Equal(t, api.RequestOneWay, cmd.(*Frame).GetStreamType())
+
+			// the seq id is replaced
+			frame.SetRequestId(math.MaxUint32)
+			encoded, err := proto.Encode(context.Background(), frame)
+			assert.Nil(t, err)
+			cmd, err = proto.Decode(context.Background(), encoded)
+			assert.Nil(t, err)
+			reencoded := cmd.(*Frame)
+			assert.Equal(t, uint64(math.MaxUint32), reencoded.GetRequestId())
+			assert.Equal(t, int32(-1), reencoded.SeqId)
+			assert.Equal(t, "echo", reencoded.Method)
+			if transport == TransportHeader {
+				caller, _ := reencoded.Get("caller")
+				assert.Equal(t, "mosn", caller)
+			}
+			assert.Equal(t, messageBody(p, buildMessage(p, MessageTypeCall, "echo", 7)), messageBody(p, reencoded.message))
+		}
+	}
+}
+
+func TestHijack(t *testing.T) {
+	proto := thriftProtocol{}
+	mapping := thriftStatusMapping{}
+	for _, p := range []Protocol{ProtocolBinary, ProtocolCompact} {
+		cmd, err := proto.Decode(context.Background(), buffer.NewIoBufferBytes(buildFrame(t, TransportHeader, p, MessageTypeCall, 9)))
+		assert.Nil(t, err)
+		request := cmd.(*Frame)
+
+		hijack := proto.Hijack(context.Background(), request, api.TimeoutExceptionCode)
+		data, err := proto.Encode(context.Background(), hijack)
+		assert.Nil(t, err)
+		cmd, err = proto.Decode(context.Background(), data)
+		assert.Nil(t, err)
+		response := cmd.(*Frame)
+		assert.Equal(t, api.Response, response.GetStreamType())
+		assert.Equal(t, uint32(MessageTypeException), response.GetStatusCode())
+		assert.Equal(t, uint64(9), response.GetRequestId())
+		assert.Equal(t, TransportHeader, response.Transport)
+		code, err := mapping.MappingHeaderStatusCode(context.Background(), response)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusInternalServerError, code)
+
+		reply := proto.Reply(context.Background(), request)
+		data, err = proto.Encode(context.Background(), reply)
+		assert.Nil(t, err)
+		cmd, err = proto.Decode(context.Background(), data)
+		assert.Nil(t, err)
+		code, _ = mapping.MappingHeaderStatusCode(context.Background(), cmd.(*Frame))
+		assert.Equal(t, http.StatusOK, code)
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	cases := map[string]api.MatchResult{
+		"":                                 api.MatchAgain,
+		"\x80":                             api.MatchAgain,
+		"\x00\x00\x00\x10":                 api.MatchAgain,
+		"GET / HTTP/1.1\r\n":               api.MatchFailed,
+		"\xda\xbb\xc2\x00\x00\x00\x00\x00": api.MatchFailed,
+		"\x00\x00\x00\x10\xda\xbc":         api.MatchFailed,
+		"\x01\x01\x00\x01\x01\x00":         api.MatchFailed,
+		"\x00\x00\x00\x00\x80\x01":         api.MatchFailed,
+	}
+	for data, want := range cases {
+		assert.Equal(t, want, thriftMatcher([]byte(data)), "%q", data)
+	}
+}
+
+func TestInvalidMessage(t *testing.T) {
+	data := buildFrame(t, TransportFramed, ProtocolBinary, MessageTypeCall, 1)
+	// unknown message type
+	data[FrameSizeLen+3] = 9
+	_, err := decodeFrame(context.Background(), buffer.NewIoBufferBytes(data), nil)
+	assert.NotNil(t, err)
+
+	// invalid field type of the unframed message
+	message := buildMessage(ProtocolBinary, MessageTypeCall, "echo", 1)
+	message[16] = 0xff
+	_, err = decodeFrame(context.Background(), buffer.NewIoBufferBytes(message), nil)
+	assert.Equal(t, ErrInvalidMessage, err)
+
+	// the unframed message larger than the max frame size
+	w := &writer{}
+	w.writeMessageBegin(ProtocolBinary, "echo", MessageTypeCall, 1)
+	w.writeByte(typeString)
+	w.writeI16(1)
+	w.writeI32(MaxFrameSize - 8)
+	_, err = decodeFrame(context.Background(), buffer.NewIoBufferBytes(w.buf), nil)
+	assert.Equal(t, ErrInvalidMessage, err)
+}
+
+func TestScanResume(t *testing.T) {
+	for _, p := range []Protocol{ProtocolBinary, ProtocolCompact} {
+		message := buildMessage(p, MessageTypeCall, "echo", 1)
+		s := &scanner{}
+		for i := 1; i < len(message); i++ {
+			_, err := s.scan(message[:i], p)
+			assert.Equal(t, errNeedMore, err)
+			// the complete values are not scanned again
+			assert.True(t, s.pos <= i)
+		}
+		n, err := s.scan(message, p)
+		assert.Nil(t, err)
+		assert.Equal(t, len(message), n)
+	}
+}
+
+func TestTHeaderTooLarge(t *testing.T) {
+	frame := &Frame{
+		Header: Header{
+			Transport:    TransportHeader,
+			Protocol:     ProtocolBinary,
+			CommonHeader: protocol.CommonHeader{},
+		},
+		message: buildMessage(ProtocolBinary, MessageTypeCall, "echo", 1),
+	}
+	frame.Set("large", strings.Repeat("a", 4*math.MaxUint16))
+	_, err := encodeFrame(context.Background(), frame)
+	assert.Equal(t, ErrHeaderTooLarge, err)
+}
+
+func TestGenerateRequestID(t *testing.T) {
+	var streamID uint64 = math.MaxUint32
+	assert.Equal(t, uint64(0), thriftProtocol{}.GenerateRequestID(&streamID))
+	assert.Equal(t, uint64(1), thriftProtocol{}.GenerateRequestID(&streamID))
+}
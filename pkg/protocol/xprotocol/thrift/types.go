@@ -0,0 +1,156 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package thrift
+
+import (
+	"errors"
+
+	"mosn.io/api"
+)
+
+const (
+	ProtocolName api.ProtocolName = "thrift"
+)
+
+// Transport is the transport of the thrift messages
+type Transport byte
+
+const (
+	// TransportUnframed writes the messages directly
+	TransportUnframed Transport = iota
+	// TransportFramed writes the 4 bytes frame size before the messages
+	TransportFramed
+	// TransportHeader is the THeader transport, the frame carries the key/value headers
+	TransportHeader
+)
+
+// Protocol is the serialization protocol of the thrift messages
+type Protocol byte
+
+const (
+	ProtocolBinary Protocol = iota
+	ProtocolCompact
+)
+
+// the message types
+const (
+	MessageTypeCall      byte = 1
+	MessageTypeReply     byte = 2
+	MessageTypeException byte = 3
+	MessageTypeOneway    byte = 4
+)
+
+// the headers of the frame, the key/values of the THeader transport are exposed as headers too
+const (
+	MethodNameHeader      string = "method"
+	SeqIdNameHeader       string = "seqId"
+	MessageTypeNameHeader string = "messageType"
+)
+
+const (
+	FrameSizeLen = 4
+	// MaxFrameSize is the max size of a frame, the larger frames are treated as invalid data
+	MaxFrameSize = 16 * 1024 * 1024
+
+	binaryVersionMask = 0xffff0000
+	binaryVersion1    = 0x80010000
+
+	compactProtocolID  = 0x82
+	compactVersion     = 1
+	compactVersionMask = 0x1f
+	compactTypeShift   = 5
+
+	headerMagic           = 0x0fff
+	headerFixedLen        = 10 // magic + flags + seq id + header size
+	headerProtocolBinary  = 0
+	headerProtocolCompact = 2
+	headerInfoKeyValue    = 1
+
+	maxSkipDepth = 64
+)
+
+// the field types of the binary protocol
+const (
+	typeStop   byte = 0
+	typeBool   byte = 2
+	typeByte   byte = 3
+	typeDouble byte = 4
+	typeI16    byte = 6
+	typeI32    byte = 8
+	typeI64    byte = 10
+	typeString byte = 11
+	typeStruct byte = 12
+	typeMap    byte = 13
+	typeSet    byte = 14
+	typeList   byte = 15
+	typeUUID   byte = 16
+)
+
+// the field types of the compact protocol
+const (
+	compactTypeBoolTrue  byte = 1
+	compactTypeBoolFalse byte = 2
+	compactTypeByte      byte = 3
+	compactTypeI16       byte = 4
+	compactTypeI32       byte = 5
+	compactTypeI64       byte = 6
+	compactTypeDouble    byte = 7
+	compactTypeBinary    byte = 8
+	compactTypeList      byte = 9
+	compactTypeSet       byte = 10
+	compactTypeMap       byte = 11
+	compactTypeStruct    byte = 12
+	compactTypeUUID      byte = 13
+)
+
+// the types of the TApplicationException
+const (
+	exceptionUnknown       int32 = 0
+	exceptionUnknownMethod int32 = 1
+	exceptionInternalError int32 = 6
+	exceptionProtocolError int32 = 7
+)
+
+var (
+	// errNeedMore means the data is not a complete message
+	errNeedMore = errors.New("[xprotocol][thrift] need more data")
+	// ErrInvalidMessage means the data is not a valid thrift message
+	ErrInvalidMessage = errors.New("[xprotocol][thrift] invalid message")
+	// ErrHeaderTooLarge means the headers can not be encoded in the THeader
+	ErrHeaderTooLarge = errors.New("[xprotocol][thrift] THeader is too large")
+)
+
+type statusInfo struct {
+	Type int32
+	Msg  string
+}
+
+var (
+	thriftMosnStatusMap = map[int]statusInfo{
+		api.CodecExceptionCode:    {Type: exceptionProtocolError, Msg: "0|codec exception"},
+		api.UnknownCode:           {Type: exceptionUnknown, Msg: "2|unknown"},
+		api.DeserialExceptionCode: {Type: exceptionProtocolError, Msg: "3|deserial exception"},
+		api.PermissionDeniedCode:  {Type: exceptionInternalError, Msg: "403|permission denied"},
+		api.RouterUnavailableCode: {Type: exceptionUnknownMethod, Msg: "404|router unavailable"},
+		api.InternalErrorCode:     {Type: exceptionInternalError, Msg: "500|internal error"},
+		api.NoHealthUpstreamCode:  {Type: exceptionUnknown, Msg: "502|no health upstream"},
+		api.UpstreamOverFlowCode:  {Type: exceptionUnknown, Msg: "503|upstream overflow"},
+		api.TimeoutExceptionCode:  {Type: exceptionInternalError, Msg: "504|timeout"},
+		api.LimitExceededCode:     {Type: exceptionUnknown, Msg: "509|limit exceeded"},
+	}
+)
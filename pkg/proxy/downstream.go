@@ -36,8 +36,10 @@ import (
 	v2 "mosn.io/mosn/pkg/config/v2"
 	mosnctx "mosn.io/mosn/pkg/context"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/protocol"
 	"mosn.io/mosn/pkg/router"
+	"mosn.io/mosn/pkg/tap"
 	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/track"
 	"mosn.io/mosn/pkg/types"
@@ -120,6 +122,12 @@ type downStream struct {
 
 	snapshot types.ClusterSnapshot
 
+	// stats scoped by the matched virtual host and route, nil if disabled
+	routeStats *RouteStats
+
+	// snapshots for the tap sessions, nil if no tap session is active
+	tapStream *tap.Stream
+
 	phase types.Phase
 }
 
@@ -212,6 +220,9 @@ func (s *downStream) endStream() {
 		// not reuse buffer
 		atomic.StoreUint32(&s.reuseBuffer, 0)
 	}
+	if s.endTunnel() {
+		return
+	}
 	s.cleanStream()
 
 	// note: if proxy logic resets the stream, there maybe some underlying data in the conn.
@@ -251,6 +262,9 @@ func (s *downStream) cleanStream() {
 	// record metrics
 	s.requestMetrics()
 
+	// capture into tap sessions
+	s.finishTap()
+
 	// finish tracing
 	s.finishTracing()
 
@@ -268,6 +282,24 @@ func (s *downStream) cleanStream() {
 	s.giveStream()
 }
 
+// finishTap captures the stream into the matched tap sessions when cleanStream
+func (s *downStream) finishTap() {
+	if s.tapStream == nil {
+		return
+	}
+	info := &tap.StreamInfo{
+		Context:         s.context,
+		RequestInfo:     s.requestInfo,
+		RequestHeaders:  s.downstreamReqHeaders,
+		ResponseHeaders: s.downstreamRespHeaders,
+	}
+	if listenerName, ok := mosnctx.Get(s.context, types.ContextKeyListenerName).(string); ok {
+		info.Listener = listenerName
+	}
+	info.VirtualHost, info.Route, _ = getRouteNames(s.route)
+	s.tapStream.Finish(info)
+}
+
 // requestMetrics records the request metrics when cleanStream
 func (s *downStream) requestMetrics() {
 	streamDurationNs := s.requestInfo.RequestFinishedDuration().Nanoseconds()
@@ -283,21 +315,27 @@ func (s *downStream) requestMetrics() {
 			processTime = requestReceivedNs + (streamDurationNs - responseReceivedNs)
 		}
 
-		s.proxy.stats.DownstreamProcessTime.Update(processTime)
+		updateLatency(s.context, s.proxy.stats.DownstreamProcessTime, processTime)
 		s.proxy.stats.DownstreamProcessTimeTotal.Inc(processTime)
 
-		s.proxy.listenerStats.DownstreamProcessTime.Update(processTime)
+		updateLatency(s.context, s.proxy.listenerStats.DownstreamProcessTime, processTime)
 		s.proxy.listenerStats.DownstreamProcessTimeTotal.Inc(processTime)
 
-		s.proxy.stats.DownstreamRequestTime.Update(streamDurationNs)
+		updateLatency(s.context, s.proxy.stats.DownstreamRequestTime, streamDurationNs)
 		s.proxy.stats.DownstreamRequestTimeTotal.Inc(streamDurationNs)
 
-		s.proxy.listenerStats.DownstreamRequestTime.Update(streamDurationNs)
+		updateLatency(s.context, s.proxy.listenerStats.DownstreamRequestTime, streamDurationNs)
 		s.proxy.listenerStats.DownstreamRequestTimeTotal.Inc(streamDurationNs)
 
 		s.proxy.stats.DownstreamUpdateRequestCode(s.requestInfo.ResponseCode())
 		s.proxy.listenerStats.DownstreamUpdateRequestCode(s.requestInfo.ResponseCode())
 
+		if s.routeStats != nil {
+			s.routeStats.RequestTotal.Inc(1)
+			s.routeStats.UpdateRequestCode(s.requestInfo.ResponseCode())
+			updateLatency(s.context, s.routeStats.RequestTime, streamDurationNs)
+		}
+
 		if s.isRequestFailed() {
 			s.proxy.stats.DownstreamRequestFailed.Inc(1)
 			s.proxy.listenerStats.DownstreamRequestFailed.Inc(1)
@@ -375,6 +413,9 @@ func (s *downStream) OnReceive(ctx context.Context, headers types.HeaderMap, dat
 	s.downstreamReqDataBuf = data
 	s.downstreamReqTrailers = trailers
 	s.tracks = track.TrackBufferByContext(ctx).Tracks
+	if s.tapStream = tap.NewStream(); s.tapStream != nil {
+		s.tapStream.OnDownstreamRequest(headers, data)
+	}
 
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(s.context, "[proxy] [downstream] OnReceive headers:%+v, data:%+v, trailers:%+v", headers, data, trailers)
@@ -451,6 +492,15 @@ func (s *downStream) receive(ctx context.Context, id uint32, phase types.Phase)
 		// init phase
 		case types.InitPhase:
 			s.printPhaseInfo(phase, id)
+
+			if overload.Triggered(overload.ActionRejectRequests) {
+				overload.RecordRejectedRequest()
+				s.requestInfo.SetResponseFlag(types.LoadShed)
+				s.sendHijackReply(api.UpstreamOverFlowCode, s.downstreamReqHeaders)
+				if p, err := s.processError(id); err != nil {
+					return p
+				}
+			}
 			phase++
 
 		// downstream filter before route
@@ -695,6 +745,7 @@ func (s *downStream) matchRoute() {
 	if s.route != nil {
 		s.requestInfo.SetRouteEntry(s.route.RouteRule())
 	}
+	s.routeStats = getRouteStats(s.route)
 }
 
 // used for adding stream filters.
@@ -821,6 +872,9 @@ func (s *downStream) chooseHost(endStream bool) {
 		s.sendHijackReply(api.RouterUnavailableCode, s.downstreamReqHeaders)
 		return
 	}
+	if !s.checkUpgrade() {
+		return
+	}
 	if s.snapshot == nil || reflect.ValueOf(s.snapshot).IsNil() {
 		// no available cluster
 		log.Proxy.Alertf(s.context, types.ErrorKeyClusterGet, " cluster snapshot is nil, cluster name is: %s", s.route.RouteRule().ClusterName(s.context))
@@ -1279,6 +1333,10 @@ func (s *downStream) onUpstreamResponseRecvFinished() {
 func (s *downStream) setupRetry(endStream bool) bool {
 	s.upstreamRequest.setupRetry = true
 
+	if s.routeStats != nil {
+		s.routeStats.RequestRetry.Inc(1)
+	}
+
 	if !endStream {
 		s.upstreamRequest.resetStream()
 	}
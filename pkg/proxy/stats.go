@@ -18,8 +18,15 @@
 package proxy
 
 import (
+	"context"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
 	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
 	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/trace"
 	"mosn.io/mosn/pkg/types"
 )
 
@@ -126,3 +133,121 @@ func (s *Stats) DownstreamUpdateRequestCode(returnCode int) {
 		s.DownstreamRequestOtherTotal.Inc(1)
 	}
 }
+
+// RouteStats is the stats scoped by virtual host and route
+type RouteStats struct {
+	RequestTotal            gometrics.Counter
+	Request1xxTotal         gometrics.Counter
+	Request2xxTotal         gometrics.Counter
+	Request3xxTotal         gometrics.Counter
+	Request4xxTotal         gometrics.Counter
+	Request5xxTotal         gometrics.Counter
+	RequestOtherTotal       gometrics.Counter
+	RequestTime             gometrics.Histogram
+	UpstreamRequestDuration gometrics.Histogram
+	RequestRetry            gometrics.Counter
+}
+
+// routeStatsCache caches the RouteStats of a route stats generation,
+// virtual host name + route name -> *RouteStats
+type routeStatsCache struct {
+	generation uint64
+	stats      sync.Map
+}
+
+// currentRouteStats stores the *routeStatsCache, it is replaced when the route stats is reconfigured
+var currentRouteStats atomic.Value
+
+func loadRouteStatsCache() *routeStatsCache {
+	generation := metrics.RouteStatsGeneration()
+	if c, ok := currentRouteStats.Load().(*routeStatsCache); ok && c.generation == generation {
+		return c
+	}
+	c := &routeStatsCache{generation: generation}
+	currentRouteStats.Store(c)
+	return c
+}
+
+func newRouteStats(virtualHost, route string) *RouteStats {
+	s := metrics.NewRouteStats(virtualHost, route)
+	return &RouteStats{
+		RequestTotal:            s.Counter(metrics.RouteRequestTotal),
+		Request1xxTotal:         s.Counter(metrics.RouteRequest1xxTotal),
+		Request2xxTotal:         s.Counter(metrics.RouteRequest2xxTotal),
+		Request3xxTotal:         s.Counter(metrics.RouteRequest3xxTotal),
+		Request4xxTotal:         s.Counter(metrics.RouteRequest4xxTotal),
+		Request5xxTotal:         s.Counter(metrics.RouteRequest5xxTotal),
+		RequestOtherTotal:       s.Counter(metrics.RouteRequestOtherTotal),
+		RequestTime:             s.Histogram(metrics.RouteRequestTime),
+		UpstreamRequestDuration: s.Histogram(metrics.RouteUpstreamRequestDuration),
+		RequestRetry:            s.Counter(metrics.RouteRequestRetry),
+	}
+}
+
+// getRouteStats returns the RouteStats of the matched route,
+// returns nil if the route stats is disabled or no route matched.
+func getRouteStats(route api.Route) *RouteStats {
+	if !metrics.RouteStatsEnabled() {
+		return nil
+	}
+	virtualHost, name, ok := getRouteNames(route)
+	if !ok {
+		return nil
+	}
+	cache := loadRouteStatsCache()
+	key := virtualHost + "\x00" + name
+	if s, ok := cache.stats.Load(key); ok {
+		return s.(*RouteStats)
+	}
+	s, _ := cache.stats.LoadOrStore(key, newRouteStats(virtualHost, name))
+	return s.(*RouteStats)
+}
+
+// getRouteNames returns the virtual host name and the route name of the route,
+// returns false if no route matched.
+func getRouteNames(route api.Route) (virtualHost, name string, ok bool) {
+	if route == nil || reflect.ValueOf(route).IsNil() {
+		return
+	}
+	rule := route.RouteRule()
+	if rule == nil || reflect.ValueOf(rule).IsNil() {
+		return
+	}
+	if vh := rule.VirtualHost(); vh != nil && !reflect.ValueOf(vh).IsNil() {
+		virtualHost = vh.Name()
+	}
+	if named, ok := rule.(types.NamedRouteRule); ok {
+		name = named.Name()
+	}
+	return virtualHost, name, true
+}
+
+// UpdateRequestCode counts the request by status class
+func (s *RouteStats) UpdateRequestCode(code int) {
+	switch code / 100 {
+	case 1:
+		s.Request1xxTotal.Inc(1)
+	case 2:
+		s.Request2xxTotal.Inc(1)
+	case 3:
+		s.Request3xxTotal.Inc(1)
+	case 4:
+		s.Request4xxTotal.Inc(1)
+	case 5:
+		s.Request5xxTotal.Inc(1)
+	default:
+		s.RequestOtherTotal.Inc(1)
+	}
+}
+
+// updateLatency updates the latency histogram, the trace id in the context
+// is recorded as exemplar if the histogram has explicit buckets.
+func updateLatency(ctx context.Context, h gometrics.Histogram, v int64) {
+	if bh, ok := h.(metrics.BucketHistogram); ok {
+		if span := trace.SpanFromContext(ctx); span != nil {
+			bh.UpdateWithExemplar(v, span.TraceId())
+			return
+		}
+	}
+	h.Update(v)
+}
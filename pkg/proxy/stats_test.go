@@ -0,0 +1,89 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/metrics"
+	"mosn.io/mosn/pkg/mock"
+)
+
+type namedRouteRule struct {
+	api.RouteRule
+	name string
+}
+
+func (r *namedRouteRule) Name() string {
+	return r.name
+}
+
+func TestGetRouteStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newRoute := func(vhName, routeName string) api.Route {
+		vh := mock.NewMockVirtualHost(ctrl)
+		vh.EXPECT().Name().Return(vhName).AnyTimes()
+		rule := mock.NewMockRouteRule(ctrl)
+		rule.EXPECT().VirtualHost().Return(vh).AnyTimes()
+		r := mock.NewMockRoute(ctrl)
+		r.EXPECT().RouteRule().Return(&namedRouteRule{RouteRule: rule, name: routeName}).AnyTimes()
+		return r
+	}
+
+	metrics.ResetAll()
+	route := newRoute("test_vh", "test_route")
+	if getRouteStats(route) != nil {
+		t.Fatal("route stats is disabled by default")
+	}
+
+	metrics.SetRouteStats(true, 0)
+	defer metrics.SetRouteStats(false, 0)
+
+	s := getRouteStats(route)
+	if s == nil {
+		t.Fatal("get route stats failed")
+	}
+	if getRouteStats(newRoute("test_vh", "test_route")) != s {
+		t.Error("route stats should be cached")
+	}
+	s.RequestTotal.Inc(1)
+	s.UpdateRequestCode(503)
+	s.UpdateRequestCode(0)
+
+	m := metrics.GetMetricsFilter("route.route.test_route.virtual_host.test_vh")
+	if m == nil {
+		t.Fatal("route metrics not found")
+	}
+	if m.Counter(metrics.RouteRequestTotal).Count() != 1 ||
+		m.Counter(metrics.RouteRequest5xxTotal).Count() != 1 ||
+		m.Counter(metrics.RouteRequestOtherTotal).Count() != 1 {
+		t.Error("unexpected route metrics")
+	}
+	if getRouteStats(nil) != nil {
+		t.Error("no route stats for nil route")
+	}
+	// the cached stats is dropped when the route stats is reconfigured
+	metrics.SetRouteStats(true, 0)
+	if getRouteStats(route) == s {
+		t.Error("route stats cache should be cleared")
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package proxy
+
+import (
+	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// upgradeTunnel returns the upgrade tunnel of the downstream request, if any
+func (s *downStream) upgradeTunnel() types.UpgradeTunnel {
+	tunnel, _ := mosnctx.Get(s.context, types.ContextKeyUpgradeTunnel).(types.UpgradeTunnel)
+	return tunnel
+}
+
+// checkUpgrade enables the upgrade tunnel if the route allows the upgrade type,
+// otherwise the request is rejected and false is returned.
+func (s *downStream) checkUpgrade() bool {
+	tunnel := s.upgradeTunnel()
+	if tunnel == nil {
+		return true
+	}
+	if rule, ok := s.route.RouteRule().(types.UpgradeRouteRule); ok {
+		if cfg, ok := rule.UpgradeConfig(tunnel.Type()); ok {
+			idleTimeout := cfg.IdleTimeout.Duration
+			if idleTimeout <= 0 {
+				idleTimeout = types.DefaultIdleTimeout
+			}
+			tunnel.Enable(idleTimeout)
+			return true
+		}
+	}
+	if log.Proxy.GetLogLevel() >= log.WARN {
+		log.Proxy.Warnf(s.context, "[proxy] [downstream] upgrade type %s is not enabled by the route, proxyId = %d", tunnel.Type(), s.ID)
+	}
+	s.sendHijackReply(api.PermissionDeniedCode, s.downstreamReqHeaders)
+	return false
+}
+
+// endTunnel defers the stream cleaning until the established tunnel is closed,
+// the relayed bytes are counted into the request info, so the access logs contain them.
+// It returns false if there is no established tunnel.
+func (s *downStream) endTunnel() bool {
+	tunnel := s.upgradeTunnel()
+	if tunnel == nil || !tunnel.Established() {
+		return false
+	}
+	// the timers are useless in the tunnel
+	s.cleanUp()
+	tunnel.OnClose(func(bytesReceived, bytesSent uint64) {
+		s.requestInfo.SetBytesReceived(s.requestInfo.BytesReceived() + bytesReceived)
+		s.requestInfo.SetBytesSent(s.requestInfo.BytesSent() + bytesSent)
+		s.cleanStream()
+	})
+	return true
+}
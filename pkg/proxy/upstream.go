@@ -85,10 +85,13 @@ func (r *upstreamRequest) OnDestroyStream() {}
 
 func (r *upstreamRequest) endStream() {
 	upstreamResponseDurationNs := time.Now().Sub(r.startTime).Nanoseconds()
-	r.host.HostStats().UpstreamRequestDuration.Update(upstreamResponseDurationNs)
+	updateLatency(r.downStream.context, r.host.HostStats().UpstreamRequestDuration, upstreamResponseDurationNs)
 	r.host.HostStats().UpstreamRequestDurationTotal.Inc(upstreamResponseDurationNs)
-	r.host.ClusterInfo().Stats().UpstreamRequestDuration.Update(upstreamResponseDurationNs)
+	updateLatency(r.downStream.context, r.host.ClusterInfo().Stats().UpstreamRequestDuration, upstreamResponseDurationNs)
 	r.host.ClusterInfo().Stats().UpstreamRequestDurationTotal.Inc(upstreamResponseDurationNs)
+	if rs := r.downStream.routeStats; rs != nil {
+		updateLatency(r.downStream.context, rs.UpstreamRequestDuration, upstreamResponseDurationNs)
+	}
 
 	// todo: record upstream process time in request info
 }
@@ -105,6 +108,10 @@ func (r *upstreamRequest) OnReceive(ctx context.Context, headers types.HeaderMap
 
 	r.endStream()
 
+	if ts := r.downStream.tapStream; ts != nil {
+		ts.OnUpstreamResponse(headers, data)
+	}
+
 	if code, err := protocol.MappingHeaderStatusCode(r.downStream.context, r.protocol, headers); err == nil {
 		r.downStream.requestInfo.SetResponseCode(code)
 	}
@@ -242,6 +249,10 @@ func (r *upstreamRequest) OnReady(sender types.StreamSender) {
 		}
 	}
 
+	if ts := r.downStream.tapStream; ts != nil {
+		ts.OnUpstreamRequest(r.downStream.downstreamReqHeaders)
+	}
+
 	endStream := r.sendComplete && !r.dataSent && !r.trailerSent
 	r.requestSender.AppendHeaders(r.downStream.context, r.downStream.downstreamReqHeaders, endStream)
 
@@ -41,6 +41,7 @@ var (
 )
 
 type RouteRuleImplBase struct {
+	name string
 	// match
 	vHost       api.VirtualHost
 	routerMatch v2.RouterMatch
@@ -62,6 +63,8 @@ type RouteRuleImplBase struct {
 	directResponseRule *directResponseImpl
 	// redirect
 	redirectRule *redirectImpl
+	// upgrade, keyed by the lower case upgrade type
+	upgradeConfigs map[string]v2.UpgradeConfig
 	// action
 	routerAction       v2.RouteAction
 	defaultCluster     *weightedClusterEntry // cluster name and metadata
@@ -73,6 +76,7 @@ type RouteRuleImplBase struct {
 
 func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleImplBase, error) {
 	base := &RouteRuleImplBase{
+		name:                  route.Name,
 		vHost:                 vHost,
 		routerMatch:           route.Match,
 		prefixRewrite:         route.Route.PrefixRewrite,
@@ -101,6 +105,13 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 		base.regexPattern = regexPattern
 	}
 
+	// add upgrade configs
+	if len(route.Route.UpgradeConfigs) > 0 {
+		base.upgradeConfigs = make(map[string]v2.UpgradeConfig, len(route.Route.UpgradeConfigs))
+		for _, cfg := range route.Route.UpgradeConfigs {
+			base.upgradeConfigs[strings.ToLower(cfg.UpgradeType)] = cfg
+		}
+	}
 	// add clusters
 	base.weightedClusters, base.totalClusterWeight = getWeightedClusterEntry(route.Route.WeightedClusters)
 	if len(route.Route.MetadataMatch) > 0 {
@@ -187,6 +198,17 @@ func NewRouteRuleImplBase(vHost api.VirtualHost, route *v2.Router) (*RouteRuleIm
 	return base, nil
 }
 
+// Name returns the route name
+func (rri *RouteRuleImplBase) Name() string {
+	return rri.name
+}
+
+// UpgradeConfig returns the config of the upgrade type, the type is case-insensitive
+func (rri *RouteRuleImplBase) UpgradeConfig(upgradeType string) (v2.UpgradeConfig, bool) {
+	cfg, ok := rri.upgradeConfigs[strings.ToLower(upgradeType)]
+	return cfg, ok
+}
+
 func (rri *RouteRuleImplBase) VirtualHost() api.VirtualHost {
 	return rri.vHost
 }
@@ -1028,3 +1028,32 @@ func TestRouterClusterVariable(t *testing.T) {
 		}
 	}
 }
+
+func TestUpgradeConfig(t *testing.T) {
+	route := &v2.Router{
+		RouterConfig: v2.RouterConfig{
+			Route: v2.RouteAction{
+				RouterActionConfig: v2.RouterActionConfig{
+					ClusterName: "test",
+					UpgradeConfigs: []v2.UpgradeConfig{
+						{UpgradeType: "websocket"},
+						{UpgradeType: "CONNECT", IdleTimeout: api.DurationConfig{Duration: time.Minute}},
+					},
+				},
+			},
+		},
+	}
+	rule, err := NewRouteRuleImplBase(nil, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rule.UpgradeConfig("WebSocket"); !ok {
+		t.Fatal("websocket should be enabled")
+	}
+	if cfg, ok := rule.UpgradeConfig(types.UpgradeTypeConnect); !ok || cfg.IdleTimeout.Duration != time.Minute {
+		t.Fatalf("unexpected connect config: %+v", cfg)
+	}
+	if _, ok := rule.UpgradeConfig("h2c"); ok {
+		t.Fatal("h2c should not be enabled")
+	}
+}
@@ -0,0 +1,271 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/cel/attribute"
+	"mosn.io/mosn/pkg/cel/extract"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+)
+
+// RouteExplanation explains the match result of a route rule
+type RouteExplanation struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name,omitempty"`
+	Match   string `json:"match"`
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// MatchExplanation explains how a request matches the routers
+type MatchExplanation struct {
+	Host        string `json:"host,omitempty"`
+	VirtualHost string `json:"virtual_host,omitempty"`
+	// Reason explains how the virtual host is selected, or why no virtual host is found
+	Reason string `json:"reason,omitempty"`
+	// Routes are the evaluated route rules in order, the last one is the matched route if any
+	Routes []RouteExplanation `json:"routes,omitempty"`
+	// WeightedClusters is true if the matched route picks the cluster from the weighted clusters randomly
+	WeightedClusters bool `json:"weighted_clusters,omitempty"`
+	// Route is the matched route, the same as MatchRoute returns
+	Route api.Route `json:"-"`
+}
+
+// ExplainMatch evaluates the request as MatchRoute does, and explains why the virtual host
+// is selected and why the route rules before the matched one are skipped.
+func ExplainMatch(ctx context.Context, routers types.Routers, headers api.HeaderMap) *MatchExplanation {
+	ri, ok := routers.(*routersImpl)
+	if !ok {
+		return &MatchExplanation{
+			Reason: "the routers do not support explanation",
+			Route:  routers.MatchRoute(ctx, headers),
+		}
+	}
+	explanation := &MatchExplanation{}
+	explanation.Host, _ = variable.GetString(ctx, types.VarHost)
+	vh := ri.findVirtualHost(ctx)
+	if vh == nil {
+		explanation.Reason = fmt.Sprintf("no virtual host matches the host %q, and no default virtual host", explanation.Host)
+		return explanation
+	}
+	explanation.VirtualHost = vh.Name()
+	if ri.defaultVirtualHostIndex != -1 && vh == ri.virtualHosts[ri.defaultVirtualHostIndex] {
+		explanation.Reason = "the default virtual host is used"
+	} else {
+		explanation.Reason = fmt.Sprintf("the domains match the host %q", explanation.Host)
+	}
+	vhImpl, ok := vh.(*VirtualHostImpl)
+	if !ok {
+		explanation.Route = vh.GetRouteFromEntries(ctx, headers)
+		return explanation
+	}
+	explanation.Routes, explanation.Route = vhImpl.explainRoutes(ctx, headers)
+	if explanation.Route != nil {
+		if base, ok := explanation.Route.RouteRule().(interface{ hasWeightedClusters() bool }); ok {
+			explanation.WeightedClusters = base.hasWeightedClusters()
+		}
+	}
+	return explanation
+}
+
+// explainRoutes evaluates the routes in order as GetRouteFromEntries does
+func (vh *VirtualHostImpl) explainRoutes(ctx context.Context, headers api.HeaderMap) ([]RouteExplanation, api.Route) {
+	vh.mutex.RLock()
+	defer vh.mutex.RUnlock()
+	explanations := make([]RouteExplanation, 0, len(vh.routes))
+	for i, route := range vh.routes {
+		explanation := RouteExplanation{
+			Index: i,
+		}
+		if base, ok := route.(interface{ matchConfig() v2.RouterMatch }); ok {
+			explanation.Match = describeMatch(base.matchConfig())
+		}
+		if named, ok := route.(types.NamedRouteRule); ok {
+			explanation.Name = named.Name()
+		}
+		if routeEntry := route.Match(ctx, headers); routeEntry != nil {
+			explanation.Matched = true
+			explanations = append(explanations, explanation)
+			return explanations, routeEntry
+		}
+		explanation.Reason = explainMismatch(ctx, headers, route)
+		explanations = append(explanations, explanation)
+	}
+	return explanations, nil
+}
+
+func (rri *RouteRuleImplBase) matchConfig() v2.RouterMatch {
+	return rri.routerMatch
+}
+
+func (rri *RouteRuleImplBase) hasWeightedClusters() bool {
+	return len(rri.weightedClusters) != 0
+}
+
+// describeMatch returns a readable description of the match config
+func describeMatch(m v2.RouterMatch) string {
+	var parts []string
+	switch {
+	case m.Prefix != "":
+		parts = append(parts, "prefix:"+m.Prefix)
+	case m.Path != "":
+		parts = append(parts, "path:"+m.Path)
+	case m.Regex != "":
+		parts = append(parts, "regex:"+m.Regex)
+	}
+	for _, h := range m.Headers {
+		parts = append(parts, "header:"+describeHeader(h))
+	}
+	for _, v := range m.Variables {
+		parts = append(parts, "variable:"+describeVariable(v))
+	}
+	for _, d := range m.DslExpressions {
+		parts = append(parts, "dsl:"+d.Expression)
+	}
+	return strings.Join(parts, " ")
+}
+
+func describeHeader(h v2.HeaderMatcher) string {
+	if h.Regex {
+		return h.Name + "~" + h.Value
+	}
+	return h.Name + "=" + h.Value
+}
+
+func describeVariable(v v2.VariableMatcher) string {
+	s := v.Name + "=" + v.Value
+	if v.Regex != "" {
+		s = v.Name + "~" + v.Regex
+	}
+	if v.Model != "" {
+		s += "(" + strings.ToLower(v.Model) + ")"
+	}
+	return s
+}
+
+// explainMismatch explains why the route rule does not match the request
+func explainMismatch(ctx context.Context, headers api.HeaderMap, route api.RouteBase) string {
+	path, _ := variable.GetString(ctx, types.VarPath)
+	switch r := route.(type) {
+	case *PathRouteRuleImpl:
+		if reason := explainHeaders(ctx, headers, r.routerMatch.Headers, CreateHTTPHeaderMatcher); reason != "" {
+			return reason
+		}
+		return fmt.Sprintf("path %q does not equal %q", path, r.path)
+	case *PrefixRouteRuleImpl:
+		if reason := explainHeaders(ctx, headers, r.routerMatch.Headers, CreateHTTPHeaderMatcher); reason != "" {
+			return reason
+		}
+		return fmt.Sprintf("path %q does not have the prefix %q", path, r.prefix)
+	case *RegexRouteRuleImpl:
+		if reason := explainHeaders(ctx, headers, r.routerMatch.Headers, CreateHTTPHeaderMatcher); reason != "" {
+			return reason
+		}
+		return fmt.Sprintf("path %q does not match the regex %q", path, r.regexStr)
+	case *VariableRouteRuleImpl:
+		return explainVariables(ctx, r.routerMatch.Variables)
+	case *DslExpressionRouteRuleImpl:
+		return r.explainExpressions(ctx, headers)
+	case *RPCRouteRuleImpl:
+		if r.fastmatch != "" {
+			value, _ := headers.Get(types.RPCRouteMatchKey)
+			return fmt.Sprintf("header %s %q does not match %q", types.RPCRouteMatchKey, value, r.fastmatch)
+		}
+		if reason := explainHeaders(ctx, headers, r.routerMatch.Headers, CreateCommonHeaderMatcher); reason != "" {
+			return reason
+		}
+	}
+	return "the route rule does not match"
+}
+
+// explainHeaders checks the header matchers one by one, and returns the first mismatch
+func explainHeaders(ctx context.Context, headers api.HeaderMap, matchers []v2.HeaderMatcher,
+	create func([]v2.HeaderMatcher) types.HeaderMatcher) string {
+	for _, h := range matchers {
+		if create([]v2.HeaderMatcher{h}).Matches(ctx, headers) {
+			continue
+		}
+		var value string
+		if h.Name == "method" {
+			value, _ = variable.GetString(ctx, types.VarMethod)
+		} else {
+			value, _ = headers.Get(h.Name)
+		}
+		return fmt.Sprintf("header %s %q does not match %q", h.Name, value, describeHeader(h))
+	}
+	return ""
+}
+
+// explainVariables lists the variables that do not match, the and/or models
+// make the rule fail only if the whole expression is false
+func explainVariables(ctx context.Context, matchers []v2.VariableMatcher) string {
+	var mismatches []string
+	for _, m := range matchers {
+		item := ParseToVariableMatchItem(m)
+		if item == nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s is invalid", describeVariable(m)))
+			continue
+		}
+		actual, err := variable.GetString(ctx, m.Name)
+		matched := false
+		if item.value != nil {
+			matched = *item.value == actual
+		}
+		if item.regexPattern != nil {
+			matched = item.regexPattern.MatchString(actual)
+		}
+		if matched {
+			continue
+		}
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s is not set, expected %q", m.Name, describeVariable(m)))
+		} else {
+			mismatches = append(mismatches, fmt.Sprintf("%s %q does not match %q", m.Name, actual, describeVariable(m)))
+		}
+	}
+	return "variables do not match: " + strings.Join(mismatches, ", ")
+}
+
+// explainExpressions returns the first dsl expression which is not true
+func (drri *DslExpressionRouteRuleImpl) explainExpressions(ctx context.Context, headers api.HeaderMap) string {
+	parentBag := extract.ExtractAttributes(ctx, headers, nil, nil, nil, nil, time.Now())
+	bag := attribute.NewMutableBag(parentBag)
+	bag.Set(extract.KContext, ctx)
+	for i, dslExpression := range drri.DslExpressions {
+		expression := ""
+		if i < len(drri.originalExpression) {
+			expression = drri.originalExpression[i].Expression
+		}
+		res, err := dslExpression.Evaluate(bag)
+		if err != nil {
+			return fmt.Sprintf("dsl expression %q evaluates failed: %v", expression, err)
+		}
+		if matched, _ := res.(bool); !matched {
+			return fmt.Sprintf("dsl expression %q is false", expression)
+		}
+	}
+	return "the dsl expressions do not match"
+}
@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package router
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+)
+
+func TestExplainMatch(t *testing.T) {
+	newRouter := func(name, cluster string, match v2.RouterMatch) v2.Router {
+		return v2.Router{
+			RouterConfig: v2.RouterConfig{
+				Name:  name,
+				Match: match,
+				Route: v2.RouteAction{
+					RouterActionConfig: v2.RouterActionConfig{
+						ClusterName: cluster,
+					},
+				},
+			},
+		}
+	}
+	cfg := &v2.RouterConfiguration{
+		VirtualHosts: []v2.VirtualHost{
+			{
+				Name:    "api",
+				Domains: []string{"api.test.com"},
+				Routers: []v2.Router{
+					newRouter("post", "post_cluster", v2.RouterMatch{
+						Prefix:  "/api",
+						Headers: []v2.HeaderMatcher{{Name: "method", Value: "POST"}},
+					}),
+					newRouter("v2", "v2_cluster", v2.RouterMatch{
+						Path: "/api/v2",
+					}),
+					newRouter("regex", "regex_cluster", v2.RouterMatch{
+						Regex: "/api/[0-9]+",
+					}),
+				},
+			},
+			{
+				Name:    "default",
+				Domains: []string{"*"},
+				Routers: []v2.Router{
+					newRouter("default", "default_cluster", v2.RouterMatch{
+						Prefix: "/",
+					}),
+				},
+			},
+		},
+	}
+	routers, err := NewRouters(cfg)
+	require.Nil(t, err)
+
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarHost, "api.test.com")
+	variable.SetString(ctx, types.VarPath, "/api/123")
+	variable.SetString(ctx, types.VarMethod, "GET")
+	explanation := ExplainMatch(ctx, routers, protocol.CommonHeader{})
+	assert.Equal(t, "api", explanation.VirtualHost)
+	require.NotNil(t, explanation.Route)
+	assert.Equal(t, "regex_cluster", explanation.Route.RouteRule().ClusterName(ctx))
+	require.Len(t, explanation.Routes, 3)
+	assert.Equal(t, "post", explanation.Routes[0].Name)
+	assert.Equal(t, "prefix:/api header:method=POST", explanation.Routes[0].Match)
+	assert.False(t, explanation.Routes[0].Matched)
+	assert.True(t, strings.Contains(explanation.Routes[0].Reason, "header method \"GET\""), explanation.Routes[0].Reason)
+	assert.True(t, strings.Contains(explanation.Routes[1].Reason, "does not equal"), explanation.Routes[1].Reason)
+	assert.True(t, explanation.Routes[2].Matched)
+
+	// the default virtual host is used
+	ctx = variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarHost, "www.test.com")
+	variable.SetString(ctx, types.VarPath, "/index")
+	explanation = ExplainMatch(ctx, routers, protocol.CommonHeader{})
+	assert.Equal(t, "default", explanation.VirtualHost)
+	assert.Equal(t, "the default virtual host is used", explanation.Reason)
+	require.NotNil(t, explanation.Route)
+	assert.Equal(t, "default_cluster", explanation.Route.RouteRule().ClusterName(ctx))
+}
+
+func TestExplainVariables(t *testing.T) {
+	variable.Register(variable.NewStringVariable("explain_var", nil, nil, variable.DefaultStringSetter, 0))
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, "explain_var", "foo")
+	reason := explainVariables(ctx, []v2.VariableMatcher{
+		{Name: "explain_var", Value: "foo", Model: "and"},
+		{Name: "explain_var", Regex: "^bar"},
+	})
+	assert.Equal(t, `variables do not match: explain_var "foo" does not match "explain_var~^bar"`, reason)
+}
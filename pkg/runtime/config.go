@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"mosn.io/api"
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// ExtendConfigType is the extend config type of the runtime
+//
+//	"extends": [{
+//		"type": "runtime",
+//		"config": {
+//			"static": {
+//				"fault_inject.abort.percent": 10,
+//				"fault_inject.delay.duration": "100ms"
+//			},
+//			"directory": "/home/admin/mosn/runtime",
+//			"poll_interval": "5s"
+//		}
+//	}]
+const ExtendConfigType = "runtime"
+
+func init() {
+	v2.RegisterParseExtendConfig(ExtendConfigType, OnRuntimeParsed)
+}
+
+// Config is the config of the runtime
+type Config struct {
+	// Static are the values of the static layer, the values can be json strings, numbers or bools
+	Static map[string]json.RawMessage `json:"static,omitempty"`
+	// Directory is the watched directory of the directory layer
+	Directory    string             `json:"directory,omitempty"`
+	PollInterval api.DurationConfig `json:"poll_interval,omitempty"`
+}
+
+// ParseValues converts the json strings, numbers and bools into the runtime values
+func ParseValues(raws map[string]json.RawMessage) (map[string]string, error) {
+	values := make(map[string]string, len(raws))
+	for key, raw := range raws {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			values[key] = s
+			continue
+		}
+		value := strings.TrimSpace(string(raw))
+		if strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[") || value == "null" {
+			return nil, fmt.Errorf("value of runtime key %s should be a string, number or bool", key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+var (
+	sourceMux sync.Mutex
+	dirSource *directorySource
+)
+
+// OnRuntimeParsed sets the static layer and starts watching the directory
+func OnRuntimeParsed(data json.RawMessage) error {
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return err
+	}
+	values, err := ParseValues(cfg.Static)
+	if err != nil {
+		return err
+	}
+	if err := SetLayer(LayerStatic, values); err != nil {
+		return err
+	}
+	sourceMux.Lock()
+	defer sourceMux.Unlock()
+	if dirSource != nil {
+		dirSource.close()
+		dirSource = nil
+	}
+	if cfg.Directory == "" {
+		return nil
+	}
+	dirSource = newDirectorySource(cfg.Directory, cfg.PollInterval.Duration)
+	dirSource.start()
+	return nil
+}
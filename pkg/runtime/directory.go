@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+const defaultPollInterval = time.Second
+
+// readDirectory reads the files in the directory recursively as the key/values.
+// The hidden files and directories are skipped, so the kubernetes configmap
+// volume (the files are symlinks to the ..data directory) can be used directly.
+func readDirectory(dir string) (map[string]string, error) {
+	values := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key := strings.Replace(filepath.ToSlash(rel), "/", ".", -1)
+		values[key] = strings.TrimSpace(string(data))
+		return nil
+	})
+	return values, err
+}
+
+// directorySource polls the directory and updates the directory layer if the values are changed.
+// Polling is used rather than inotify, the files in a directory tree are added and removed frequently.
+type directorySource struct {
+	dir       string
+	interval  time.Duration
+	values    map[string]string
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func newDirectorySource(dir string, interval time.Duration) *directorySource {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	return &directorySource{
+		dir:      dir,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// load reads the directory and updates the layer, the layer is kept if the directory can not be read
+func (s *directorySource) load() error {
+	values, err := readDirectory(s.dir)
+	if err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyConfigParse, "[runtime] read directory %s failed: %v", s.dir, err)
+		return err
+	}
+	if s.values != nil && reflect.DeepEqual(s.values, values) {
+		return nil
+	}
+	if err := SetLayer(LayerDirectory, values); err != nil {
+		log.DefaultLogger.Alertf(types.ErrorKeyConfigParse, "[runtime] apply directory %s failed: %v", s.dir, err)
+		return err
+	}
+	s.values = values
+	return nil
+}
+
+func (s *directorySource) start() {
+	s.load()
+	utils.GoWithRecover(s.run, nil)
+	log.DefaultLogger.Infof("[runtime] watch directory %s, interval: %v", s.dir, s.interval)
+}
+
+func (s *directorySource) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.load()
+		}
+	}
+}
+
+func (s *directorySource) close() {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+}
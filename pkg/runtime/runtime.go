@@ -0,0 +1,267 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package runtime is a layered key/value store of the runtime overrides.
+// The feature flags and the numeric knobs, such as the timeouts and the fault
+// percentages, can be changed without a config push.
+//
+// The values come from three layers, a higher layer overrides the lower ones:
+//   - static: the values in the runtime extend config
+//   - directory: the files in a watched directory, the relative path of a file is the key
+//     (the separators are replaced by '.'), and the trimmed content is the value
+//   - admin: the values set by the admin api
+//
+// The readers get the typed values with defaults, an invalid value is ignored and
+// the default is returned.
+package runtime
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+)
+
+// The layers, in the order from low to high
+const (
+	LayerStatic    = "static"
+	LayerDirectory = "directory"
+	LayerAdmin     = "admin"
+)
+
+var layers = []string{LayerStatic, LayerDirectory, LayerAdmin}
+
+// ErrInvalidKey is returned if a key is empty or contains spaces
+var ErrInvalidKey = errors.New("invalid runtime key")
+
+// Entry is the effective value of a key
+type Entry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Layer is the layer that the value comes from
+	Layer string `json:"layer"`
+	// Overridden are the values of the lower layers, keyed by the layer
+	Overridden map[string]string `json:"overridden,omitempty"`
+}
+
+// store merges the layers into a snapshot, the readers load the snapshot without lock
+type store struct {
+	mux      sync.Mutex
+	layers   map[string]map[string]string
+	snapshot atomic.Value // map[string]*Entry
+}
+
+func newStore() *store {
+	s := &store{
+		layers: make(map[string]map[string]string, len(layers)),
+	}
+	s.snapshot.Store(map[string]*Entry{})
+	return s
+}
+
+var defaultStore = newStore()
+
+// merge rebuilds the snapshot, it is called with the lock held
+func (s *store) merge() {
+	snapshot := map[string]*Entry{}
+	for _, layer := range layers {
+		for key, value := range s.layers[layer] {
+			entry, ok := snapshot[key]
+			if !ok {
+				snapshot[key] = &Entry{
+					Key:   key,
+					Value: value,
+					Layer: layer,
+				}
+				continue
+			}
+			if entry.Overridden == nil {
+				entry.Overridden = map[string]string{}
+			}
+			entry.Overridden[entry.Layer] = entry.Value
+			entry.Value = value
+			entry.Layer = layer
+		}
+	}
+	s.snapshot.Store(snapshot)
+}
+
+func (s *store) load() map[string]*Entry {
+	return s.snapshot.Load().(map[string]*Entry)
+}
+
+func validKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, " \t\r\n")
+}
+
+// SetLayer replaces all the values of a layer
+func SetLayer(layer string, values map[string]string) error {
+	known := false
+	for _, l := range layers {
+		if l == layer {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return errors.New("unknown runtime layer: " + layer)
+	}
+	copied := make(map[string]string, len(values))
+	for key, value := range values {
+		if !validKey(key) {
+			return ErrInvalidKey
+		}
+		copied[key] = value
+	}
+	defaultStore.mux.Lock()
+	defer defaultStore.mux.Unlock()
+	defaultStore.layers[layer] = copied
+	defaultStore.merge()
+	log.DefaultLogger.Infof("[runtime] layer %s updated, keys: %d", layer, len(copied))
+	return nil
+}
+
+// Set sets the values in the admin layer
+func Set(values map[string]string) error {
+	for key := range values {
+		if !validKey(key) {
+			return ErrInvalidKey
+		}
+	}
+	defaultStore.mux.Lock()
+	defer defaultStore.mux.Unlock()
+	admin := defaultStore.layers[LayerAdmin]
+	if admin == nil {
+		admin = make(map[string]string, len(values))
+		defaultStore.layers[LayerAdmin] = admin
+	}
+	for key, value := range values {
+		admin[key] = value
+		log.DefaultLogger.Infof("[runtime] key %s is set to %s by admin", key, value)
+	}
+	defaultStore.merge()
+	return nil
+}
+
+// Unset removes the keys from the admin layer, the values of the lower layers take effect again
+func Unset(keys ...string) {
+	defaultStore.mux.Lock()
+	defer defaultStore.mux.Unlock()
+	for _, key := range keys {
+		delete(defaultStore.layers[LayerAdmin], key)
+		log.DefaultLogger.Infof("[runtime] key %s is unset by admin", key)
+	}
+	defaultStore.merge()
+}
+
+// Reset removes all the values, it is used in tests
+func Reset() {
+	defaultStore.mux.Lock()
+	defer defaultStore.mux.Unlock()
+	defaultStore.layers = make(map[string]map[string]string, len(layers))
+	defaultStore.merge()
+}
+
+// Dump returns the effective values sorted by the keys
+func Dump() []Entry {
+	snapshot := defaultStore.load()
+	entries := make([]Entry, 0, len(snapshot))
+	for _, entry := range snapshot {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+// Get returns the raw value of the key
+func Get(key string) (string, bool) {
+	entry, ok := defaultStore.load()[key]
+	if !ok {
+		return "", false
+	}
+	return entry.Value, true
+}
+
+func invalid(key, value, typ string) {
+	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+		log.DefaultLogger.Debugf("[runtime] value %s of key %s is not a valid %s, use the default", value, key, typ)
+	}
+}
+
+// GetBool returns the bool value of the key, or the default
+func GetBool(key string, def bool) bool {
+	value, ok := Get(key)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		invalid(key, value, "bool")
+		return def
+	}
+	return b
+}
+
+// GetInt returns the int value of the key, or the default
+func GetInt(key string, def int64) int64 {
+	value, ok := Get(key)
+	if !ok {
+		return def
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		invalid(key, value, "int")
+		return def
+	}
+	return i
+}
+
+// GetPercent returns the percent value of the key in [0, 100], or the default.
+// The value can be written as "10" or "10%".
+func GetPercent(key string, def uint32) uint32 {
+	value, ok := Get(key)
+	if !ok {
+		return def
+	}
+	p, err := strconv.ParseUint(strings.TrimSuffix(value, "%"), 10, 32)
+	if err != nil || p > 100 {
+		invalid(key, value, "percent")
+		return def
+	}
+	return uint32(p)
+}
+
+// GetDuration returns the duration value of the key, such as "1.5s", or the default
+func GetDuration(key string, def time.Duration) time.Duration {
+	value, ok := Get(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		invalid(key, value, "duration")
+		return def
+	}
+	return d
+}
@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTypedValues(t *testing.T) {
+	Reset()
+	defer Reset()
+	if err := Set(map[string]string{
+		"bool":      "true",
+		"int":       "42",
+		"percent":   "30%",
+		"duration":  "1.5s",
+		"invalid":   "abc",
+		"overflow":  "101",
+		"negative":  "-1s",
+		"int.space": " 1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !GetBool("bool", false) || GetBool("invalid", true) != true || GetBool("not_exists", true) != true {
+		t.Fatal("unexpected bool values")
+	}
+	if GetInt("int", 0) != 42 || GetInt("invalid", 7) != 7 || GetInt("int.space", 7) != 7 {
+		t.Fatal("unexpected int values")
+	}
+	if GetPercent("percent", 0) != 30 || GetPercent("overflow", 5) != 5 || GetPercent("int", 5) != 42 {
+		t.Fatal("unexpected percent values")
+	}
+	if GetDuration("duration", 0) != 1500*time.Millisecond || GetDuration("negative", time.Second) != time.Second {
+		t.Fatal("unexpected duration values")
+	}
+	if err := Set(map[string]string{"invalid key": "1"}); err != ErrInvalidKey {
+		t.Fatalf("expected invalid key, but got %v", err)
+	}
+}
+
+func TestLayers(t *testing.T) {
+	Reset()
+	defer Reset()
+	if err := OnRuntimeParsed([]byte(`{"static":{"a":"static","b":10,"c":true}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLayer(LayerDirectory, map[string]string{"a": "directory", "b": "20"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(map[string]string{"a": "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	entries := Dump()
+	if len(entries) != 3 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	a, b, c := entries[0], entries[1], entries[2]
+	if a.Key != "a" || a.Value != "admin" || a.Layer != LayerAdmin ||
+		a.Overridden[LayerStatic] != "static" || a.Overridden[LayerDirectory] != "directory" {
+		t.Fatalf("unexpected entry: %+v", a)
+	}
+	if b.Value != "20" || b.Layer != LayerDirectory || b.Overridden[LayerStatic] != "10" {
+		t.Fatalf("unexpected entry: %+v", b)
+	}
+	if c.Value != "true" || c.Layer != LayerStatic || len(c.Overridden) != 0 {
+		t.Fatalf("unexpected entry: %+v", c)
+	}
+	// the lower layer takes effect again
+	Unset("a")
+	if v, _ := Get("a"); v != "directory" {
+		t.Fatalf("unexpected value after unset: %s", v)
+	}
+	if err := SetLayer("unknown", nil); err == nil {
+		t.Fatal("unknown layer should be failed")
+	}
+	if err := OnRuntimeParsed([]byte(`{"static":{"a":{"b":1}}}`)); err == nil {
+		t.Fatal("object value should be failed")
+	}
+}
+
+func TestDirectorySource(t *testing.T) {
+	Reset()
+	defer Reset()
+	dir, err := ioutil.TempDir("", "runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("fault_inject/abort/percent", "10\n")
+	write(".hidden", "1")
+	write("..data/ignored", "1")
+	s := newDirectorySource(dir, 20*time.Millisecond)
+	s.start()
+	defer s.close()
+	if v, _ := Get("fault_inject.abort.percent"); v != "10" {
+		t.Fatalf("unexpected value: %s", v)
+	}
+	if len(Dump()) != 1 {
+		t.Fatalf("hidden files should be skipped: %+v", Dump())
+	}
+	write("fault_inject/abort/percent", "20")
+	os.Remove(filepath.Join(dir, "fault_inject/abort/percent"))
+	write("timeout", "3s")
+	for i := 0; i < 50; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if GetDuration("timeout", 0) == 3*time.Second {
+			break
+		}
+	}
+	if GetDuration("timeout", 0) != 3*time.Second {
+		t.Fatalf("directory changes are not applied: %+v", Dump())
+	}
+	if _, ok := Get("fault_inject.abort.percent"); ok {
+		t.Fatal("removed file should be removed from the layer")
+	}
+}
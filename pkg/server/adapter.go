@@ -132,3 +132,21 @@ func (adapter *ListenerAdapter) DeleteListener(serverName string, listenerName s
 	connHandler.RemoveListeners(listenerName)
 	return nil
 }
+
+// Drain drains the downstream connections of the server, see DrainOptions.
+func (adapter *ListenerAdapter) Drain(serverName string, opts DrainOptions) error {
+	ch, ok := adapter.findHandler(serverName).(*connHandler)
+	if !ok {
+		return fmt.Errorf("Drain error, servername = %s not found", serverName)
+	}
+	return ch.Drain(opts)
+}
+
+// DrainStatus returns the progress of the last drain of the server
+func (adapter *ListenerAdapter) DrainStatus(serverName string) (DrainStatus, error) {
+	ch, ok := adapter.findHandler(serverName).(*connHandler)
+	if !ok {
+		return DrainStatus{}, fmt.Errorf("DrainStatus error, servername = %s not found", serverName)
+	}
+	return ch.DrainStatus(), nil
+}
@@ -421,3 +421,52 @@ func TestListenerMetrics(t *testing.T) {
 		t.Fatalf("mosn listener metrics is not expected, got %d", lnCount)
 	}
 }
+
+func TestListenerMaxConnections(t *testing.T) {
+	setup()
+	defer tearDown()
+	defer SetMaxConnections(0)
+
+	addrStr := "127.0.0.1:18091"
+	name := "max_connections_listener"
+	cfg := baseListenerConfig(addrStr, name)
+	cfg.FilterChains[0].TLSContexts = nil
+	cfg.MaxConnections = 2
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, cfg); err != nil {
+		t.Fatalf("add listener failed, %v", err)
+	}
+	time.Sleep(time.Second) // wait listener start
+	handler := listenerAdapterInstance.defaultConnHandler.(*connHandler)
+	var conns []net.Conn
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	dial := func(n int) {
+		for i := 0; i < n; i++ {
+			conn, err := net.DialTimeout("tcp", addrStr, time.Second)
+			if err != nil {
+				t.Fatalf("dial listener failed, %v", err)
+			}
+			conns = append(conns, conn)
+		}
+		time.Sleep(100 * time.Millisecond) // wait connections accepted
+	}
+	overflow := metrics.NewListenerStats(name).Counter(metrics.DownstreamConnectionOverflow)
+	dial(3)
+	if handler.NumConnections() != 2 || overflow.Count() != 1 {
+		t.Fatalf("listener max connections is not expected, connections: %d, overflow: %d", handler.NumConnections(), overflow.Count())
+	}
+	// the process-wide limit
+	cfg = baseListenerConfig(addrStr, name)
+	cfg.FilterChains[0].TLSContexts = nil
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, cfg); err != nil {
+		t.Fatalf("update listener failed, %v", err)
+	}
+	SetMaxConnections(3)
+	dial(2)
+	if handler.NumConnections() != 3 || overflow.Count() != 2 {
+		t.Fatalf("process max connections is not expected, connections: %d, overflow: %d", handler.NumConnections(), overflow.Count())
+	}
+}
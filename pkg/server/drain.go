@@ -0,0 +1,328 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gometrics "github.com/rcrowley/go-metrics"
+	"mosn.io/api"
+	"mosn.io/pkg/utils"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/metrics"
+)
+
+// DrainMode decides what to do with the existing downstream connections
+type DrainMode string
+
+const (
+	// DrainStopListening stops accepting new connections only, the existing connections are kept
+	DrainStopListening DrainMode = "stop_listening"
+	// DrainGoAway stops accepting new connections and asks the clients of the existing connections
+	// to go away: http1 responses carry 'Connection: close' and the idle connections are closed,
+	// http2 sends GOAWAY, dubbo sends the readonly event if enable_dubbo_goaway is configured
+	// and bolt sends the goaway frame if enable_bolt_goaway is configured.
+	DrainGoAway DrainMode = "goaway"
+)
+
+// DrainStrategy decides how fast the existing connections are asked to go away
+type DrainStrategy string
+
+const (
+	// DrainImmediate notifies all the connections at once
+	DrainImmediate DrainStrategy = "immediate"
+	// DrainGradual spreads the notifications over the drain window, so the clients
+	// do not reconnect to the other instances at the same time
+	DrainGradual DrainStrategy = "gradual"
+)
+
+// The drain states
+const (
+	DrainStateNone     = "none"
+	DrainStateDraining = "draining"
+	DrainStateDrained  = "drained"
+	DrainStateTimeout  = "timeout"
+)
+
+var drainStateCode = map[string]int64{
+	DrainStateNone:     0,
+	DrainStateDraining: 1,
+	DrainStateDrained:  2,
+	DrainStateTimeout:  3,
+}
+
+var (
+	ErrDrainInProgress  = errors.New("a drain is in progress")
+	ErrListenerNotFound = errors.New("listener not found")
+)
+
+// DrainOptions describes a drain
+type DrainOptions struct {
+	Mode     DrainMode
+	Strategy DrainStrategy
+	// Window is the duration that the gradual strategy spreads the notifications over
+	Window time.Duration
+	// Timeout is the max duration to wait for the active requests after all the
+	// connections are notified, the drain time is used if it is zero
+	Timeout time.Duration
+	// Listeners are the names of the listeners to drain, all the listeners are drained if it is empty
+	Listeners []string
+}
+
+func (opts *DrainOptions) validate() error {
+	switch opts.Mode {
+	case DrainStopListening, DrainGoAway:
+	default:
+		return fmt.Errorf("invalid drain mode: %s", opts.Mode)
+	}
+	switch opts.Strategy {
+	case "":
+		opts.Strategy = DrainImmediate
+	case DrainImmediate:
+	case DrainGradual:
+		if opts.Window <= 0 {
+			return errors.New("gradual drain requires a positive window")
+		}
+	default:
+		return fmt.Errorf("invalid drain strategy: %s", opts.Strategy)
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = drainTime
+	}
+	return nil
+}
+
+// ListenerDrainStatus is the drain progress of a listener
+type ListenerDrainStatus struct {
+	ConnectionsTotal     int64 `json:"connections_total"`
+	ConnectionsNotified  int64 `json:"connections_notified"`
+	ConnectionsRemaining int64 `json:"connections_remaining"`
+	RequestsActive       int64 `json:"requests_active"`
+}
+
+// DrainStatus is the progress of the last drain
+type DrainStatus struct {
+	State     string                          `json:"state"`
+	Mode      DrainMode                       `json:"mode,omitempty"`
+	Strategy  DrainStrategy                   `json:"strategy,omitempty"`
+	StartTime time.Time                       `json:"start_time,omitempty"`
+	EndTime   time.Time                       `json:"end_time,omitempty"`
+	Listeners map[string]*ListenerDrainStatus `json:"listeners,omitempty"`
+}
+
+// listenerDrain records the drain progress of a listener, the progress is exposed
+// by the listener gauges too.
+type listenerDrain struct {
+	al       *activeListener
+	total    int64
+	notified int64
+
+	stateGauge     gometrics.Gauge
+	notifiedGauge  gometrics.Gauge
+	remainingGauge gometrics.Gauge
+	requestsGauge  gometrics.Gauge
+}
+
+func newListenerDrain(al *activeListener) *listenerDrain {
+	s := metrics.NewListenerStats(al.listener.Name())
+	return &listenerDrain{
+		al:             al,
+		stateGauge:     s.Gauge(metrics.DownstreamDrainState),
+		notifiedGauge:  s.Gauge(metrics.DownstreamDrainConnectionsNotified),
+		remainingGauge: s.Gauge(metrics.DownstreamDrainConnectionsRemaining),
+		requestsGauge:  s.Gauge(metrics.DownstreamDrainRequestsActive),
+	}
+}
+
+func (ld *listenerDrain) connections() []api.Connection {
+	var conns []api.Connection
+	ld.al.conns.VisitSafe(func(v interface{}) {
+		conns = append(conns, v.(*activeConnection).conn)
+	})
+	return conns
+}
+
+// notify asks the connections to go away, it returns after all the connections are notified.
+func (ld *listenerDrain) notify(strategy DrainStrategy, window time.Duration) {
+	conns := ld.connections()
+	atomic.StoreInt64(&ld.total, int64(len(conns)))
+	if len(conns) == 0 {
+		return
+	}
+	var interval time.Duration
+	if strategy == DrainGradual {
+		interval = window / time.Duration(len(conns))
+	}
+	for i, conn := range conns {
+		if i > 0 && interval > 0 {
+			time.Sleep(interval)
+		}
+		// the connection may be closed already, the event is ignored then.
+		conn.OnConnectionEvent(api.OnShutdown)
+		ld.notifiedGauge.Update(atomic.AddInt64(&ld.notified, 1))
+	}
+}
+
+// update refreshes the gauges and returns the active requests
+func (ld *listenerDrain) update() int64 {
+	requests := int64(ld.al.activeStreamSize())
+	ld.requestsGauge.Update(requests)
+	ld.remainingGauge.Update(int64(len(ld.connections())))
+	return requests
+}
+
+func (ld *listenerDrain) status() *ListenerDrainStatus {
+	return &ListenerDrainStatus{
+		ConnectionsTotal:     atomic.LoadInt64(&ld.total),
+		ConnectionsNotified:  atomic.LoadInt64(&ld.notified),
+		ConnectionsRemaining: ld.remainingGauge.Value(),
+		RequestsActive:       ld.requestsGauge.Value(),
+	}
+}
+
+// drainer runs the drains of a connection handler, only one drain can run at the same time
+type drainer struct {
+	mux       sync.Mutex
+	status    DrainStatus
+	listeners map[string]*listenerDrain
+}
+
+func (d *drainer) setState(state string) {
+	d.mux.Lock()
+	d.status.State = state
+	if state != DrainStateDraining {
+		d.status.EndTime = time.Now()
+	}
+	listeners := d.listeners
+	d.mux.Unlock()
+	for _, ld := range listeners {
+		ld.stateGauge.Update(drainStateCode[state])
+	}
+}
+
+// Status returns the progress of the last drain
+func (d *drainer) Status() DrainStatus {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	status := d.status
+	if status.State == "" {
+		status.State = DrainStateNone
+	}
+	if len(d.listeners) > 0 {
+		status.Listeners = make(map[string]*ListenerDrainStatus, len(d.listeners))
+		for name, ld := range d.listeners {
+			status.Listeners[name] = ld.status()
+		}
+	}
+	return status
+}
+
+// Drain stops accepting new connections of the listeners and drains the existing connections
+// in background, the progress can be got by DrainStatus.
+func (ch *connHandler) Drain(opts DrainOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	var listeners []*activeListener
+	if len(opts.Listeners) == 0 {
+		listeners = ch.getListeners()
+	} else {
+		for _, name := range opts.Listeners {
+			al := ch.findActiveListenerByName(name)
+			if al == nil {
+				return fmt.Errorf("%w: %s", ErrListenerNotFound, name)
+			}
+			listeners = append(listeners, al)
+		}
+	}
+	d := &ch.drainer
+	d.mux.Lock()
+	if d.status.State == DrainStateDraining {
+		d.mux.Unlock()
+		return ErrDrainInProgress
+	}
+	d.status = DrainStatus{
+		State:     DrainStateDraining,
+		Mode:      opts.Mode,
+		Strategy:  opts.Strategy,
+		StartTime: time.Now(),
+	}
+	d.listeners = make(map[string]*listenerDrain, len(listeners))
+	for _, al := range listeners {
+		d.listeners[al.listener.Name()] = newListenerDrain(al)
+	}
+	drains := d.listeners
+	d.mux.Unlock()
+
+	log.DefaultLogger.Infof("[server] [drain] start drain, mode: %s, strategy: %s, window: %v, listeners: %d",
+		opts.Mode, opts.Strategy, opts.Window, len(drains))
+	d.setState(DrainStateDraining)
+	for name, ld := range drains {
+		if err := ld.al.listener.StopAccept(); err != nil {
+			log.DefaultLogger.Errorf("[server] [drain] failed to stop accepting listener %s: %v", name, err)
+		}
+	}
+	utils.GoWithRecover(func() {
+		ch.runDrain(opts, drains)
+	}, nil)
+	return nil
+}
+
+func (ch *connHandler) runDrain(opts DrainOptions, drains map[string]*listenerDrain) {
+	if opts.Mode == DrainGoAway {
+		wg := sync.WaitGroup{}
+		wg.Add(len(drains))
+		for _, ld := range drains {
+			ld := ld
+			utils.GoWithRecover(func() {
+				defer wg.Done()
+				ld.notify(opts.Strategy, opts.Window)
+			}, nil)
+		}
+		wg.Wait()
+	}
+	// wait the active requests to be finished
+	start := time.Now()
+	for {
+		var requests int64
+		for _, ld := range drains {
+			requests += ld.update()
+		}
+		if requests == 0 {
+			ch.drainer.setState(DrainStateDrained)
+			break
+		}
+		if time.Since(start) > opts.Timeout {
+			ch.drainer.setState(DrainStateTimeout)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.DefaultLogger.Infof("[server] [drain] drain finished, state: %s, waited time %dms",
+		ch.DrainStatus().State, Milliseconds(time.Since(start)))
+}
+
+// DrainStatus returns the progress of the last drain
+func (ch *connHandler) DrainStatus() DrainStatus {
+	return ch.drainer.Status()
+}
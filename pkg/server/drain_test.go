@@ -0,0 +1,125 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"mosn.io/mosn/pkg/metrics"
+)
+
+func TestDrainOptions(t *testing.T) {
+	for _, opts := range []DrainOptions{
+		{},
+		{Mode: "unknown"},
+		{Mode: DrainGoAway, Strategy: "unknown"},
+		{Mode: DrainGoAway, Strategy: DrainGradual},
+	} {
+		if err := opts.validate(); err == nil {
+			t.Fatalf("options %+v should be invalid", opts)
+		}
+	}
+	opts := DrainOptions{Mode: DrainStopListening}
+	if err := opts.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Strategy != DrainImmediate || opts.Timeout != drainTime {
+		t.Fatalf("unexpected default options: %+v", opts)
+	}
+}
+
+func TestDrainGradual(t *testing.T) {
+	setup()
+	defer tearDown()
+
+	addrStr := "127.0.0.1:18090"
+	name := "drain_listener"
+	cfg := baseListenerConfig(addrStr, name)
+	cfg.FilterChains[0].TLSContexts = nil
+	if err := GetListenerAdapterInstance().AddOrUpdateListener(testServerName, cfg); err != nil {
+		t.Fatalf("add listener failed, %v", err)
+	}
+	time.Sleep(time.Second) // wait listener start
+	handler := listenerAdapterInstance.defaultConnHandler.(*connHandler)
+	for i := 0; i < 3; i++ {
+		conn, err := net.DialTimeout("tcp", addrStr, time.Second)
+		if err != nil {
+			t.Fatalf("dial listener failed, %v", err)
+		}
+		defer conn.Close()
+	}
+	time.Sleep(100 * time.Millisecond) // wait connections accepted
+	if handler.NumConnections() != 3 {
+		t.Fatalf("expected 3 connections, but got %d", handler.NumConnections())
+	}
+
+	if err := GetListenerAdapterInstance().Drain(testServerName, DrainOptions{
+		Mode:      DrainGoAway,
+		Listeners: []string{"not_exists"},
+	}); !errors.Is(err, ErrListenerNotFound) {
+		t.Fatalf("expected listener not found, but got %v", err)
+	}
+	start := time.Now()
+	if err := GetListenerAdapterInstance().Drain(testServerName, DrainOptions{
+		Mode:     DrainGoAway,
+		Strategy: DrainGradual,
+		Window:   300 * time.Millisecond,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := GetListenerAdapterInstance().Drain(testServerName, DrainOptions{
+		Mode: DrainStopListening,
+	}); err != ErrDrainInProgress {
+		t.Fatalf("expected drain in progress, but got %v", err)
+	}
+	// new connections are not accepted
+	if conn, err := net.DialTimeout("tcp", addrStr, time.Second); err == nil {
+		defer conn.Close()
+	}
+
+	var status DrainStatus
+	for i := 0; i < 100; i++ {
+		time.Sleep(20 * time.Millisecond)
+		status, _ = GetListenerAdapterInstance().DrainStatus(testServerName)
+		if status.State != DrainStateDraining {
+			break
+		}
+	}
+	if status.State != DrainStateDrained {
+		t.Fatalf("unexpected drain status: %+v", status)
+	}
+	// the notifications are spread over the window
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("gradual drain finished too fast: %v", elapsed)
+	}
+	ls := status.Listeners[name]
+	if ls == nil || ls.ConnectionsTotal != 3 || ls.ConnectionsNotified != 3 || ls.RequestsActive != 0 {
+		t.Fatalf("unexpected listener drain status: %+v", ls)
+	}
+	if handler.NumConnections() != 3 {
+		t.Fatalf("expected 3 connections, but got %d", handler.NumConnections())
+	}
+	s := metrics.NewListenerStats(name)
+	if s.Gauge(metrics.DownstreamDrainState).Value() != drainStateCode[DrainStateDrained] ||
+		s.Gauge(metrics.DownstreamDrainConnectionsNotified).Value() != 3 {
+		t.Fatal("unexpected drain gauges")
+	}
+}
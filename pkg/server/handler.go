@@ -45,6 +45,7 @@ import (
 	"mosn.io/mosn/pkg/metrics"
 	"mosn.io/mosn/pkg/mtls"
 	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/streamfilter"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/mosn/pkg/variable"
@@ -56,8 +57,11 @@ import (
 // ClusterHostFactoryCb
 type connHandler struct {
 	numConnections int64
+	// listenersMux guards the listeners, which may be added or removed concurrently
+	listenersMux   sync.RWMutex
 	listeners      []*activeListener
 	clusterManager types.ClusterManager
+	drainer        drainer
 }
 
 // NewHandler
@@ -164,6 +168,8 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 		rawConfig.UseOriginalDst = lc.UseOriginalDst
 		al.listener.SetUseOriginalDst(lc.UseOriginalDst)
 		al.idleTimeout = lc.ConnectionIdleTimeout
+		rawConfig.MaxConnections = lc.MaxConnections
+		atomic.StoreInt64(&al.maxConnections, int64(lc.MaxConnections))
 
 		al.listener.SetConfig(rawConfig)
 
@@ -203,7 +209,9 @@ func (ch *connHandler) AddOrUpdateListener(lc *v2.Listener) (types.ListenerEvent
 			return al, err
 		}
 		l.SetListenerCallbacks(al)
+		ch.listenersMux.Lock()
 		ch.listeners = append(ch.listeners, al)
+		ch.listenersMux.Unlock()
 		if log.DefaultLogger.GetLogLevel() >= log.INFO {
 			log.DefaultLogger.Infof("[server] [conn handler] [add listener] add listener: %s", lc.Addr.String())
 		}
@@ -251,10 +259,13 @@ func (ch *connHandler) FindListenerByName(name string) types.Listener {
 }
 
 func (ch *connHandler) RemoveListeners(name string) {
+	ch.listenersMux.Lock()
+	defer ch.listenersMux.Unlock()
 	for i, l := range ch.listeners {
 		if l.listener.Name() == name {
 			log.DefaultLogger.Infof("[server] [conn handler] remove listener name: %s", name)
 			ch.listeners = append(ch.listeners[:i], ch.listeners[i+1:]...)
+			configmanager.SetRemoveListenerConfig(name)
 		}
 	}
 }
@@ -341,7 +352,18 @@ func (ch *connHandler) ListListenersFile(lctx context.Context) []*os.File {
 	return files
 }
 
+// getListeners returns a snapshot of the listeners
+func (ch *connHandler) getListeners() []*activeListener {
+	ch.listenersMux.RLock()
+	defer ch.listenersMux.RUnlock()
+	listeners := make([]*activeListener, len(ch.listeners))
+	copy(listeners, ch.listeners)
+	return listeners
+}
+
 func (ch *connHandler) findActiveListenerByAddress(addr net.Addr) *activeListener {
+	ch.listenersMux.RLock()
+	defer ch.listenersMux.RUnlock()
 	for _, l := range ch.listeners {
 		if l.listener != nil {
 			if l.listener.Addr().Network() == addr.Network() &&
@@ -355,6 +377,8 @@ func (ch *connHandler) findActiveListenerByAddress(addr net.Addr) *activeListene
 }
 
 func (ch *connHandler) findActiveListenerByName(name string) *activeListener {
+	ch.listenersMux.RLock()
+	defer ch.listenersMux.RUnlock()
 	for _, l := range ch.listeners {
 		if l.listener != nil && l.listener.Name() == name {
 			return l
@@ -372,6 +396,8 @@ func (ch *connHandler) StopConnection() {
 
 // ListenerEventListener
 type activeListener struct {
+	numConnections           int64
+	maxConnections           int64
 	listener                 types.Listener
 	listenerFiltersFactories []api.ListenerFilterChainFactory
 	networkFiltersFactories  []api.NetworkFilterChainFactory
@@ -399,6 +425,7 @@ func newActiveListener(listener types.Listener, lc *v2.Listener, accessLoggers [
 		accessLogs:               accessLoggers,
 		updatedLabel:             false,
 		idleTimeout:              lc.ConnectionIdleTimeout,
+		maxConnections:           int64(lc.MaxConnections),
 		networkFiltersFactories:  networkFiltersFactories,
 		listenerFiltersFactories: listenerFiltersFactories,
 	}
@@ -518,6 +545,15 @@ func (al *activeListener) OnAccept(rawc net.Conn, useOriginalDst bool, oriRemote
 }
 
 func (al *activeListener) OnNewConnection(ctx context.Context, conn api.Connection) {
+	if !al.reserveConnection() {
+		al.stats.DownstreamConnectionOverflow.Inc(1)
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[server] [listener] listener %s reaches the max connections, close the connection from %s", al.listener.Name(), conn.RemoteAddr())
+		}
+		conn.Close(api.NoFlush, api.LocalClose)
+		return
+	}
+
 	//Register Proxy's Filter
 	filterManager := conn.FilterManager()
 	for _, nfcf := range al.networkFiltersFactories {
@@ -533,8 +569,6 @@ func (al *activeListener) OnNewConnection(ctx context.Context, conn api.Connecti
 	e := al.conns.PushBack(ac)
 	ac.element = e
 
-	atomic.AddInt64(&al.handler.numConnections, 1)
-
 	if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
 		log.DefaultLogger.Debugf("[server] [listener] accept connection from %s, condId= %d, remote addr:%s", al.listener.Addr().String(), conn.ID(), conn.RemoteAddr().String())
 	}
@@ -571,6 +605,14 @@ func SetDrainTime(time time.Duration) {
 	drainTime = time
 }
 
+// maxConnections is the max downstream connections of the process, no limit if it is zero
+var maxConnections int64
+
+// SetMaxConnections sets the max downstream connections of the process
+func SetMaxConnections(max uint64) {
+	atomic.StoreInt64(&maxConnections, int64(max))
+}
+
 // OnShutdown graceful stop the existing connection and wait all connections to be closed
 func (al *activeListener) OnShutdown() {
 	utils.GoWithRecover(func() {
@@ -631,9 +673,25 @@ func (al *activeListener) PreStopHook(ctx context.Context) func() error {
 // compatible with go 1.12.x
 func Milliseconds(d time.Duration) int64 { return int64(d) / 1e6 }
 
+// reserveConnection counts a new connection, it returns false if the listener
+// or the process reaches the max connections
+func (al *activeListener) reserveConnection() bool {
+	n := atomic.AddInt64(&al.numConnections, 1)
+	total := atomic.AddInt64(&al.handler.numConnections, 1)
+	max := atomic.LoadInt64(&al.maxConnections)
+	globalMax := atomic.LoadInt64(&maxConnections)
+	if (max > 0 && n > max) || (globalMax > 0 && total > globalMax) {
+		atomic.AddInt64(&al.numConnections, -1)
+		atomic.AddInt64(&al.handler.numConnections, -1)
+		return false
+	}
+	return true
+}
+
 func (al *activeListener) removeConnection(ac *activeConnection) {
 	al.conns.Remove(ac.element)
 
+	atomic.AddInt64(&al.numConnections, -1)
 	atomic.AddInt64(&al.handler.numConnections, -1)
 
 }
@@ -665,7 +723,7 @@ func (al *activeListener) newConnection(ctx context.Context, rawc net.Conn) {
 	newCtx := mosnctx.WithValue(ctx, types.ContextKeyConnectionID, conn.ID())
 	newCtx = mosnctx.WithValue(newCtx, types.ContextKeyConnection, conn)
 
-	conn.SetBufferLimit(al.listener.PerConnBufferLimitBytes())
+	conn.SetBufferLimit(overload.ScaleBufferLimit(al.listener.PerConnBufferLimitBytes()))
 
 	al.OnNewConnection(newCtx, conn)
 }
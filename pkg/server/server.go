@@ -19,27 +19,50 @@ package server
 
 import (
 	"os"
+	"sync"
 	"time"
 
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/configmanager"
 	mlog "mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/log"
 )
 
 // GetServer currently, only one server supported
 func GetServer() Server {
-	if len(servers) == 0 {
+	srvs := getServers()
+	if len(srvs) == 0 {
 		log.DefaultLogger.Errorf("[server] Server is nil and hasn't been initiated at this time")
 		return nil
 	}
 
-	return servers[0]
+	return srvs[0]
 }
 
-var servers []*server
+var (
+	serversMux sync.RWMutex
+	servers    []*server
+)
+
+// getServers returns the servers, the servers may be appended concurrently
+func getServers() []*server {
+	serversMux.RLock()
+	defer serversMux.RUnlock()
+	return servers
+}
+
+func init() {
+	overload.RegisterUsage(overload.MonitorActiveConnections, func() (uint64, error) {
+		var n uint64
+		for _, srv := range getServers() {
+			n += srv.handler.NumConnections()
+		}
+		return n, nil
+	})
+}
 
 type server struct {
 	serverName string
@@ -56,6 +79,7 @@ func NewConfig(c *v2.ServerConfig) *Config {
 		LogRoller:       c.GlobalLogRoller,
 		GracefulTimeout: c.GracefulTimeout.Duration,
 		UseNetpollMode:  c.UseNetpollMode,
+		MaxConnections:  c.MaxConnections,
 	}
 }
 
@@ -67,6 +91,8 @@ func NewServer(config *Config, cmFilter types.ClusterManagerFilter, clMng types.
 			GracefulTimeout = config.GracefulTimeout
 		}
 
+		SetMaxConnections(config.MaxConnections)
+
 		if config.UseNetpollMode {
 			network.UseNetpollMode = config.UseNetpollMode
 			log.DefaultLogger.Infof("[server] [reconfigure] [new server] Netpoll mode enabled.")
@@ -81,7 +107,9 @@ func NewServer(config *Config, cmFilter types.ClusterManagerFilter, clMng types.
 
 	initListenerAdapterInstance(server.serverName, server.handler)
 
+	serversMux.Lock()
 	servers = append(servers, server)
+	serversMux.Unlock()
 
 	return server
 }
@@ -127,20 +155,20 @@ func (srv *server) Handler() types.ConnectionHandler {
 
 // Stop the server
 func Stop() {
-	for _, server := range servers {
+	for _, server := range getServers() {
 		server.Close()
 	}
 }
 
 func shutdownServers() {
-	for _, server := range servers {
+	for _, server := range getServers() {
 		server.Shutdown()
 	}
 }
 
 // StopConnection stops all connections in servers
 func StopConnection() {
-	for _, server := range servers {
+	for _, server := range getServers() {
 		server.handler.StopConnection()
 	}
 }
@@ -148,7 +176,7 @@ func StopConnection() {
 // ListListenersFile returns all server listener's fds
 func ListListenersFile() []*os.File {
 	var files []*os.File
-	for _, server := range servers {
+	for _, server := range getServers() {
 		files = append(files, server.handler.ListListenersFile(nil)...)
 	}
 	return files
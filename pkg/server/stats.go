@@ -23,14 +23,16 @@ import (
 )
 
 type listenerStats struct {
-	DownstreamBytesReadTotal  gometrics.Counter
-	DownstreamBytesWriteTotal gometrics.Counter
+	DownstreamBytesReadTotal     gometrics.Counter
+	DownstreamBytesWriteTotal    gometrics.Counter
+	DownstreamConnectionOverflow gometrics.Counter
 }
 
 func newListenerStats(listenerName string) *listenerStats {
 	s := metrics.NewListenerStats(listenerName)
 	return &listenerStats{
-		DownstreamBytesReadTotal:  s.Counter(metrics.DownstreamBytesReadTotal),
-		DownstreamBytesWriteTotal: s.Counter(metrics.DownstreamBytesWriteTotal),
+		DownstreamBytesReadTotal:     s.Counter(metrics.DownstreamBytesReadTotal),
+		DownstreamBytesWriteTotal:    s.Counter(metrics.DownstreamBytesWriteTotal),
+		DownstreamConnectionOverflow: s.Counter(metrics.DownstreamConnectionOverflow),
 	}
 }
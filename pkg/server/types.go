@@ -32,6 +32,7 @@ type Config struct {
 	LogRoller       string
 	GracefulTimeout time.Duration
 	UseNetpollMode  bool
+	MaxConnections  uint64
 }
 
 type Server interface {
@@ -110,6 +110,7 @@ func (p *connPool) NewStream(ctx context.Context, receiver types.StreamReceiveLi
 	host.ClusterInfo().Stats().UpstreamRequestActive.Inc(1)
 	host.ClusterInfo().ResourceManager().Requests().Increase()
 
+	c.tunnel, _ = mosnctx.Get(ctx, types.ContextKeyUpgradeTunnel).(*str.Tunnel)
 	streamEncoder := c.client.NewStream(ctx, receiver)
 	streamEncoder.GetStream().AddEventListener(c)
 	return host, streamEncoder, ""
@@ -226,9 +227,9 @@ func (p *connPool) onStreamDestroy(client *activeClient) {
 	host.ClusterInfo().Stats().UpstreamRequestActive.Dec(1)
 	host.ClusterInfo().ResourceManager().Requests().Decrease()
 
-	// return to pool
+	// return to pool, the connection detached into the upgrade tunnel is not reused
 	p.clientMux.Lock()
-	if !client.closed {
+	if !client.closed && !(client.tunnel != nil && client.tunnel.Detached()) {
 		p.availableClients = append(p.availableClients, client)
 	}
 	p.clientMux.Unlock()
@@ -278,6 +279,7 @@ type activeClient struct {
 	closeWithActiveReq bool
 	closed             bool
 	closeConn          bool
+	tunnel             *str.Tunnel
 }
 
 func newActiveClient(ctx context.Context, pool *connPool) (*activeClient, types.PoolFailureReason) {
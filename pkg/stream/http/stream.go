@@ -27,6 +27,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -36,6 +37,7 @@ import (
 	mbuffer "mosn.io/mosn/pkg/buffer"
 	mosnctx "mosn.io/mosn/pkg/context"
 	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/protocol"
 	mosnhttp "mosn.io/mosn/pkg/protocol/http"
 	str "mosn.io/mosn/pkg/stream"
@@ -284,8 +286,8 @@ func (conn *clientStreamConnection) serve() {
 		request := &buffers.serverRequest
 
 		// Response.Read() skips reading body if set to true.
-		// Use it for reading HEAD responses.
-		if request.Header.IsHead() {
+		// Use it for reading HEAD responses, and the responses of CONNECT requests.
+		if request.Header.IsHead() || (s.tunnel != nil && s.tunnel.Enabled() && s.tunnel.IsConnect()) {
 			s.response.SkipBody = true
 		}
 
@@ -319,9 +321,19 @@ func (conn *clientStreamConnection) serve() {
 			s.connection.streamConnectionEventListener.OnGoAway()
 		}
 
+		// 4. the accepted upgrade response detaches the connection into the tunnel
+		tunnel := s.tunnel
+		upgraded := !resetConn && tunnel != nil && upgradeAccepted(tunnel, s.response.StatusCode()) &&
+			tunnel.SetUpstream(&connEndpoint{conn: conn.conn})
+
 		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
 			s.handleResponse()
 		}
+
+		if upgraded {
+			relay(conn.br, tunnel.ToDownstream, tunnel.WaitDownstream, tunnel)
+			return
+		}
 	}
 }
 
@@ -338,6 +350,7 @@ func (conn *clientStreamConnection) NewStream(ctx context.Context, receiver type
 		receiver: receiver,
 	}
 	s.connection = conn
+	s.tunnel, _ = mosnctx.Get(ctx, types.ContextKeyUpgradeTunnel).(*str.Tunnel)
 
 	conn.mutex.Lock()
 	conn.stream = s
@@ -558,6 +571,12 @@ func (conn *serverStreamConnection) serve() {
 		s.connection = conn
 		s.responseDoneChan = make(chan bool, 1)
 		s.header = mosnhttp.RequestHeader{&s.request.Header}
+		s.tunnel = nil
+		if typ := upgradeType(request); typ != "" {
+			s.tunnel = str.NewTunnel(typ, string(request.Header.RequestURI()), &connEndpoint{conn: conn.conn})
+			s.tunnel.SetBufferLimit(conn.conn.BufferLimit())
+			ctx = mosnctx.WithValue(ctx, types.ContextKeyUpgradeTunnel, types.UpgradeTunnel(s.tunnel))
+		}
 
 		// save the connection state, because clientStream AppendHeaders will modify it.
 		if s.header.ConnectionClose() {
@@ -587,6 +606,7 @@ func (conn *serverStreamConnection) serve() {
 		// because it will be recycled in proxy
 		// refer https://github.com/mosn/mosn/issues/1948
 		responseDoneChan := s.responseDoneChan
+		tunnel := s.tunnel
 
 		if atomic.LoadInt32(&s.readDisableCount) <= 0 {
 			s.handleRequest(s.stream.ctx)
@@ -596,6 +616,15 @@ func (conn *serverStreamConnection) serve() {
 		select {
 		case <-responseDoneChan:
 		case <-conn.connClosed:
+			if tunnel != nil {
+				tunnel.Close()
+			}
+			return
+		}
+
+		// 6. the connection is switched into the tunnel, no more requests
+		if tunnel != nil && tunnel.Established() {
+			relay(conn.br, tunnel.ToUpstream, tunnel.WaitUpstream, tunnel)
 			return
 		}
 
@@ -603,6 +632,18 @@ func (conn *serverStreamConnection) serve() {
 	}
 }
 
+// GoAway makes the connection to be closed: the response of the in-flight request
+// carries 'Connection: close', and an idle connection is closed directly.
+func (conn *serverStreamConnection) GoAway() {
+	conn.mutex.Lock()
+	conn.close = true
+	idle := conn.stream == nil
+	conn.mutex.Unlock()
+	if idle {
+		conn.conn.Close(api.FlushWrite, api.LocalClose)
+	}
+}
+
 func (conn *serverStreamConnection) ActiveStreamsNum() int {
 	conn.mutex.RLock()
 	defer conn.mutex.RUnlock()
@@ -658,11 +699,17 @@ type clientStream struct {
 	stream
 
 	connection *clientStreamConnection
+	tunnel     *str.Tunnel
 }
 
 // types.StreamSender
 func (s *clientStream) AppendHeaders(context context.Context, headersIn types.HeaderMap, endStream bool) error {
-	headers := headersIn.(mosnhttp.RequestHeader)
+	headers, ok := headersIn.(mosnhttp.RequestHeader)
+	if !ok {
+		// the request headers of the other protocols, such as http2
+		headers = mosnhttp.RequestHeader{&fasthttp.RequestHeader{}}
+		copyHeaders(headersIn, headers.Set)
+	}
 
 	// TODO: protocol convert in pkg/protocol
 	//if the request contains body, use "POST" as default, the http request method will be setted by MosnHeaderMethod
@@ -679,6 +726,15 @@ func (s *clientStream) AppendHeaders(context context.Context, headersIn types.He
 
 	FillRequestHeadersFromCtxVar(context, headers, s.connection.conn.RemoteAddr())
 
+	if s.tunnel != nil && s.tunnel.Enabled() {
+		if s.tunnel.IsConnect() {
+			// the CONNECT request uri is the authority, not the path
+			headers.SetRequestURI(s.tunnel.Authority())
+		} else if string(headers.Method()) == http.MethodConnect {
+			toUpgradeRequest(headers, s.tunnel.Type())
+		}
+	}
+
 	// copy headers
 	headers.CopyTo(&s.request.Header)
 
@@ -781,6 +837,7 @@ type serverStream struct {
 	header           mosnhttp.RequestHeader
 	connection       *serverStreamConnection
 	responseDoneChan chan bool
+	tunnel           *str.Tunnel
 }
 
 // types.StreamSender
@@ -814,6 +871,15 @@ func (s *serverStream) AppendHeaders(context context.Context, headersIn types.He
 		}
 
 		headers.CopyTo(&s.response.Header)
+
+	default:
+		// the response headers of the other protocols, such as http2
+		status, err := variable.GetString(context, types.VarHeaderStatus)
+		if err == nil && status != "" {
+			statusCode, _ := strconv.Atoi(status)
+			s.response.SetStatusCode(statusCode)
+		}
+		copyHeaders(headersIn, s.response.Header.Set)
 	}
 
 	if endStream {
@@ -840,6 +906,27 @@ func (s *serverStream) AppendTrailers(context context.Context, trailers types.He
 }
 
 func (s *serverStream) endStream() {
+	// the established tunnel keeps the connection, and starts relaying after the response is sent
+	if s.tunnel != nil && s.tunnel.Detached() {
+		statusCode := s.response.StatusCode()
+		if !s.tunnel.IsConnect() && statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices {
+			// the upstream is an accepted http2 extended CONNECT
+			toUpgradeResponse(s.request, s.response, s.tunnel.Type())
+		}
+	}
+	if s.tunnel != nil && s.tunnel.Establish(upgradeAccepted(s.tunnel, s.response.StatusCode())) {
+		defer s.DestroyStream()
+
+		s.doSend()
+		s.tunnel.Start()
+		s.responseDoneChan <- true
+
+		s.connection.mutex.Lock()
+		s.connection.stream = nil
+		s.connection.mutex.Unlock()
+		return
+	}
+
 	resetConn := false
 
 	// Response.Write() skips writing body if set to true.
@@ -848,8 +935,8 @@ func (s *serverStream) endStream() {
 		s.response.SkipBody = true
 	}
 
-	// check if we need close connection
-	if s.connection.close || s.request.Header.ConnectionClose() {
+	// check if we need close connection, the keep-alive is disabled when overloaded
+	if s.connection.close || s.request.Header.ConnectionClose() || overload.Triggered(overload.ActionDisableKeepalive) {
 		// should delete 'Connection:keepalive' header
 		if !s.response.ConnectionClose() {
 			s.response.Header.Del("Connection")
@@ -923,6 +1010,16 @@ func (s *serverStream) GetStream() types.Stream {
 	return s
 }
 
+// copyHeaders copies the headers except the pseudo-headers, such as the http2 :protocol
+func copyHeaders(headers types.HeaderMap, set func(key, value string)) {
+	headers.Range(func(key, value string) bool {
+		if !strings.HasPrefix(key, ":") {
+			set(key, value)
+		}
+		return true
+	})
+}
+
 // consider host, method, path are necessary, but check querystring
 func injectCtxVarFromProtocolHeaders(ctx context.Context, header mosnhttp.RequestHeader, uri *fasthttp.URI) {
 	// 1. host
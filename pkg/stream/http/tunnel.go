@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	str "mosn.io/mosn/pkg/stream"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+const (
+	relayBufferSize = 16 * 1024
+
+	upgradeWebSocket = "websocket"
+	websocketGUID    = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// upgradeType returns the upgrade type of the request: CONNECT for the CONNECT requests,
+// the lower case value of the Upgrade header for the upgrade requests, or empty.
+func upgradeType(request *fasthttp.Request) string {
+	if string(request.Header.Method()) == http.MethodConnect {
+		return types.UpgradeTypeConnect
+	}
+	upgrade := request.Header.Peek("Upgrade")
+	if len(upgrade) == 0 {
+		return ""
+	}
+	for _, token := range strings.Split(string(request.Header.Peek("Connection")), ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+			return strings.ToLower(string(upgrade))
+		}
+	}
+	return ""
+}
+
+// upgradeAccepted checks the http1 response status, the CONNECT request is accepted
+// by any 2xx response, and the upgrade request is accepted by the 101 response.
+func upgradeAccepted(tunnel *str.Tunnel, statusCode int) bool {
+	if tunnel.IsConnect() {
+		return statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices
+	}
+	return statusCode == http.StatusSwitchingProtocols
+}
+
+// toUpgradeRequest translates the http2 extended CONNECT request into the http1 upgrade request
+func toUpgradeRequest(headers mosnhttp.RequestHeader, upgrade string) {
+	headers.SetMethod(http.MethodGet)
+	headers.Set("Connection", "Upgrade")
+	headers.Set("Upgrade", upgrade)
+	if upgrade != upgradeWebSocket {
+		return
+	}
+	// RFC 8441 does not use the key, but the http1 websocket servers require it
+	if _, ok := headers.Get("Sec-WebSocket-Key"); !ok {
+		key := make([]byte, 16)
+		rand.Read(key)
+		headers.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	}
+	if _, ok := headers.Get("Sec-WebSocket-Version"); !ok {
+		headers.Set("Sec-WebSocket-Version", "13")
+	}
+}
+
+// toUpgradeResponse translates the 2xx response of the http2 extended CONNECT request into
+// the 101 response of the http1 upgrade request
+func toUpgradeResponse(request *fasthttp.Request, response *fasthttp.Response, upgrade string) {
+	response.SetStatusCode(http.StatusSwitchingProtocols)
+	response.Header.Set("Connection", "Upgrade")
+	response.Header.Set("Upgrade", upgrade)
+	if upgrade != upgradeWebSocket {
+		return
+	}
+	if key := request.Header.Peek("Sec-WebSocket-Key"); len(key) > 0 {
+		response.Header.Set("Sec-WebSocket-Accept", websocketAccept(string(key)))
+	}
+}
+
+// websocketAccept computes the Sec-WebSocket-Accept of the key, see RFC 6455 4.2.2
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// connEndpoint is the http1 connection side of the tunnel
+type connEndpoint struct {
+	conn api.Connection
+}
+
+func (e *connEndpoint) Write(data []byte) error {
+	buf := buffer.GetIoBuffer(len(data))
+	buf.Write(data)
+	return e.conn.Write(buf)
+}
+
+func (e *connEndpoint) Close() {
+	e.conn.Close(api.FlushWrite, api.LocalClose)
+}
+
+// relay reads the data from the http1 connection until it fails, and closes the tunnel.
+// the reading waits if the other side is slow, so the data queued in the tunnel is limited.
+func relay(r io.Reader, write func(data []byte) error, wait func(), tunnel *str.Tunnel) {
+	defer tunnel.Close()
+	buf := make([]byte, relayBufferSize)
+	for {
+		wait()
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
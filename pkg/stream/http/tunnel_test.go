@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/mock"
+	mosnhttp "mosn.io/mosn/pkg/protocol/http"
+	str "mosn.io/mosn/pkg/stream"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/buffer"
+)
+
+func TestUpgradeType(t *testing.T) {
+	testCases := []struct {
+		request  string
+		expected string
+	}{
+		{"GET /chat HTTP/1.1\r\nHost: a\r\nConnection: keep-alive, Upgrade\r\nUpgrade: WebSocket\r\n\r\n", "websocket"},
+		{"GET /chat HTTP/1.1\r\nHost: a\r\nUpgrade: websocket\r\n\r\n", ""},
+		{"GET /chat HTTP/1.1\r\nHost: a\r\n\r\n", ""},
+		{"CONNECT a:443 HTTP/1.1\r\nHost: a:443\r\n\r\n", types.UpgradeTypeConnect},
+	}
+	for _, tc := range testCases {
+		request := &fasthttp.Request{}
+		if err := request.Read(bufio.NewReader(strings.NewReader(tc.request))); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tc.expected, upgradeType(request), tc.request)
+	}
+}
+
+func TestUpgradeAccepted(t *testing.T) {
+	connect := str.NewTunnel(types.UpgradeTypeConnect, "a:443", nil)
+	assert.True(t, upgradeAccepted(connect, http.StatusOK))
+	assert.True(t, upgradeAccepted(connect, http.StatusNoContent))
+	assert.False(t, upgradeAccepted(connect, http.StatusSwitchingProtocols))
+	websocket := str.NewTunnel(upgradeWebSocket, "", nil)
+	assert.True(t, upgradeAccepted(websocket, http.StatusSwitchingProtocols))
+	assert.False(t, upgradeAccepted(websocket, http.StatusOK))
+}
+
+func TestUpgradeTranslation(t *testing.T) {
+	// the extended CONNECT request of http2 is translated into the http1 upgrade request
+	headers := mosnhttp.RequestHeader{&fasthttp.RequestHeader{}}
+	headers.SetMethod(http.MethodConnect)
+	toUpgradeRequest(headers, upgradeWebSocket)
+	assert.Equal(t, http.MethodGet, string(headers.Method()))
+	upgrade, _ := headers.Get("Upgrade")
+	assert.Equal(t, upgradeWebSocket, upgrade)
+	key, ok := headers.Get("Sec-WebSocket-Key")
+	assert.True(t, ok)
+	assert.NotEmpty(t, key)
+	version, _ := headers.Get("Sec-WebSocket-Version")
+	assert.Equal(t, "13", version)
+
+	// the 200 response is translated into the 101 response, see the example in RFC 6455
+	request := &fasthttp.Request{}
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	response := &fasthttp.Response{}
+	response.SetStatusCode(http.StatusOK)
+	toUpgradeResponse(request, response, upgradeWebSocket)
+	assert.Equal(t, http.StatusSwitchingProtocols, response.StatusCode())
+	assert.Equal(t, "websocket", string(response.Header.Peek("Upgrade")))
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", string(response.Header.Peek("Sec-WebSocket-Accept")))
+}
+
+func TestRelay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	downstream := mock.NewMockConnection(ctrl)
+	upstream := mock.NewMockConnection(ctrl)
+
+	upstreamData := &bytes.Buffer{}
+	upstream.EXPECT().Write(gomock.Any()).DoAndReturn(func(bufs ...buffer.IoBuffer) error {
+		for _, b := range bufs {
+			upstreamData.Write(b.Bytes())
+		}
+		return nil
+	}).AnyTimes()
+	downstream.EXPECT().Close(api.FlushWrite, api.LocalClose).Return(nil)
+	upstream.EXPECT().Close(api.FlushWrite, api.LocalClose).Return(nil)
+
+	tn := str.NewTunnel(upgradeWebSocket, "", &connEndpoint{conn: downstream})
+	tn.Enable(0)
+	assert.True(t, tn.SetUpstream(&connEndpoint{conn: upstream}))
+	assert.True(t, tn.Establish(true))
+	tn.Start()
+
+	// the downstream data is relayed until EOF, and the tunnel is closed
+	relay(bytes.NewReader([]byte("hello tunnel")), tn.ToUpstream, tn.WaitUpstream, tn)
+	assert.Equal(t, "hello tunnel", upstreamData.String())
+	select {
+	case <-tn.Done():
+	default:
+		t.Fatal("tunnel is not closed")
+	}
+}
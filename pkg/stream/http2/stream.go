@@ -36,6 +36,7 @@ import (
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/module/http2"
 	"mosn.io/mosn/pkg/mtls"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/protocol"
 	mhttp2 "mosn.io/mosn/pkg/protocol/http2"
 	str "mosn.io/mosn/pkg/stream"
@@ -43,6 +44,7 @@ import (
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/mosn/pkg/variable"
 	"mosn.io/pkg/buffer"
+	"mosn.io/pkg/utils"
 )
 
 // TODO: move it to main
@@ -146,6 +148,9 @@ func (s *stream) GetStream() types.Stream {
 
 type StreamConfig struct {
 	Http2UseStream bool `json:"http2_use_stream,omitempty"`
+	// Http2EnableConnectProtocol advertises SETTINGS_ENABLE_CONNECT_PROTOCOL to the downstream,
+	// so the extended CONNECT requests (RFC 8441), such as the websocket over http2, are accepted.
+	Http2EnableConnectProtocol bool `json:"http2_enable_connect_protocol,omitempty"`
 }
 
 var defaultStreamConfig = StreamConfig{
@@ -223,6 +228,7 @@ func newServerStreamConnection(ctx context.Context, connection api.Connection, s
 	}
 
 	sc.useStream = sc.config.Http2UseStream
+	h2sc.EnableConnectProtocol = sc.config.Http2EnableConnectProtocol
 
 	// init first context
 	sc.cm.Next()
@@ -333,7 +339,12 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 	var stream *serverStream
 	// header
 	if h2s != nil {
-		stream, err = conn.onNewStreamDetect(mosnctx.Clone(ctx), h2s, endStream)
+		streamCtx := mosnctx.Clone(ctx)
+		tunnel := conn.newTunnel(h2s, endStream)
+		if tunnel != nil {
+			streamCtx = mosnctx.WithValue(streamCtx, types.ContextKeyUpgradeTunnel, tunnel)
+		}
+		stream, err = conn.onNewStreamDetect(streamCtx, h2s, endStream)
 		if err != nil {
 			conn.handleError(ctx, f, err)
 			return
@@ -368,6 +379,13 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 		}
 		stream.header = header
 		stream.trailer = &mhttp2.HeaderMap{}
+
+		// the tunnel request is routed without waiting for the body, the data frames are relayed by the tunnel
+		if tunnel != nil {
+			stream.tunnel = tunnel
+			stream.receiver.OnReceive(stream.ctx, header, nil, nil)
+			return
+		}
 	}
 
 	if stream == nil {
@@ -378,6 +396,11 @@ func (conn *serverStreamConnection) handleFrame(ctx context.Context, i interface
 		}
 	}
 
+	if stream.tunnel != nil {
+		relayTunnelFrame(stream.tunnel, stream.tunnel.ToUpstream, data, endStream)
+		return
+	}
+
 	// data
 	if data != nil {
 		if log.Proxy.GetLogLevel() >= log.DEBUG {
@@ -470,6 +493,11 @@ func (conn *serverStreamConnection) onNewStreamDetect(ctx context.Context, h2s *
 	conn.streams[stream.id] = stream
 	conn.mutex.Unlock()
 
+	// the connection is drained by a goaway when overloaded, the current stream is still served
+	if overload.Triggered(overload.ActionDisableKeepalive) {
+		conn.GoAway()
+	}
+
 	var span api.Span
 	if trace.IsEnabled() {
 		// try build trace span
@@ -483,6 +511,24 @@ func (conn *serverStreamConnection) onNewStreamDetect(ctx context.Context, h2s *
 	return stream, nil
 }
 
+// newTunnel creates the tunnel of the CONNECT request, the type of the extended CONNECT
+// request is the :protocol, such as websocket.
+func (conn *serverStreamConnection) newTunnel(h2s *http2.MStream, endStream bool) *str.Tunnel {
+	if endStream || h2s.Request.Method != http.MethodConnect {
+		return nil
+	}
+	typ := types.UpgradeTypeConnect
+	if protocol := http2.ExtendedConnectProtocol(h2s.Request); protocol != "" {
+		typ = strings.ToLower(protocol)
+	}
+	// the writing blocks on the flow control, which should not block the reading of the connection
+	endpoint := str.NewAsyncEndpoint(&serverEndpoint{sc: conn, h2s: h2s}, conn.conn.BufferLimit())
+	tunnel := str.NewTunnel(typ, h2s.Request.Host, endpoint)
+	tunnel.SetBufferLimit(conn.conn.BufferLimit())
+	endpoint.OnError(tunnel.Close)
+	return tunnel
+}
+
 func (conn *serverStreamConnection) onStreamRecv(ctx context.Context, id uint32, endStream bool) *serverStream {
 	conn.mutex.Lock()
 	defer conn.mutex.Unlock()
@@ -501,6 +547,7 @@ type serverStream struct {
 	sc            *serverStreamConnection
 	reqUseStream  bool
 	respUseStream bool
+	tunnel        *str.Tunnel
 }
 
 // types.StreamSender
@@ -588,6 +635,10 @@ func (s *serverStream) ResetStream(reason types.StreamResetReason) {
 	if s.reqUseStream && s.recData != nil {
 		s.recData.CloseWithError(io.EOF)
 	}
+	if s.tunnel != nil {
+		// the endpoints take the connection lock, which may be held by the caller
+		utils.GoWithRecover(s.tunnel.Close, nil)
+	}
 
 	s.h2s.Reset()
 	s.stream.ResetStream(reason)
@@ -598,6 +649,11 @@ func (s *serverStream) GetStream() types.Stream {
 }
 
 func (s *serverStream) endStream() {
+	// the established tunnel keeps the stream, and starts relaying after the response headers are sent
+	if s.tunnel != nil && s.tunnel.Detached() && s.establishTunnel() {
+		return
+	}
+
 	if s.h2s.SendData != nil {
 		// Need to reset the 'Content-Length' response header when it's a direct response.
 		isDirectResponse, _ := variable.GetString(s.ctx, types.VarProxyIsDirectResponse)
@@ -626,6 +682,31 @@ func (s *serverStream) endStream() {
 	}
 }
 
+// establishTunnel sends the response headers of the accepted tunnel without END_STREAM
+func (s *serverStream) establishTunnel() bool {
+	rsp := s.h2s.Response
+	if !s.tunnel.IsConnect() && rsp.StatusCode == http.StatusSwitchingProtocols {
+		// the upstream is an accepted http1 upgrade, the extended CONNECT is accepted by 200
+		rsp.StatusCode = http.StatusOK
+		rsp.Header.Del("Connection")
+		rsp.Header.Del("Upgrade")
+		rsp.Header.Del("Sec-WebSocket-Accept")
+	}
+	if !s.tunnel.Establish(rsp.StatusCode >= http.StatusOK && rsp.StatusCode < http.StatusMultipleChoices) {
+		return false
+	}
+	if err := s.h2s.WriteTunnelHeader(); err != nil {
+		log.Proxy.Errorf(s.ctx, "http2 server send tunnel response error: %v", err)
+		s.tunnel.Close()
+		return true
+	}
+	if log.Proxy.GetLogLevel() >= log.DEBUG {
+		log.Proxy.Debugf(s.ctx, "http2 server %s tunnel established, id = %d", s.tunnel.Type(), s.id)
+	}
+	s.tunnel.Start()
+	return true
+}
+
 type clientStreamConnection struct {
 	streamConnection
 	lastStream                    uint32
@@ -750,6 +831,7 @@ func (conn *clientStreamConnection) NewStream(ctx context.Context, receiver type
 			stream.reqUseStream = h2UseStream
 		}
 	}
+	stream.tunnel, _ = mosnctx.Get(ctx, types.ContextKeyUpgradeTunnel).(*str.Tunnel)
 
 	return stream
 }
@@ -797,6 +879,11 @@ func (conn *clientStreamConnection) handleFrame(ctx context.Context, i interface
 		return
 	}
 
+	if stream.tunneled {
+		relayTunnelFrame(stream.tunnel, stream.tunnel.ToDownstream, data, endStream)
+		return
+	}
+
 	if rsp != nil {
 		header := mhttp2.NewRspHeader(rsp)
 
@@ -823,6 +910,16 @@ func (conn *clientStreamConnection) handleFrame(ctx context.Context, i interface
 		}
 		stream.header = header
 		stream.trailer = &mhttp2.HeaderMap{}
+
+		// the accepted tunnel keeps the stream, the data frames are relayed by the tunnel
+		if stream.tunnel != nil && rsp.StatusCode >= http.StatusOK && rsp.StatusCode < http.StatusMultipleChoices &&
+			stream.tunnel.SetUpstream(conn.newTunnelEndpoint(stream)) {
+			stream.tunneled = true
+			if stream.receiver != nil {
+				stream.receiver.OnReceive(stream.ctx, header, nil, nil)
+			}
+			return
+		}
 	}
 
 	// data
@@ -907,6 +1004,9 @@ type clientStream struct {
 	reqUseStream  bool
 	respUseStream bool
 	connReset     bool
+	tunnel        *str.Tunnel
+	// tunneled is set if the upstream accepts the tunnel
+	tunneled bool
 
 	h2s *http2.MClientStream
 	sc  *clientStreamConnection
@@ -979,12 +1079,17 @@ func (s *clientStream) AppendHeaders(ctx context.Context, headersIn api.HeaderMa
 		req.Header = mhttp2.EncodeHeader(headersIn)
 	}
 
+	if s.tunnel != nil && s.tunnel.Enabled() {
+		s.toConnectRequest(req)
+	}
+
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
 		log.Proxy.Debugf(s.ctx, "http2 client AppendHeaders: id = %d, headers = %+v", s.id, req.Header)
 	}
 
 	s.h2s = http2.NewMClientStream(s.sc.mClientConn, req)
 	s.h2s.UseStream = s.reqUseStream
+	s.h2s.Tunnel = s.tunnel != nil && s.tunnel.Enabled()
 
 	if endStream {
 		s.endStream()
@@ -992,6 +1097,22 @@ func (s *clientStream) AppendHeaders(ctx context.Context, headersIn api.HeaderMa
 	return nil
 }
 
+// toConnectRequest translates the tunnel request into the CONNECT request, the upgrade request,
+// such as the http1 websocket, is translated into the extended CONNECT request.
+func (s *clientStream) toConnectRequest(req *http.Request) {
+	req.Method = http.MethodConnect
+	for _, key := range []string{"Connection", "Upgrade", "Sec-WebSocket-Key", "Content-Length"} {
+		req.Header.Del(key)
+	}
+	if s.tunnel.IsConnect() {
+		req.Host = s.tunnel.Authority()
+		req.URL = &url.URL{Host: req.Host}
+		delete(req.Header, http2.ProtocolPseudoHeader)
+		return
+	}
+	req.Header[http2.ProtocolPseudoHeader] = []string{s.tunnel.Type()}
+}
+
 func (s *clientStream) AppendData(context context.Context, data buffer.IoBuffer, endStream bool) error {
 	s.h2s.SendData = data
 	if log.Proxy.GetLogLevel() >= log.DEBUG {
@@ -1095,6 +1216,10 @@ func (s *clientStream) ResetStream(reason types.StreamResetReason) {
 	if s.h2s != nil {
 		s.h2s.Reset()
 	}
+	if s.tunneled {
+		// the endpoints take the connection lock, which may be held by the caller
+		utils.GoWithRecover(s.tunnel.Close, nil)
+	}
 
 	if !s.connReset {
 		s.sc.mutex.Lock()
@@ -1104,3 +1229,59 @@ func (s *clientStream) ResetStream(reason types.StreamResetReason) {
 
 	s.stream.ResetStream(reason)
 }
+
+// relayTunnelFrame relays the data of the tunnel stream, the tunnel is closed if the stream ends.
+// it is called by the reading goroutine of the connection, the data is queued by the tunnel endpoint
+// of the other side, and the tunnel is closed if its buffer limit is exceeded.
+func relayTunnelFrame(tunnel *str.Tunnel, write func(data []byte) error, data []byte, endStream bool) {
+	if data != nil {
+		if err := write(data); err != nil {
+			tunnel.Close()
+			return
+		}
+	}
+	if endStream {
+		tunnel.Close()
+	}
+}
+
+// serverEndpoint is the downstream http2 stream side of the tunnel
+type serverEndpoint struct {
+	sc  *serverStreamConnection
+	h2s *http2.MStream
+}
+
+func (e *serverEndpoint) Write(data []byte) error {
+	return e.h2s.WriteTunnelData(data)
+}
+
+func (e *serverEndpoint) Close() {
+	e.h2s.EndTunnel()
+	e.sc.mutex.Lock()
+	delete(e.sc.streams, e.h2s.ID())
+	e.sc.mutex.Unlock()
+}
+
+// newTunnelEndpoint creates the upstream endpoint, the writing blocks on the flow control of the stream
+func (conn *clientStreamConnection) newTunnelEndpoint(s *clientStream) str.TunnelEndpoint {
+	endpoint := str.NewAsyncEndpoint(&clientEndpoint{sc: conn, h2s: s.h2s}, conn.conn.BufferLimit())
+	endpoint.OnError(s.tunnel.Close)
+	return endpoint
+}
+
+// clientEndpoint is the upstream http2 stream side of the tunnel
+type clientEndpoint struct {
+	sc  *clientStreamConnection
+	h2s *http2.MClientStream
+}
+
+func (e *clientEndpoint) Write(data []byte) error {
+	return e.h2s.WriteTunnelData(data)
+}
+
+func (e *clientEndpoint) Close() {
+	e.h2s.EndTunnel()
+	e.sc.mutex.Lock()
+	delete(e.sc.streams, e.h2s.GetID())
+	e.sc.mutex.Unlock()
+}
@@ -0,0 +1,133 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package triple registers the Triple protocol, the streams are the http2 streams,
+// and the dubbo headers of the Triple requests are set before the requests are received
+// and stripped before the requests are sent to the upstream.
+package triple
+
+import (
+	"context"
+
+	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/triple"
+	"mosn.io/mosn/pkg/stream/http2"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+)
+
+func init() {
+	protocol.RegisterProtocolConfigHandler(triple.ProtocolName, func(v interface{}) interface{} {
+		return protocol.HandleConfig(protocol.HTTP2, v)
+	})
+	protocol.RegisterProtocol(triple.ProtocolName, newConnPool, &streamConnFactory{}, protocol.GetStatusCodeMapping{})
+}
+
+// connPool is the http2 connection pool, the dubbo headers are stripped from the requests
+type connPool struct {
+	types.ConnectionPool
+}
+
+func newConnPool(ctx context.Context, host types.Host) types.ConnectionPool {
+	return &connPool{http2.NewConnPool(ctx, host)}
+}
+
+func (p *connPool) NewStream(ctx context.Context, receiver types.StreamReceiveListener) (types.Host, types.StreamSender, types.PoolFailureReason) {
+	host, sender, reason := p.ConnectionPool.NewStream(ctx, receiver)
+	if sender != nil {
+		sender = &streamSender{sender}
+	}
+	return host, sender, reason
+}
+
+type streamSender struct {
+	types.StreamSender
+}
+
+func (s *streamSender) AppendHeaders(ctx context.Context, headers api.HeaderMap, endStream bool) error {
+	// the headers of the downstream request are kept for the retries and the access logs
+	headers = headers.Clone()
+	triple.StripDubboHeaders(headers)
+	return s.StreamSender.AppendHeaders(ctx, headers, endStream)
+}
+
+type streamConnFactory struct {
+	http2.StreamConnFactory
+}
+
+func (f *streamConnFactory) CreateClientStream(context context.Context, connection types.ClientConnection,
+	clientCallbacks types.StreamConnectionEventListener, connCallbacks api.ConnectionEventListener) types.ClientStreamConnection {
+	return f.StreamConnFactory.CreateClientStream(http2Context(context), connection, clientCallbacks, connCallbacks)
+}
+
+func (f *streamConnFactory) CreateServerStream(context context.Context, connection api.Connection,
+	serverCallbacks types.ServerStreamConnectionEventListener) types.ServerStreamConnection {
+	conn := f.StreamConnFactory.CreateServerStream(http2Context(context), connection, &serverStreamCallbacks{serverCallbacks})
+	return &serverStreamConnection{conn}
+}
+
+// ProtocolMatch never matches, the Triple connections can not be distinguished from
+// the http2 connections by the preface, the Triple protocol should be configured explicitly.
+func (f *streamConnFactory) ProtocolMatch(context context.Context, prot string, magic []byte) error {
+	return protocol.FAILED
+}
+
+// http2Context makes the http2 streams use the extend config of the Triple protocol
+func http2Context(ctx context.Context) context.Context {
+	extendConfig, ok := mosnctx.Get(ctx, types.ContextKeyProxyGeneralConfig).(map[api.ProtocolName]interface{})
+	if !ok {
+		return ctx
+	}
+	cfg, ok := extendConfig[triple.ProtocolName]
+	if !ok {
+		return ctx
+	}
+	configs := make(map[api.ProtocolName]interface{}, len(extendConfig))
+	for proto, c := range extendConfig {
+		configs[proto] = c
+	}
+	configs[protocol.HTTP2] = cfg
+	return mosnctx.WithValue(mosnctx.Clone(ctx), types.ContextKeyProxyGeneralConfig, configs)
+}
+
+type serverStreamConnection struct {
+	types.ServerStreamConnection
+}
+
+func (sc *serverStreamConnection) Protocol() types.ProtocolName {
+	return triple.ProtocolName
+}
+
+type serverStreamCallbacks struct {
+	types.ServerStreamConnectionEventListener
+}
+
+func (cb *serverStreamCallbacks) NewStreamDetect(ctx context.Context, sender types.StreamSender, span api.Span) types.StreamReceiveListener {
+	return &streamReceiver{cb.ServerStreamConnectionEventListener.NewStreamDetect(ctx, sender, span)}
+}
+
+type streamReceiver struct {
+	types.StreamReceiveListener
+}
+
+func (r *streamReceiver) OnReceive(ctx context.Context, headers api.HeaderMap, data types.IoBuffer, trailers api.HeaderMap) {
+	path, _ := variable.GetString(ctx, types.VarPath)
+	triple.SetDubboHeaders(headers, path)
+	r.StreamReceiveListener.OnReceive(ctx, headers, data, trailers)
+}
@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package triple
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"mosn.io/api"
+	mosnctx "mosn.io/mosn/pkg/context"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/protocol/triple"
+	"mosn.io/mosn/pkg/protocol/xprotocol/dubbo"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/mosn/pkg/variable"
+)
+
+func TestRegistered(t *testing.T) {
+	assert.True(t, protocol.ProtocolRegistered(triple.ProtocolName))
+	f, ok := protocol.GetProtocolStreamFactory(triple.ProtocolName)
+	assert.True(t, ok)
+	// the triple connections are never detected automatically
+	assert.Equal(t, protocol.FAILED, f.ProtocolMatch(context.Background(), "", []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")))
+}
+
+func TestHttp2Context(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, ctx, http2Context(ctx))
+
+	configs := map[api.ProtocolName]interface{}{
+		triple.ProtocolName: "triple config",
+	}
+	ctx = mosnctx.WithValue(context.Background(), types.ContextKeyProxyGeneralConfig, configs)
+	h2ctx := http2Context(ctx)
+	got := mosnctx.Get(h2ctx, types.ContextKeyProxyGeneralConfig).(map[api.ProtocolName]interface{})
+	assert.Equal(t, "triple config", got[protocol.HTTP2])
+	// the config of the connection is not changed
+	_, ok := mosnctx.Get(ctx, types.ContextKeyProxyGeneralConfig).(map[api.ProtocolName]interface{})[protocol.HTTP2]
+	assert.False(t, ok)
+}
+
+type mockReceiver struct {
+	headers api.HeaderMap
+}
+
+func (r *mockReceiver) OnReceive(ctx context.Context, headers api.HeaderMap, data types.IoBuffer, trailers api.HeaderMap) {
+	r.headers = headers
+}
+
+func (r *mockReceiver) OnDecodeError(ctx context.Context, err error, headers api.HeaderMap) {}
+
+func TestStreamReceiver(t *testing.T) {
+	ctx := variable.NewVariableContext(context.Background())
+	variable.SetString(ctx, types.VarPath, "/org.apache.dubbo.demo.Greeter/sayHello")
+	mock := &mockReceiver{}
+	r := &streamReceiver{mock}
+	r.OnReceive(ctx, protocol.CommonHeader{triple.HeaderServiceVersion: "1.0.0"}, nil, nil)
+	service, _ := mock.headers.Get(dubbo.ServiceNameHeader)
+	assert.Equal(t, "org.apache.dubbo.demo.Greeter", service)
+	version, _ := mock.headers.Get(dubbo.VersionNameHeader)
+	assert.Equal(t, "1.0.0", version)
+
+	sc := &serverStreamConnection{}
+	assert.Equal(t, triple.ProtocolName, sc.Protocol())
+}
+
+type mockSender struct {
+	types.StreamSender
+	headers api.HeaderMap
+}
+
+func (s *mockSender) AppendHeaders(ctx context.Context, headers api.HeaderMap, endStream bool) error {
+	s.headers = headers
+	return nil
+}
+
+func TestStreamSender(t *testing.T) {
+	headers := protocol.CommonHeader{}
+	assert.True(t, triple.SetDubboHeaders(headers, "/org.apache.dubbo.demo.Greeter/sayHello"))
+	headers["tri-service-group"] = "test"
+	mock := &mockSender{}
+	s := &streamSender{mock}
+	assert.Nil(t, s.AppendHeaders(context.Background(), headers, true))
+	// the dubbo headers are not sent to the upstream
+	for _, key := range []string{dubbo.ServiceNameHeader, dubbo.InterfaceNameHeader, dubbo.MethodNameHeader, dubbo.VersionNameHeader, dubbo.GroupNameHeader} {
+		_, ok := mock.headers.Get(key)
+		assert.False(t, ok, key)
+	}
+	group, _ := mock.headers.Get(triple.HeaderServiceGroup)
+	assert.Equal(t, "test", group)
+	// the downstream headers are kept
+	service, _ := headers.Get(dubbo.ServiceNameHeader)
+	assert.Equal(t, "org.apache.dubbo.demo.Greeter", service)
+}
@@ -0,0 +1,509 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+	"mosn.io/pkg/utils"
+)
+
+var (
+	// ErrTunnelClosed is returned if the data is relayed after the tunnel is closed
+	ErrTunnelClosed = errors.New("tunnel is closed")
+	// ErrTunnelBufferOverflow is returned if the data queued in the tunnel exceeds the buffer limit
+	ErrTunnelBufferOverflow = errors.New("tunnel buffer overflow")
+)
+
+// DefaultTunnelBufferLimit is the limit of the data queued in each direction of the tunnel,
+// if the buffer limit of the connection is not set
+const DefaultTunnelBufferLimit = 1 << 20
+
+// minTunnelBufferLimit keeps the limit larger than the data relayed at once
+const minTunnelBufferLimit = 64 * 1024
+
+func tunnelBufferLimit(limit uint32) int {
+	if limit == 0 {
+		return DefaultTunnelBufferLimit
+	}
+	if limit < minTunnelBufferLimit {
+		return minTunnelBufferLimit
+	}
+	return int(limit)
+}
+
+// TunnelEndpoint is a side of an upgrade tunnel, such as an http1 connection or an http2 stream
+type TunnelEndpoint interface {
+	// Write writes the relayed data to the endpoint
+	Write(data []byte) error
+	// Close closes the endpoint, it is called once when the tunnel is closed
+	Close()
+}
+
+// tunnelWaiter is implemented by the endpoints that queue the data, the readers that can stop
+// reading wait until the queue is drained, rather than overflow it.
+type tunnelWaiter interface {
+	wait(done <-chan struct{})
+}
+
+// tunnelPipe is a direction of the tunnel, the data is queued until the pipe is started
+type tunnelPipe struct {
+	mux      sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	started  bool
+	pending  [][]byte
+	queued   int
+	endpoint TunnelEndpoint
+	bytes    uint64
+}
+
+func (p *tunnelPipe) init() {
+	p.cond = sync.NewCond(&p.mux)
+	p.limit = DefaultTunnelBufferLimit
+}
+
+func (p *tunnelPipe) write(data []byte) error {
+	atomic.AddUint64(&p.bytes, uint64(len(data)))
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if !p.started {
+		if p.queued+len(data) > p.limit {
+			return ErrTunnelBufferOverflow
+		}
+		p.pending = append(p.pending, append([]byte(nil), data...))
+		p.queued += len(data)
+		return nil
+	}
+	return p.endpoint.Write(data)
+}
+
+// wait blocks until the pipe can accept more data or the done is closed
+func (p *tunnelPipe) wait(done <-chan struct{}) {
+	p.mux.Lock()
+	for !p.started && p.queued >= p.limit/2 && !isDone(done) {
+		p.cond.Wait()
+	}
+	endpoint := p.endpoint
+	p.mux.Unlock()
+	if w, ok := endpoint.(tunnelWaiter); ok {
+		w.wait(done)
+	}
+}
+
+// wakeup wakes up the waiting readers, the tunnel is started or closed
+func (p *tunnelPipe) wakeup() {
+	p.mux.Lock()
+	p.cond.Broadcast()
+	p.mux.Unlock()
+}
+
+func (p *tunnelPipe) start(endpoint TunnelEndpoint) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.started = true
+	p.endpoint = endpoint
+	pending := p.pending
+	p.pending = nil
+	p.queued = 0
+	p.cond.Broadcast()
+	for _, data := range pending {
+		if err := endpoint.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// AsyncEndpoint writes the data to an endpoint in its own goroutine, such as an http2 stream
+// whose writing blocks on the flow control. The data is relayed by the reading goroutine of
+// the other side, which may be shared by the other streams of an http2 connection, and it
+// should not be blocked by a tunnel. The queued data is limited, the write fails if the
+// limit is exceeded, so a slow endpoint resets its own tunnel only.
+type AsyncEndpoint struct {
+	endpoint TunnelEndpoint
+	limit    int
+	onError  func()
+
+	mux     sync.Mutex
+	cond    *sync.Cond
+	queue   [][]byte
+	queued  int
+	running bool
+	closed  bool
+	err     error
+}
+
+// NewAsyncEndpoint wraps the endpoint, the limit is the buffer limit of the connection,
+// zero means DefaultTunnelBufferLimit
+func NewAsyncEndpoint(endpoint TunnelEndpoint, limit uint32) *AsyncEndpoint {
+	e := &AsyncEndpoint{
+		endpoint: endpoint,
+		limit:    tunnelBufferLimit(limit),
+	}
+	e.cond = sync.NewCond(&e.mux)
+	return e
+}
+
+// OnError sets the callback of the write error, it usually closes the tunnel
+func (e *AsyncEndpoint) OnError(cb func()) {
+	e.mux.Lock()
+	e.onError = cb
+	e.mux.Unlock()
+}
+
+// Write queues the data, the writing goroutine is started at the first write
+func (e *AsyncEndpoint) Write(data []byte) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	if e.closed {
+		return ErrTunnelClosed
+	}
+	if e.queued+len(data) > e.limit {
+		return ErrTunnelBufferOverflow
+	}
+	// the data may be reused by the caller
+	e.queue = append(e.queue, append([]byte(nil), data...))
+	e.queued += len(data)
+	if !e.running {
+		e.running = true
+		utils.GoWithRecover(e.run, nil)
+	} else {
+		e.cond.Broadcast()
+	}
+	return nil
+}
+
+// Close closes the endpoint after the queued data is written
+func (e *AsyncEndpoint) Close() {
+	e.mux.Lock()
+	if e.closed {
+		e.mux.Unlock()
+		return
+	}
+	e.closed = true
+	running := e.running
+	e.cond.Broadcast()
+	e.mux.Unlock()
+	if !running {
+		e.endpoint.Close()
+	}
+}
+
+func (e *AsyncEndpoint) wait(done <-chan struct{}) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	for e.queued >= e.limit/2 && !e.closed && e.err == nil && !isDone(done) {
+		e.cond.Wait()
+	}
+}
+
+func (e *AsyncEndpoint) run() {
+	e.mux.Lock()
+	for {
+		for len(e.queue) == 0 && !e.closed {
+			e.cond.Wait()
+		}
+		if len(e.queue) == 0 {
+			break
+		}
+		data := e.queue[0]
+		e.queue[0] = nil
+		e.queue = e.queue[1:]
+		if e.err != nil {
+			// the data after the error is dropped
+			e.queued -= len(data)
+			continue
+		}
+		e.mux.Unlock()
+		err := e.endpoint.Write(data)
+		e.mux.Lock()
+		e.queued -= len(data)
+		e.cond.Broadcast()
+		if err != nil {
+			e.err = err
+			if cb := e.onError; cb != nil {
+				e.mux.Unlock()
+				cb()
+				e.mux.Lock()
+			}
+		}
+	}
+	e.mux.Unlock()
+	e.endpoint.Close()
+}
+
+// Tunnel implements types.UpgradeTunnel, it relays the data between the downstream and the upstream endpoints,
+// the endpoints can be in different protocols, such as an http2 extended CONNECT stream and an http1 connection.
+//
+// The tunnel is established in steps:
+//  1. the downstream stream connection creates the tunnel with the downstream endpoint
+//  2. the upstream stream connection sets the upstream endpoint if the upstream accepts the request
+//  3. the downstream stream establishes the tunnel if the response is still accepted, and starts relaying after
+//     the response is sent. The data received before starting is queued.
+//
+// Either side fails or the tunnel idles, both endpoints are closed.
+type Tunnel struct {
+	typ string
+	// authority is the target of the CONNECT request
+	authority string
+
+	enabled     uint32
+	idleTimeout time.Duration
+
+	mux         sync.Mutex
+	downstream  TunnelEndpoint
+	upstream    TunnelEndpoint
+	established bool
+	closed      bool
+	callbacks   []func(bytesReceived, bytesSent uint64)
+	idleTimer   *time.Timer
+	lastActive  int64
+
+	// toUpstream relays the data received from the downstream, toDownstream relays the data sent to the downstream
+	toUpstream   tunnelPipe
+	toDownstream tunnelPipe
+
+	done chan struct{}
+}
+
+// NewTunnel creates a tunnel of the upgrade type
+func NewTunnel(typ, authority string, downstream TunnelEndpoint) *Tunnel {
+	t := &Tunnel{
+		typ:        typ,
+		authority:  authority,
+		downstream: downstream,
+		done:       make(chan struct{}),
+	}
+	t.toUpstream.init()
+	t.toDownstream.init()
+	return t
+}
+
+// SetBufferLimit sets the limit of the data queued before the tunnel is started,
+// the limit is the buffer limit of the connection, zero means DefaultTunnelBufferLimit
+func (t *Tunnel) SetBufferLimit(limit uint32) {
+	for _, p := range []*tunnelPipe{&t.toUpstream, &t.toDownstream} {
+		p.mux.Lock()
+		p.limit = tunnelBufferLimit(limit)
+		p.mux.Unlock()
+	}
+}
+
+func (t *Tunnel) Type() string {
+	return t.typ
+}
+
+func (t *Tunnel) Enable(idleTimeout time.Duration) {
+	t.idleTimeout = idleTimeout
+	atomic.StoreUint32(&t.enabled, 1)
+}
+
+func (t *Tunnel) Established() bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.established
+}
+
+func (t *Tunnel) OnClose(cb func(bytesReceived, bytesSent uint64)) {
+	t.mux.Lock()
+	if !t.closed {
+		t.callbacks = append(t.callbacks, cb)
+		t.mux.Unlock()
+		return
+	}
+	t.mux.Unlock()
+	cb(atomic.LoadUint64(&t.toUpstream.bytes), atomic.LoadUint64(&t.toDownstream.bytes))
+}
+
+// Enabled returns true if the route enables the upgrade type
+func (t *Tunnel) Enabled() bool {
+	return atomic.LoadUint32(&t.enabled) == 1
+}
+
+// IsConnect returns true if the tunnel is a CONNECT request rather than an upgrade
+func (t *Tunnel) IsConnect() bool {
+	return t.typ == types.UpgradeTypeConnect
+}
+
+// Authority returns the target of the CONNECT request
+func (t *Tunnel) Authority() string {
+	return t.authority
+}
+
+// Done is closed when the tunnel is closed
+func (t *Tunnel) Done() <-chan struct{} {
+	return t.done
+}
+
+// SetUpstream sets the upstream endpoint if the tunnel is enabled, it is called if the upstream accepts the request.
+// The upstream endpoint belongs to the tunnel after that, for example, the http1 connection is not reused.
+func (t *Tunnel) SetUpstream(upstream TunnelEndpoint) bool {
+	if !t.Enabled() {
+		return false
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.closed {
+		return false
+	}
+	t.upstream = upstream
+	return true
+}
+
+// Detached returns true if the upstream endpoint is set
+func (t *Tunnel) Detached() bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.upstream != nil
+}
+
+// Establish is called before the response is sent to the downstream. The tunnel is established if the
+// upstream endpoint is set and the response is still accepted, otherwise the upstream endpoint is closed,
+// and the downstream is kept.
+func (t *Tunnel) Establish(accepted bool) bool {
+	t.mux.Lock()
+	if t.upstream == nil || t.closed {
+		t.mux.Unlock()
+		return false
+	}
+	if !accepted {
+		t.closed = true
+		close(t.done)
+		upstream := t.upstream
+		t.mux.Unlock()
+		t.toUpstream.wakeup()
+		t.toDownstream.wakeup()
+		upstream.Close()
+		return false
+	}
+	t.established = true
+	atomic.StoreInt64(&t.lastActive, time.Now().UnixNano())
+	if t.idleTimeout > 0 {
+		t.idleTimer = time.AfterFunc(t.idleTimeout, t.checkIdle)
+	}
+	t.mux.Unlock()
+	return true
+}
+
+// Start is called after the response is sent to the downstream, the queued data is relayed
+func (t *Tunnel) Start() {
+	if err := t.toDownstream.start(t.downstream); err != nil {
+		t.Close()
+		return
+	}
+	if err := t.toUpstream.start(t.upstream); err != nil {
+		t.Close()
+	}
+}
+
+// ToUpstream relays the data received from the downstream
+func (t *Tunnel) ToUpstream(data []byte) error {
+	if t.isClosed() {
+		return ErrTunnelClosed
+	}
+	atomic.StoreInt64(&t.lastActive, time.Now().UnixNano())
+	return t.toUpstream.write(data)
+}
+
+// ToDownstream relays the data received from the upstream
+func (t *Tunnel) ToDownstream(data []byte) error {
+	if t.isClosed() {
+		return ErrTunnelClosed
+	}
+	atomic.StoreInt64(&t.lastActive, time.Now().UnixNano())
+	return t.toDownstream.write(data)
+}
+
+// WaitUpstream blocks until the data received from the downstream can be relayed without
+// exceeding the buffer limit, or the tunnel is closed. It is called by the readers that
+// can stop reading, such as an http1 connection.
+func (t *Tunnel) WaitUpstream() {
+	t.toUpstream.wait(t.done)
+}
+
+// WaitDownstream is the same as WaitUpstream, for the data received from the upstream
+func (t *Tunnel) WaitDownstream() {
+	t.toDownstream.wait(t.done)
+}
+
+func (t *Tunnel) isClosed() bool {
+	return isDone(t.done)
+}
+
+func (t *Tunnel) checkIdle() {
+	idle := time.Duration(time.Now().UnixNano() - atomic.LoadInt64(&t.lastActive))
+	if idle >= t.idleTimeout {
+		if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+			log.DefaultLogger.Debugf("[stream] %s tunnel idle timeout", t.typ)
+		}
+		t.Close()
+		return
+	}
+	t.mux.Lock()
+	if !t.closed {
+		t.idleTimer.Reset(t.idleTimeout - idle)
+	}
+	t.mux.Unlock()
+}
+
+// Close closes both endpoints and calls the callbacks with the relayed bytes
+func (t *Tunnel) Close() {
+	t.mux.Lock()
+	if t.closed {
+		t.mux.Unlock()
+		return
+	}
+	t.closed = true
+	close(t.done)
+	if t.idleTimer != nil {
+		t.idleTimer.Stop()
+	}
+	upstream := t.upstream
+	callbacks := t.callbacks
+	t.callbacks = nil
+	t.mux.Unlock()
+
+	t.toUpstream.wakeup()
+	t.toDownstream.wakeup()
+	t.downstream.Close()
+	if upstream != nil {
+		upstream.Close()
+	}
+	received, sent := atomic.LoadUint64(&t.toUpstream.bytes), atomic.LoadUint64(&t.toDownstream.bytes)
+	for _, cb := range callbacks {
+		cb(received, sent)
+	}
+}
@@ -0,0 +1,240 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"mosn.io/mosn/pkg/types"
+)
+
+type testEndpoint struct {
+	mux    sync.Mutex
+	data   bytes.Buffer
+	err    error
+	closed int
+}
+
+func (e *testEndpoint) Write(data []byte) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	e.data.Write(data)
+	return nil
+}
+
+func (e *testEndpoint) Close() {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.closed++
+}
+
+func (e *testEndpoint) String() string {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.data.String()
+}
+
+func TestTunnelEstablish(t *testing.T) {
+	downstream, upstream := &testEndpoint{}, &testEndpoint{}
+
+	// not enabled
+	tn := NewTunnel("websocket", "", downstream)
+	assert.False(t, tn.SetUpstream(upstream))
+	assert.False(t, tn.Detached())
+	assert.False(t, tn.Establish(true))
+
+	// the downstream response is changed, the upstream is closed and the downstream is kept
+	tn.Enable(0)
+	assert.True(t, tn.SetUpstream(upstream))
+	assert.True(t, tn.Detached())
+	assert.False(t, tn.Establish(false))
+	assert.False(t, tn.Established())
+	assert.Equal(t, 1, upstream.closed)
+	assert.Equal(t, 0, downstream.closed)
+	assert.False(t, tn.SetUpstream(upstream))
+
+	tn = NewTunnel(types.UpgradeTypeConnect, "a:443", downstream)
+	assert.True(t, tn.IsConnect())
+	assert.Equal(t, "a:443", tn.Authority())
+	tn.Enable(0)
+	assert.True(t, tn.SetUpstream(upstream))
+	assert.True(t, tn.Establish(true))
+	assert.True(t, tn.Established())
+}
+
+func TestTunnelRelay(t *testing.T) {
+	downstream, upstream := &testEndpoint{}, &testEndpoint{}
+	tn := NewTunnel("websocket", "", downstream)
+	tn.Enable(0)
+
+	// the data is queued until the tunnel is started
+	assert.Nil(t, tn.ToUpstream([]byte("hello ")))
+	assert.True(t, tn.SetUpstream(upstream))
+	assert.Nil(t, tn.ToDownstream([]byte("world")))
+	assert.True(t, tn.Establish(true))
+	assert.Equal(t, "", upstream.String())
+	tn.Start()
+	assert.Equal(t, "hello ", upstream.String())
+	assert.Equal(t, "world", downstream.String())
+
+	assert.Nil(t, tn.ToUpstream([]byte("tunnel")))
+	assert.Equal(t, "hello tunnel", upstream.String())
+
+	var received, sent uint64
+	tn.OnClose(func(bytesReceived, bytesSent uint64) {
+		received, sent = bytesReceived, bytesSent
+	})
+	tn.Close()
+	tn.Close()
+	assert.Equal(t, uint64(12), received)
+	assert.Equal(t, uint64(5), sent)
+	assert.Equal(t, 1, downstream.closed)
+	assert.Equal(t, 1, upstream.closed)
+	assert.Equal(t, ErrTunnelClosed, tn.ToUpstream([]byte("ignored")))
+
+	// the callback is called immediately after closed
+	called := false
+	tn.OnClose(func(bytesReceived, bytesSent uint64) {
+		called = true
+	})
+	assert.True(t, called)
+}
+
+func TestTunnelStartFailed(t *testing.T) {
+	downstream, upstream := &testEndpoint{}, &testEndpoint{err: errors.New("write failed")}
+	tn := NewTunnel("websocket", "", downstream)
+	tn.Enable(0)
+	assert.Nil(t, tn.ToUpstream([]byte("hello")))
+	assert.True(t, tn.SetUpstream(upstream))
+	assert.True(t, tn.Establish(true))
+	tn.Start()
+	select {
+	case <-tn.Done():
+	default:
+		t.Fatal("tunnel is not closed by the write error")
+	}
+	assert.Equal(t, 1, downstream.closed)
+	assert.Equal(t, 1, upstream.closed)
+}
+
+func TestTunnelIdleTimeout(t *testing.T) {
+	downstream, upstream := &testEndpoint{}, &testEndpoint{}
+	tn := NewTunnel("websocket", "", downstream)
+	tn.Enable(50 * time.Millisecond)
+	assert.True(t, tn.SetUpstream(upstream))
+	assert.True(t, tn.Establish(true))
+	tn.Start()
+	select {
+	case <-tn.Done():
+	case <-time.After(time.Second):
+		t.Fatal("tunnel is not closed by the idle timeout")
+	}
+}
+
+func TestTunnelPendingLimit(t *testing.T) {
+	downstream := &testEndpoint{}
+	tn := NewTunnel("websocket", "", downstream)
+	tn.Enable(0)
+	tn.SetBufferLimit(1)
+	// the limit is at least the data relayed at once
+	assert.Nil(t, tn.ToUpstream(make([]byte, minTunnelBufferLimit)))
+	assert.Equal(t, ErrTunnelBufferOverflow, tn.ToUpstream([]byte("overflow")))
+
+	// the reader waits until the tunnel is started or closed
+	waited := make(chan struct{})
+	go func() {
+		tn.WaitUpstream()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("the reader should wait for the pending data")
+	case <-time.After(50 * time.Millisecond):
+	}
+	tn.Close()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("the reader is not woken up by the close")
+	}
+}
+
+// blockingEndpoint blocks the writing until it is released
+type blockingEndpoint struct {
+	testEndpoint
+	release chan struct{}
+}
+
+func (e *blockingEndpoint) Write(data []byte) error {
+	<-e.release
+	return e.testEndpoint.Write(data)
+}
+
+func TestAsyncEndpoint(t *testing.T) {
+	inner := &blockingEndpoint{release: make(chan struct{})}
+	endpoint := NewAsyncEndpoint(inner, 0)
+	assert.Equal(t, DefaultTunnelBufferLimit, endpoint.limit)
+	endpoint = NewAsyncEndpoint(inner, minTunnelBufferLimit)
+
+	// the writing is not blocked by the endpoint
+	assert.Nil(t, endpoint.Write([]byte("hello ")))
+	assert.Nil(t, endpoint.Write([]byte("tunnel")))
+	assert.Equal(t, ErrTunnelBufferOverflow, endpoint.Write(make([]byte, minTunnelBufferLimit)))
+
+	// the queued data is written before closed
+	endpoint.Close()
+	assert.Equal(t, ErrTunnelClosed, endpoint.Write([]byte("closed")))
+	close(inner.release)
+	assert.Eventually(t, func() bool {
+		inner.mux.Lock()
+		defer inner.mux.Unlock()
+		return inner.closed == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "hello tunnel", inner.String())
+}
+
+func TestAsyncEndpointError(t *testing.T) {
+	downstream, upstream := &testEndpoint{}, &testEndpoint{err: errors.New("write failed")}
+	tn := NewTunnel("websocket", "", downstream)
+	tn.Enable(0)
+	endpoint := NewAsyncEndpoint(upstream, 0)
+	endpoint.OnError(tn.Close)
+	assert.True(t, tn.SetUpstream(endpoint))
+	assert.True(t, tn.Establish(true))
+	tn.Start()
+	assert.Nil(t, tn.ToUpstream([]byte("hello")))
+	select {
+	case <-tn.Done():
+	case <-time.After(time.Second):
+		t.Fatal("tunnel is not closed by the write error")
+	}
+	assert.Eventually(t, func() bool {
+		upstream.mux.Lock()
+		defer upstream.mux.Unlock()
+		return upstream.closed == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.NotNil(t, tn.ToUpstream([]byte("ignored")))
+}
@@ -0,0 +1,444 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package conntap captures the raw byte streams of the connections, the captured
+// streams are written as pcapng with synthetic IP/TCP headers or as json lines,
+// so they can be opened with wireshark or any other tools.
+package conntap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/mosn/pkg/types"
+)
+
+// capture output formats
+const (
+	FormatPcapng = "pcapng"
+	FormatJSON   = "json"
+)
+
+const defaultMaxBytes = 64 * 1024 * 1024
+
+// Source is where a connection comes from
+type Source string
+
+// connection sources
+const (
+	SourceListener Source = "listener"
+	SourceCluster  Source = "cluster"
+)
+
+// Direction is the direction of the captured bytes, from the view of mosn
+type Direction string
+
+// data directions
+const (
+	DirectionRead  Direction = "read"
+	DirectionWrite Direction = "write"
+)
+
+var (
+	ErrCaptureRunning    = errors.New("connection tap is already running")
+	ErrCaptureNotRunning = errors.New("connection tap is not running")
+)
+
+// Config describes which connections should be captured and where to write them.
+// A connection is captured if it is accepted by any of the listeners, or
+// created to any hosts of the clusters.
+type Config struct {
+	Listeners []string `json:"listeners,omitempty"`
+	Clusters  []string `json:"clusters,omitempty"`
+	Format    string   `json:"format,omitempty"` // pcapng or json, default is pcapng
+	// Path is the output file relative to the output directory, see SetOutputDir
+	Path string `json:"path,omitempty"`
+	// MaxBytes limits the output file size, the capture stops when it is reached
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	// MaxConnectionBytes limits the captured payload of each connection, zero means no limit
+	MaxConnectionBytes int64 `json:"max_connection_bytes,omitempty"`
+	// Duration stops the capture automatically, zero means the capture runs until stopped
+	Duration api.DurationConfig `json:"duration,omitempty"`
+}
+
+// Status is the state of the current or the last capture
+type Status struct {
+	Running     bool      `json:"running"`
+	Config      *Config   `json:"config,omitempty"`
+	StartTime   time.Time `json:"start_time,omitempty"`
+	StopTime    time.Time `json:"stop_time,omitempty"`
+	Connections int64     `json:"connections"`
+	Bytes       int64     `json:"bytes"`
+	Truncated   bool      `json:"truncated,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type recorder interface {
+	open(t *Tap, ts time.Time) error
+	data(t *Tap, dir Direction, b []byte, ts time.Time) error
+	close(t *Tap, ts time.Time) error
+}
+
+type capture struct {
+	mux       sync.Mutex
+	config    *Config
+	listeners map[string]struct{}
+	clusters  map[string]struct{}
+	file      *os.File
+	counter   *countWriter
+	writer    *bufio.Writer
+	recorder  recorder
+	timer     *time.Timer
+	status    Status
+	stopped   bool
+}
+
+var (
+	// outputDir is the directory of the capture files, empty means the mosn log directory
+	outputDir string
+	// enabled is checked on each new connection, it is 1 only if a capture is running
+	enabled uint32
+	current struct {
+		sync.Mutex
+		capture *capture
+		last    *Status
+	}
+)
+
+// SetOutputDir sets the directory of the capture files, the output paths cannot
+// leave the directory, so the capture cannot overwrite any other files.
+func SetOutputDir(dir string) {
+	current.Lock()
+	defer current.Unlock()
+	outputDir = dir
+}
+
+// resolvePath returns the output file of a relative path under the output directory
+func resolvePath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return "", fmt.Errorf("path %s should be relative to the output directory", path)
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(path), "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("path %s should not contain ..", path)
+		}
+	}
+	dir := outputDir
+	if dir == "" {
+		dir = types.MosnLogBasePath
+	}
+	return filepath.Join(dir, filepath.Clean(path)), nil
+}
+
+// Enabled returns true if a capture is running
+func Enabled() bool {
+	return atomic.LoadUint32(&enabled) == 1
+}
+
+// Start starts a capture, only one capture can be running at the same time
+func Start(cfg *Config) error {
+	if len(cfg.Listeners) == 0 && len(cfg.Clusters) == 0 {
+		return errors.New("no listeners or clusters to capture")
+	}
+	if cfg.MaxBytes < 0 || cfg.MaxConnectionBytes < 0 {
+		return errors.New("max_bytes and max_connection_bytes should not be negative")
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultMaxBytes
+	}
+	switch cfg.Format {
+	case "":
+		cfg.Format = FormatPcapng
+	case FormatPcapng, FormatJSON:
+	default:
+		return fmt.Errorf("unknown capture format: %s", cfg.Format)
+	}
+	if cfg.Path == "" {
+		cfg.Path = "conn_tap." + cfg.Format
+	}
+
+	current.Lock()
+	defer current.Unlock()
+	if current.capture != nil {
+		return ErrCaptureRunning
+	}
+	path, err := resolvePath(cfg.Path)
+	if err != nil {
+		return err
+	}
+	cfg.Path = path
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	c := &capture{
+		config:    cfg,
+		listeners: make(map[string]struct{}, len(cfg.Listeners)),
+		clusters:  make(map[string]struct{}, len(cfg.Clusters)),
+		file:      f,
+		status: Status{
+			Running:   true,
+			Config:    cfg,
+			StartTime: time.Now(),
+		},
+	}
+	for _, name := range cfg.Listeners {
+		c.listeners[name] = struct{}{}
+	}
+	for _, name := range cfg.Clusters {
+		c.clusters[name] = struct{}{}
+	}
+	c.counter = &countWriter{w: f}
+	c.writer = bufio.NewWriter(c.counter)
+	switch cfg.Format {
+	case FormatJSON:
+		c.recorder = newJSONRecorder(c.writer)
+	default:
+		r, err := newPcapngRecorder(c.writer)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		c.recorder = r
+	}
+	if d := cfg.Duration.Duration; d > 0 {
+		// the timer may fire before it is stored
+		c.mux.Lock()
+		c.timer = time.AfterFunc(d, func() {
+			c.stop("duration reached")
+		})
+		c.mux.Unlock()
+	}
+	current.capture = c
+	atomic.StoreUint32(&enabled, 1)
+	log.DefaultLogger.Infof("[conntap] start connection tap, listeners: %v, clusters: %v, output: %s", cfg.Listeners, cfg.Clusters, cfg.Path)
+	return nil
+}
+
+// Stop stops the running capture and returns its final status
+func Stop() (*Status, error) {
+	current.Lock()
+	c := current.capture
+	current.Unlock()
+	if c == nil {
+		return nil, ErrCaptureNotRunning
+	}
+	return c.stop("stopped"), nil
+}
+
+// GetStatus returns the status of the running capture, or the last capture if
+// nothing is running. It returns nil if there is no capture at all.
+func GetStatus() *Status {
+	current.Lock()
+	c := current.capture
+	last := current.last
+	current.Unlock()
+	if c == nil {
+		return last
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	st := c.status
+	return &st
+}
+
+// stop closes the output file, the taps of the capture become no-ops
+func (c *capture) stop(reason string) *Status {
+	c.mux.Lock()
+	if c.stopped {
+		st := c.status
+		c.mux.Unlock()
+		return &st
+	}
+	c.stopped = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	if err := c.writer.Flush(); err != nil && c.status.Error == "" {
+		c.status.Error = err.Error()
+	}
+	if err := c.file.Close(); err != nil && c.status.Error == "" {
+		c.status.Error = err.Error()
+	}
+	c.status.Running = false
+	c.status.StopTime = time.Now()
+	c.status.Bytes = c.counter.n
+	st := c.status
+	c.mux.Unlock()
+
+	current.Lock()
+	if current.capture == c {
+		current.capture = nil
+		current.last = &st
+		atomic.StoreUint32(&enabled, 0)
+	}
+	current.Unlock()
+	log.DefaultLogger.Infof("[conntap] connection tap %s, connections: %d, bytes: %d", reason, st.Connections, st.Bytes)
+	return &st
+}
+
+func (c *capture) match(source Source, name string) bool {
+	switch source {
+	case SourceListener:
+		_, ok := c.listeners[name]
+		return ok
+	case SourceCluster:
+		_, ok := c.clusters[name]
+		return ok
+	}
+	return false
+}
+
+// record writes an event under the lock, and stops the capture when
+// the output file reaches the max bytes.
+func (c *capture) record(f func() error) {
+	c.mux.Lock()
+	if c.stopped {
+		c.mux.Unlock()
+		return
+	}
+	err := f()
+	if err != nil {
+		c.status.Error = err.Error()
+	}
+	size := c.counter.n + int64(c.writer.Buffered())
+	c.status.Bytes = size
+	full := size >= c.config.MaxBytes
+	if full {
+		c.status.Truncated = true
+	}
+	c.mux.Unlock()
+	if err != nil {
+		log.DefaultLogger.Errorf("[conntap] write capture failed: %v", err)
+		c.stop("failed")
+	} else if full {
+		c.stop("reached max bytes")
+	}
+}
+
+// Tap captures the bytes of a connection
+type Tap struct {
+	capture  *capture
+	id       uint64
+	source   Source
+	name     string
+	local    *net.TCPAddr
+	remote   *net.TCPAddr
+	captured int64
+	// synthetic tcp sequence numbers, used by pcapng only
+	localSeq  uint32
+	remoteSeq uint32
+}
+
+// NewTap returns a tap if the connection should be captured, or nil.
+// Only tcp connections can be captured.
+func NewTap(source Source, name string, id uint64, local, remote net.Addr) *Tap {
+	if !Enabled() {
+		return nil
+	}
+	current.Lock()
+	c := current.capture
+	current.Unlock()
+	if c == nil || !c.match(source, name) {
+		return nil
+	}
+	la, ok := local.(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+	ra, ok := remote.(*net.TCPAddr)
+	if !ok {
+		return nil
+	}
+	t := &Tap{
+		capture: c,
+		id:      id,
+		source:  source,
+		name:    name,
+		local:   la,
+		remote:  ra,
+	}
+	c.record(func() error {
+		c.status.Connections++
+		return c.recorder.open(t, time.Now())
+	})
+	return t
+}
+
+// OnRead records the bytes read from the connection
+func (t *Tap) OnRead(b []byte) {
+	t.onData(DirectionRead, b)
+}
+
+// OnWrite records the bytes written to the connection
+func (t *Tap) OnWrite(b []byte) {
+	t.onData(DirectionWrite, b)
+}
+
+func (t *Tap) onData(dir Direction, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	now := time.Now()
+	t.capture.record(func() error {
+		if limit := t.capture.config.MaxConnectionBytes; limit > 0 {
+			if t.captured >= limit {
+				t.capture.status.Truncated = true
+				return nil
+			}
+			if remain := limit - t.captured; int64(len(b)) > remain {
+				b = b[:remain]
+				t.capture.status.Truncated = true
+			}
+		}
+		t.captured += int64(len(b))
+		return t.capture.recorder.data(t, dir, b, now)
+	})
+}
+
+// Close records the connection is closed
+func (t *Tap) Close() {
+	now := time.Now()
+	t.capture.record(func() error {
+		return t.capture.recorder.close(t, now)
+	})
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,279 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conntap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mosn.io/api"
+)
+
+var (
+	localAddr  = &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 2045}
+	remoteAddr = &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 50001}
+)
+
+func tempPath(t *testing.T, name string) string {
+	dir, err := ioutil.TempDir("", "conntap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetOutputDir(dir)
+	return filepath.Join(dir, name)
+}
+
+func TestStartInvalid(t *testing.T) {
+	for _, cfg := range []*Config{
+		{},
+		{Listeners: []string{"test"}, Format: "pcap"},
+		{Listeners: []string{"test"}, MaxBytes: -1},
+		{Listeners: []string{"test"}, Path: "/tmp/test.pcapng"},
+		{Listeners: []string{"test"}, Path: "../test.pcapng"},
+		{Listeners: []string{"test"}, Path: "dir/../../test.pcapng"},
+	} {
+		if err := Start(cfg); err == nil {
+			t.Fatalf("config %+v should be invalid", cfg)
+		}
+	}
+	if Enabled() {
+		t.Fatal("tap should not be enabled")
+	}
+	if _, err := Stop(); err != ErrCaptureNotRunning {
+		t.Fatalf("stop should be failed, but got: %v", err)
+	}
+}
+
+func TestNewTap(t *testing.T) {
+	path := tempPath(t, "test.pcapng")
+	defer os.RemoveAll(filepath.Dir(path))
+	if tap := NewTap(SourceListener, "listener", 1, localAddr, remoteAddr); tap != nil {
+		t.Fatal("no tap expected before started")
+	}
+	if err := Start(&Config{
+		Listeners: []string{"listener"},
+		Clusters:  []string{"cluster"},
+		Path:      filepath.Base(path),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Start(&Config{Listeners: []string{"listener"}, Path: filepath.Base(path)}); err != ErrCaptureRunning {
+		t.Fatalf("start twice should be failed, but got: %v", err)
+	}
+	for _, tc := range []struct {
+		source   Source
+		name     string
+		local    net.Addr
+		expected bool
+	}{
+		{SourceListener, "listener", localAddr, true},
+		{SourceCluster, "cluster", localAddr, true},
+		{SourceListener, "cluster", localAddr, false},
+		{SourceCluster, "unknown", localAddr, false},
+		{SourceListener, "listener", &net.UnixAddr{Name: "/tmp/test.sock", Net: "unix"}, false},
+	} {
+		tap := NewTap(tc.source, tc.name, 1, tc.local, remoteAddr)
+		if (tap != nil) != tc.expected {
+			t.Fatalf("%s %s tap expected %v, but got %v", tc.source, tc.name, tc.expected, tap)
+		}
+	}
+	status, err := Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Running || status.Connections != 2 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if Enabled() || GetStatus().Connections != 2 {
+		t.Fatal("last status should be kept after stopped")
+	}
+}
+
+func TestPcapng(t *testing.T) {
+	path := tempPath(t, "test.pcapng")
+	defer os.RemoveAll(filepath.Dir(path))
+	if err := Start(&Config{Listeners: []string{"listener"}, Path: filepath.Base(path)}); err != nil {
+		t.Fatal(err)
+	}
+	tap := NewTap(SourceListener, "listener", 1, localAddr, remoteAddr)
+	request := []byte("GET / HTTP/1.1\r\nHost: mosn.io\r\n\r\n")
+	response := []byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+	tap.OnRead(request)
+	tap.OnWrite(response)
+	tap.Close()
+	if _, err := Stop(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type packet struct {
+		src, dst   uint16
+		seq, ack   uint32
+		flags      byte
+		payload    []byte
+		ipChecksum bool
+	}
+	var blocks []uint32
+	var packets []packet
+	for len(data) > 0 {
+		typ := binary.LittleEndian.Uint32(data)
+		l := binary.LittleEndian.Uint32(data[4:])
+		if l%4 != 0 || int(l) > len(data) || binary.LittleEndian.Uint32(data[l-4:]) != l {
+			t.Fatalf("invalid block length %d", l)
+		}
+		blocks = append(blocks, typ)
+		if typ == blockEnhancedPacket {
+			n := binary.LittleEndian.Uint32(data[20:])
+			ip := data[28 : 28+n]
+			tcp := ip[ipv4HeaderLen:]
+			packets = append(packets, packet{
+				src:        binary.BigEndian.Uint16(tcp[0:]),
+				dst:        binary.BigEndian.Uint16(tcp[2:]),
+				seq:        binary.BigEndian.Uint32(tcp[4:]),
+				ack:        binary.BigEndian.Uint32(tcp[8:]),
+				flags:      tcp[13],
+				payload:    tcp[tcpHeaderLen:],
+				ipChecksum: checksum(0, ip[:ipv4HeaderLen]) == 0,
+			})
+		}
+		data = data[l:]
+	}
+	if len(blocks) < 2 || blocks[0] != blockSectionHeader || blocks[1] != blockInterfaceDesc {
+		t.Fatalf("unexpected blocks: %v", blocks)
+	}
+	// handshake, request, response and close
+	if len(packets) != 8 {
+		t.Fatalf("expected 8 packets, but got %d", len(packets))
+	}
+	syn, req, resp := packets[0], packets[3], packets[4]
+	if syn.flags != tcpFlagSYN || syn.src != uint16(remoteAddr.Port) || syn.dst != uint16(localAddr.Port) {
+		t.Fatalf("unexpected syn: %+v", syn)
+	}
+	if !bytes.Equal(req.payload, request) || req.src != uint16(remoteAddr.Port) {
+		t.Fatalf("unexpected request: %+v", req)
+	}
+	if !bytes.Equal(resp.payload, response) || resp.src != uint16(localAddr.Port) {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.ack != req.seq+uint32(len(request)) {
+		t.Fatalf("response should ack the request, ack: %d, request seq: %d", resp.ack, req.seq)
+	}
+	for _, p := range packets {
+		if !p.ipChecksum {
+			t.Fatalf("invalid ip checksum: %+v", p)
+		}
+	}
+	if packets[5].flags != tcpFlagFIN|tcpFlagACK {
+		t.Fatalf("unexpected fin: %+v", packets[5])
+	}
+}
+
+func TestJSON(t *testing.T) {
+	path := tempPath(t, "test.json")
+	defer os.RemoveAll(filepath.Dir(path))
+	if err := Start(&Config{
+		Clusters:           []string{"cluster"},
+		Format:             FormatJSON,
+		Path:               path,
+		MaxConnectionBytes: 4,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tap := NewTap(SourceCluster, "cluster", 2, localAddr, remoteAddr)
+	tap.OnWrite([]byte("ping"))
+	tap.OnRead([]byte("pong"))
+	tap.Close()
+	status, err := Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Truncated {
+		t.Fatal("capture should be truncated by max connection bytes")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := Event{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, but got %d", len(events))
+	}
+	if events[0].Event != eventOpen || events[0].ConnectionID != 2 || events[0].Source != SourceCluster {
+		t.Fatalf("unexpected open event: %+v", events[0])
+	}
+	if events[1].Event != string(DirectionWrite) || string(events[1].Data) != "ping" {
+		t.Fatalf("unexpected write event: %+v", events[1])
+	}
+	if events[2].Event != eventClose || events[2].RemoteAddress != remoteAddr.String() {
+		t.Fatalf("unexpected close event: %+v", events[2])
+	}
+}
+
+func TestMaxBytesAndDuration(t *testing.T) {
+	path := tempPath(t, "test.pcapng")
+	defer os.RemoveAll(filepath.Dir(path))
+	if err := Start(&Config{
+		Listeners: []string{"listener"},
+		Path:      filepath.Base(path),
+		MaxBytes:  1024,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tap := NewTap(SourceListener, "listener", 3, localAddr, remoteAddr)
+	tap.OnRead(make([]byte, 2048))
+	if Enabled() {
+		t.Fatal("capture should be stopped when reached max bytes")
+	}
+	if st := GetStatus(); !st.Truncated || st.Running {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	// no panic after stopped
+	tap.OnWrite([]byte("after stopped"))
+	tap.Close()
+
+	if err := Start(&Config{
+		Listeners: []string{"listener"},
+		Path:      filepath.Base(path),
+		Duration:  api.DurationConfig{Duration: 10 * time.Millisecond},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if Enabled() {
+		t.Fatal("capture should be stopped after duration")
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conntap
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// connection events in json trace
+const (
+	eventOpen  = "open"
+	eventClose = "close"
+)
+
+// Event is a line of the json trace. The Event is open, close, read or write,
+// and the Data is encoded in base64.
+type Event struct {
+	Timestamp     time.Time `json:"timestamp"`
+	ConnectionID  uint64    `json:"connection_id"`
+	Source        Source    `json:"source"`
+	Name          string    `json:"name"`
+	LocalAddress  string    `json:"local_address"`
+	RemoteAddress string    `json:"remote_address"`
+	Event         string    `json:"event"`
+	Data          []byte    `json:"data,omitempty"`
+}
+
+type jsonRecorder struct {
+	encoder *json.Encoder
+}
+
+func newJSONRecorder(w io.Writer) *jsonRecorder {
+	return &jsonRecorder{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+func (r *jsonRecorder) write(t *Tap, event string, b []byte, ts time.Time) error {
+	return r.encoder.Encode(&Event{
+		Timestamp:     ts,
+		ConnectionID:  t.id,
+		Source:        t.source,
+		Name:          t.name,
+		LocalAddress:  t.local.String(),
+		RemoteAddress: t.remote.String(),
+		Event:         event,
+		Data:          b,
+	})
+}
+
+func (r *jsonRecorder) open(t *Tap, ts time.Time) error {
+	return r.write(t, eventOpen, nil, ts)
+}
+
+func (r *jsonRecorder) data(t *Tap, dir Direction, b []byte, ts time.Time) error {
+	return r.write(t, string(dir), b, ts)
+}
+
+func (r *jsonRecorder) close(t *Tap, ts time.Time) error {
+	return r.write(t, eventClose, nil, ts)
+}
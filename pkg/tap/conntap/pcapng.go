@@ -0,0 +1,250 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package conntap
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+)
+
+// pcapng block types, see https://www.ietf.org/archive/id/draft-tuexen-opsawg-pcapng-05.html
+const (
+	blockSectionHeader  = 0x0A0D0D0A
+	blockInterfaceDesc  = 0x00000001
+	blockEnhancedPacket = 0x00000006
+	byteOrderMagic      = 0x1A2B3C4D
+	linkTypeRaw         = 101 // raw IPv4 or IPv6 packets
+)
+
+// synthetic headers
+const (
+	maxSegmentSize = 32 * 1024
+	tcpHeaderLen   = 20
+	ipv4HeaderLen  = 20
+	ipv6HeaderLen  = 40
+
+	tcpFlagFIN byte = 0x01
+	tcpFlagSYN byte = 0x02
+	tcpFlagPSH byte = 0x08
+	tcpFlagACK byte = 0x10
+)
+
+// pcapngRecorder writes each captured read or write as tcp segments with synthetic
+// ip and tcp headers. Each connection starts with a synthetic three way handshake,
+// so that wireshark can follow the tcp streams.
+type pcapngRecorder struct {
+	w   io.Writer
+	buf []byte
+}
+
+func newPcapngRecorder(w io.Writer) (*pcapngRecorder, error) {
+	r := &pcapngRecorder{w: w}
+	// section header block, without options
+	shb := make([]byte, 28)
+	binary.LittleEndian.PutUint32(shb[0:], blockSectionHeader)
+	binary.LittleEndian.PutUint32(shb[4:], 28)
+	binary.LittleEndian.PutUint32(shb[8:], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[12:], 1) // major version
+	binary.LittleEndian.PutUint16(shb[14:], 0) // minor version
+	binary.LittleEndian.PutUint64(shb[16:], 0xFFFFFFFFFFFFFFFF)
+	binary.LittleEndian.PutUint32(shb[24:], 28)
+	if _, err := w.Write(shb); err != nil {
+		return nil, err
+	}
+	// interface description block, the timestamps are in microseconds by default
+	idb := make([]byte, 20)
+	binary.LittleEndian.PutUint32(idb[0:], blockInterfaceDesc)
+	binary.LittleEndian.PutUint32(idb[4:], 20)
+	binary.LittleEndian.PutUint16(idb[8:], linkTypeRaw)
+	binary.LittleEndian.PutUint32(idb[12:], 0) // no snap length limit
+	binary.LittleEndian.PutUint32(idb[16:], 20)
+	if _, err := w.Write(idb); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// client returns true if the local side is the client of the connection
+func (t *Tap) client() bool {
+	return t.source == SourceCluster
+}
+
+func (r *pcapngRecorder) open(t *Tap, ts time.Time) error {
+	// the initial sequence numbers are derived from the connection id, so
+	// they are stable in the same capture
+	t.localSeq = uint32(t.id) * 1000003
+	t.remoteSeq = uint32(t.id)*7919 + 1
+	if t.client() {
+		if err := r.segment(t, true, tcpFlagSYN, nil, ts); err != nil {
+			return err
+		}
+		t.localSeq++
+		if err := r.segment(t, false, tcpFlagSYN|tcpFlagACK, nil, ts); err != nil {
+			return err
+		}
+		t.remoteSeq++
+		return r.segment(t, true, tcpFlagACK, nil, ts)
+	}
+	if err := r.segment(t, false, tcpFlagSYN, nil, ts); err != nil {
+		return err
+	}
+	t.remoteSeq++
+	if err := r.segment(t, true, tcpFlagSYN|tcpFlagACK, nil, ts); err != nil {
+		return err
+	}
+	t.localSeq++
+	return r.segment(t, false, tcpFlagACK, nil, ts)
+}
+
+func (r *pcapngRecorder) data(t *Tap, dir Direction, b []byte, ts time.Time) error {
+	fromLocal := dir == DirectionWrite
+	for len(b) > 0 {
+		n := len(b)
+		if n > maxSegmentSize {
+			n = maxSegmentSize
+		}
+		if err := r.segment(t, fromLocal, tcpFlagPSH|tcpFlagACK, b[:n], ts); err != nil {
+			return err
+		}
+		if fromLocal {
+			t.localSeq += uint32(n)
+		} else {
+			t.remoteSeq += uint32(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func (r *pcapngRecorder) close(t *Tap, ts time.Time) error {
+	if err := r.segment(t, true, tcpFlagFIN|tcpFlagACK, nil, ts); err != nil {
+		return err
+	}
+	t.localSeq++
+	if err := r.segment(t, false, tcpFlagFIN|tcpFlagACK, nil, ts); err != nil {
+		return err
+	}
+	t.remoteSeq++
+	return r.segment(t, true, tcpFlagACK, nil, ts)
+}
+
+// segment writes an enhanced packet block contains a tcp segment
+func (r *pcapngRecorder) segment(t *Tap, fromLocal bool, flags byte, payload []byte, ts time.Time) error {
+	src, dst := t.local, t.remote
+	seq, ack := t.localSeq, t.remoteSeq
+	if !fromLocal {
+		src, dst = dst, src
+		seq, ack = ack, seq
+	}
+	if flags&tcpFlagACK == 0 {
+		ack = 0
+	}
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	ipLen := ipv4HeaderLen
+	if srcIP == nil || dstIP == nil {
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+		ipLen = ipv6HeaderLen
+	}
+	packetLen := ipLen + tcpHeaderLen + len(payload)
+	padded := (packetLen + 3) &^ 3
+	blockLen := 32 + padded
+
+	if cap(r.buf) < blockLen {
+		r.buf = make([]byte, blockLen)
+	}
+	b := r.buf[:blockLen]
+	for i := range b {
+		b[i] = 0
+	}
+	us := uint64(ts.UnixNano() / int64(time.Microsecond))
+	binary.LittleEndian.PutUint32(b[0:], blockEnhancedPacket)
+	binary.LittleEndian.PutUint32(b[4:], uint32(blockLen))
+	binary.LittleEndian.PutUint32(b[8:], 0) // interface id
+	binary.LittleEndian.PutUint32(b[12:], uint32(us>>32))
+	binary.LittleEndian.PutUint32(b[16:], uint32(us))
+	binary.LittleEndian.PutUint32(b[20:], uint32(packetLen))
+	binary.LittleEndian.PutUint32(b[24:], uint32(packetLen))
+	binary.LittleEndian.PutUint32(b[blockLen-4:], uint32(blockLen))
+
+	packet := b[28 : 28+packetLen]
+	tcp := packet[ipLen:]
+	if ipLen == ipv4HeaderLen {
+		putIPv4Header(packet, srcIP, dstIP, packetLen)
+	} else {
+		putIPv6Header(packet, srcIP, dstIP, tcpHeaderLen+len(payload))
+	}
+	binary.BigEndian.PutUint16(tcp[0:], uint16(src.Port))
+	binary.BigEndian.PutUint16(tcp[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	binary.BigEndian.PutUint32(tcp[8:], ack)
+	tcp[12] = (tcpHeaderLen / 4) << 4
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:], 0xFFFF) // window
+	copy(tcp[tcpHeaderLen:], payload)
+	binary.BigEndian.PutUint16(tcp[16:], tcpChecksum(srcIP, dstIP, tcp))
+
+	_, err := r.w.Write(b)
+	return err
+}
+
+func putIPv4Header(b []byte, src, dst net.IP, totalLen int) {
+	b[0] = 0x45 // version 4, header length 5 words
+	binary.BigEndian.PutUint16(b[2:], uint16(totalLen))
+	binary.BigEndian.PutUint16(b[6:], 0x4000) // don't fragment
+	b[8] = 64                                 // ttl
+	b[9] = 6                                  // tcp
+	copy(b[12:16], src)
+	copy(b[16:20], dst)
+	binary.BigEndian.PutUint16(b[10:], checksum(0, b[:ipv4HeaderLen]))
+}
+
+func putIPv6Header(b []byte, src, dst net.IP, payloadLen int) {
+	b[0] = 0x60 // version 6
+	binary.BigEndian.PutUint16(b[4:], uint16(payloadLen))
+	b[6] = 6  // next header: tcp
+	b[7] = 64 // hop limit
+	copy(b[8:24], src)
+	copy(b[24:40], dst)
+}
+
+func tcpChecksum(src, dst net.IP, segment []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(src); i += 2 {
+		sum += uint32(src[i])<<8 | uint32(src[i+1])
+		sum += uint32(dst[i])<<8 | uint32(dst[i+1])
+	}
+	sum += 6 // protocol
+	sum += uint32(len(segment))
+	return checksum(sum, segment)
+}
+
+// checksum is the internet checksum of rfc1071
+func checksum(sum uint32, b []byte) uint16 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xFFFF + sum>>16
+	}
+	return ^uint16(sum)
+}
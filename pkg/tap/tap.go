@@ -0,0 +1,360 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mosn.io/api"
+	"mosn.io/mosn/pkg/cel"
+	"mosn.io/mosn/pkg/cel/attribute"
+	"mosn.io/mosn/pkg/cel/extract"
+	"mosn.io/mosn/pkg/log"
+	"mosn.io/pkg/buffer"
+)
+
+const (
+	defaultCount        = 10
+	defaultTimeout      = time.Minute
+	defaultMaxBodyBytes = 1024
+	// the records of a session are buffered in memory until they are written,
+	// so the count and body size of a session are limited.
+	maxCount     = 10000
+	maxBodyBytes = 1024 * 1024
+)
+
+var compiler = cel.NewExpressionBuilder(extract.Attributemanifest, cel.CompatCEXL)
+
+// Config describes which requests should be tapped
+type Config struct {
+	Listener    string            `json:"listener,omitempty"`
+	VirtualHost string            `json:"virtual_host,omitempty"`
+	Route       string            `json:"route,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"` // request headers exact match
+	// Expression is a CEL condition, same as the dsl expressions of routers
+	Expression   string             `json:"expression,omitempty"`
+	Count        int                `json:"count,omitempty"`
+	Timeout      api.DurationConfig `json:"timeout,omitempty"`
+	MaxBodyBytes int                `json:"max_body_bytes,omitempty"`
+}
+
+// Body is a captured body, truncated to the MaxBodyBytes
+type Body struct {
+	Data      string `json:"data"`
+	Size      int    `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// Timing is the durations since the request started
+type Timing struct {
+	RequestReceived  string `json:"request_received"`
+	ResponseReceived string `json:"response_received"`
+	RequestFinished  string `json:"request_finished"`
+}
+
+// Record is a tapped request
+type Record struct {
+	StartTime              time.Time         `json:"start_time"`
+	Listener               string            `json:"listener,omitempty"`
+	VirtualHost            string            `json:"virtual_host,omitempty"`
+	Route                  string            `json:"route,omitempty"`
+	Protocol               string            `json:"protocol,omitempty"`
+	DownstreamRemoteAddr   string            `json:"downstream_remote_address,omitempty"`
+	UpstreamHost           string            `json:"upstream_host,omitempty"`
+	ResponseCode           int               `json:"response_code"`
+	RequestHeaders         map[string]string `json:"request_headers,omitempty"`
+	RequestBody            *Body             `json:"request_body,omitempty"`
+	UpstreamRequestHeaders map[string]string `json:"upstream_request_headers,omitempty"`
+	ResponseHeaders        map[string]string `json:"response_headers,omitempty"`
+	ResponseBody           *Body             `json:"response_body,omitempty"`
+	Timing                 Timing            `json:"timing"`
+}
+
+// Session is an active tap, it captures at most Count records
+type Session struct {
+	config    *Config
+	expr      attribute.Expression
+	remaining int32
+	records   chan *Record
+	done      chan struct{}
+	doneOnce  sync.Once
+}
+
+// sessions contains all the active sessions
+var sessions = struct {
+	sync.RWMutex
+	all          map[*Session]struct{}
+	maxBodyBytes int
+}{
+	all: make(map[*Session]struct{}),
+}
+
+var activeSessions int32
+
+// Enabled returns true if there is any active session, the proxy
+// captures nothing if it returns false.
+func Enabled() bool {
+	return atomic.LoadInt32(&activeSessions) > 0
+}
+
+// NewSession creates and starts a tap session
+func NewSession(cfg *Config) (*Session, error) {
+	if cfg.Count < 0 || cfg.MaxBodyBytes < 0 {
+		return nil, errors.New("count and max_body_bytes should not be negative")
+	}
+	if cfg.Count > maxCount {
+		return nil, fmt.Errorf("count should not be greater than %d", maxCount)
+	}
+	if cfg.MaxBodyBytes > maxBodyBytes {
+		return nil, fmt.Errorf("max_body_bytes should not be greater than %d", maxBodyBytes)
+	}
+	if cfg.Count == 0 {
+		cfg.Count = defaultCount
+	}
+	if cfg.Timeout.Duration <= 0 {
+		cfg.Timeout.Duration = defaultTimeout
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	s := &Session{
+		config:    cfg,
+		remaining: int32(cfg.Count),
+		records:   make(chan *Record, cfg.Count),
+		done:      make(chan struct{}),
+	}
+	if cfg.Expression != "" {
+		expr, _, err := compiler.Compile(cfg.Expression)
+		if err != nil {
+			return nil, err
+		}
+		s.expr = expr
+	}
+
+	sessions.Lock()
+	sessions.all[s] = struct{}{}
+	if cfg.MaxBodyBytes > sessions.maxBodyBytes {
+		sessions.maxBodyBytes = cfg.MaxBodyBytes
+	}
+	atomic.StoreInt32(&activeSessions, int32(len(sessions.all)))
+	sessions.Unlock()
+	return s, nil
+}
+
+// Timeout returns the session timeout
+func (s *Session) Timeout() time.Duration {
+	return s.config.Timeout.Duration
+}
+
+// Records returns the captured records
+func (s *Session) Records() <-chan *Record {
+	return s.records
+}
+
+// Done is closed when the session captured enough records
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops the session
+func (s *Session) Close() {
+	sessions.Lock()
+	defer sessions.Unlock()
+	delete(sessions.all, s)
+	sessions.maxBodyBytes = 0
+	for other := range sessions.all {
+		if other.config.MaxBodyBytes > sessions.maxBodyBytes {
+			sessions.maxBodyBytes = other.config.MaxBodyBytes
+		}
+	}
+	atomic.StoreInt32(&activeSessions, int32(len(sessions.all)))
+}
+
+func (s *Session) match(st *Stream, info *StreamInfo) bool {
+	cfg := s.config
+	if (cfg.Listener != "" && cfg.Listener != info.Listener) ||
+		(cfg.VirtualHost != "" && cfg.VirtualHost != info.VirtualHost) ||
+		(cfg.Route != "" && cfg.Route != info.Route) {
+		return false
+	}
+	for k, v := range cfg.Headers {
+		if st.requestHeaders[k] != v {
+			return false
+		}
+	}
+	if s.expr != nil {
+		bag := attribute.NewMutableBag(extract.ExtractAttributes(info.Context, info.RequestHeaders, info.ResponseHeaders, info.RequestInfo, nil, nil, time.Now()))
+		bag.Set(extract.KContext, info.Context)
+		res, err := s.expr.Evaluate(bag)
+		if err != nil {
+			if log.DefaultLogger.GetLogLevel() >= log.DEBUG {
+				log.DefaultLogger.Debugf("[tap] evaluate expression %s failed: %v", cfg.Expression, err)
+			}
+			return false
+		}
+		if matched, ok := res.(bool); !ok || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Session) record(st *Stream, info *StreamInfo) {
+	remaining := atomic.AddInt32(&s.remaining, -1)
+	if remaining < 0 {
+		return
+	}
+	r := &Record{
+		Listener:               info.Listener,
+		VirtualHost:            info.VirtualHost,
+		Route:                  info.Route,
+		RequestHeaders:         st.requestHeaders,
+		RequestBody:            truncate(st.requestBody, s.config.MaxBodyBytes),
+		UpstreamRequestHeaders: st.upstreamRequestHeaders,
+		ResponseHeaders:        st.responseHeaders,
+		ResponseBody:           truncate(st.responseBody, s.config.MaxBodyBytes),
+	}
+	if ri := info.RequestInfo; ri != nil {
+		r.StartTime = ri.StartTime()
+		r.Protocol = string(ri.Protocol())
+		r.ResponseCode = ri.ResponseCode()
+		if addr := ri.DownstreamRemoteAddress(); addr != nil {
+			r.DownstreamRemoteAddr = addr.String()
+		}
+		if host := ri.UpstreamHost(); host != nil {
+			r.UpstreamHost = host.AddressString()
+		}
+		r.Timing = Timing{
+			RequestReceived:  ri.RequestReceivedDuration().String(),
+			ResponseReceived: ri.ResponseReceivedDuration().String(),
+			RequestFinished:  ri.RequestFinishedDuration().String(),
+		}
+	}
+	// never blocks, the channel capacity is the count
+	s.records <- r
+	if remaining == 0 {
+		s.doneOnce.Do(func() {
+			close(s.done)
+		})
+	}
+}
+
+// Stream keeps the snapshots of a stream, the headers and body may be modified or
+// drained during the proxy, so the snapshots are taken when they are received.
+type Stream struct {
+	requestHeaders         map[string]string
+	requestBody            *Body
+	upstreamRequestHeaders map[string]string
+	responseHeaders        map[string]string
+	responseBody           *Body
+}
+
+// StreamInfo is the information of a finished stream
+type StreamInfo struct {
+	Context         context.Context
+	Listener        string
+	VirtualHost     string
+	Route           string
+	RequestInfo     api.RequestInfo
+	RequestHeaders  api.HeaderMap
+	ResponseHeaders api.HeaderMap
+}
+
+// NewStream returns a Stream if tap is enabled, otherwise returns nil
+func NewStream() *Stream {
+	if !Enabled() {
+		return nil
+	}
+	return &Stream{}
+}
+
+// OnDownstreamRequest records the request received from downstream
+func (st *Stream) OnDownstreamRequest(headers api.HeaderMap, data buffer.IoBuffer) {
+	st.requestHeaders = copyHeaders(headers)
+	st.requestBody = copyBody(data)
+}
+
+// OnUpstreamRequest records the request headers sent to upstream
+func (st *Stream) OnUpstreamRequest(headers api.HeaderMap) {
+	st.upstreamRequestHeaders = copyHeaders(headers)
+}
+
+// OnUpstreamResponse records the response received from upstream
+func (st *Stream) OnUpstreamResponse(headers api.HeaderMap, data buffer.IoBuffer) {
+	st.responseHeaders = copyHeaders(headers)
+	st.responseBody = copyBody(data)
+}
+
+// Finish matches the stream with all the active sessions, and records it into the matched sessions
+func (st *Stream) Finish(info *StreamInfo) {
+	sessions.RLock()
+	defer sessions.RUnlock()
+	for s := range sessions.all {
+		if s.match(st, info) {
+			s.record(st, info)
+		}
+	}
+}
+
+func copyHeaders(headers api.HeaderMap) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	m := make(map[string]string)
+	headers.Range(func(key, value string) bool {
+		m[key] = value
+		return true
+	})
+	return m
+}
+
+// copyBody copies the body, at most max body bytes of all the sessions
+func copyBody(data buffer.IoBuffer) *Body {
+	if data == nil || data.Len() == 0 {
+		return nil
+	}
+	sessions.RLock()
+	max := sessions.maxBodyBytes
+	sessions.RUnlock()
+	b := data.Bytes()
+	body := &Body{
+		Size: len(b),
+	}
+	if len(b) > max {
+		b = b[:max]
+		body.Truncated = true
+	}
+	body.Data = string(b)
+	return body
+}
+
+func truncate(body *Body, max int) *Body {
+	if body == nil || len(body.Data) <= max {
+		return body
+	}
+	return &Body{
+		Data:      body.Data[:max],
+		Size:      body.Size,
+		Truncated: true,
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tap
+
+import (
+	"context"
+	"testing"
+
+	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/protocol"
+	"mosn.io/mosn/pkg/variable"
+	"mosn.io/pkg/buffer"
+)
+
+func TestTapSession(t *testing.T) {
+	if Enabled() || NewStream() != nil {
+		t.Fatal("tap should be disabled without sessions")
+	}
+	s, err := NewSession(&Config{
+		Listener:     "test_listener",
+		Headers:      map[string]string{"service": "test"},
+		Count:        1,
+		MaxBodyBytes: 4,
+	})
+	if err != nil {
+		t.Fatalf("create session failed: %v", err)
+	}
+	if !Enabled() {
+		t.Fatal("tap should be enabled")
+	}
+
+	capture := func(listener string, headers protocol.CommonHeader, body string) {
+		st := NewStream()
+		st.OnDownstreamRequest(headers, buffer.NewIoBufferString(body))
+		st.OnUpstreamRequest(headers)
+		st.OnUpstreamResponse(protocol.CommonHeader{"status": "ok"}, buffer.NewIoBufferString("response"))
+		info := network.NewRequestInfo()
+		info.SetResponseCode(200)
+		st.Finish(&StreamInfo{
+			Context:        context.Background(),
+			Listener:       listener,
+			RequestInfo:    info,
+			RequestHeaders: headers,
+		})
+	}
+	// not matched
+	capture("other_listener", protocol.CommonHeader{"service": "test"}, "request")
+	capture("test_listener", protocol.CommonHeader{"service": "other"}, "request")
+	select {
+	case r := <-s.Records():
+		t.Fatalf("unexpected record: %+v", r)
+	default:
+	}
+	// matched
+	capture("test_listener", protocol.CommonHeader{"service": "test"}, "request")
+	capture("test_listener", protocol.CommonHeader{"service": "test"}, "request")
+
+	<-s.Done()
+	r := <-s.Records()
+	if r.ResponseCode != 200 || r.RequestHeaders["service"] != "test" || r.ResponseHeaders["status"] != "ok" {
+		t.Errorf("unexpected record: %+v", r)
+	}
+	if r.RequestBody.Data != "requ" || !r.RequestBody.Truncated || r.RequestBody.Size != 7 {
+		t.Errorf("unexpected request body: %+v", r.RequestBody)
+	}
+	// the count is reached
+	select {
+	case r := <-s.Records():
+		t.Fatalf("unexpected record: %+v", r)
+	default:
+	}
+
+	s.Close()
+	if Enabled() {
+		t.Fatal("tap should be disabled after session closed")
+	}
+}
+
+func TestTapSessionLimits(t *testing.T) {
+	for _, cfg := range []*Config{
+		{Count: -1},
+		{MaxBodyBytes: -1},
+		{Count: maxCount + 1},
+		{MaxBodyBytes: maxBodyBytes + 1},
+	} {
+		if _, err := NewSession(cfg); err == nil {
+			t.Fatalf("expected error for config %+v", cfg)
+		}
+	}
+	if Enabled() {
+		t.Fatal("tap should not be enabled by the invalid sessions")
+	}
+	s, err := NewSession(&Config{Count: maxCount, MaxBodyBytes: maxBodyBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+}
+
+func TestTapSessionExpression(t *testing.T) {
+	if _, err := NewSession(&Config{Expression: "invalid expression ("}); err == nil {
+		t.Fatal("expected error for invalid expression")
+	}
+	s, err := NewSession(&Config{
+		Expression: `request.headers["service"] == "test"`,
+	})
+	if err != nil {
+		t.Fatalf("create session failed: %v", err)
+	}
+	defer s.Close()
+
+	headers := protocol.CommonHeader{"service": "test"}
+	st := NewStream()
+	st.OnDownstreamRequest(headers, nil)
+	st.Finish(&StreamInfo{
+		Context:        variable.NewVariableContext(context.Background()),
+		RequestInfo:    network.NewRequestInfo(),
+		RequestHeaders: headers,
+	})
+	select {
+	case <-s.Records():
+	default:
+		t.Fatal("expected a record")
+	}
+}
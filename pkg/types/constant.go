@@ -70,8 +70,12 @@ func ConvertReasonToCode(reason StreamResetReason) int {
 	return api.InternalErrorCode
 }
 
+// LoadShed is the response flag of the requests rejected by the overload manager,
+// it takes a high bit to keep away from the flags defined in the api.
+const LoadShed api.ResponseFlag = 0x40000000
+
 // ResponseFlags sets
 const (
 	MosnProcessFailedFlags = api.NoHealthyUpstream | api.NoRouteFound | api.UpstreamLocalReset |
-		api.FaultInjected | api.RateLimited | api.DownStreamTerminate | api.ReqEntityTooLarge
+		api.FaultInjected | api.RateLimited | api.DownStreamTerminate | api.ReqEntityTooLarge | LoadShed
 )
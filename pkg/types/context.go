@@ -49,6 +49,7 @@ const (
 	ContextKeyDownStreamRespHeaders
 	ContextUpstreamConnectionID
 	ContextKeyConnectionEventListeners
+	ContextKeyUpgradeTunnel
 	ContextKeyEnd
 )
 
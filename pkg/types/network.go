@@ -124,6 +124,9 @@ type Listener interface {
 	// Shutdown stop accepting new connections and graceful stop the existing connections
 	Shutdown() error
 
+	// StopAccept stop accepting new connections, the existing connections are kept
+	StopAccept() error
+
 	// Close closes listener, not closing connections
 	Close(lctx context.Context) error
 
@@ -84,6 +84,14 @@ type RouteHandler interface {
 	// Route returns handler's route
 	Route() api.Route
 }
+
+// NamedRouteRule is a route rule with a name, the name distinguishes the routes in a virtual host
+type NamedRouteRule interface {
+	api.RouteRule
+	// Name returns the route name, it can be empty
+	Name() string
+}
+
 type RouterWrapper interface {
 	// GetRouters returns the routers in the wrapper
 	GetRouters() Routers
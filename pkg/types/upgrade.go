@@ -0,0 +1,51 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"time"
+
+	v2 "mosn.io/mosn/pkg/config/v2"
+)
+
+// UpgradeTypeConnect is the upgrade type of the http CONNECT request
+const UpgradeTypeConnect = "CONNECT"
+
+// UpgradeTunnel is a http upgrade request (such as websocket) or a CONNECT request.
+// After the upstream accepts it, the downstream and the upstream connections are
+// switched into a raw bidirectional byte relay.
+// The tunnel is created by the downstream stream connection, and stored in the context
+// by ContextKeyUpgradeTunnel.
+type UpgradeTunnel interface {
+	// Type returns the upgrade type, the lower case value of the Upgrade header, or CONNECT
+	Type() string
+	// Enable allows the tunnel to be established, it is called if the route enables the upgrade type.
+	// The tunnel is closed if no data is relayed in the idle timeout, zero means no idle timeout.
+	Enable(idleTimeout time.Duration)
+	// Established returns true if the connections are switched into the relay
+	Established() bool
+	// OnClose adds a callback that is called with the relayed bytes when the tunnel is closed,
+	// the callback is called immediately if the tunnel is closed already.
+	OnClose(cb func(bytesReceived, bytesSent uint64))
+}
+
+// UpgradeRouteRule is a route rule that enables the upgrade types
+type UpgradeRouteRule interface {
+	// UpgradeConfig returns the config of the upgrade type, the type is case-insensitive
+	UpgradeConfig(upgradeType string) (v2.UpgradeConfig, bool)
+}
@@ -41,6 +41,13 @@ func RegisterClusterType(clusterType v2.ClusterType, f func(v2.Cluster) types.Cl
 	clusterFactories[clusterType] = f
 }
 
+// IsClusterTypeRegistered returns true if the cluster type is registered,
+// a cluster with unregistered type is created as a simple cluster
+func IsClusterTypeRegistered(clusterType v2.ClusterType) bool {
+	_, ok := clusterFactories[clusterType]
+	return ok
+}
+
 func init() {
 	RegisterClusterType(v2.SIMPLE_CLUSTER, newSimpleCluster)
 }
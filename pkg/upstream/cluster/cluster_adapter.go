@@ -18,7 +18,12 @@
 package cluster
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	v2 "mosn.io/mosn/pkg/config/v2"
+	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/types"
 )
 
@@ -60,3 +65,63 @@ func (ca *MngAdapter) TriggerHostDel(clusterName string, hosts []string) error {
 func (ca *MngAdapter) TriggerHostAppend(clusterName string, hostAppend []v2.Host) error {
 	return ca.AppendClusterHosts(clusterName, hostAppend)
 }
+
+var (
+	ErrClusterNotFound = errors.New("cluster not found")
+	ErrHostNotFound    = errors.New("host not found")
+)
+
+func (ca *MngAdapter) findHost(clusterName, addr string) (types.Host, error) {
+	snap := ca.GetClusterSnapshot(context.Background(), clusterName)
+	if snap == nil {
+		return nil, fmt.Errorf("%w: %s", ErrClusterNotFound, clusterName)
+	}
+	var found types.Host
+	snap.HostSet().Range(func(host types.Host) bool {
+		if host.AddressString() == addr {
+			found = host
+			return false
+		}
+		return true
+	})
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s in cluster %s", ErrHostNotFound, addr, clusterName)
+	}
+	return found, nil
+}
+
+// EjectHost ejects the host in the cluster, the host is not chosen by the load balancers
+// and its connections are closed until it is restored.
+func (ca *MngAdapter) EjectHost(clusterName, addr string) error {
+	host, err := ca.findHost(clusterName, addr)
+	if err != nil {
+		return err
+	}
+	host.SetHealthFlag(ADMIN_EJECTED)
+	ca.ShutdownConnectionPool("", addr)
+	log.DefaultLogger.Infof("[upstream] [cluster adapter] host %s in cluster %s is ejected", addr, clusterName)
+	return nil
+}
+
+// DrainHost drains the host in the cluster, the host is not chosen by the load balancers,
+// but the exists connections are kept until it is restored.
+func (ca *MngAdapter) DrainHost(clusterName, addr string) error {
+	host, err := ca.findHost(clusterName, addr)
+	if err != nil {
+		return err
+	}
+	host.SetHealthFlag(ADMIN_DRAINING)
+	log.DefaultLogger.Infof("[upstream] [cluster adapter] host %s in cluster %s is draining", addr, clusterName)
+	return nil
+}
+
+// RestoreHost clears the ejected and draining flags of the host in the cluster
+func (ca *MngAdapter) RestoreHost(clusterName, addr string) error {
+	host, err := ca.findHost(clusterName, addr)
+	if err != nil {
+		return err
+	}
+	host.ClearHealthFlag(ADMIN_EJECTED | ADMIN_DRAINING)
+	log.DefaultLogger.Infof("[upstream] [cluster adapter] host %s in cluster %s is restored", addr, clusterName)
+	return nil
+}
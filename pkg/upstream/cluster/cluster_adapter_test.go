@@ -19,6 +19,8 @@ package cluster
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 
 	"mosn.io/api"
@@ -526,3 +528,47 @@ func TestClusterManagerTLSUpdateTLS(t *testing.T) {
 		t.Fatal("should be disabled hash value")
 	}
 }
+
+func TestClusterManagerHostAdminState(t *testing.T) {
+	healthStore = sync.Map{}
+	_createClusterManager()
+	adapter := GetClusterMngAdapterInstance()
+	getHost := func() types.Host {
+		host, err := adapter.findHost("test1", "127.0.0.1:10000")
+		if err != nil {
+			t.Fatalf("find host failed: %v", err)
+		}
+		return host
+	}
+	if err := adapter.EjectHost("test1", "127.0.0.1:10000"); err != nil {
+		t.Fatalf("eject host failed: %v", err)
+	}
+	if host := getHost(); host.Health() || !host.ContainHealthFlag(ADMIN_EJECTED) {
+		t.Fatal("host should be ejected")
+	}
+	if err := adapter.DrainHost("test1", "127.0.0.1:10000"); err != nil {
+		t.Fatalf("drain host failed: %v", err)
+	}
+	if host := getHost(); !host.ContainHealthFlag(ADMIN_DRAINING) {
+		t.Fatal("host should be draining")
+	}
+	// the flags are kept after the hosts updated
+	if err := adapter.TriggerHostAppend("test1", []v2.Host{{HostConfig: v2.HostConfig{Address: "127.0.0.1:10002"}}}); err != nil {
+		t.Fatalf("append host failed: %v", err)
+	}
+	if host := getHost(); host.Health() {
+		t.Fatal("host flags should be kept")
+	}
+	if err := adapter.RestoreHost("test1", "127.0.0.1:10000"); err != nil {
+		t.Fatalf("restore host failed: %v", err)
+	}
+	if host := getHost(); !host.Health() {
+		t.Fatal("host should be restored")
+	}
+	if err := adapter.EjectHost("test1", "127.0.0.1:20000"); !errors.Is(err, ErrHostNotFound) {
+		t.Fatalf("expected host not found, but got: %v", err)
+	}
+	if err := adapter.EjectHost("unknown", "127.0.0.1:10000"); !errors.Is(err, ErrClusterNotFound) {
+		t.Fatalf("expected cluster not found, but got: %v", err)
+	}
+}
@@ -24,6 +24,16 @@ import (
 	"mosn.io/api"
 )
 
+// The health flags set by the operators, the health checkers never clear them.
+// The health flags are shared by the hosts with the same address, so they are
+// kept when the hosts are updated.
+const (
+	// ADMIN_EJECTED marks the host is ejected, it is not chosen and its connections are closed
+	ADMIN_EJECTED api.HealthFlag = 1 << 16
+	// ADMIN_DRAINING marks the host is draining, it is not chosen but the exists requests are not affected
+	ADMIN_DRAINING api.HealthFlag = 1 << 17
+)
+
 // health flag reuse for same address
 // TODO: use one map for all reuse data
 var healthStore = sync.Map{}
@@ -29,6 +29,7 @@ import (
 	v2 "mosn.io/mosn/pkg/config/v2"
 	"mosn.io/mosn/pkg/log"
 	"mosn.io/mosn/pkg/network"
+	"mosn.io/mosn/pkg/overload"
 	"mosn.io/mosn/pkg/types"
 	"mosn.io/pkg/utils"
 )
@@ -137,7 +138,8 @@ func (sh *simpleHost) CreateConnection(context context.Context) types.CreateConn
 		tlsMng = sh.ClusterInfo().TLSMng()
 	}
 	clientConn := network.NewClientConnection(sh.ClusterInfo().ConnectTimeout(), tlsMng, sh.Address(), nil)
-	clientConn.SetBufferLimit(sh.ClusterInfo().ConnBufferLimitBytes())
+	clientConn.SetBufferLimit(overload.ScaleBufferLimit(sh.ClusterInfo().ConnBufferLimitBytes()))
+	network.SetTapCluster(clientConn, sh.ClusterInfo().Name())
 
 	if sh.ClusterInfo().IdleTimeout() > 0 {
 		clientConn.SetIdleTimeout(types.DefaultConnReadTimeout, sh.ClusterInfo().IdleTimeout())
@@ -151,7 +153,7 @@ func (sh *simpleHost) CreateConnection(context context.Context) types.CreateConn
 
 func (sh *simpleHost) CreateUDPConnection(context context.Context) types.CreateConnectionData {
 	clientConn := network.NewClientConnection(sh.ClusterInfo().ConnectTimeout(), nil, sh.UDPAddress(), nil)
-	clientConn.SetBufferLimit(sh.ClusterInfo().ConnBufferLimitBytes())
+	clientConn.SetBufferLimit(overload.ScaleBufferLimit(sh.ClusterInfo().ConnBufferLimitBytes()))
 
 	return types.CreateConnectionData{
 		Connection: clientConn,
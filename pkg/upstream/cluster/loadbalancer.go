@@ -42,6 +42,13 @@ func RegisterLBType(lbType types.LoadBalancerType, f func(types.ClusterInfo, typ
 	lbFactories[lbType] = f
 }
 
+// IsLBTypeRegistered returns true if the load balancer type is registered,
+// a cluster with unregistered type uses the round robin load balancer
+func IsLBTypeRegistered(lbType types.LoadBalancerType) bool {
+	_, ok := lbFactories[lbType]
+	return ok
+}
+
 var rrFactory *roundRobinLoadBalancerFactory
 
 func init() {